@@ -0,0 +1,106 @@
+// Command fakeprom serves the Prometheus query API from a synthetic GPU fleet,
+// so the backend can run locally without a cluster:
+//
+//	go run ./cmd/fakeprom -nodes 4 -pattern mixed
+//	PROMETHEUS_URL=http://localhost:9090 go run ./cmd/server
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s-gpu-monitoring/internal/fakeprom"
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+// main starts the fake Prometheus server.
+func main() {
+	addr := flag.String("addr", ":9090", "listen address")
+	schemaName := flag.String("schema", prometheus.SchemaNvidiaGPUExporter, fmt.Sprintf("exporter metric schema %v", prometheus.SchemaNames()))
+	schemaFile := flag.String("schema-file", "", "user-defined metric schema file, overriding -schema")
+	nodes := flag.Int("nodes", 2, "number of GPU nodes")
+	gpusPerNode := flag.Int("gpus-per-node", 8, "GPUs on each node")
+	models := flag.String("models", fakeprom.DefaultModel, "comma-separated GPU models, assigned to nodes round-robin")
+	pattern := flag.String("pattern", "mixed", fmt.Sprintf("utilization pattern %v", fakeprom.PatternNames()))
+	allocated := flag.Float64("allocated", 0.5, "fraction of each node's GPUs held by a pod")
+	namespaces := flag.String("namespaces", "ml-training,inference", "comma-separated namespaces of the generated pods")
+	kubeStateMetrics := flag.Bool("kube-state-metrics", true, "serve kube-state-metrics pod GPU requests")
+	missing := flag.String("missing", "", "comma-separated metric types the exporter does not report, e.g. temperature")
+	offline := flag.String("offline", "", "comma-separated nodes whose exporter is down")
+	scrapeInterval := flag.Duration("scrape-interval", 15*time.Second, "spacing of samples")
+	latency := flag.Duration("latency", 0, "delay before every response")
+	errorRate := flag.Float64("error-rate", 0, "fraction of requests failed with 503")
+	flag.Parse()
+
+	naming, err := loadNaming(*schemaName, *schemaFile)
+	if err != nil {
+		log.Fatalf("Invalid metric schema: %v", err)
+	}
+	utilization, err := fakeprom.LookupPattern(*pattern)
+	if err != nil {
+		log.Fatalf("Invalid -pattern: %v", err)
+	}
+
+	fleet := fakeprom.Fleet{
+		Naming:           naming,
+		Nodes:            *nodes,
+		GPUsPerNode:      *gpusPerNode,
+		Models:           splitList(*models),
+		Utilization:      utilization,
+		Allocated:        *allocated,
+		Namespaces:       splitList(*namespaces),
+		KubeStateMetrics: *kubeStateMetrics,
+		Missing:          splitList(*missing),
+		Offline:          splitList(*offline),
+		ScrapeInterval:   *scrapeInterval,
+	}
+	server := fakeprom.NewServer(fleet, fakeprom.WithFaults(fakeprom.Faults{
+		Latency:   *latency,
+		ErrorRate: *errorRate,
+	}))
+
+	log.Printf("Serving %d GPUs on %d nodes (%s, pattern %s) on %s", len(fleet.GPUs()), *nodes, *schemaName, *pattern, *addr)
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+// loadNaming converts the backend's metric schema to the fake server's naming.
+func loadNaming(name, file string) (fakeprom.Naming, error) {
+	var schema prometheus.Schema
+	var err error
+	if file != "" {
+		schema, err = prometheus.LoadSchemaFile(file)
+	} else {
+		schema, err = prometheus.LookupSchema(name)
+	}
+	if err != nil {
+		return fakeprom.Naming{}, err
+	}
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		return fakeprom.Naming{}, err
+	}
+	var naming fakeprom.Naming
+	if err := json.Unmarshal(data, &naming); err != nil {
+		return fakeprom.Naming{}, err
+	}
+	return naming, nil
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
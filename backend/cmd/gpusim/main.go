@@ -0,0 +1,80 @@
+// Command gpusim simulates a fleet of GPUs running training, inference and
+// idle workloads and exposes their readings as a GPU exporter would, for
+// Prometheus to scrape in demos and load tests:
+//
+//	go run ./cmd/gpusim -schema dcgm -nodes 32 -hot-nodes 0.1
+//	curl localhost:9400/metrics
+//	curl localhost:9400/nodes/gpu-node-00/metrics
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s-gpu-monitoring/internal/gpusim"
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+// main starts the simulated exporter.
+func main() {
+	addr := flag.String("addr", ":9400", "listen address")
+	schemaName := flag.String("schema", prometheus.SchemaNvidiaGPUExporter, fmt.Sprintf("exporter metric schema %v", prometheus.SchemaNames()))
+	schemaFile := flag.String("schema-file", "", "user-defined metric schema file, overriding -schema")
+	nodes := flag.Int("nodes", 32, "number of GPU nodes")
+	gpusPerNode := flag.Int("gpus-per-node", 8, "GPUs on each node")
+	models := flag.String("models", "NVIDIA A100-SXM4-80GB,NVIDIA H100 80GB HBM3", fmt.Sprintf("comma-separated GPU models, assigned to nodes round-robin %v", gpusim.ModelNames()))
+	mix := flag.String("mix", "training=4,inference=3,idle=2,free=1", "comma-separated profile=weight shares of GPUs per workload profile")
+	hotNodes := flag.Float64("hot-nodes", 0.1, "fraction of nodes with poor cooling, whose busy GPUs throttle")
+	xidRate := flag.Float64("xid-rate", 0.05, "expected XID errors per GPU per hour")
+	seed := flag.Uint64("seed", uint64(time.Now().UnixNano()), "random seed, for a reproducible fleet")
+	flag.Parse()
+
+	var schema prometheus.Schema
+	var err error
+	if *schemaFile != "" {
+		schema, err = prometheus.LoadSchemaFile(*schemaFile)
+	} else {
+		schema, err = prometheus.LookupSchema(*schemaName)
+	}
+	if err != nil {
+		log.Fatalf("Invalid metric schema: %v", err)
+	}
+	weights, err := gpusim.ParseMix(*mix)
+	if err != nil {
+		log.Fatalf("Invalid -mix: %v", err)
+	}
+
+	sim, err := gpusim.New(gpusim.Config{
+		Schema:      schema,
+		Nodes:       *nodes,
+		GPUsPerNode: *gpusPerNode,
+		Models:      splitList(*models),
+		Mix:         weights,
+		HotNodes:    *hotNodes,
+		XIDRate:     *xidRate,
+		Seed:        *seed,
+	}, time.Now())
+	if err != nil {
+		log.Fatalf("Invalid fleet: %v", err)
+	}
+
+	log.Printf("Simulating %d GPUs on %d nodes (%s) on %s", sim.GPUCount(), len(sim.Nodes()), schema.Name, *addr)
+	if err := http.ListenAndServe(*addr, sim.Handler(time.Now)); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
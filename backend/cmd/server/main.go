@@ -2,55 +2,227 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
+	"k8s-gpu-monitoring/internal/alerts"
+	"k8s-gpu-monitoring/internal/auth"
+	"k8s-gpu-monitoring/internal/federation"
 	"k8s-gpu-monitoring/internal/handlers"
+	"k8s-gpu-monitoring/internal/history"
+	"k8s-gpu-monitoring/internal/metrics"
 	"k8s-gpu-monitoring/internal/middleware"
 	"k8s-gpu-monitoring/internal/prometheus"
+	"k8s-gpu-monitoring/internal/scrape"
+	"k8s-gpu-monitoring/internal/spa"
+	"k8s-gpu-monitoring/internal/stream"
+	"k8s-gpu-monitoring/web"
 )
 
 // main starts the GPU monitoring API server with graceful shutdown support.
 func main() {
 	// Load configuration from environment variables
+	metricsSource := getEnv("METRICS_SOURCE", "prometheus")
 	prometheusURL := getEnv("PROMETHEUS_URL", "http://localhost:9090")
+	prometheusClusters := getEnv("PROMETHEUS_CLUSTERS", "")
+	clusterName := getEnv("CLUSTER_NAME", "")
 	port := getEnv("PORT", "8080")
+	schemaName := getEnv("METRIC_SCHEMA", prometheus.SchemaNvidiaGPUExporter)
+	schemaFile := getEnv("METRIC_SCHEMA_FILE", "")
+	energyWindow := getDurationEnv("ENERGY_WINDOW", time.Hour)
+	cacheTTL := getDurationEnv("CACHE_TTL", 15*time.Second)
+	cacheStaleTTL := getDurationEnv("CACHE_STALE_TTL", 5*time.Minute)
+	streamInterval := getIntervalEnv("STREAM_INTERVAL", stream.DefaultInterval)
+	streamHeartbeat := getIntervalEnv("STREAM_HEARTBEAT", handlers.DefaultHeartbeat)
+	alertConfigFile := getEnv("ALERT_CONFIG_FILE", "")
+	alertWebhookURLs := getEnv("ALERT_WEBHOOK_URLS", "")
+	alertInterval := getIntervalEnv("ALERT_INTERVAL", alerts.DefaultInterval)
+	corsOrigins := getEnv("CORS_ALLOWED_ORIGINS", "")
+	defaultRetry := prometheus.DefaultRetryPolicy()
+	retryPolicy := prometheus.RetryPolicy{
+		MaxAttempts:    getIntEnv("PROMETHEUS_RETRY_ATTEMPTS", defaultRetry.MaxAttempts),
+		InitialBackoff: getDurationEnv("PROMETHEUS_RETRY_INITIAL_BACKOFF", defaultRetry.InitialBackoff),
+		MaxBackoff:     getDurationEnv("PROMETHEUS_RETRY_MAX_BACKOFF", defaultRetry.MaxBackoff),
+	}
+	defaultBreaker := prometheus.DefaultBreakerPolicy()
+	breakerPolicy := prometheus.BreakerPolicy{
+		FailureThreshold: getIntEnv("PROMETHEUS_BREAKER_THRESHOLD", defaultBreaker.FailureThreshold),
+		OpenTimeout:      getDurationEnv("PROMETHEUS_BREAKER_TIMEOUT", defaultBreaker.OpenTimeout),
+	}
+	maxConcurrency := getIntEnv("PROMETHEUS_MAX_CONCURRENCY", 4)
+	batchQueries := getEnv("PROMETHEUS_BATCH_QUERIES", "true") == "true"
+	idleCriteria := metrics.IdleCriteria{
+		Lookback:   getDurationEnv("IDLE_LOOKBACK", handlers.DefaultIdleCriteria.Lookback),
+		MaxAverage: getFloatEnv("IDLE_MAX_AVG_UTILIZATION", handlers.DefaultIdleCriteria.MaxAverage),
+		MaxP95:     getFloatEnv("IDLE_MAX_P95_UTILIZATION", handlers.DefaultIdleCriteria.MaxP95),
+	}
 
 	log.Printf("Starting GPU Monitoring API Server...")
-	log.Printf("Prometheus URL: %s", prometheusURL)
 	log.Printf("Server Port: %s", port)
 
-	// Initialize Prometheus client
-	promClient := prometheus.NewClient(prometheusURL)
+	// Select the metric schema matching the deployed GPU exporter
+	schema, err := loadSchema(schemaName, schemaFile)
+	if err != nil {
+		log.Fatalf("Invalid metric schema: %v", err)
+	}
+	log.Printf("Metric Schema: %s", schema.Name)
+
+	// Read GPU metrics from Prometheus, or scrape the exporters directly
+	var source metrics.Source
+	var sourceRetention time.Duration
+	scrapeCtx, stopScraping := context.WithCancel(context.Background())
+	switch metricsSource {
+	case "prometheus":
+		log.Printf("Query Cache TTL: %s (stale: %s)", cacheTTL, cacheStaleTTL)
+		log.Printf("Query Retries: %d attempts, circuit breaker after %d failures", retryPolicy.MaxAttempts, breakerPolicy.FailureThreshold)
+		log.Printf("Query Concurrency: %d (batched selectors: %t)", maxConcurrency, batchQueries)
+
+		// Initialize one Prometheus client per cluster
+		endpoints, err := parseClusters(prometheusClusters, clusterName, prometheusURL)
+		if err != nil {
+			log.Fatalf("Invalid PROMETHEUS_CLUSTERS: %v", err)
+		}
+		connectionOpts, err := loadConnectionOptions()
+		if err != nil {
+			log.Fatalf("Invalid Prometheus connection configuration: %v", err)
+		}
+		clientOpts := append([]prometheus.Option{
+			prometheus.WithSchema(schema),
+			prometheus.WithEnergyWindow(energyWindow),
+			prometheus.WithCache(cacheTTL, cacheStaleTTL),
+			prometheus.WithRetry(retryPolicy),
+			prometheus.WithCircuitBreaker(breakerPolicy),
+			prometheus.WithMaxConcurrency(maxConcurrency),
+		}, connectionOpts...)
+		if batchQueries {
+			clientOpts = append(clientOpts, prometheus.WithQueryBatching())
+		}
+		clusters := make([]federation.Cluster, 0, len(endpoints))
+		for _, endpoint := range endpoints {
+			log.Printf("Prometheus URL: %s (cluster %q)", endpoint.url, endpoint.name)
+			clusters = append(clusters, federation.Cluster{
+				Name:   endpoint.name,
+				Client: prometheus.NewClient(endpoint.url, clientOpts...),
+			})
+		}
+		fed, err := federation.New(clusters...)
+		if err != nil {
+			log.Fatalf("Invalid cluster configuration: %v", err)
+		}
+		source = fed
+		sourceRetention = getDurationEnv("PROMETHEUS_RETENTION", 0)
+	case "scrape":
+		scrapeWindow := getDurationEnv("SCRAPE_WINDOW", 15*time.Minute)
+		scraper, err := loadScraper(clusterName, schema, energyWindow, scrapeWindow)
+		if err != nil {
+			log.Fatalf("Invalid scrape configuration: %v", err)
+		}
+		go scraper.Run(scrapeCtx)
+		source = scraper
+		sourceRetention = scrapeWindow
+	default:
+		log.Fatalf("Invalid METRICS_SOURCE: %q (expected prometheus or scrape)", metricsSource)
+	}
+
+	// Keep a local history to answer range queries the source no longer can
+	var historyStore *history.Store
+	historyCtx, stopHistory := context.WithCancel(context.Background())
+	if historyDir := getEnv("HISTORY_DIR", ""); historyDir != "" {
+		historyStore, err = loadHistory(historyDir)
+		if err != nil {
+			log.Fatalf("Invalid history configuration: %v", err)
+		}
+		go historyStore.Run(historyCtx, source.GetGPUMetrics)
+		source = history.NewFallback(source, historyStore, sourceRetention)
+		log.Printf("History: %s (source retention: %s)", historyDir, sourceRetention)
+	}
 
 	// Initialize handlers
-	gpuHandler := handlers.NewGPUHandler(promClient)
+	gpuHandler := handlers.NewGPUHandler(source, handlers.WithIdleCriteria(idleCriteria))
+
+	// One shared poller feeds every connected stream client
+	streamHub := stream.NewHub(source.GetGPUMetrics, streamInterval)
+	streamHandler := handlers.NewStreamHandler(streamHub, source, streamHeartbeat)
+
+	// Evaluate alert rules in the background until shutdown
+	alertConfig, err := loadAlertConfig(alertConfigFile, alertWebhookURLs)
+	if err != nil {
+		log.Fatalf("Invalid alert configuration: %v", err)
+	}
+	notifier, err := alerts.NewWebhookNotifier(alertConfig.Webhooks, 10*time.Second)
+	if err != nil {
+		log.Fatalf("Invalid alert webhook: %v", err)
+	}
+	alertEngine := alerts.NewEngine(alertConfig.Rules, source.GetGPUMetrics, notifier, alertInterval)
+	alertHandler := handlers.NewAlertHandler(alertEngine)
+	log.Printf("Alerting: %d rules, %d webhooks, every %s", len(alertConfig.Rules), len(alertConfig.Webhooks), alertInterval)
+
+	alertCtx, stopAlerts := context.WithCancel(context.Background())
+	go alertEngine.Run(alertCtx)
 
 	// Use Go 1.22's new ServeMux with method-specific routing
 	mux := http.NewServeMux()
 
 	// Register API routes
-	mux.HandleFunc("GET /api/health", gpuHandler.HealthCheck)
-	mux.HandleFunc("GET /api/v1/gpu/metrics", gpuHandler.GetGPUMetrics)
-	mux.HandleFunc("GET /api/v1/gpu/nodes", gpuHandler.GetGPUNodes)
-	mux.HandleFunc("GET /api/v1/gpu/utilization", gpuHandler.GetGPUUtilization)
-
-	// Serve static files for frontend
-	mux.Handle("GET /", http.FileServer(http.Dir("./static/")))
-
-	// Apply middleware chain
-	handler := middleware.Chain(
-		mux,
-		middleware.Logger,
-		middleware.CORS,
-		middleware.Recovery,
-	)
-
-	// Configure HTTP server with timeouts
+	gpuHandler.Register(mux)
+	mux.HandleFunc("GET /api/v1/gpu/stream", streamHandler.StreamGPUMetrics)
+	mux.HandleFunc("GET /api/v1/alerts", alertHandler.GetAlerts)
+
+	// Serve the frontend, falling back to index.html for client-side routes
+	frontend, embedded := web.Frontend()
+	if embedded {
+		log.Printf("Frontend: embedded build")
+	} else {
+		staticDir := getEnv("STATIC_DIR", "./static")
+		frontend = os.DirFS(staticDir)
+		log.Printf("Frontend: %s", staticDir)
+	}
+	mux.Handle("GET /", spa.Handler(frontend, "/api/"))
+
+	// Require credentials on the API; health checks and the frontend stay public
+	authenticator, err := loadAuthenticator()
+	if err != nil {
+		log.Fatalf("Invalid authentication configuration: %v", err)
+	}
+	if !authenticator.Enabled() {
+		log.Printf("WARNING: API authentication disabled; set OIDC_ISSUER_URL or API_KEYS to enable it")
+	}
+
+	// Apply middleware chain. An authenticated API is not opened to every origin
+	// by default; without CORS headers browsers only allow same-origin requests.
+	middlewares := []func(http.Handler) http.Handler{middleware.Logger}
+	if corsOrigins != "" || !authenticator.Enabled() {
+		middlewares = append(middlewares, middleware.CORSOrigins(splitList(corsOrigins)))
+	} else {
+		log.Printf("CORS: CORS_ALLOWED_ORIGINS unset with authentication enabled; allowing same-origin requests only")
+	}
+	middlewares = append(middlewares, middleware.Recovery)
+	if authenticator.Enabled() {
+		middlewares = append(middlewares, middleware.Auth(authenticator, isPublicPath))
+	}
+
+	// Restrict non-admin principals to the namespaces and nodes granted by the policy
+	if policyFile := getEnv("AUTHZ_POLICY_FILE", ""); policyFile != "" {
+		if !authenticator.Enabled() {
+			log.Fatalf("AUTHZ_POLICY_FILE requires authentication; set OIDC_ISSUER_URL or API_KEYS")
+		}
+		policy, err := auth.LoadPolicy(policyFile)
+		if err != nil {
+			log.Fatalf("Invalid authorization policy: %v", err)
+		}
+		middlewares = append(middlewares, middleware.Authorize(policy))
+		log.Printf("Authorization policy: %d bindings", len(policy.Bindings))
+	}
+	handler := middleware.Chain(mux, middlewares...)
+
+	// Configure HTTP server with timeouts; the stream handler lifts WriteTimeout per connection
 	server := &http.Server{
 		Addr:         ":" + port,
 		Handler:      handler,
@@ -59,6 +231,12 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
+	// Close open streams so Shutdown does not wait for them
+	server.RegisterOnShutdown(streamHub.Close)
+	server.RegisterOnShutdown(stopAlerts)
+	server.RegisterOnShutdown(stopScraping)
+	server.RegisterOnShutdown(stopHistory)
+
 	// Start server in a goroutine
 	go func() {
 		log.Printf("Server starting on port %s", port)
@@ -81,10 +259,214 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if historyStore != nil {
+		if err := historyStore.Close(); err != nil {
+			log.Printf("Error closing history store: %v", err)
+		}
+	}
 
 	log.Println("Server exited")
 }
 
+// loadSchema resolves the metric schema from a custom file or a built-in profile name.
+func loadSchema(name, file string) (prometheus.Schema, error) {
+	if file != "" {
+		return prometheus.LoadSchemaFile(file)
+	}
+	return prometheus.LookupSchema(name)
+}
+
+// loadScraper configures direct scraping of GPU exporters from the environment.
+func loadScraper(clusterName string, schema prometheus.Schema, energyWindow, window time.Duration) (*scrape.Scraper, error) {
+	targets := splitList(getEnv("SCRAPE_TARGETS", ""))
+	interval := getDurationEnv("SCRAPE_INTERVAL", 15*time.Second)
+	opts := []scrape.Option{
+		scrape.WithCluster(clusterName),
+		scrape.WithSchema(schema),
+		scrape.WithEnergyWindow(energyWindow),
+		scrape.WithInterval(interval),
+		scrape.WithWindow(window),
+		scrape.WithTimeout(getDurationEnv("SCRAPE_TIMEOUT", 10*time.Second)),
+	}
+	if dns := getEnv("SCRAPE_DNS", ""); dns != "" {
+		opts = append(opts, scrape.WithDNS(dns))
+		log.Printf("Scrape DNS: %s", dns)
+	}
+
+	scraper, err := scrape.New(targets, opts...)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Scrape Targets: %d static, every %s (window: %s)", len(targets), interval, window)
+	return scraper, nil
+}
+
+// loadHistory opens the local history store with the snapshot interval and
+// retention periods from the environment.
+func loadHistory(dir string) (*history.Store, error) {
+	defaults := history.DefaultRetention()
+	retention := history.Retention{
+		Raw:        getDurationEnv("HISTORY_RAW_RETENTION", defaults.Raw),
+		FiveMinute: getDurationEnv("HISTORY_5M_RETENTION", defaults.FiveMinute),
+		Hourly:     getDurationEnv("HISTORY_1H_RETENTION", defaults.Hourly),
+	}
+	interval := getDurationEnv("HISTORY_INTERVAL", time.Minute)
+
+	store, err := history.Open(dir, history.WithRetention(retention), history.WithInterval(interval))
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("History Retention: raw %s, 5m %s, 1h %s (every %s)", retention.Raw, retention.FiveMinute, retention.Hourly, interval)
+	return store, nil
+}
+
+// clusterEndpoint is a named Prometheus URL.
+type clusterEndpoint struct {
+	name string
+	url  string
+}
+
+// parseClusters reads a comma-separated list of name=url pairs. When the list is
+// empty, the single Prometheus URL is used under the optional cluster name.
+func parseClusters(list, name, defaultURL string) ([]clusterEndpoint, error) {
+	items := splitList(list)
+	if len(items) == 0 {
+		return []clusterEndpoint{{name: name, url: defaultURL}}, nil
+	}
+
+	endpoints := make([]clusterEndpoint, 0, len(items))
+	for _, item := range items {
+		clusterName, clusterURL, ok := strings.Cut(item, "=")
+		clusterName, clusterURL = strings.TrimSpace(clusterName), strings.TrimSpace(clusterURL)
+		if !ok || clusterName == "" || clusterURL == "" {
+			return nil, fmt.Errorf("%q is not name=url", item)
+		}
+		endpoints = append(endpoints, clusterEndpoint{name: clusterName, url: clusterURL})
+	}
+	return endpoints, nil
+}
+
+// loadConnectionOptions configures Prometheus credentials, extra headers and TLS from the environment.
+func loadConnectionOptions() ([]prometheus.Option, error) {
+	var opts []prometheus.Option
+
+	token := getEnv("PROMETHEUS_BEARER_TOKEN", "")
+	tokenFile := getEnv("PROMETHEUS_BEARER_TOKEN_FILE", "")
+	username := getEnv("PROMETHEUS_BASIC_AUTH_USERNAME", "")
+	configured := 0
+	for _, value := range []string{token, tokenFile, username} {
+		if value != "" {
+			configured++
+		}
+	}
+	if configured > 1 {
+		return nil, fmt.Errorf("set only one of PROMETHEUS_BEARER_TOKEN, PROMETHEUS_BEARER_TOKEN_FILE and PROMETHEUS_BASIC_AUTH_USERNAME")
+	}
+	switch {
+	case token != "":
+		opts = append(opts, prometheus.WithBearerToken(token))
+	case tokenFile != "":
+		opts = append(opts, prometheus.WithBearerTokenFile(tokenFile))
+	case username != "":
+		opts = append(opts, prometheus.WithBasicAuth(username, getEnv("PROMETHEUS_BASIC_AUTH_PASSWORD", "")))
+	}
+
+	if list := splitList(getEnv("PROMETHEUS_HEADERS", "")); len(list) > 0 {
+		headers := make(map[string]string, len(list))
+		for _, item := range list {
+			name, value, ok := strings.Cut(item, "=")
+			name = strings.TrimSpace(name)
+			if !ok || name == "" {
+				return nil, fmt.Errorf("PROMETHEUS_HEADERS: %q is not name=value", item)
+			}
+			headers[name] = strings.TrimSpace(value)
+		}
+		opts = append(opts, prometheus.WithHeaders(headers))
+	}
+
+	tlsOpts := prometheus.TLSOptions{
+		CAFile:             getEnv("PROMETHEUS_CA_FILE", ""),
+		CertFile:           getEnv("PROMETHEUS_CERT_FILE", ""),
+		KeyFile:            getEnv("PROMETHEUS_KEY_FILE", ""),
+		ServerName:         getEnv("PROMETHEUS_TLS_SERVER_NAME", ""),
+		InsecureSkipVerify: getEnv("PROMETHEUS_TLS_INSECURE_SKIP_VERIFY", "") == "true",
+	}
+	if tlsOpts.Enabled() {
+		config, err := prometheus.LoadTLSConfig(tlsOpts)
+		if err != nil {
+			return nil, err
+		}
+		if tlsOpts.InsecureSkipVerify {
+			log.Printf("WARNING: Prometheus TLS certificate verification disabled")
+		}
+		opts = append(opts, prometheus.WithTLSConfig(config))
+	}
+
+	return opts, nil
+}
+
+// loadAlertConfig reads the alert configuration file, falling back to the default rules,
+// and adds webhooks from a comma-separated URL list.
+func loadAlertConfig(file, webhookURLs string) (alerts.Config, error) {
+	config := alerts.Config{Rules: alerts.DefaultRules()}
+	if file != "" {
+		var err error
+		config, err = alerts.LoadConfig(file)
+		if err != nil {
+			return alerts.Config{}, err
+		}
+	}
+
+	for _, url := range splitList(webhookURLs) {
+		config.Webhooks = append(config.Webhooks, alerts.Webhook{URL: url})
+	}
+
+	return config, config.Validate()
+}
+
+// loadAuthenticator configures OIDC bearer tokens and hashed API keys from the environment.
+func loadAuthenticator() (*auth.Authenticator, error) {
+	apiKeys, err := auth.ParseAPIKeys(getEnv("API_KEYS", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	var verifier *auth.OIDCVerifier
+	if issuer := getEnv("OIDC_ISSUER_URL", ""); issuer != "" {
+		verifier, err = auth.NewOIDCVerifier(auth.OIDCConfig{
+			IssuerURL:       issuer,
+			Audience:        getEnv("OIDC_AUDIENCE", ""),
+			GroupsClaim:     getEnv("OIDC_GROUPS_CLAIM", "groups"),
+			RefreshInterval: getDurationEnv("OIDC_JWKS_REFRESH", time.Hour),
+		})
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("OIDC Issuer: %s", issuer)
+	}
+
+	return auth.NewAuthenticator(verifier, apiKeys), nil
+}
+
+// isPublicPath reports whether a request may skip authentication: health checks,
+// CORS preflights and the static frontend.
+func isPublicPath(r *http.Request) bool {
+	return r.Method == http.MethodOptions ||
+		r.URL.Path == "/api/health" ||
+		!strings.HasPrefix(r.URL.Path, "/api/")
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
 // getEnv retrieves environment variable value with fallback to default.
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -92,3 +474,55 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getDurationEnv retrieves a duration environment variable, exiting on malformed values.
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return d
+}
+
+// getIntervalEnv retrieves a positive duration environment variable, such as a
+// ticker interval, exiting on malformed or non-positive values.
+func getIntervalEnv(key string, defaultValue time.Duration) time.Duration {
+	d := getDurationEnv(key, defaultValue)
+	if d <= 0 {
+		log.Fatalf("Invalid %s: %q (must be positive)", key, os.Getenv(key))
+	}
+	return d
+}
+
+// getIntEnv retrieves a non-negative integer environment variable, exiting on malformed values.
+func getIntEnv(key string, defaultValue int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return n
+}
+
+// getFloatEnv retrieves a non-negative numeric environment variable, exiting on malformed values.
+func getFloatEnv(key string, defaultValue float64) float64 {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		log.Fatalf("Invalid %s: %q", key, value)
+	}
+	return f
+}
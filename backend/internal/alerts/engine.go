@@ -0,0 +1,269 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// FetchFunc retrieves the current GPU metrics.
+type FetchFunc func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error)
+
+// Engine evaluates rules against GPU metrics on a schedule and notifies when
+// alerts start or stop firing.
+type Engine struct {
+	rules        []Rule
+	fetch        FetchFunc
+	notifier     Notifier
+	interval     time.Duration
+	fetchTimeout time.Duration
+	now          func() time.Time
+
+	mu     sync.Mutex
+	active map[string]*models.Alert // key: "rule|node_name:gpu_index"
+}
+
+// DefaultInterval is the evaluation interval used when NewEngine is given a non-positive one.
+const DefaultInterval = 30 * time.Second
+
+// NewEngine creates an engine evaluating rules every interval. notifier may be nil.
+func NewEngine(rules []Rule, fetch FetchFunc, notifier Notifier, interval time.Duration) *Engine {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Engine{
+		rules:        rules,
+		fetch:        fetch,
+		notifier:     notifier,
+		interval:     interval,
+		fetchTimeout: 30 * time.Second,
+		now:          time.Now,
+		active:       make(map[string]*models.Alert),
+	}
+}
+
+// Rules returns the rules the engine evaluates.
+func (e *Engine) Rules() []Rule {
+	return e.rules
+}
+
+// Run evaluates rules immediately and then every interval until ctx is canceled.
+func (e *Engine) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		e.evaluateOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// evaluateOnce fetches metrics, evaluates every rule and delivers the resulting notifications.
+func (e *Engine) evaluateOnce(ctx context.Context) {
+	fetchCtx, cancel := context.WithTimeout(ctx, e.fetchTimeout)
+	metrics, warnings, err := e.fetch(fetchCtx)
+	cancel()
+
+	if ctx.Err() != nil {
+		return
+	}
+	// Keep alert state as is rather than resolving everything on a failed poll
+	if err != nil {
+		log.Printf("Error fetching GPU metrics for alerting: %v", err)
+		return
+	}
+
+	for _, n := range e.Evaluate(metrics, warnings, e.now()) {
+		log.Printf("Alert %s %s on %s GPU %d (value %.2f)", n.Alert.Rule, n.Status, gpuLabel(n.Alert), n.Alert.GPUIndex, n.Alert.Value)
+		if e.notifier == nil {
+			continue
+		}
+		if err := e.notifier.Notify(ctx, n); err != nil {
+			log.Printf("Error sending alert notification: %v", err)
+		}
+	}
+}
+
+// Evaluate updates alert state from a set of metrics observed at now and returns
+// notifications for alerts that started firing or resolved. GPUs missing from
+// metrics resolve their alerts. Rules reading a metric the warnings report as
+// missing are skipped, and GPUs of clusters the warnings report as unreachable
+// keep their alerts, so partial results neither fire nor resolve anything.
+func (e *Engine) Evaluate(metrics []models.GPUMetrics, warnings []models.Warning, now time.Time) []Notification {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	gaps := gapsFrom(warnings)
+
+	var notifications []Notification
+	notify := func(status string, alert *models.Alert) {
+		notifications = append(notifications, Notification{Status: status, Alert: *alert})
+	}
+
+	seen := make(map[string]bool, len(metrics)*len(e.rules))
+	for _, m := range metrics {
+		for _, rule := range e.rules {
+			key := alertKey(rule.Name, m.Cluster, m.NodeName, m.GPUIndex)
+			seen[key] = true
+			if gaps.missing(m.Cluster, rule.Metric) {
+				continue
+			}
+			value := metricValues[rule.Metric](m)
+
+			alert, exists := e.active[key]
+			if !exists {
+				if !rule.breached(value) {
+					continue
+				}
+				alert = &models.Alert{
+					Rule:      rule.Name,
+					Severity:  rule.Severity,
+					State:     models.AlertPending,
+					Metric:    rule.Metric,
+					Operator:  rule.Operator,
+					Threshold: rule.Threshold,
+					Summary:   rule.Summary,
+					ActiveAt:  now,
+				}
+				e.active[key] = alert
+			} else if alert.State == models.AlertPending && !rule.breached(value) {
+				delete(e.active, key)
+				continue
+			}
+
+			alert.Cluster = m.Cluster
+			alert.NodeName = m.NodeName
+			alert.GPUIndex = m.GPUIndex
+			alert.GPUName = m.GPUName
+			alert.UUID = m.UUID
+			alert.Owner = m.Owner
+			alert.Value = value
+
+			switch alert.State {
+			case models.AlertPending:
+				if now.Sub(alert.ActiveAt) >= time.Duration(rule.For) {
+					firedAt := now
+					alert.State = models.AlertFiring
+					alert.FiredAt = &firedAt
+					notify(models.AlertFiring, alert)
+				}
+			case models.AlertFiring:
+				if rule.recovered(value) {
+					resolve(alert, now)
+					notify(models.AlertResolved, alert)
+					delete(e.active, key)
+				}
+			}
+		}
+	}
+
+	for key, alert := range e.active {
+		if seen[key] || gaps.clusters[alert.Cluster] {
+			continue
+		}
+		if alert.State == models.AlertFiring {
+			resolve(alert, now)
+			notify(models.AlertResolved, alert)
+		}
+		delete(e.active, key)
+	}
+
+	sort.Slice(notifications, func(i, j int) bool {
+		return alertLess(notifications[i].Alert, notifications[j].Alert)
+	})
+	return notifications
+}
+
+// Alerts returns the pending and firing alerts.
+func (e *Engine) Alerts() []models.Alert {
+	e.mu.Lock()
+	alerts := make([]models.Alert, 0, len(e.active))
+	for _, alert := range e.active {
+		alerts = append(alerts, *alert)
+	}
+	e.mu.Unlock()
+
+	sort.Slice(alerts, func(i, j int) bool {
+		return alertLess(alerts[i], alerts[j])
+	})
+	return alerts
+}
+
+// gaps records what a partial result is missing.
+type gaps struct {
+	metrics  map[string]bool // key: "cluster|metric"
+	clusters map[string]bool // clusters whose GPUs may be absent from the result
+}
+
+// incompleteSources are warning metrics reporting that GPUs, rather than a single
+// metric, may be absent: an unreachable federated cluster, scrape target or
+// target discovery.
+var incompleteSources = map[string]bool{"cluster": true, "target": true, "discovery": true}
+
+// gapsFrom collects the clusters and metrics the warnings report as missing.
+func gapsFrom(warnings []models.Warning) gaps {
+	g := gaps{metrics: make(map[string]bool), clusters: make(map[string]bool)}
+	for _, w := range warnings {
+		if incompleteSources[w.Metric] {
+			g.clusters[w.Cluster] = true
+			continue
+		}
+		g.metrics[w.Cluster+"|"+w.Metric] = true
+	}
+	return g
+}
+
+// missing reports whether a rule metric cannot be trusted in cluster because a
+// source metric it is computed from is missing.
+func (g gaps) missing(cluster, metric string) bool {
+	for _, source := range metricSources(metric) {
+		if g.metrics[cluster+"|"+source] {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve marks a firing alert resolved at now.
+func resolve(alert *models.Alert, now time.Time) {
+	resolvedAt := now
+	alert.State = models.AlertResolved
+	alert.ResolvedAt = &resolvedAt
+}
+
+// gpuLabel names the node of an alert, prefixed with its cluster when federated.
+func gpuLabel(alert models.Alert) string {
+	if alert.Cluster == "" {
+		return alert.NodeName
+	}
+	return alert.Cluster + "/" + alert.NodeName
+}
+
+// alertKey identifies a rule's alert for a single GPU.
+func alertKey(rule, cluster, nodeName string, gpuIndex int) string {
+	return fmt.Sprintf("%s|%s/%s:%d", rule, cluster, nodeName, gpuIndex)
+}
+
+// alertLess orders alerts by cluster, node, GPU index and rule name.
+func alertLess(a, b models.Alert) bool {
+	if a.Cluster != b.Cluster {
+		return a.Cluster < b.Cluster
+	}
+	if a.NodeName != b.NodeName {
+		return a.NodeName < b.NodeName
+	}
+	if a.GPUIndex != b.GPUIndex {
+		return a.GPUIndex < b.GPUIndex
+	}
+	return a.Rule < b.Rule
+}
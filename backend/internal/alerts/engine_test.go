@@ -0,0 +1,201 @@
+package alerts
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+var tempRule = Rule{
+	Name:       "GPUHot",
+	Metric:     "temperature",
+	Operator:   ">=",
+	Threshold:  90,
+	For:        Duration(time.Minute),
+	Hysteresis: 5,
+}
+
+func gpu(node string, index int, temperature float64) models.GPUMetrics {
+	return models.GPUMetrics{NodeName: node, GPUIndex: index, Temperature: temperature}
+}
+
+func TestEngineLifecycle(t *testing.T) {
+	engine := NewEngine([]Rule{tempRule}, nil, nil, time.Minute)
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		name        string
+		temperature float64
+		after       time.Duration
+		wantState   string // "" when no alert is tracked
+		wantNotify  string // "" when no notification is expected
+	}{
+		{"below threshold", 80, 0, "", ""},
+		{"breach starts pending", 91, 10 * time.Second, models.AlertPending, ""},
+		{"still pending before for", 92, 40 * time.Second, models.AlertPending, ""},
+		{"fires after for", 93, 70 * time.Second, models.AlertFiring, models.AlertFiring},
+		{"within hysteresis stays firing", 87, 80 * time.Second, models.AlertFiring, ""},
+		{"clears hysteresis and resolves", 85, 90 * time.Second, "", models.AlertResolved},
+		{"breach again", 95, 100 * time.Second, models.AlertPending, ""},
+		{"pending drops without notification", 70, 110 * time.Second, "", ""},
+	}
+
+	for _, step := range steps {
+		notifications := engine.Evaluate([]models.GPUMetrics{gpu("node1", 0, step.temperature)}, nil, start.Add(step.after))
+
+		active := engine.Alerts()
+		state := ""
+		if len(active) == 1 {
+			state = active[0].State
+		}
+		if state != step.wantState || len(active) > 1 {
+			t.Fatalf("%s: got state %q (%d alerts), want %q", step.name, state, len(active), step.wantState)
+		}
+
+		switch {
+		case step.wantNotify == "" && len(notifications) != 0:
+			t.Fatalf("%s: unexpected notifications %+v", step.name, notifications)
+		case step.wantNotify != "" && (len(notifications) != 1 || notifications[0].Status != step.wantNotify):
+			t.Fatalf("%s: got notifications %+v, want %s", step.name, notifications, step.wantNotify)
+		}
+	}
+}
+
+func TestEngineResolvesMissingGPU(t *testing.T) {
+	rule := tempRule
+	rule.For = 0
+	engine := NewEngine([]Rule{rule}, nil, nil, time.Minute)
+	now := time.Now()
+
+	notifications := engine.Evaluate([]models.GPUMetrics{gpu("node1", 0, 95), gpu("node1", 1, 50)}, nil, now)
+	if len(notifications) != 1 || notifications[0].Status != models.AlertFiring || notifications[0].Alert.FiredAt == nil {
+		t.Fatalf("expected immediate firing without for, got %+v", notifications)
+	}
+
+	notifications = engine.Evaluate([]models.GPUMetrics{gpu("node1", 1, 50)}, nil, now.Add(time.Minute))
+	if len(notifications) != 1 || notifications[0].Status != models.AlertResolved || notifications[0].Alert.ResolvedAt == nil {
+		t.Fatalf("expected disappeared GPU to resolve, got %+v", notifications)
+	}
+	if len(engine.Alerts()) != 0 {
+		t.Errorf("expected no active alerts, got %+v", engine.Alerts())
+	}
+}
+
+func TestEngineSkipsMissingData(t *testing.T) {
+	rule := tempRule
+	rule.For = 0
+	headroom := Rule{Name: "PowerCapped", Metric: "power_headroom", Operator: "<", Threshold: 10}
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		rules    []Rule
+		first    []models.GPUMetrics
+		next     []models.GPUMetrics
+		warnings []models.Warning
+		want     int // active alerts after the partial result
+	}{
+		{
+			name:     "missing series keeps firing alert",
+			rules:    []Rule{rule},
+			first:    []models.GPUMetrics{gpu("node1", 0, 95)},
+			next:     []models.GPUMetrics{gpu("node1", 0, 0)},
+			warnings: []models.Warning{{Metric: "temperature", Reason: "no series found"}},
+			want:     1,
+		},
+		{
+			name:     "missing power limit does not fire headroom",
+			rules:    []Rule{headroom},
+			first:    []models.GPUMetrics{{NodeName: "node1", PowerDraw: 200, PowerLimit: 400, PowerHeadroom: 200}},
+			next:     []models.GPUMetrics{{NodeName: "node1", PowerDraw: 200}},
+			warnings: []models.Warning{{Metric: "power_limit", Reason: "request timed out"}},
+			want:     0,
+		},
+		{
+			name:     "unreachable cluster keeps its alerts",
+			rules:    []Rule{rule},
+			first:    []models.GPUMetrics{{Cluster: "a", NodeName: "node1", Temperature: 95}},
+			next:     nil,
+			warnings: []models.Warning{{Cluster: "a", Metric: "cluster", Reason: "backend unreachable"}},
+			want:     1,
+		},
+		{
+			name:     "missing metric in another cluster is still evaluated",
+			rules:    []Rule{rule},
+			first:    []models.GPUMetrics{{Cluster: "a", NodeName: "node1", Temperature: 95}},
+			next:     []models.GPUMetrics{{Cluster: "a", NodeName: "node1", Temperature: 50}},
+			warnings: []models.Warning{{Cluster: "b", Metric: "temperature", Reason: "no series found"}},
+			want:     0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(tt.rules, nil, nil, time.Minute)
+			engine.Evaluate(tt.first, nil, now)
+
+			notifications := engine.Evaluate(tt.next, tt.warnings, now.Add(time.Minute))
+			if tt.want == 1 && len(notifications) != 0 {
+				t.Errorf("expected no notifications, got %+v", notifications)
+			}
+			if got := len(engine.Alerts()); got != tt.want {
+				t.Errorf("expected %d active alerts, got %+v", tt.want, engine.Alerts())
+			}
+		})
+	}
+}
+
+// recordingNotifier collects notifications delivered by the engine.
+type recordingNotifier struct {
+	mu            sync.Mutex
+	notifications []Notification
+}
+
+func (r *recordingNotifier) Notify(ctx context.Context, n Notification) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.notifications = append(r.notifications, n)
+	return nil
+}
+
+func (r *recordingNotifier) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.notifications)
+}
+
+func TestEngineRun(t *testing.T) {
+	rule := tempRule
+	rule.For = 0
+	fetch := func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+		return []models.GPUMetrics{gpu("node1", 0, 99)}, nil, nil
+	}
+	notifier := &recordingNotifier{}
+	engine := NewEngine([]Rule{rule}, fetch, notifier, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		engine.Run(ctx)
+		close(done)
+	}()
+
+	deadline := time.After(time.Second)
+	for notifier.count() == 0 {
+		select {
+		case <-deadline:
+			t.Fatal("timed out waiting for notification")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+	cancel()
+	<-done
+
+	// A firing alert is only announced once
+	if got := notifier.count(); got != 1 {
+		t.Errorf("expected 1 notification, got %d", got)
+	}
+}
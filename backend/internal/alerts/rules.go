@@ -0,0 +1,184 @@
+package alerts
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// metricValues maps rule metric names to the GPUMetrics field they read.
+var metricValues = map[string]func(m models.GPUMetrics) float64{
+	"utilization":        func(m models.GPUMetrics) float64 { return m.Utilization },
+	"memory_used":        func(m models.GPUMetrics) float64 { return m.MemoryUsed },
+	"memory_free":        func(m models.GPUMetrics) float64 { return m.MemoryFree },
+	"memory_utilization": func(m models.GPUMetrics) float64 { return m.MemoryUtilization },
+	"temperature":        func(m models.GPUMetrics) float64 { return m.Temperature },
+	"power_draw":         func(m models.GPUMetrics) float64 { return m.PowerDraw },
+	"power_headroom":     func(m models.GPUMetrics) float64 { return m.PowerHeadroom },
+}
+
+// metricSources returns the source metrics a rule metric is computed from.
+func metricSources(metric string) []string {
+	if metric == "power_headroom" {
+		return []string{"power_draw", "power_limit"}
+	}
+	return []string{metric}
+}
+
+// Duration is a time.Duration that decodes from JSON strings such as "5m".
+type Duration time.Duration
+
+// UnmarshalJSON parses a Go duration string.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string: %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON formats the duration as a Go duration string.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rule fires when a GPU metric compares against Threshold for at least For.
+// A firing alert only resolves once the value moves Hysteresis past the
+// threshold in the other direction, so readings hovering at the limit do not flap.
+type Rule struct {
+	Name       string   `json:"name"`
+	Metric     string   `json:"metric"`
+	Operator   string   `json:"operator"`
+	Threshold  float64  `json:"threshold"`
+	For        Duration `json:"for,omitempty"`
+	Hysteresis float64  `json:"hysteresis,omitempty"`
+	Severity   string   `json:"severity,omitempty"`
+	Summary    string   `json:"summary,omitempty"`
+}
+
+// Validate checks that the rule can be evaluated.
+func (r Rule) Validate() error {
+	if r.Name == "" {
+		return fmt.Errorf("rule name is required")
+	}
+	if _, ok := metricValues[r.Metric]; !ok {
+		return fmt.Errorf("rule %s: unknown metric %q", r.Name, r.Metric)
+	}
+	switch r.Operator {
+	case ">", ">=", "<", "<=":
+	default:
+		return fmt.Errorf("rule %s: unknown operator %q", r.Name, r.Operator)
+	}
+	if r.For < 0 || r.Hysteresis < 0 {
+		return fmt.Errorf("rule %s: for and hysteresis must not be negative", r.Name)
+	}
+	return nil
+}
+
+// breached reports whether value meets the rule condition.
+func (r Rule) breached(value float64) bool {
+	switch r.Operator {
+	case ">":
+		return value > r.Threshold
+	case ">=":
+		return value >= r.Threshold
+	case "<":
+		return value < r.Threshold
+	case "<=":
+		return value <= r.Threshold
+	}
+	return false
+}
+
+// recovered reports whether value has cleared the threshold by the hysteresis margin.
+func (r Rule) recovered(value float64) bool {
+	if r.breached(value) {
+		return false
+	}
+	if strings.HasPrefix(r.Operator, ">") {
+		return value <= r.Threshold-r.Hysteresis
+	}
+	return value >= r.Threshold+r.Hysteresis
+}
+
+// Config is the alerting configuration file.
+type Config struct {
+	Rules    []Rule    `json:"rules"`
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// DefaultRules cover overheating and exhausted GPU memory.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			Name:       "GPUHighTemperature",
+			Metric:     "temperature",
+			Operator:   ">=",
+			Threshold:  90,
+			For:        Duration(time.Minute),
+			Hysteresis: 5,
+			Severity:   "critical",
+			Summary:    "GPU temperature is at or above 90°C",
+		},
+		{
+			Name:       "GPUMemoryExhausted",
+			Metric:     "memory_utilization",
+			Operator:   ">=",
+			Threshold:  95,
+			For:        Duration(5 * time.Minute),
+			Hysteresis: 5,
+			Severity:   "warning",
+			Summary:    "GPU memory utilization is at or above 95%",
+		},
+	}
+}
+
+// LoadConfig reads an alerting configuration file. The default rules apply when
+// the file defines none.
+func LoadConfig(path string) (Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("reading alert config: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return Config{}, fmt.Errorf("parsing alert config: %w", err)
+	}
+	if len(config.Rules) == 0 {
+		config.Rules = DefaultRules()
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Validate checks every rule and webhook, rejecting duplicate rule names.
+func (c Config) Validate() error {
+	names := make(map[string]bool, len(c.Rules))
+	for _, rule := range c.Rules {
+		if err := rule.Validate(); err != nil {
+			return err
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name %q", rule.Name)
+		}
+		names[rule.Name] = true
+	}
+	for i, webhook := range c.Webhooks {
+		if err := webhook.Validate(); err != nil {
+			return fmt.Errorf("webhook %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"text/template"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// Notification is sent when an alert starts or stops firing.
+type Notification struct {
+	// Status is models.AlertFiring or models.AlertResolved.
+	Status string       `json:"status"`
+	Alert  models.Alert `json:"alert"`
+}
+
+// Notifier delivers alert notifications.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// Webhook is a generic HTTP receiver. Template is a text/template rendered with a
+// Notification to build the request body; when empty the Notification is posted as JSON.
+type Webhook struct {
+	URL         string            `json:"url"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	ContentType string            `json:"content_type,omitempty"`
+	Template    string            `json:"template,omitempty"`
+}
+
+// Validate checks the webhook URL and template.
+func (w Webhook) Validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid webhook url %q", w.URL)
+	}
+	if _, err := w.parseTemplate(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// templateFuncs are available to webhook templates; json escapes values for JSON payloads.
+var templateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// parseTemplate parses the payload template, returning nil when none is set.
+func (w Webhook) parseTemplate() (*template.Template, error) {
+	if w.Template == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("webhook").Funcs(templateFuncs).Option("missingkey=error").Parse(w.Template)
+	if err != nil {
+		return nil, fmt.Errorf("parsing webhook template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// webhookTarget is a webhook with its parsed template.
+type webhookTarget struct {
+	Webhook
+	tmpl *template.Template
+}
+
+// WebhookNotifier posts notifications to every configured webhook.
+type WebhookNotifier struct {
+	targets    []webhookTarget
+	httpClient *http.Client
+}
+
+// NewWebhookNotifier creates a notifier for webhooks, bounding each request by timeout.
+func NewWebhookNotifier(webhooks []Webhook, timeout time.Duration) (*WebhookNotifier, error) {
+	targets := make([]webhookTarget, 0, len(webhooks))
+	for _, w := range webhooks {
+		if err := w.Validate(); err != nil {
+			return nil, err
+		}
+		tmpl, _ := w.parseTemplate()
+		targets = append(targets, webhookTarget{Webhook: w, tmpl: tmpl})
+	}
+
+	return &WebhookNotifier{
+		targets:    targets,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Notify posts n to every webhook, returning the joined delivery errors.
+func (n *WebhookNotifier) Notify(ctx context.Context, notification Notification) error {
+	var errs []error
+	for _, target := range n.targets {
+		if err := n.send(ctx, target, notification); err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", target.URL, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// send renders the payload and posts it to a single webhook.
+func (n *WebhookNotifier) send(ctx context.Context, target webhookTarget, notification Notification) error {
+	var body bytes.Buffer
+	if target.tmpl != nil {
+		if err := target.tmpl.Execute(&body, notification); err != nil {
+			return fmt.Errorf("rendering payload: %w", err)
+		}
+	} else if err := json.NewEncoder(&body).Encode(notification); err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target.URL, &body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	contentType := target.ContentType
+	if contentType == "" {
+		contentType = "application/json"
+	}
+	req.Header.Set("Content-Type", contentType)
+	for key, value := range target.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := n.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+	return nil
+}
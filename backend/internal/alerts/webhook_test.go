@@ -0,0 +1,140 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// receivedRequest is a request captured by the stand-in receiver.
+type receivedRequest struct {
+	header http.Header
+	body   string
+}
+
+// newReceiver starts a local webhook receiver answering with status.
+func newReceiver(t *testing.T, status int) (*httptest.Server, chan receivedRequest) {
+	t.Helper()
+	received := make(chan receivedRequest, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedRequest{header: r.Header, body: string(body)}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, received
+}
+
+var testNotification = Notification{
+	Status: models.AlertFiring,
+	Alert: models.Alert{
+		Rule:     "GPUHot",
+		State:    models.AlertFiring,
+		NodeName: "node1",
+		GPUIndex: 2,
+		Value:    93.5,
+		Summary:  `GPU is "hot"`,
+	},
+}
+
+func TestWebhookDefaultPayload(t *testing.T) {
+	server, received := newReceiver(t, http.StatusOK)
+
+	notifier, err := NewWebhookNotifier([]Webhook{{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}}}, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	req := <-received
+	if req.header.Get("Content-Type") != "application/json" || req.header.Get("Authorization") != "Bearer token" {
+		t.Errorf("unexpected headers: %v", req.header)
+	}
+
+	var got Notification
+	if err := json.Unmarshal([]byte(req.body), &got); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	if got.Status != models.AlertFiring || got.Alert.NodeName != "node1" || got.Alert.Value != 93.5 {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
+
+func TestWebhookTemplate(t *testing.T) {
+	server, received := newReceiver(t, http.StatusOK)
+
+	webhook := Webhook{
+		URL:      server.URL,
+		Template: `{"text": {{ printf "[%s] %s on %s GPU %d: %s" .Status .Alert.Rule .Alert.NodeName .Alert.GPUIndex .Alert.Summary | json }}}`,
+	}
+	notifier, err := NewWebhookNotifier([]Webhook{webhook}, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), testNotification); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var got map[string]string
+	if err := json.Unmarshal([]byte((<-received).body), &got); err != nil {
+		t.Fatalf("rendered payload is not JSON: %v", err)
+	}
+	if want := `[firing] GPUHot on node1 GPU 2: GPU is "hot"`; got["text"] != want {
+		t.Errorf("got %q, want %q", got["text"], want)
+	}
+}
+
+func TestWebhookReceiverError(t *testing.T) {
+	server, _ := newReceiver(t, http.StatusBadGateway)
+
+	notifier, err := NewWebhookNotifier([]Webhook{{URL: server.URL}}, time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := notifier.Notify(context.Background(), testNotification); err == nil {
+		t.Error("expected error for non-2xx receiver response")
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "alerts.json")
+	os.WriteFile(valid, []byte(`{
+  "rules": [{"name": "Busy", "metric": "utilization", "operator": ">", "threshold": 99, "for": "10m"}],
+  "webhooks": [{"url": "http://receiver.example/hook", "template": "{{ .Status }}"}]
+}`), 0o644)
+
+	config, err := LoadConfig(valid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(config.Rules) != 1 || time.Duration(config.Rules[0].For) != 10*time.Minute || len(config.Webhooks) != 1 {
+		t.Errorf("unexpected config: %+v", config)
+	}
+
+	invalid := []string{
+		`{"rules": [{"name": "Bad", "metric": "fan_speed", "operator": ">", "threshold": 1}]}`,
+		`{"rules": [{"name": "Bad", "metric": "utilization", "operator": "==", "threshold": 1}]}`,
+		`{"rules": [{"name": "Bad", "metric": "utilization", "operator": ">", "threshold": 1, "for": 60}]}`,
+		`{"webhooks": [{"url": "ftp://receiver.example"}]}`,
+		`{"webhooks": [{"url": "http://receiver.example", "template": "{{ .Status"}]}`,
+	}
+	for i, body := range invalid {
+		path := filepath.Join(dir, "invalid.json")
+		os.WriteFile(path, []byte(body), 0o644)
+		if _, err := LoadConfig(path); err == nil {
+			t.Errorf("config %d: expected error", i)
+		}
+	}
+}
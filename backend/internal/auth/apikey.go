@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// APIKey is a static key for automation, stored only as its SHA-256 hash.
+type APIKey struct {
+	Name string
+	hash [sha256.Size]byte
+}
+
+// HashAPIKey returns the hex SHA-256 hash of key in the form ParseAPIKeys expects.
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ParseAPIKeys parses a comma-separated list of name:sha256hex entries.
+func ParseAPIKeys(spec string) ([]APIKey, error) {
+	var keys []APIKey
+	names := make(map[string]bool)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, hash, found := strings.Cut(entry, ":")
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid API key entry %q: expected name:sha256hex", entry)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate API key name %q", name)
+		}
+
+		decoded, err := hex.DecodeString(hash)
+		if err != nil || len(decoded) != sha256.Size {
+			return nil, fmt.Errorf("invalid API key %q: hash must be 64 hex characters", name)
+		}
+
+		key := APIKey{Name: name}
+		copy(key.hash[:], decoded)
+		keys = append(keys, key)
+		names[name] = true
+	}
+
+	return keys, nil
+}
+
+// matchAPIKey returns the key whose hash matches presented. Every key is compared
+// in constant time so the response time does not reveal which entry matched.
+func matchAPIKey(keys []APIKey, presented string) (APIKey, bool) {
+	sum := sha256.Sum256([]byte(presented))
+
+	var match APIKey
+	found := false
+	for _, key := range keys {
+		if subtle.ConstantTimeCompare(sum[:], key.hash[:]) == 1 {
+			match = key
+			found = true
+		}
+	}
+	return match, found
+}
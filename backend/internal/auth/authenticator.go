@@ -0,0 +1,59 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader carries static API keys.
+const APIKeyHeader = "X-API-Key"
+
+// Authenticator accepts OIDC bearer tokens and static API keys.
+type Authenticator struct {
+	oidc    *OIDCVerifier
+	apiKeys []APIKey
+}
+
+// NewAuthenticator creates an authenticator. oidc may be nil to accept API keys only.
+func NewAuthenticator(oidc *OIDCVerifier, apiKeys []APIKey) *Authenticator {
+	return &Authenticator{oidc: oidc, apiKeys: apiKeys}
+}
+
+// Enabled reports whether any credential type is configured.
+func (a *Authenticator) Enabled() bool {
+	return a.oidc != nil || len(a.apiKeys) > 0
+}
+
+// Authenticate identifies the caller of r. API keys are read from the X-API-Key
+// header or, for clients that can only send bearer tokens, from an Authorization
+// bearer value that is not a JWT. The returned error wraps ErrNoCredentials or
+// ErrInvalidCredentials.
+func (a *Authenticator) Authenticate(r *http.Request) (*Principal, error) {
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		return a.authenticateAPIKey(key)
+	}
+
+	scheme, token, found := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, ErrNoCredentials
+	}
+
+	if strings.Count(token, ".") == 2 && a.oidc != nil {
+		principal, err := a.oidc.Verify(r.Context(), token)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		return principal, nil
+	}
+	return a.authenticateAPIKey(token)
+}
+
+// authenticateAPIKey matches a presented key against the configured hashes.
+func (a *Authenticator) authenticateAPIKey(presented string) (*Principal, error) {
+	key, ok := matchAPIKey(a.apiKeys, presented)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown API key", ErrInvalidCredentials)
+	}
+	return &Principal{Subject: key.Name, Name: key.Name, Method: MethodAPIKey}, nil
+}
@@ -0,0 +1,71 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseAPIKeys(t *testing.T) {
+	keys, err := ParseAPIKeys("ci:" + HashAPIKey("secret-1") + ", grafana:" + HashAPIKey("secret-2"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 2 || keys[0].Name != "ci" || keys[1].Name != "grafana" {
+		t.Fatalf("unexpected keys: %+v", keys)
+	}
+
+	invalid := []string{
+		"ci",
+		":" + HashAPIKey("x"),
+		"ci:not-hex",
+		"ci:abcd",
+		"ci:" + HashAPIKey("a") + ",ci:" + HashAPIKey("b"),
+	}
+	for _, spec := range invalid {
+		if _, err := ParseAPIKeys(spec); err == nil {
+			t.Errorf("%q: expected error", spec)
+		}
+	}
+}
+
+func TestAuthenticateAPIKey(t *testing.T) {
+	keys, _ := ParseAPIKeys("ci:" + HashAPIKey("secret-1"))
+	authenticator := NewAuthenticator(nil, keys)
+
+	tests := []struct {
+		name    string
+		header  string
+		value   string
+		wantErr error
+	}{
+		{"api key header", APIKeyHeader, "secret-1", nil},
+		{"bearer api key", "Authorization", "Bearer secret-1", nil},
+		{"wrong key", APIKeyHeader, "secret-2", ErrInvalidCredentials},
+		{"jwt without oidc", "Authorization", "Bearer a.b.c", ErrInvalidCredentials},
+		{"basic auth", "Authorization", "Basic Y2k6c2VjcmV0", ErrNoCredentials},
+		{"no credentials", "", "", ErrNoCredentials},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/gpu/metrics", nil)
+		if tt.header != "" {
+			r.Header.Set(tt.header, tt.value)
+		}
+
+		principal, err := authenticator.Authenticate(r)
+		if tt.wantErr != nil {
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("%s: got error %v, want %v", tt.name, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if principal.Subject != "ci" || principal.Method != MethodAPIKey {
+			t.Errorf("%s: unexpected principal %+v", tt.name, principal)
+		}
+	}
+}
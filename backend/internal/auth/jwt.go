@@ -0,0 +1,195 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// jwtHeader is the JOSE header of a signed token.
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// signatureAlgorithms lists the accepted JWS algorithms. Symmetric and "none"
+// algorithms are deliberately absent.
+var signatureAlgorithms = map[string]crypto.Hash{
+	"RS256": crypto.SHA256,
+	"RS384": crypto.SHA384,
+	"RS512": crypto.SHA512,
+	"ES256": crypto.SHA256,
+	"ES384": crypto.SHA384,
+	"ES512": crypto.SHA512,
+}
+
+// parsedToken is a structurally valid JWT whose signature has not yet been checked.
+type parsedToken struct {
+	header       jwtHeader
+	claims       map[string]json.RawMessage
+	signingInput string
+	signature    []byte
+}
+
+// parseJWT splits and decodes a compact JWS.
+func parseJWT(token string) (*parsedToken, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed token")
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decoding header: %w", err)
+	}
+	if _, ok := signatureAlgorithms[header.Alg]; !ok {
+		return nil, fmt.Errorf("unsupported algorithm %q", header.Alg)
+	}
+
+	var claims map[string]json.RawMessage
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decoding claims: %w", err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("decoding signature: %w", err)
+	}
+
+	return &parsedToken{
+		header:       header,
+		claims:       claims,
+		signingInput: parts[0] + "." + parts[1],
+		signature:    signature,
+	}, nil
+}
+
+// decodeSegment decodes a base64url JSON segment into v.
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// verifySignature checks the token signature with key, which must match the algorithm family.
+func (t *parsedToken) verifySignature(key crypto.PublicKey) error {
+	hash := signatureAlgorithms[t.header.Alg]
+	h := hash.New()
+	h.Write([]byte(t.signingInput))
+	digest := h.Sum(nil)
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(t.header.Alg, "RS") {
+			return fmt.Errorf("algorithm %s does not match RSA key", t.header.Alg)
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, t.signature); err != nil {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(t.header.Alg, "ES") {
+			return fmt.Errorf("algorithm %s does not match EC key", t.header.Alg)
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(t.signature) != 2*size || ecCurveAlgorithm(pub.Curve.Params().Name) != t.header.Alg {
+			return fmt.Errorf("invalid signature")
+		}
+		r := new(big.Int).SetBytes(t.signature[:size])
+		s := new(big.Int).SetBytes(t.signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("unsupported key type %T", key)
+}
+
+// ecCurveAlgorithm returns the JWS algorithm paired with an elliptic curve.
+func ecCurveAlgorithm(curve string) string {
+	switch curve {
+	case "P-256":
+		return "ES256"
+	case "P-384":
+		return "ES384"
+	case "P-521":
+		return "ES512"
+	}
+	return ""
+}
+
+// stringClaim returns a string claim, or "" if absent or not a string.
+func (t *parsedToken) stringClaim(name string) string {
+	var s string
+	if raw, ok := t.claims[name]; ok {
+		json.Unmarshal(raw, &s)
+	}
+	return s
+}
+
+// stringsClaim returns a claim holding a string or an array of strings.
+func (t *parsedToken) stringsClaim(name string) []string {
+	raw, ok := t.claims[name]
+	if !ok {
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return list
+	}
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil && s != "" {
+		return []string{s}
+	}
+	return nil
+}
+
+// timeClaim returns a NumericDate claim.
+func (t *parsedToken) timeClaim(name string) (time.Time, bool) {
+	var seconds float64
+	raw, ok := t.claims[name]
+	if !ok || json.Unmarshal(raw, &seconds) != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// validateClaims checks issuer, audience and validity period at now, allowing leeway for clock skew.
+func (t *parsedToken) validateClaims(issuer, audience string, now time.Time, leeway time.Duration) error {
+	if got := t.stringClaim("iss"); got != issuer {
+		return fmt.Errorf("unexpected issuer %q", got)
+	}
+
+	audienceOK := false
+	for _, aud := range t.stringsClaim("aud") {
+		if aud == audience {
+			audienceOK = true
+			break
+		}
+	}
+	if !audienceOK {
+		return fmt.Errorf("token not issued for audience %q", audience)
+	}
+
+	exp, ok := t.timeClaim("exp")
+	if !ok {
+		return fmt.Errorf("missing exp claim")
+	}
+	if now.After(exp.Add(leeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if nbf, ok := t.timeClaim("nbf"); ok && now.Add(leeway).Before(nbf) {
+		return fmt.Errorf("token not yet valid")
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultJWKSRefresh is how long fetched signing keys are trusted before refetching.
+	defaultJWKSRefresh = time.Hour
+	// minJWKSRefresh throttles refetches, whether triggered by unknown key IDs or stale keys.
+	minJWKSRefresh = 30 * time.Second
+	// jwksFetchTimeout bounds a refetch, which does not follow the deadline of the triggering request.
+	jwksFetchTimeout = 10 * time.Second
+	// clockLeeway tolerates clock skew between the issuer and this server.
+	clockLeeway = time.Minute
+)
+
+// OIDCConfig configures bearer token validation against an OIDC issuer.
+type OIDCConfig struct {
+	IssuerURL string
+	Audience  string
+	// GroupsClaim names the claim listing the caller's groups. Defaults to "groups".
+	GroupsClaim string
+	// RefreshInterval is how often signing keys are refetched. Defaults to one hour.
+	RefreshInterval time.Duration
+	HTTPClient      *http.Client
+}
+
+// OIDCVerifier validates JWT bearer tokens with keys from the issuer's JWKS.
+// Keys are discovered lazily, cached, and refetched periodically or when a
+// token names an unknown key so that issuer key rotation is picked up.
+type OIDCVerifier struct {
+	config OIDCConfig
+	now    func() time.Time
+
+	mu          sync.Mutex
+	jwksURI     string
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+	// refreshing is closed when the refetch in flight completes, nil when there is none.
+	refreshing chan struct{}
+}
+
+// NewOIDCVerifier creates a verifier for config.
+func NewOIDCVerifier(config OIDCConfig) (*OIDCVerifier, error) {
+	if config.IssuerURL == "" {
+		return nil, fmt.Errorf("OIDC issuer URL is required")
+	}
+	if config.Audience == "" {
+		return nil, fmt.Errorf("OIDC audience is required")
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	if config.RefreshInterval <= 0 {
+		config.RefreshInterval = defaultJWKSRefresh
+	}
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	return &OIDCVerifier{
+		config: config,
+		now:    time.Now,
+	}, nil
+}
+
+// Verify validates a bearer token and returns its principal.
+func (v *OIDCVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	parsed, err := parseJWT(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := v.key(ctx, parsed.header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := parsed.verifySignature(key); err != nil {
+		return nil, err
+	}
+	if err := parsed.validateClaims(v.config.IssuerURL, v.config.Audience, v.now(), clockLeeway); err != nil {
+		return nil, err
+	}
+
+	subject := parsed.stringClaim("sub")
+	if subject == "" {
+		return nil, fmt.Errorf("missing sub claim")
+	}
+
+	name := parsed.stringClaim("preferred_username")
+	if name == "" {
+		name = parsed.stringClaim("email")
+	}
+
+	return &Principal{
+		Subject: subject,
+		Name:    name,
+		Groups:  parsed.stringsClaim(v.config.GroupsClaim),
+		Method:  MethodOIDC,
+	}, nil
+}
+
+// key returns the signing key with kid, refreshing the key set when it is stale
+// or does not contain kid. A token without kid is accepted only when the set holds one key.
+// Stale keys keep being served while the refresh runs, and after it fails.
+func (v *OIDCVerifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	now := v.now()
+	stale := now.Sub(v.fetchedAt) >= v.config.RefreshInterval
+	key, known := v.lookup(kid)
+	done := v.refreshing
+	if done == nil && (stale || !known) && now.Sub(v.lastAttempt) >= minJWKSRefresh {
+		v.lastAttempt = now
+		done = make(chan struct{})
+		v.refreshing = done
+		go v.refresh(done)
+	}
+	v.mu.Unlock()
+
+	if known {
+		return key, nil
+	}
+	if done != nil {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.lookup(kid)
+	if !ok {
+		if v.keys == nil && v.lastErr != nil {
+			return nil, v.lastErr
+		}
+		return nil, fmt.Errorf("unknown signing key %q", kid)
+	}
+	return key, nil
+}
+
+// lookup finds a cached key. Callers must hold v.mu.
+func (v *OIDCVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" {
+		if len(v.keys) != 1 {
+			return nil, false
+		}
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// refresh refetches the key set without holding v.mu and closes done when it
+// completes. It is detached from the request that triggered it, whose
+// cancellation would otherwise fail the refresh for every caller waiting on it.
+// A failed refresh keeps the previous keys.
+func (v *OIDCVerifier) refresh(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), jwksFetchTimeout)
+	defer cancel()
+
+	v.mu.Lock()
+	uri := v.jwksURI
+	v.mu.Unlock()
+
+	keys, uri, err := v.fetch(ctx, uri)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.jwksURI = uri
+	v.lastErr = err
+	if err == nil {
+		v.keys = keys
+		v.fetchedAt = v.now()
+	}
+	v.refreshing = nil
+	close(done)
+}
+
+// fetch discovers the JWKS endpoint if uri is empty and fetches the key set from it.
+func (v *OIDCVerifier) fetch(ctx context.Context, uri string) (map[string]crypto.PublicKey, string, error) {
+	if uri == "" {
+		discovered, err := v.discover(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		uri = discovered
+	}
+
+	var set jsonWebKeySet
+	if err := v.getJSON(ctx, uri, &set); err != nil {
+		return nil, uri, fmt.Errorf("fetching JWKS: %w", err)
+	}
+
+	keys, err := set.publicKeys()
+	if err != nil {
+		return nil, uri, err
+	}
+	return keys, uri, nil
+}
+
+// discover reads the jwks_uri from the issuer's OpenID configuration.
+func (v *OIDCVerifier) discover(ctx context.Context) (string, error) {
+	var doc struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}
+
+	url := strings.TrimSuffix(v.config.IssuerURL, "/") + "/.well-known/openid-configuration"
+	if err := v.getJSON(ctx, url, &doc); err != nil {
+		return "", fmt.Errorf("fetching OpenID configuration: %w", err)
+	}
+	if doc.Issuer != v.config.IssuerURL {
+		return "", fmt.Errorf("OpenID configuration issuer %q does not match %q", doc.Issuer, v.config.IssuerURL)
+	}
+	if doc.JWKSURI == "" {
+		return "", fmt.Errorf("OpenID configuration has no jwks_uri")
+	}
+	return doc.JWKSURI, nil
+}
+
+// getJSON fetches url and decodes the JSON body into v.
+func (v *OIDCVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+
+	resp, err := v.config.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
+
+// jsonWebKeySet is a JWKS document.
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+// jsonWebKey is a single RSA or EC public key.
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// publicKeys converts the signing keys of the set, skipping encryption and unsupported keys.
+func (s jsonWebKeySet) publicKeys() (map[string]crypto.PublicKey, error) {
+	keys := make(map[string]crypto.PublicKey, len(s.Keys))
+	for _, jwk := range s.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[jwk.Kid] = key
+	}
+
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+// publicKey decodes the key material.
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	decode := func(s string) ([]byte, error) {
+		return base64.RawURLEncoding.DecodeString(s)
+	}
+
+	switch k.Kty {
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decode(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		var validator ecdh.Curve
+		switch k.Crv {
+		case "P-256":
+			curve, validator = elliptic.P256(), ecdh.P256()
+		case "P-384":
+			curve, validator = elliptic.P384(), ecdh.P384()
+		case "P-521":
+			curve, validator = elliptic.P521(), ecdh.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		// Reject points that are not on the curve
+		size := (curve.Params().BitSize + 7) / 8
+		if len(x) != size || len(y) != size {
+			return nil, fmt.Errorf("invalid EC coordinates")
+		}
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := validator.NewPublicKey(point); err != nil {
+			return nil, fmt.Errorf("invalid EC point: %w", err)
+		}
+
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
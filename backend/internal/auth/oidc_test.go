@@ -0,0 +1,280 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testIssuer is a local OIDC issuer serving discovery and a JWKS that can be rotated.
+type testIssuer struct {
+	server   *httptest.Server
+	mu       sync.Mutex
+	keys     []jsonWebKey
+	jwksHits atomic.Int32
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+	issuer := &testIssuer{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":   issuer.server.URL,
+			"jwks_uri": issuer.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		issuer.jwksHits.Add(1)
+		issuer.mu.Lock()
+		defer issuer.mu.Unlock()
+		json.NewEncoder(w).Encode(jsonWebKeySet{Keys: issuer.keys})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) setKeys(keys ...jsonWebKey) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.keys = keys
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{Kty: "RSA", Kid: kid, Use: "sig", N: b64(key.N.Bytes()), E: b64(big.NewInt(int64(key.E)).Bytes())}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) jsonWebKey {
+	return jsonWebKey{Kty: "EC", Kid: kid, Crv: "P-256", X: b64(key.X.FillBytes(make([]byte, 32))), Y: b64(key.Y.FillBytes(make([]byte, 32)))}
+}
+
+// signToken builds a compact JWS over claims with the given algorithm and key.
+func signToken(t *testing.T, alg, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	t.Helper()
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatalf("signing: %v", err)
+		}
+		signature = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}
+	return input + "." + b64(signature)
+}
+
+func validClaims(issuer string) map[string]interface{} {
+	return map[string]interface{}{
+		"iss":                issuer,
+		"aud":                []string{"gpu-monitoring", "other"},
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"groups":             []string{"ml-team"},
+		"exp":                time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func TestOIDCVerifier(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	issuer.setKeys(rsaJWK("rsa-1", rsaKey), ecJWK("ec-1", ecKey))
+
+	verifier, err := NewOIDCVerifier(OIDCConfig{IssuerURL: issuer.server.URL, Audience: "gpu-monitoring"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		alg string
+		kid string
+		key crypto.Signer
+	}{
+		{"RS256", "rsa-1", rsaKey},
+		{"ES256", "ec-1", ecKey},
+	} {
+		principal, err := verifier.Verify(context.Background(), signToken(t, tc.alg, tc.kid, tc.key, validClaims(issuer.server.URL)))
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tc.alg, err)
+		}
+		if principal.Subject != "user-1" || principal.Name != "alice" || principal.Method != MethodOIDC ||
+			len(principal.Groups) != 1 || principal.Groups[0] != "ml-team" {
+			t.Errorf("%s: unexpected principal %+v", tc.alg, principal)
+		}
+	}
+
+	if hits := issuer.jwksHits.Load(); hits != 1 {
+		t.Errorf("expected keys to be cached after one fetch, got %d fetches", hits)
+	}
+}
+
+func TestOIDCVerifierRejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	issuer.setKeys(rsaJWK("rsa-1", rsaKey))
+
+	verifier, _ := NewOIDCVerifier(OIDCConfig{IssuerURL: issuer.server.URL, Audience: "gpu-monitoring"})
+
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := validClaims(issuer.server.URL)
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	valid := signToken(t, "RS256", "rsa-1", rsaKey, validClaims(issuer.server.URL))
+	parts := strings.Split(valid, ".")
+	unsigned := b64([]byte(`{"alg":"none","kid":"rsa-1"}`)) + "." + parts[1] + "."
+
+	tests := map[string]string{
+		"expired":         signToken(t, "RS256", "rsa-1", rsaKey, with("exp", time.Now().Add(-time.Hour).Unix())),
+		"missing exp":     signToken(t, "RS256", "rsa-1", rsaKey, with("exp", nil)),
+		"not yet valid":   signToken(t, "RS256", "rsa-1", rsaKey, with("nbf", time.Now().Add(time.Hour).Unix())),
+		"wrong audience":  signToken(t, "RS256", "rsa-1", rsaKey, with("aud", "someone-else")),
+		"wrong issuer":    signToken(t, "RS256", "rsa-1", rsaKey, with("iss", "https://evil.example")),
+		"foreign key":     signToken(t, "RS256", "rsa-1", otherKey, validClaims(issuer.server.URL)),
+		"unknown kid":     signToken(t, "RS256", "rsa-2", rsaKey, validClaims(issuer.server.URL)),
+		"alg none":        unsigned,
+		"tampered claims": parts[0] + "." + b64([]byte(`{"sub":"admin"}`)) + "." + parts[2],
+		"malformed":       "not-a-token",
+	}
+
+	for name, token := range tests {
+		if _, err := verifier.Verify(context.Background(), token); err == nil {
+			t.Errorf("%s: expected token to be rejected", name)
+		}
+	}
+}
+
+func TestOIDCVerifierKeyRotation(t *testing.T) {
+	issuer := newTestIssuer(t)
+	oldKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	newKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	issuer.setKeys(rsaJWK("old", oldKey))
+
+	verifier, _ := NewOIDCVerifier(OIDCConfig{IssuerURL: issuer.server.URL, Audience: "gpu-monitoring"})
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	if _, err := verifier.Verify(context.Background(), signToken(t, "RS256", "old", oldKey, validClaims(issuer.server.URL))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The issuer rotates to a new key; a token naming it triggers a refetch
+	issuer.setKeys(rsaJWK("new", newKey))
+	now = now.Add(minJWKSRefresh)
+	if _, err := verifier.Verify(context.Background(), signToken(t, "RS256", "new", newKey, validClaims(issuer.server.URL))); err != nil {
+		t.Fatalf("expected rotated key to be fetched: %v", err)
+	}
+
+	// Unknown key IDs do not refetch more often than minJWKSRefresh
+	hits := issuer.jwksHits.Load()
+	verifier.Verify(context.Background(), signToken(t, "RS256", "bogus", newKey, validClaims(issuer.server.URL)))
+	verifier.Verify(context.Background(), signToken(t, "RS256", "bogus", newKey, validClaims(issuer.server.URL)))
+	if got := issuer.jwksHits.Load(); got != hits {
+		t.Errorf("expected refetches to be throttled, got %d extra fetches", got-hits)
+	}
+
+	// Stale keys are refetched in the background even when the key ID is known
+	now = now.Add(defaultJWKSRefresh)
+	if _, err := verifier.Verify(context.Background(), signToken(t, "RS256", "new", newKey, validClaims(issuer.server.URL))); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	waitRefresh(t, verifier)
+	if got := issuer.jwksHits.Load(); got != hits+1 {
+		t.Errorf("expected stale keys to be refetched once, got %d fetches", got-hits)
+	}
+}
+
+// waitRefresh waits for the key refresh in flight, failing when there is none.
+func waitRefresh(t *testing.T, verifier *OIDCVerifier) {
+	t.Helper()
+	verifier.mu.Lock()
+	done := verifier.refreshing
+	verifier.mu.Unlock()
+	if done == nil {
+		t.Fatal("expected a key refresh in flight")
+	}
+	<-done
+}
+
+func TestOIDCVerifierRefreshFailure(t *testing.T) {
+	issuer := newTestIssuer(t)
+	key, _ := rsa.GenerateKey(rand.Reader, 2048)
+	issuer.setKeys(rsaJWK("k1", key))
+
+	verifier, _ := NewOIDCVerifier(OIDCConfig{IssuerURL: issuer.server.URL, Audience: "gpu-monitoring"})
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+	token := signToken(t, "RS256", "k1", key, validClaims(issuer.server.URL))
+
+	// A cancelled request does not abort the fetch other callers depend on
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := verifier.Verify(cancelled, token); err == nil {
+		t.Error("expected the cancelled request to fail")
+	}
+	waitRefresh(t, verifier)
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected the keys fetched for the cancelled request: %v", err)
+	}
+
+	// The issuer breaks; stale keys keep being served while refreshes fail
+	issuer.setKeys()
+	now = now.Add(defaultJWKSRefresh)
+	hits := issuer.jwksHits.Load()
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected stale keys to be served: %v", err)
+	}
+	waitRefresh(t, verifier)
+	if _, err := verifier.Verify(context.Background(), token); err != nil {
+		t.Fatalf("expected stale keys to survive a failed refresh: %v", err)
+	}
+
+	// Failed refreshes of stale keys are throttled too
+	if got := issuer.jwksHits.Load(); got != hits+1 {
+		t.Errorf("expected one refetch, got %d", got-hits)
+	}
+	now = now.Add(minJWKSRefresh)
+	verifier.Verify(context.Background(), token)
+	waitRefresh(t, verifier)
+	if got := issuer.jwksHits.Load(); got != hits+2 {
+		t.Errorf("expected a refetch after minJWKSRefresh, got %d", got-hits)
+	}
+}
+
+func TestNewOIDCVerifierRequiresAudience(t *testing.T) {
+	if _, err := NewOIDCVerifier(OIDCConfig{IssuerURL: "https://issuer.example"}); err == nil {
+		t.Error("expected error without audience")
+	}
+}
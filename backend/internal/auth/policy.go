@@ -0,0 +1,169 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// PrincipalSet matches principals by OIDC group, by OIDC subject, or by API key
+// name. Subjects and API key names are separate namespaces, so an OIDC subject
+// never matches an API key entry or the other way around.
+type PrincipalSet struct {
+	Groups   []string `json:"groups,omitempty"`
+	Subjects []string `json:"subjects,omitempty"`
+	APIKeys  []string `json:"api_keys,omitempty"`
+}
+
+// matches reports whether p is one of the listed subjects or API keys, or belongs to a listed group.
+func (s PrincipalSet) matches(p *Principal) bool {
+	names := s.Subjects
+	if p.Method == MethodAPIKey {
+		names = s.APIKeys
+	} else if p.Method != MethodOIDC {
+		names = nil
+	}
+	for _, name := range names {
+		if name == p.Subject {
+			return true
+		}
+	}
+	for _, group := range s.Groups {
+		for _, g := range p.Groups {
+			if g == group {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Binding grants matching principals the GPUs of Namespaces and Nodes.
+type Binding struct {
+	PrincipalSet
+	Namespaces []string `json:"namespaces,omitempty"`
+	Nodes      []string `json:"nodes,omitempty"`
+}
+
+// Policy maps principals to the GPUs they may see. Admins see everything;
+// principals matching no binding are denied.
+type Policy struct {
+	Admins   PrincipalSet `json:"admins"`
+	Bindings []Binding    `json:"bindings"`
+}
+
+// Clause grants the GPUs allocated to pods in one of Namespaces and on one of
+// Nodes. An empty field does not restrict.
+type Clause struct {
+	Namespaces []string
+	Nodes      []string
+}
+
+// Grant is the view a principal is authorized for: the GPUs matching any of Clauses.
+type Grant struct {
+	Admin   bool
+	Clauses []Clause
+}
+
+// LoadPolicy reads an authorization policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading authorization policy: %w", err)
+	}
+
+	var policy Policy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("parsing authorization policy: %w", err)
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// Validate rejects bindings that would match nobody or restrict nothing.
+func (p *Policy) Validate() error {
+	for i, b := range p.Bindings {
+		if len(b.Groups) == 0 && len(b.Subjects) == 0 && len(b.APIKeys) == 0 {
+			return fmt.Errorf("binding %d: groups, subjects or api_keys are required", i)
+		}
+		if len(b.Namespaces) == 0 && len(b.Nodes) == 0 {
+			return fmt.Errorf("binding %d: namespaces or nodes are required; use admins for a full view", i)
+		}
+	}
+	return nil
+}
+
+// Grant returns the view authorized for p, or false if p may see nothing.
+// Every matching binding adds a clause, so the principal sees the union of
+// what the bindings grant. Bindings restricting only namespaces, or only
+// nodes, are folded into a single clause.
+func (p *Policy) Grant(principal *Principal) (Grant, bool) {
+	if p.Admins.matches(principal) {
+		return Grant{Admin: true}, true
+	}
+
+	var grant Grant
+	matched := false
+	for _, b := range p.Bindings {
+		if !b.matches(principal) {
+			continue
+		}
+		matched = true
+		grant.add(Clause{Namespaces: b.Namespaces, Nodes: b.Nodes})
+	}
+	return grant, matched
+}
+
+// add joins c to the grant's clauses, folding it into a clause restricting the
+// same kind of field when it only restricts one.
+func (g *Grant) add(c Clause) {
+	for i := range g.Clauses {
+		existing := &g.Clauses[i]
+		switch {
+		case len(c.Nodes) == 0 && len(existing.Nodes) == 0:
+			existing.Namespaces = appendUnique(existing.Namespaces, c.Namespaces...)
+			return
+		case len(c.Namespaces) == 0 && len(existing.Namespaces) == 0:
+			existing.Nodes = appendUnique(existing.Nodes, c.Nodes...)
+			return
+		}
+	}
+	g.Clauses = append(g.Clauses, Clause{
+		Namespaces: appendUnique(nil, c.Namespaces...),
+		Nodes:      appendUnique(nil, c.Nodes...),
+	})
+}
+
+// appendUnique appends the values not already present in list.
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		found := false
+		for _, existing := range list {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// grantKey stores the Grant in a request context.
+type grantKey struct{}
+
+// WithGrant returns a context carrying g.
+func WithGrant(ctx context.Context, g Grant) context.Context {
+	return context.WithValue(ctx, grantKey{}, g)
+}
+
+// GrantFrom returns the grant attached to ctx, if any.
+func GrantFrom(ctx context.Context) (Grant, bool) {
+	g, ok := ctx.Value(grantKey{}).(Grant)
+	return g, ok
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestPolicyGrant(t *testing.T) {
+	policy := &Policy{
+		Admins: PrincipalSet{Groups: []string{"gpu-admins"}, APIKeys: []string{"ci"}},
+		Bindings: []Binding{
+			{PrincipalSet: PrincipalSet{Groups: []string{"ml-team"}}, Namespaces: []string{"ml-train"}},
+			{PrincipalSet: PrincipalSet{Groups: []string{"ml-team"}, Subjects: []string{"ml-bot"}}, Namespaces: []string{"ml-serve", "ml-train"}},
+			{PrincipalSet: PrincipalSet{APIKeys: []string{"lab-bot"}, Groups: []string{"lab-users"}}, Nodes: []string{"lab-node-1"}},
+			{PrincipalSet: PrincipalSet{Groups: []string{"lab-users"}}, Nodes: []string{"lab-node-2"}},
+			{PrincipalSet: PrincipalSet{Groups: []string{"research"}}, Namespaces: []string{"research"}, Nodes: []string{"lab-node-2"}},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		want      Grant
+		wantOK    bool
+	}{
+		{"admin group", Principal{Subject: "u1", Groups: []string{"gpu-admins"}}, Grant{Admin: true}, true},
+		{"admin api key", Principal{Subject: "ci", Method: MethodAPIKey}, Grant{Admin: true}, true},
+		{"merged namespace bindings", Principal{Subject: "u2", Groups: []string{"ml-team"}}, Grant{Clauses: []Clause{{Namespaces: []string{"ml-train", "ml-serve"}}}}, true},
+		{"subject binding", Principal{Subject: "ml-bot", Method: MethodOIDC}, Grant{Clauses: []Clause{{Namespaces: []string{"ml-serve", "ml-train"}}}}, true},
+		{"api key binding", Principal{Subject: "lab-bot", Method: MethodAPIKey}, Grant{Clauses: []Clause{{Nodes: []string{"lab-node-1"}}}}, true},
+		{"oidc subject named like an api key", Principal{Subject: "lab-bot", Method: MethodOIDC}, Grant{}, false},
+		{"oidc subject named like an admin api key", Principal{Subject: "ci", Method: MethodOIDC}, Grant{}, false},
+		{"api key named like an oidc subject", Principal{Subject: "ml-bot", Method: MethodAPIKey}, Grant{}, false},
+		{"merged node bindings", Principal{Subject: "u4", Groups: []string{"lab-users"}}, Grant{Clauses: []Clause{{Nodes: []string{"lab-node-1", "lab-node-2"}}}}, true},
+		{
+			"namespace and node bindings", Principal{Subject: "u5", Groups: []string{"ml-team", "lab-users"}},
+			Grant{Clauses: []Clause{{Namespaces: []string{"ml-train", "ml-serve"}}, {Nodes: []string{"lab-node-1", "lab-node-2"}}}}, true,
+		},
+		{
+			"namespace on node binding", Principal{Subject: "u6", Groups: []string{"research", "ml-team"}},
+			Grant{Clauses: []Clause{{Namespaces: []string{"ml-train", "ml-serve"}}, {Namespaces: []string{"research"}, Nodes: []string{"lab-node-2"}}}}, true,
+		},
+		{"no binding", Principal{Subject: "u3", Groups: []string{"finance"}}, Grant{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := policy.Grant(&tt.principal)
+		if ok != tt.wantOK || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, %v; want %+v, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+
+	valid := filepath.Join(dir, "policy.json")
+	os.WriteFile(valid, []byte(`{
+  "admins": {"groups": ["gpu-admins"]},
+  "bindings": [{"groups": ["ml-team"], "namespaces": ["ml"]}]
+}`), 0o644)
+	policy, err := LoadPolicy(valid)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if grant, ok := policy.Grant(&Principal{Groups: []string{"ml-team"}}); !ok || len(grant.Clauses) != 1 || len(grant.Clauses[0].Namespaces) != 1 {
+		t.Errorf("unexpected grant: %+v", grant)
+	}
+
+	for i, body := range []string{
+		`{"bindings": [{"namespaces": ["ml"]}]}`,
+		`{"bindings": [{"groups": ["ml-team"]}]}`,
+		`{"bindings": "nope"}`,
+	} {
+		path := filepath.Join(dir, "invalid.json")
+		os.WriteFile(path, []byte(body), 0o644)
+		if _, err := LoadPolicy(path); err == nil {
+			t.Errorf("policy %d: expected error", i)
+		}
+	}
+}
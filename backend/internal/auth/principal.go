@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// Authentication methods reported in Principal.Method.
+const (
+	MethodOIDC   = "oidc"
+	MethodAPIKey = "api_key"
+)
+
+var (
+	// ErrNoCredentials is returned when a request carries neither a bearer token nor an API key.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials is returned when the presented credentials are not accepted.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Subject string   `json:"subject"`
+	Name    string   `json:"name,omitempty"`
+	Groups  []string `json:"groups,omitempty"`
+	Method  string   `json:"method"`
+}
+
+// principalKey stores the Principal in a request context.
+type principalKey struct{}
+
+// WithPrincipal returns a context carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal attached to ctx, if any.
+func PrincipalFrom(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats holds cache counters.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Coalesced uint64 `json:"coalesced"`
+	Stale     uint64 `json:"stale"`
+	Bypassed  uint64 `json:"bypassed"`
+	Entries   int    `json:"entries"`
+}
+
+// Cache is a TTL cache that coalesces concurrent loads of the same key and
+// serves stale values when a reload fails.
+type Cache[V any] struct {
+	ttl      time.Duration
+	staleTTL time.Duration
+	// fetchTimeout bounds loads, which run detached from any single caller's context.
+	fetchTimeout time.Duration
+	now          func() time.Time
+
+	mu       sync.Mutex
+	entries  map[string]entry[V]
+	inflight map[string]*call[V]
+
+	hits, misses, coalesced, stale, bypassed atomic.Uint64
+}
+
+// entry is a cached value and the time it was stored.
+type entry[V any] struct {
+	value    V
+	storedAt time.Time
+}
+
+// call is an in-flight load shared by every caller of the same key.
+type call[V any] struct {
+	done  chan struct{}
+	value V
+	err   error
+}
+
+// New creates a cache whose entries are fresh for ttl and may be served for a
+// further staleTTL when reloading fails.
+func New[V any](ttl, staleTTL, fetchTimeout time.Duration) *Cache[V] {
+	return &Cache[V]{
+		ttl:          ttl,
+		staleTTL:     staleTTL,
+		fetchTimeout: fetchTimeout,
+		now:          time.Now,
+		entries:      make(map[string]entry[V]),
+		inflight:     make(map[string]*call[V]),
+	}
+}
+
+// Get returns the cached value for key, loading it with fetch when missing or expired.
+// Concurrent callers for the same key share a single fetch.
+func (c *Cache[V]) Get(ctx context.Context, key string, fetch func(ctx context.Context) (V, error)) (V, error) {
+	bypass := Bypassed(ctx)
+
+	c.mu.Lock()
+	cached, found := c.entries[key]
+	if found && !bypass && c.now().Sub(cached.storedAt) < c.ttl {
+		c.mu.Unlock()
+		c.hits.Add(1)
+		return cached.value, nil
+	}
+
+	if bypass {
+		c.bypassed.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+
+	inflight, shared := c.inflight[key]
+	if shared {
+		c.coalesced.Add(1)
+	} else {
+		inflight = &call[V]{done: make(chan struct{})}
+		c.inflight[key] = inflight
+		go c.load(ctx, key, inflight, fetch)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-inflight.done:
+	case <-ctx.Done():
+		var zero V
+		return zero, ctx.Err()
+	}
+
+	if inflight.err == nil {
+		return inflight.value, nil
+	}
+
+	// Serve the last good value while the upstream is failing, unless the caller asked for fresh data
+	if bypass {
+		var zero V
+		return zero, inflight.err
+	}
+
+	c.mu.Lock()
+	cached, found = c.entries[key]
+	c.mu.Unlock()
+	if found && c.now().Sub(cached.storedAt) < c.ttl+c.staleTTL {
+		c.stale.Add(1)
+		return cached.value, nil
+	}
+
+	var zero V
+	return zero, inflight.err
+}
+
+// load runs fetch for key and publishes the result to all waiters.
+func (c *Cache[V]) load(ctx context.Context, key string, inflight *call[V], fetch func(ctx context.Context) (V, error)) {
+	// Detach from the caller so its cancellation does not fail the other waiters
+	fetchCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), c.fetchTimeout)
+	defer cancel()
+
+	inflight.value, inflight.err = fetch(fetchCtx)
+
+	c.mu.Lock()
+	now := c.now()
+	if inflight.err == nil {
+		c.entries[key] = entry[V]{value: inflight.value, storedAt: now}
+	}
+	delete(c.inflight, key)
+
+	// Drop entries too old to be served even as stale values
+	for k, e := range c.entries {
+		if now.Sub(e.storedAt) >= c.ttl+c.staleTTL {
+			delete(c.entries, k)
+		}
+	}
+	c.mu.Unlock()
+
+	close(inflight.done)
+}
+
+// Stats returns a snapshot of the cache counters.
+func (c *Cache[V]) Stats() Stats {
+	c.mu.Lock()
+	entries := len(c.entries)
+	c.mu.Unlock()
+
+	return Stats{
+		Hits:      c.hits.Load(),
+		Misses:    c.misses.Load(),
+		Coalesced: c.coalesced.Load(),
+		Stale:     c.stale.Load(),
+		Bypassed:  c.bypassed.Load(),
+		Entries:   entries,
+	}
+}
+
+// bypassKey marks contexts whose lookups must skip cached values.
+type bypassKey struct{}
+
+// WithBypass returns a context whose lookups always reload, refreshing the cache.
+func WithBypass(ctx context.Context) context.Context {
+	return context.WithValue(ctx, bypassKey{}, true)
+}
+
+// Bypassed reports whether ctx was marked with WithBypass.
+func Bypassed(ctx context.Context) bool {
+	bypass, _ := ctx.Value(bypassKey{}).(bool)
+	return bypass
+}
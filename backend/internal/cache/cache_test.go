@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced time source.
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.now
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = f.now.Add(d)
+}
+
+func newTestCache(ttl, staleTTL time.Duration) (*Cache[int], *fakeClock) {
+	clock := &fakeClock{now: time.Unix(1700000000, 0)}
+	c := New[int](ttl, staleTTL, time.Second)
+	c.now = clock.Now
+	return c, clock
+}
+
+func TestGetCachesUntilTTL(t *testing.T) {
+	c, clock := newTestCache(10*time.Second, time.Minute)
+	var calls int
+	fetch := func(ctx context.Context) (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if v, err := c.Get(context.Background(), "k", fetch); err != nil || v != 1 {
+			t.Fatalf("expected cached value 1, got %d (%v)", v, err)
+		}
+	}
+
+	clock.Advance(10 * time.Second)
+	if v, _ := c.Get(context.Background(), "k", fetch); v != 2 {
+		t.Errorf("expected reload after TTL, got %d", v)
+	}
+
+	stats := c.Stats()
+	if stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestGetCoalescesConcurrentLoads(t *testing.T) {
+	c, _ := newTestCache(time.Minute, time.Minute)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	const callers = 20
+	var wg sync.WaitGroup
+	results := make(chan int, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, _ := c.Get(context.Background(), "k", fetch)
+			results <- v
+		}()
+	}
+
+	// Wait until every caller has joined the in-flight load
+	for c.Stats().Misses < callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	close(results)
+
+	for v := range results {
+		if v != 42 {
+			t.Errorf("expected 42, got %d", v)
+		}
+	}
+	if calls.Load() != 1 {
+		t.Errorf("expected 1 fetch, got %d", calls.Load())
+	}
+	if got := c.Stats().Coalesced; got != callers-1 {
+		t.Errorf("expected %d coalesced callers, got %d", callers-1, got)
+	}
+}
+
+func TestGetServesStaleOnError(t *testing.T) {
+	c, clock := newTestCache(10*time.Second, time.Minute)
+	upstreamErr := errors.New("prometheus down")
+
+	c.Get(context.Background(), "k", func(ctx context.Context) (int, error) { return 7, nil })
+	failing := func(ctx context.Context) (int, error) { return 0, upstreamErr }
+
+	clock.Advance(30 * time.Second)
+	if v, err := c.Get(context.Background(), "k", failing); err != nil || v != 7 {
+		t.Errorf("expected stale value 7, got %d (%v)", v, err)
+	}
+
+	if _, err := c.Get(WithBypass(context.Background()), "k", failing); !errors.Is(err, upstreamErr) {
+		t.Errorf("expected bypass to surface upstream error, got %v", err)
+	}
+
+	clock.Advance(time.Minute)
+	if _, err := c.Get(context.Background(), "k", failing); !errors.Is(err, upstreamErr) {
+		t.Errorf("expected error beyond stale window, got %v", err)
+	}
+
+	if got := c.Stats().Stale; got != 1 {
+		t.Errorf("expected 1 stale response, got %d", got)
+	}
+}
+
+func TestGetBypassRefreshes(t *testing.T) {
+	c, _ := newTestCache(time.Minute, time.Minute)
+	var calls int
+	fetch := func(ctx context.Context) (int, error) {
+		calls++
+		return calls, nil
+	}
+
+	c.Get(context.Background(), "k", fetch)
+	if v, _ := c.Get(WithBypass(context.Background()), "k", fetch); v != 2 {
+		t.Errorf("expected bypass to reload, got %d", v)
+	}
+	if v, _ := c.Get(context.Background(), "k", fetch); v != 2 {
+		t.Errorf("expected bypass result to refresh cache, got %d", v)
+	}
+	if got := c.Stats().Bypassed; got != 1 {
+		t.Errorf("expected 1 bypassed lookup, got %d", got)
+	}
+}
+
+func TestGetCallerCancellationDoesNotFailOthers(t *testing.T) {
+	c, _ := newTestCache(time.Minute, time.Minute)
+	release := make(chan struct{})
+	fetch := func(ctx context.Context) (int, error) {
+		<-release
+		return 1, ctx.Err()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := c.Get(ctx, "k", fetch)
+		leaderErr <- err
+	}()
+	for c.Stats().Misses < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	followerResult := make(chan int, 1)
+	go func() {
+		v, _ := c.Get(context.Background(), "k", fetch)
+		followerResult <- v
+	}()
+	for c.Stats().Coalesced < 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected leader to observe cancellation, got %v", err)
+	}
+
+	close(release)
+	if v := <-followerResult; v != 1 {
+		t.Errorf("expected follower to receive value, got %d", v)
+	}
+}
@@ -0,0 +1,749 @@
+package fakeprom
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// labels are the labels of a series, including __name__.
+type labels map[string]string
+
+// copy returns a copy of l without the named labels.
+func (l labels) copy(drop ...string) labels {
+	c := make(labels, len(l))
+	for name, value := range l {
+		c[name] = value
+	}
+	for _, name := range drop {
+		delete(c, name)
+	}
+	return c
+}
+
+// signature identifies l by the named labels when on is true, and otherwise by
+// every label except the named ones and __name__.
+func (l labels) signature(names []string, on bool) string {
+	var keys []string
+	if on {
+		keys = append(keys, names...)
+	} else {
+		for name := range l {
+			if name != "__name__" && !contains(names, name) {
+				keys = append(keys, name)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(key + "\xff" + l[key] + "\xff")
+	}
+	return b.String()
+}
+
+// key identifies l by all its labels, giving series a stable order.
+func (l labels) key() string {
+	return l.signature(nil, false) + "\xff" + l["__name__"]
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+// series is a synthetic time series, sampled at every scrape.
+type series struct {
+	labels labels
+	value  func(t time.Time) float64
+}
+
+// value is the result of evaluating an expression: a scalar, vector or matrix.
+type value interface{}
+
+type (
+	scalar float64
+	sample struct {
+		labels labels
+		value  float64
+	}
+	vector []sample
+	point  struct {
+		t     time.Time
+		value float64
+	}
+	rangeSeries struct {
+		labels labels
+		points []point
+	}
+	matrix struct {
+		window time.Duration
+		series []rangeSeries
+	}
+)
+
+// evaluator evaluates parsed queries against a fixed set of series.
+type evaluator struct {
+	series   []series
+	interval time.Duration
+}
+
+// align returns the latest scrape time at or before t.
+func (ev *evaluator) align(t time.Time) time.Time {
+	ns := t.UnixNano()
+	return time.Unix(0, ns-ns%int64(ev.interval))
+}
+
+// selects reports whether every matcher of sel accepts the series labels.
+func selects(sel selectorNode, l labels) bool {
+	for _, m := range sel.matchers {
+		if !m.matches(l[m.name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// eval evaluates n at t.
+func (ev *evaluator) eval(n node, t time.Time) (value, error) {
+	switch n := n.(type) {
+	case numberLit:
+		return scalar(n.value), nil
+
+	case selectorNode:
+		at := ev.align(t)
+		var v vector
+		for _, s := range ev.series {
+			if selects(n, s.labels) {
+				v = append(v, sample{labels: s.labels, value: s.value(at)})
+			}
+		}
+		return v, nil
+
+	case matrixNode:
+		m := matrix{window: n.window}
+		for _, s := range ev.series {
+			if !selects(n.selector, s.labels) {
+				continue
+			}
+			rs := rangeSeries{labels: s.labels}
+			for at := ev.align(t); at.After(t.Add(-n.window)); at = at.Add(-ev.interval) {
+				rs.points = append(rs.points, point{t: at, value: s.value(at)})
+			}
+			slices.Reverse(rs.points)
+			m.series = append(m.series, rs)
+		}
+		return m, nil
+
+	case negNode:
+		v, err := ev.eval(n.expr, t)
+		if err != nil {
+			return nil, err
+		}
+		return ev.binary(&binaryNode{op: "*"}, v, scalar(-1))
+
+	case callNode:
+		args := make([]value, len(n.args))
+		for i, arg := range n.args {
+			v, err := ev.eval(arg, t)
+			if err != nil {
+				return nil, err
+			}
+			args[i] = v
+		}
+		v, err := functions[n.fn](args, t)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", n.fn, err)
+		}
+		return v, nil
+
+	case aggregateNode:
+		return ev.aggregate(n, t)
+
+	case *binaryNode:
+		lhs, err := ev.eval(n.lhs, t)
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := ev.eval(n.rhs, t)
+		if err != nil {
+			return nil, err
+		}
+		return ev.binary(n, lhs, rhs)
+	}
+	return nil, fmt.Errorf("unsupported expression %T", n)
+}
+
+// function evaluates a call on its evaluated arguments.
+type function func(args []value, t time.Time) (value, error)
+
+// functions are the supported PromQL functions.
+var functions = map[string]function{
+	"rate": rangeFunction(func(points []point, window time.Duration) float64 {
+		return extrapolate(points, window, true) / window.Seconds()
+	}),
+	"increase": rangeFunction(func(points []point, window time.Duration) float64 {
+		return extrapolate(points, window, true)
+	}),
+	"delta": rangeFunction(func(points []point, window time.Duration) float64 {
+		return extrapolate(points, window, false)
+	}),
+	"avg_over_time": rangeFunction(func(points []point, _ time.Duration) float64 {
+		return sum(points) / float64(len(points))
+	}),
+	"sum_over_time": rangeFunction(func(points []point, _ time.Duration) float64 {
+		return sum(points)
+	}),
+	"count_over_time": rangeFunction(func(points []point, _ time.Duration) float64 {
+		return float64(len(points))
+	}),
+	"min_over_time": rangeFunction(func(points []point, _ time.Duration) float64 {
+		return reduce(points, math.Min)
+	}),
+	"max_over_time": rangeFunction(func(points []point, _ time.Duration) float64 {
+		return reduce(points, math.Max)
+	}),
+	"last_over_time": rangeFunction(func(points []point, _ time.Duration) float64 {
+		return points[len(points)-1].value
+	}),
+	"quantile_over_time": quantileOverTime,
+	"abs":                mathFunction(math.Abs),
+	"ceil":               mathFunction(math.Ceil),
+	"floor":              mathFunction(math.Floor),
+	"clamp_min":          clampFunction(math.Max),
+	"clamp_max":          clampFunction(math.Min),
+	"scalar":             toScalar,
+	"vector":             toVector,
+	"time": func(args []value, t time.Time) (value, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("expected no arguments")
+		}
+		return scalar(float64(t.UnixMilli()) / 1000), nil
+	},
+}
+
+// rangeFunction applies fn to the points of every series of its range vector
+// argument, dropping the metric name.
+func rangeFunction(fn func(points []point, window time.Duration) float64) function {
+	return func(args []value, _ time.Time) (value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		m, ok := args[0].(matrix)
+		if !ok {
+			return nil, fmt.Errorf("expected range vector argument")
+		}
+		return applyRange(m, func(points []point) float64 { return fn(points, m.window) }), nil
+	}
+}
+
+func applyRange(m matrix, fn func(points []point) float64) vector {
+	var v vector
+	for _, s := range m.series {
+		if len(s.points) == 0 {
+			continue
+		}
+		v = append(v, sample{labels: s.labels.copy("__name__"), value: fn(s.points)})
+	}
+	return v
+}
+
+func quantileOverTime(args []value, _ time.Time) (value, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+	phi, ok := args[0].(scalar)
+	if !ok {
+		return nil, fmt.Errorf("expected scalar quantile")
+	}
+	m, ok := args[1].(matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected range vector argument")
+	}
+	return applyRange(m, func(points []point) float64 {
+		values := make([]float64, len(points))
+		for i, p := range points {
+			values[i] = p.value
+		}
+		return quantile(float64(phi), values)
+	}), nil
+}
+
+// mathFunction applies fn to every sample of its vector argument, dropping the metric name.
+func mathFunction(fn func(float64) float64) function {
+	return func(args []value, _ time.Time) (value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		v, ok := args[0].(vector)
+		if !ok {
+			return nil, fmt.Errorf("expected instant vector argument")
+		}
+		out := make(vector, len(v))
+		for i, s := range v {
+			out[i] = sample{labels: s.labels.copy("__name__"), value: fn(s.value)}
+		}
+		return out, nil
+	}
+}
+
+// clampFunction bounds every sample of its vector argument by a scalar.
+func clampFunction(bound func(float64, float64) float64) function {
+	return func(args []value, t time.Time) (value, error) {
+		if len(args) != 2 {
+			return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		limit, ok := args[1].(scalar)
+		if !ok {
+			return nil, fmt.Errorf("expected scalar bound")
+		}
+		return mathFunction(func(f float64) float64 { return bound(f, float64(limit)) })(args[:1], t)
+	}
+}
+
+func toScalar(args []value, _ time.Time) (value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	v, ok := args[0].(vector)
+	if !ok {
+		return nil, fmt.Errorf("expected instant vector argument")
+	}
+	if len(v) != 1 {
+		return scalar(math.NaN()), nil
+	}
+	return scalar(v[0].value), nil
+}
+
+func toVector(args []value, _ time.Time) (value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	s, ok := args[0].(scalar)
+	if !ok {
+		return nil, fmt.Errorf("expected scalar argument")
+	}
+	return vector{{labels: labels{}, value: float64(s)}}, nil
+}
+
+// extrapolate returns the change across points scaled to the whole window. For
+// counters, a decrease is taken as a reset to zero.
+func extrapolate(points []point, window time.Duration, counter bool) float64 {
+	if len(points) < 2 {
+		return math.NaN()
+	}
+
+	change := points[len(points)-1].value - points[0].value
+	if counter {
+		change = 0
+		for i := 1; i < len(points); i++ {
+			if d := points[i].value - points[i-1].value; d >= 0 {
+				change += d
+			} else {
+				change += points[i].value
+			}
+		}
+	}
+
+	sampled := points[len(points)-1].t.Sub(points[0].t)
+	return change * window.Seconds() / sampled.Seconds()
+}
+
+func sum(points []point) float64 {
+	var total float64
+	for _, p := range points {
+		total += p.value
+	}
+	return total
+}
+
+func reduce(points []point, fn func(float64, float64) float64) float64 {
+	result := points[0].value
+	for _, p := range points[1:] {
+		result = fn(result, p.value)
+	}
+	return result
+}
+
+// quantile returns the phi-quantile of values, interpolating between ranks as Prometheus does.
+func quantile(phi float64, values []float64) float64 {
+	switch {
+	case len(values) == 0 || math.IsNaN(phi):
+		return math.NaN()
+	case phi < 0:
+		return math.Inf(-1)
+	case phi > 1:
+		return math.Inf(1)
+	}
+
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	rank := phi * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	upper := int(math.Min(float64(lower+1), float64(len(sorted)-1)))
+	weight := rank - float64(lower)
+	return sorted[lower]*(1-weight) + sorted[upper]*weight
+}
+
+// aggregate evaluates an aggregation over the groups of its operand.
+func (ev *evaluator) aggregate(n aggregateNode, t time.Time) (value, error) {
+	operand, err := ev.eval(n.expr, t)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := operand.(vector)
+	if !ok {
+		return nil, fmt.Errorf("%s: expected instant vector", n.op)
+	}
+
+	var param float64
+	if n.param != nil {
+		p, err := ev.eval(n.param, t)
+		if err != nil {
+			return nil, err
+		}
+		s, ok := p.(scalar)
+		if !ok {
+			return nil, fmt.Errorf("%s: expected scalar parameter", n.op)
+		}
+		param = float64(s)
+	}
+
+	type group struct {
+		labels  labels
+		samples vector
+	}
+	groups := make(map[string]*group)
+	var order []string
+	for _, s := range v {
+		key := s.labels.signature(n.grouping, !n.without)
+		g := groups[key]
+		if g == nil {
+			g = &group{}
+			if n.without {
+				g.labels = s.labels.copy(append([]string{"__name__"}, n.grouping...)...)
+			} else {
+				g.labels = labels{}
+				for _, name := range n.grouping {
+					if value := s.labels[name]; value != "" {
+						g.labels[name] = value
+					}
+				}
+			}
+			groups[key] = g
+			order = append(order, key)
+		}
+		g.samples = append(g.samples, s)
+	}
+
+	var out vector
+	for _, key := range order {
+		g := groups[key]
+		values := make([]float64, len(g.samples))
+		for i, s := range g.samples {
+			values[i] = s.value
+		}
+
+		switch n.op {
+		case "topk", "bottomk":
+			sorted := append(vector(nil), g.samples...)
+			sort.SliceStable(sorted, func(i, j int) bool {
+				if n.op == "topk" {
+					return sorted[i].value > sorted[j].value
+				}
+				return sorted[i].value < sorted[j].value
+			})
+			k := int(math.Min(param, float64(len(sorted))))
+			out = append(out, sorted[:max(k, 0)]...)
+			continue
+		}
+
+		var result float64
+		switch n.op {
+		case "sum":
+			for _, value := range values {
+				result += value
+			}
+		case "avg":
+			for _, value := range values {
+				result += value
+			}
+			result /= float64(len(values))
+		case "count":
+			result = float64(len(values))
+		case "group":
+			result = 1
+		case "min":
+			result = values[0]
+			for _, value := range values[1:] {
+				result = math.Min(result, value)
+			}
+		case "max":
+			result = values[0]
+			for _, value := range values[1:] {
+				result = math.Max(result, value)
+			}
+		case "quantile":
+			result = quantile(param, values)
+		}
+		out = append(out, sample{labels: g.labels, value: result})
+	}
+	return out, nil
+}
+
+// comparisons are the comparison operators, which filter unless given bool.
+var comparisons = map[string]bool{"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true}
+
+// apply computes lhs op rhs. For comparisons it returns whether the comparison
+// holds, with the value of lhs.
+func apply(op string, lhs, rhs float64) (float64, bool) {
+	switch op {
+	case "+":
+		return lhs + rhs, true
+	case "-":
+		return lhs - rhs, true
+	case "*":
+		return lhs * rhs, true
+	case "/":
+		return lhs / rhs, true
+	case "%":
+		return math.Mod(lhs, rhs), true
+	case "^":
+		return math.Pow(lhs, rhs), true
+	case "atan2":
+		return math.Atan2(lhs, rhs), true
+	case "==":
+		return lhs, lhs == rhs
+	case "!=":
+		return lhs, lhs != rhs
+	case "<":
+		return lhs, lhs < rhs
+	case "<=":
+		return lhs, lhs <= rhs
+	case ">":
+		return lhs, lhs > rhs
+	case ">=":
+		return lhs, lhs >= rhs
+	}
+	return math.NaN(), false
+}
+
+// binary evaluates a binary operation on evaluated operands.
+func (ev *evaluator) binary(n *binaryNode, lhs, rhs value) (value, error) {
+	if _, ok := lhs.(matrix); ok {
+		return nil, fmt.Errorf("binary %s: range vector operand", n.op)
+	}
+	if _, ok := rhs.(matrix); ok {
+		return nil, fmt.Errorf("binary %s: range vector operand", n.op)
+	}
+
+	ls, lScalar := lhs.(scalar)
+	rs, rScalar := rhs.(scalar)
+	switch {
+	case lScalar && rScalar:
+		if comparisons[n.op] && !n.returnBool {
+			return nil, fmt.Errorf("comparisons between scalars must use the bool modifier")
+		}
+		result, ok := apply(n.op, float64(ls), float64(rs))
+		if comparisons[n.op] {
+			result = boolValue(ok)
+		}
+		return scalar(result), nil
+	case lScalar:
+		return ev.vectorScalar(n, rhs.(vector), float64(ls), true), nil
+	case rScalar:
+		return ev.vectorScalar(n, lhs.(vector), float64(rs), false), nil
+	}
+	return ev.vectorVector(n, lhs.(vector), rhs.(vector))
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// vectorScalar applies the operation between each sample of v and s. A filtering
+// comparison keeps the sample value even when the scalar is on the left.
+func (ev *evaluator) vectorScalar(n *binaryNode, v vector, s float64, scalarLeft bool) vector {
+	var out vector
+	for _, smp := range v {
+		lhs, rhs := smp.value, s
+		if scalarLeft {
+			lhs, rhs = rhs, lhs
+		}
+		result, keep := apply(n.op, lhs, rhs)
+
+		l := smp.labels
+		switch {
+		case !comparisons[n.op]:
+			l = l.copy("__name__")
+		case n.returnBool:
+			result, l = boolValue(keep), l.copy("__name__")
+		case !keep:
+			continue
+		default:
+			result = smp.value
+		}
+		out = append(out, sample{labels: l, value: result})
+	}
+	return out
+}
+
+// vectorVector applies the operation between matching samples of lhs and rhs.
+func (ev *evaluator) vectorVector(n *binaryNode, lhs, rhs vector) (value, error) {
+	m := n.matching
+	if m == nil {
+		m = &vectorMatching{}
+	}
+
+	switch n.op {
+	case "and", "or", "unless":
+		rightSigs := make(map[string]bool, len(rhs))
+		for _, s := range rhs {
+			rightSigs[s.labels.signature(m.labels, m.on)] = true
+		}
+		var out vector
+		leftSigs := make(map[string]bool, len(lhs))
+		for _, s := range lhs {
+			sig := s.labels.signature(m.labels, m.on)
+			leftSigs[sig] = true
+			switch {
+			case n.op == "or", n.op == "and" && rightSigs[sig], n.op == "unless" && !rightSigs[sig]:
+				out = append(out, s)
+			}
+		}
+		if n.op == "or" {
+			for _, s := range rhs {
+				if !leftSigs[s.labels.signature(m.labels, m.on)] {
+					out = append(out, s)
+				}
+			}
+		}
+		return out, nil
+	}
+
+	// Match the "many" side against the "one" side, swapping for group_right
+	many, one := lhs, rhs
+	if m.card == "right" {
+		many, one = rhs, lhs
+	}
+
+	oneBySig := make(map[string]sample, len(one))
+	for _, s := range one {
+		sig := s.labels.signature(m.labels, m.on)
+		if _, dup := oneBySig[sig]; dup {
+			return nil, fmt.Errorf("found duplicate series for the match group on the one side of %s", n.op)
+		}
+		oneBySig[sig] = s
+	}
+
+	var out vector
+	seen := make(map[string]bool)
+	for _, s := range many {
+		sig := s.labels.signature(m.labels, m.on)
+		match, ok := oneBySig[sig]
+		if !ok {
+			continue
+		}
+
+		l, r := s, match
+		if m.card == "right" {
+			l, r = r, l
+		}
+		result, keep := apply(n.op, l.value, r.value)
+		if comparisons[n.op] {
+			if n.returnBool {
+				result = boolValue(keep)
+			} else if !keep {
+				continue
+			}
+		}
+
+		resultLabels := s.labels.copy()
+		if !comparisons[n.op] || n.returnBool {
+			delete(resultLabels, "__name__")
+		}
+		if m.card == "" {
+			if seen[sig] {
+				return nil, fmt.Errorf("multiple matches for labels: many-to-one matching must be explicit (group_left/group_right)")
+			}
+			seen[sig] = true
+			if m.on {
+				for name := range resultLabels {
+					if !contains(m.labels, name) {
+						delete(resultLabels, name)
+					}
+				}
+			} else {
+				for _, name := range m.labels {
+					delete(resultLabels, name)
+				}
+			}
+		} else {
+			for _, name := range m.include {
+				if value := match.labels[name]; value != "" {
+					resultLabels[name] = value
+				} else {
+					delete(resultLabels, name)
+				}
+			}
+		}
+		out = append(out, sample{labels: resultLabels, value: result})
+	}
+	return out, nil
+}
+
+// selectedMetrics returns the metric names each selector in n may select, for
+// failing queries touching a metric. A selector without a metric name matches
+// any of names whose value its __name__ matchers accept.
+func selectedMetrics(n node, names []string) []string {
+	var selected []string
+	var walk func(n node)
+	walk = func(n node) {
+		switch n := n.(type) {
+		case selectorNode:
+			for _, name := range names {
+				if selects(selectorNode{matchers: nameMatchers(n)}, labels{"__name__": name}) {
+					selected = append(selected, name)
+				}
+			}
+		case matrixNode:
+			walk(n.selector)
+		case callNode:
+			for _, arg := range n.args {
+				walk(arg)
+			}
+		case aggregateNode:
+			walk(n.expr)
+		case *binaryNode:
+			walk(n.lhs)
+			walk(n.rhs)
+		case negNode:
+			walk(n.expr)
+		}
+	}
+	walk(n)
+	return selected
+}
+
+// nameMatchers returns the __name__ matchers of sel.
+func nameMatchers(sel selectorNode) []matcher {
+	var matchers []matcher
+	for _, m := range sel.matchers {
+		if m.name == "__name__" {
+			matchers = append(matchers, m)
+		}
+	}
+	return matchers
+}
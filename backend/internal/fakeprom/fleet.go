@@ -0,0 +1,442 @@
+// Package fakeprom serves the Prometheus HTTP query API from a scripted fleet
+// of synthetic GPUs, for tests and for running the backend locally without a
+// real Prometheus. It evaluates the subset of PromQL the backend issues:
+// selectors, range functions, aggregations and binary operations with vector
+// matching.
+package fakeprom
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strconv"
+	"time"
+)
+
+// Naming maps the fleet's metric types and label roles to exporter names. The
+// keys and JSON layout match the backend's metric schema, so a schema can be
+// converted by round-tripping it through JSON. Metric types without a name are
+// not exported.
+type Naming struct {
+	// Metrics is keyed by metric type: utilization, memory_used, memory_total,
+	// memory_free, memory_utilization, temperature, power_draw, power_limit,
+	// energy_total, sm_clock, memory_clock, ecc_corrected and ecc_uncorrected.
+	Metrics map[string]string `json:"metrics"`
+	// Labels is keyed by role: node, gpu_index, gpu_name, uuid, pod, namespace,
+	// container, driver_version and pci_bus_id.
+	Labels map[string]string `json:"labels"`
+	// MemoryBytesPerUnit and EnergyJoulesPerUnit are the exporter's units.
+	MemoryBytesPerUnit  float64 `json:"memory_bytes_per_unit"`
+	EnergyJoulesPerUnit float64 `json:"energy_joules_per_unit"`
+}
+
+// NvidiaGPUExporter returns the naming of nvidia_gpu_exporter.
+func NvidiaGPUExporter() Naming {
+	return Naming{
+		Metrics: map[string]string{
+			"utilization":        "nvidia_gpu_utilization_percent",
+			"memory_used":        "nvidia_gpu_used_memory_bytes",
+			"memory_total":       "nvidia_gpu_total_memory_bytes",
+			"memory_free":        "nvidia_gpu_free_memory_bytes",
+			"memory_utilization": "nvidia_gpu_memory_utilization_percent",
+			"temperature":        "nvidia_gpu_temperature_celsius",
+			"power_draw":         "nvidia_gpu_power_draw_watts",
+			"power_limit":        "nvidia_gpu_power_limit_watts",
+			"sm_clock":           "nvidia_gpu_sm_clock_mhz",
+			"memory_clock":       "nvidia_gpu_memory_clock_mhz",
+			"ecc_corrected":      "nvidia_gpu_ecc_corrected_errors_total",
+			"ecc_uncorrected":    "nvidia_gpu_ecc_uncorrected_errors_total",
+		},
+		Labels: map[string]string{
+			"node":           "hostname",
+			"gpu_index":      "gpu_id",
+			"gpu_name":       "gpu_name",
+			"uuid":           "uuid",
+			"driver_version": "driver_version",
+			"pci_bus_id":     "pci_bus_id",
+		},
+		MemoryBytesPerUnit:  1,
+		EnergyJoulesPerUnit: 1,
+	}
+}
+
+// DCGMExporter returns the naming of dcgm-exporter, which labels GPUs with the pod holding them.
+func DCGMExporter() Naming {
+	return Naming{
+		Metrics: map[string]string{
+			"utilization":        "DCGM_FI_DEV_GPU_UTIL",
+			"memory_used":        "DCGM_FI_DEV_FB_USED",
+			"memory_free":        "DCGM_FI_DEV_FB_FREE",
+			"memory_utilization": "DCGM_FI_DEV_MEM_COPY_UTIL",
+			"temperature":        "DCGM_FI_DEV_GPU_TEMP",
+			"power_draw":         "DCGM_FI_DEV_POWER_USAGE",
+			"power_limit":        "DCGM_FI_DEV_ENFORCED_POWER_LIMIT",
+			"energy_total":       "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION",
+			"sm_clock":           "DCGM_FI_DEV_SM_CLOCK",
+			"memory_clock":       "DCGM_FI_DEV_MEM_CLOCK",
+			"ecc_corrected":      "DCGM_FI_DEV_ECC_SBE_VOL_TOTAL",
+			"ecc_uncorrected":    "DCGM_FI_DEV_ECC_DBE_VOL_TOTAL",
+		},
+		Labels: map[string]string{
+			"node":           "Hostname",
+			"gpu_index":      "gpu",
+			"gpu_name":       "modelName",
+			"uuid":           "UUID",
+			"pod":            "pod",
+			"namespace":      "namespace",
+			"container":      "container",
+			"driver_version": "DCGM_FI_DRIVER_VERSION",
+			"pci_bus_id":     "pci_bus_id",
+		},
+		MemoryBytesPerUnit:  1024 * 1024,
+		EnergyJoulesPerUnit: 0.001,
+	}
+}
+
+// Model describes the fixed characteristics of a GPU model.
+type Model struct {
+	Name        string
+	MemoryBytes float64
+	PowerLimit  float64
+	// MaxSMClock and MemoryClock are in MHz.
+	MaxSMClock  float64
+	MemoryClock float64
+}
+
+// DefaultModel is used when a fleet names no models.
+const DefaultModel = "NVIDIA A100-SXM4-80GB"
+
+const gib = 1024 * 1024 * 1024
+
+// knownModels holds the specifications of common data center GPUs.
+var knownModels = map[string]Model{
+	"NVIDIA A100-SXM4-80GB": {MemoryBytes: 80 * gib, PowerLimit: 400, MaxSMClock: 1410, MemoryClock: 1593},
+	"NVIDIA A100-PCIE-40GB": {MemoryBytes: 40 * gib, PowerLimit: 250, MaxSMClock: 1410, MemoryClock: 1215},
+	"NVIDIA H100 80GB HBM3": {MemoryBytes: 80 * gib, PowerLimit: 700, MaxSMClock: 1980, MemoryClock: 2619},
+	"NVIDIA L4":             {MemoryBytes: 24 * gib, PowerLimit: 72, MaxSMClock: 2040, MemoryClock: 6251},
+	"Tesla T4":              {MemoryBytes: 16 * gib, PowerLimit: 70, MaxSMClock: 1590, MemoryClock: 5001},
+	"Tesla V100-SXM2-32GB":  {MemoryBytes: 32 * gib, PowerLimit: 300, MaxSMClock: 1530, MemoryClock: 877},
+}
+
+// LookupModel returns the specification of a GPU model, or a generic 16 GiB, 250 W one for unknown names.
+func LookupModel(name string) Model {
+	model, ok := knownModels[name]
+	if !ok {
+		model = Model{MemoryBytes: 16 * gib, PowerLimit: 250, MaxSMClock: 1500, MemoryClock: 1000}
+	}
+	model.Name = name
+	return model
+}
+
+// Workload is a pod container holding GPUs on a node.
+type Workload struct {
+	Namespace string
+	Pod       string
+	Container string
+	Node      string
+	GPUs      int
+}
+
+// Fleet scripts the GPUs the server reports. The zero value is a single node
+// with eight A100s following a diurnal utilization pattern.
+type Fleet struct {
+	// Naming selects the exporter's metric and label names; the zero value is nvidia_gpu_exporter.
+	Naming Naming
+	// NodeNames lists the nodes; when empty, Nodes nodes named gpu-node-NN are generated.
+	NodeNames []string
+	Nodes     int
+	// GPUsPerNode defaults to 8.
+	GPUsPerNode int
+	// Models are assigned to nodes round-robin.
+	Models []string
+	// Utilization drives every other metric; it defaults to Diurnal(10, 90).
+	Utilization Pattern
+	// Workloads hold GPUs in order of index on their node. When empty, Allocated
+	// is the fraction of each node's GPUs held by a single generated pod, in a
+	// namespace taken round-robin from Namespaces.
+	Workloads  []Workload
+	Allocated  float64
+	Namespaces []string
+	// KubeStateMetrics serves kube_pod_container_resource_requests and
+	// kube_pod_status_phase for the workloads.
+	KubeStateMetrics bool
+	// Missing lists metric types the exporter does not report.
+	Missing []string
+	// Offline lists nodes whose exporter is down: up is 0 and their GPUs have no series.
+	Offline []string
+	// ScrapeInterval spaces the samples of every series; it defaults to 15s.
+	ScrapeInterval time.Duration
+}
+
+// GPU is a synthetic device of the fleet.
+type GPU struct {
+	Node          string
+	Index         int
+	Model         Model
+	UUID          string
+	DriverVersion string
+	PCIBusID      string
+	// Owner is the workload holding the GPU, or nil if it is unallocated.
+	Owner *Workload
+	// ordinal numbers GPUs across the fleet, giving each a distinct pattern phase.
+	ordinal int
+}
+
+// withDefaults fills in the zero fields of f.
+func (f Fleet) withDefaults() Fleet {
+	if f.Naming.Metrics == nil {
+		f.Naming = NvidiaGPUExporter()
+	}
+	if f.Naming.MemoryBytesPerUnit <= 0 {
+		f.Naming.MemoryBytesPerUnit = 1
+	}
+	if f.Naming.EnergyJoulesPerUnit <= 0 {
+		f.Naming.EnergyJoulesPerUnit = 1
+	}
+	if len(f.NodeNames) == 0 {
+		nodes := max(f.Nodes, 1)
+		for i := 0; i < nodes; i++ {
+			f.NodeNames = append(f.NodeNames, fmt.Sprintf("gpu-node-%02d", i))
+		}
+	}
+	if f.GPUsPerNode <= 0 {
+		f.GPUsPerNode = 8
+	}
+	if len(f.Models) == 0 {
+		f.Models = []string{DefaultModel}
+	}
+	if f.Utilization == nil {
+		f.Utilization = Diurnal(10, 90)
+	}
+	if len(f.Namespaces) == 0 {
+		f.Namespaces = []string{"default"}
+	}
+	if f.ScrapeInterval <= 0 {
+		f.ScrapeInterval = 15 * time.Second
+	}
+	return f
+}
+
+// workloads returns the configured workloads, or generates one per node from Allocated.
+func (f Fleet) workloads() []Workload {
+	if len(f.Workloads) > 0 || f.Allocated <= 0 {
+		return f.Workloads
+	}
+
+	count := int(math.Ceil(math.Min(f.Allocated, 1) * float64(f.GPUsPerNode)))
+	workloads := make([]Workload, 0, len(f.NodeNames))
+	for i, node := range f.NodeNames {
+		workloads = append(workloads, Workload{
+			Namespace: f.Namespaces[i%len(f.Namespaces)],
+			Pod:       fmt.Sprintf("train-%s", node),
+			Container: "trainer",
+			Node:      node,
+			GPUs:      count,
+		})
+	}
+	return workloads
+}
+
+// GPUs returns the devices of the fleet in node and index order.
+func (f Fleet) GPUs() []GPU {
+	f = f.withDefaults()
+
+	workloads := f.workloads()
+	var gpus []GPU
+	for n, node := range f.NodeNames {
+		model := LookupModel(f.Models[n%len(f.Models)])
+
+		// Hand out the node's GPUs to its workloads in order
+		var owners []*Workload
+		for i := range workloads {
+			if workloads[i].Node != node {
+				continue
+			}
+			for j := 0; j < workloads[i].GPUs; j++ {
+				owners = append(owners, &workloads[i])
+			}
+		}
+
+		for i := 0; i < f.GPUsPerNode; i++ {
+			gpu := GPU{
+				Node:          node,
+				Index:         i,
+				Model:         model,
+				UUID:          uuid(node, i),
+				DriverVersion: "550.54.15",
+				PCIBusID:      fmt.Sprintf("00000000:%02X:00.0", 0x18+i*0x10),
+				ordinal:       len(gpus),
+			}
+			if i < len(owners) {
+				gpu.Owner = owners[i]
+			}
+			gpus = append(gpus, gpu)
+		}
+	}
+	return gpus
+}
+
+// uuid derives a stable GPU UUID from its node and index.
+func uuid(node string, index int) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", node, index)
+	sum := h.Sum64()
+	return fmt.Sprintf("GPU-%08x-%04x-%04x-%04x-%012x", uint32(sum>>32), uint16(sum>>16), uint16(sum), uint16(index), sum&0xffffffffffff)
+}
+
+// value returns the reading of metricType on gpu at t, in the exporter's units.
+func (f Fleet) value(gpu GPU, metricType string, t time.Time) float64 {
+	load := math.Max(0, math.Min(100, f.Utilization(gpu.ordinal, t)))
+	fraction := load / 100
+	memoryUsed := gpu.Model.MemoryBytes * (0.05 + 0.85*fraction)
+
+	switch metricType {
+	case "utilization":
+		return load
+	case "memory_used":
+		return memoryUsed / f.Naming.MemoryBytesPerUnit
+	case "memory_total":
+		return gpu.Model.MemoryBytes / f.Naming.MemoryBytesPerUnit
+	case "memory_free":
+		return (gpu.Model.MemoryBytes - memoryUsed) / f.Naming.MemoryBytesPerUnit
+	case "memory_utilization":
+		return math.Round(load * 0.6)
+	case "temperature":
+		return math.Round(32 + 0.5*load)
+	case "power_draw":
+		return gpu.Model.PowerLimit * (0.15 + 0.8*fraction)
+	case "power_limit":
+		return gpu.Model.PowerLimit
+	case "energy_total":
+		// A counter growing at the mean draw keeps increase() consistent over any window
+		return float64(t.Unix()) * gpu.Model.PowerLimit * 0.5 / f.Naming.EnergyJoulesPerUnit
+	case "sm_clock":
+		return math.Round(gpu.Model.MaxSMClock * (0.3 + 0.7*fraction))
+	case "memory_clock":
+		return gpu.Model.MemoryClock
+	case "ecc_corrected":
+		return float64(gpu.ordinal % 3)
+	case "ecc_uncorrected":
+		return 0
+	}
+	return math.NaN()
+}
+
+// metricTypes are the fleet's metric types in export order.
+var metricTypes = []string{
+	"utilization",
+	"memory_used",
+	"memory_total",
+	"memory_free",
+	"memory_utilization",
+	"temperature",
+	"power_draw",
+	"power_limit",
+	"energy_total",
+	"sm_clock",
+	"memory_clock",
+	"ecc_corrected",
+	"ecc_uncorrected",
+}
+
+// podPhases are the phases kube-state-metrics reports for every pod.
+var podPhases = []string{"Pending", "Running", "Succeeded", "Failed", "Unknown"}
+
+// exporterPort is the port in the instance label of exporter series.
+const exporterPort = 9400
+
+// series returns every series the fleet exports: the exporter's GPU metrics,
+// an up series per node and, if enabled, the kube-state-metrics series.
+func (f Fleet) series() []series {
+	f = f.withDefaults()
+
+	var all []series
+	constant := func(v float64) func(time.Time) float64 {
+		return func(time.Time) float64 { return v }
+	}
+	add := func(name string, l labels, value func(time.Time) float64) {
+		l["__name__"] = name
+		all = append(all, series{labels: l, value: value})
+	}
+
+	nodeLabel := f.Naming.Labels["node"]
+	for _, node := range f.NodeNames {
+		up := 1.0
+		if contains(f.Offline, node) {
+			up = 0
+		}
+		l := labels{"job": "gpu-exporter", "instance": fmt.Sprintf("%s:%d", node, exporterPort)}
+		if nodeLabel != "" {
+			l[nodeLabel] = node
+		}
+		add("up", l, constant(up))
+	}
+
+	for _, gpu := range f.GPUs() {
+		if contains(f.Offline, gpu.Node) {
+			continue
+		}
+		for _, metricType := range metricTypes {
+			name := f.Naming.Metrics[metricType]
+			if name == "" || contains(f.Missing, metricType) {
+				continue
+			}
+			add(name, f.gpuLabels(gpu), func(t time.Time) float64 {
+				return f.value(gpu, metricType, t)
+			})
+		}
+	}
+
+	if !f.KubeStateMetrics {
+		return all
+	}
+	add("up", labels{"job": "kube-state-metrics", "instance": "kube-state-metrics:8080"}, constant(1))
+	for _, w := range f.workloads() {
+		add("kube_pod_container_resource_requests", labels{
+			"job":       "kube-state-metrics",
+			"namespace": w.Namespace,
+			"pod":       w.Pod,
+			"container": w.Container,
+			"node":      w.Node,
+			"resource":  "nvidia.com/gpu",
+			"unit":      "integer",
+		}, constant(float64(w.GPUs)))
+		for _, phase := range podPhases {
+			running := 0.0
+			if phase == "Running" {
+				running = 1
+			}
+			add("kube_pod_status_phase", labels{
+				"job":       "kube-state-metrics",
+				"namespace": w.Namespace,
+				"pod":       w.Pod,
+				"phase":     phase,
+			}, constant(running))
+		}
+	}
+	return all
+}
+
+// gpuLabels returns the exporter labels identifying gpu, including its owner
+// when the exporter reports pods.
+func (f Fleet) gpuLabels(gpu GPU) labels {
+	l := labels{"job": "gpu-exporter", "instance": fmt.Sprintf("%s:%d", gpu.Node, exporterPort)}
+	set := func(role, value string) {
+		if name := f.Naming.Labels[role]; name != "" && value != "" {
+			l[name] = value
+		}
+	}
+
+	set("node", gpu.Node)
+	set("gpu_index", strconv.Itoa(gpu.Index))
+	set("gpu_name", gpu.Model.Name)
+	set("uuid", gpu.UUID)
+	set("driver_version", gpu.DriverVersion)
+	set("pci_bus_id", gpu.PCIBusID)
+	if gpu.Owner != nil {
+		set("namespace", gpu.Owner.Namespace)
+		set("pod", gpu.Owner.Pod)
+		set("container", gpu.Owner.Container)
+	}
+	return l
+}
@@ -0,0 +1,559 @@
+package fakeprom
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// node is a parsed PromQL expression.
+type node interface{}
+
+type (
+	numberLit struct {
+		value float64
+	}
+	selectorNode struct {
+		matchers []matcher
+	}
+	matrixNode struct {
+		selector selectorNode
+		window   time.Duration
+	}
+	callNode struct {
+		fn   string
+		args []node
+	}
+	aggregateNode struct {
+		op       string
+		param    node
+		expr     node
+		grouping []string
+		without  bool
+	}
+	binaryNode struct {
+		op         string
+		lhs, rhs   node
+		returnBool bool
+		matching   *vectorMatching
+	}
+	negNode struct {
+		expr node
+	}
+)
+
+// vectorMatching holds the on/ignoring and group_left/group_right modifiers of a binary operation.
+type vectorMatching struct {
+	on      bool
+	labels  []string
+	card    string // "", "left" or "right"
+	include []string
+}
+
+// matcher is a label matcher; regex matchers are fully anchored.
+type matcher struct {
+	name, op, value string
+	re              *regexp.Regexp
+}
+
+// matches reports whether the label value satisfies the matcher.
+func (m matcher) matches(value string) bool {
+	switch m.op {
+	case "=":
+		return value == m.value
+	case "!=":
+		return value != m.value
+	case "=~":
+		return m.re.MatchString(value)
+	default:
+		return !m.re.MatchString(value)
+	}
+}
+
+// aggregations lists the supported aggregation operators; those in params take a parameter.
+var (
+	aggregations = map[string]bool{"sum": true, "count": true, "avg": true, "min": true, "max": true, "group": true, "topk": true, "bottomk": true, "quantile": true}
+	params       = map[string]bool{"topk": true, "bottomk": true, "quantile": true}
+)
+
+// precedences of the binary operators; ^ is right-associative.
+var precedences = map[string]int{
+	"or":  1,
+	"and": 2, "unless": 2,
+	"==": 3, "!=": 3, "<": 3, "<=": 3, ">": 3, ">=": 3,
+	"+": 4, "-": 4,
+	"*": 5, "/": 5, "%": 5, "atan2": 5,
+	"^": 6,
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokDuration
+	tokPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+// lex splits a query into tokens. The contents of square brackets become a single duration token.
+func lex(query string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(query); {
+		c := rune(query[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '#':
+			for i < len(query) && query[i] != '\n' {
+				i++
+			}
+		case c == '_' || c == ':' || unicode.IsLetter(c):
+			start := i
+			for i < len(query) && (query[i] == '_' || query[i] == ':' || unicode.IsLetter(rune(query[i])) || unicode.IsDigit(rune(query[i]))) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, query[start:i]})
+		case unicode.IsDigit(c) || (c == '.' && i+1 < len(query) && unicode.IsDigit(rune(query[i+1]))):
+			start := i
+			for i < len(query) && (unicode.IsDigit(rune(query[i])) || strings.ContainsRune(".eE", rune(query[i])) ||
+				((query[i] == '+' || query[i] == '-') && (query[i-1] == 'e' || query[i-1] == 'E'))) {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, query[start:i]})
+		case c == '"' || c == '\'' || c == '`':
+			end := i + 1
+			for end < len(query) && query[end] != byte(c) {
+				if query[end] == '\\' && c != '`' {
+					end++
+				}
+				end++
+			}
+			if end >= len(query) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			raw := query[i : end+1]
+			if c == '\'' {
+				raw = `"` + strings.ReplaceAll(raw[1:len(raw)-1], `"`, `\"`) + `"`
+			}
+			value, err := strconv.Unquote(raw)
+			if err != nil {
+				return nil, fmt.Errorf("invalid string %s: %w", query[i:end+1], err)
+			}
+			tokens = append(tokens, token{tokString, value})
+			i = end + 1
+		case c == '[':
+			end := strings.IndexByte(query[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated range at position %d", i)
+			}
+			tokens = append(tokens, token{tokDuration, strings.TrimSpace(query[i+1 : i+end])})
+			i += end + 1
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", ">=", "<=", "=~", "!~"} {
+				if strings.HasPrefix(query[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" && strings.ContainsRune("+-*/%^<>=(){},", c) {
+				op = string(c)
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, token{tokPunct, op})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF}), nil
+}
+
+// parser is a precedence-climbing parser over the tokens of a query.
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+// parse parses a PromQL query.
+func parse(query string) (node, error) {
+	tokens, err := lex(query)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	expr, err := p.expr(0)
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q after expression", tok.text)
+	}
+	return expr, nil
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// accept consumes the next token if it is the given punctuation or keyword.
+func (p *parser) accept(text string) bool {
+	tok := p.peek()
+	if (tok.kind == tokPunct || tok.kind == tokIdent) && tok.text == text {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(text string) error {
+	if !p.accept(text) {
+		return fmt.Errorf("expected %q, got %q", text, p.peek().text)
+	}
+	return nil
+}
+
+// binaryOp returns the operator at the current position, if any.
+func (p *parser) binaryOp() (string, bool) {
+	tok := p.peek()
+	if tok.kind != tokPunct && tok.kind != tokIdent {
+		return "", false
+	}
+	_, ok := precedences[tok.text]
+	return tok.text, ok
+}
+
+// expr parses a binary expression whose operators bind at least as tightly as minPrec.
+func (p *parser) expr(minPrec int) (node, error) {
+	lhs, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op, ok := p.binaryOp()
+		if !ok || precedences[op] < minPrec {
+			return lhs, nil
+		}
+		p.next()
+
+		bin := &binaryNode{op: op, lhs: lhs}
+		bin.returnBool = p.accept("bool")
+		if bin.matching, err = p.vectorMatching(); err != nil {
+			return nil, err
+		}
+
+		nextPrec := precedences[op] + 1
+		if op == "^" {
+			nextPrec = precedences[op]
+		}
+		if bin.rhs, err = p.expr(nextPrec); err != nil {
+			return nil, err
+		}
+		lhs = bin
+	}
+}
+
+// vectorMatching parses optional on/ignoring and group_left/group_right modifiers.
+func (p *parser) vectorMatching() (*vectorMatching, error) {
+	var m *vectorMatching
+	var err error
+	switch {
+	case p.accept("on"):
+		m = &vectorMatching{on: true}
+	case p.accept("ignoring"):
+		m = &vectorMatching{}
+	default:
+		return nil, nil
+	}
+	if m.labels, err = p.labelList(); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case p.accept("group_left"):
+		m.card = "left"
+	case p.accept("group_right"):
+		m.card = "right"
+	default:
+		return m, nil
+	}
+	if p.peek().text == "(" {
+		if m.include, err = p.labelList(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// labelList parses a parenthesized, comma-separated list of label names.
+func (p *parser) labelList() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var labels []string
+	for !p.accept(")") {
+		tok := p.next()
+		if tok.kind != tokIdent {
+			return nil, fmt.Errorf("expected label name, got %q", tok.text)
+		}
+		labels = append(labels, tok.text)
+		if !p.accept(",") && p.peek().text != ")" {
+			return nil, fmt.Errorf("expected \",\" or \")\" in label list, got %q", p.peek().text)
+		}
+	}
+	return labels, nil
+}
+
+// unary parses an optionally negated primary expression.
+func (p *parser) unary() (node, error) {
+	if p.accept("-") {
+		expr, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		if n, ok := expr.(numberLit); ok {
+			return numberLit{-n.value}, nil
+		}
+		return negNode{expr}, nil
+	}
+	if p.accept("+") {
+		return p.unary()
+	}
+	return p.primary()
+}
+
+// primary parses a literal, parenthesized expression, call, aggregation or selector.
+func (p *parser) primary() (node, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == tokNumber:
+		p.next()
+		value, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return numberLit{value}, nil
+
+	case tok.text == "(" && tok.kind == tokPunct:
+		p.next()
+		expr, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if p.peek().kind == tokDuration {
+			return nil, fmt.Errorf("subqueries are not supported")
+		}
+		return expr, nil
+
+	case tok.text == "{" && tok.kind == tokPunct:
+		return p.selector("")
+
+	case tok.kind == tokIdent:
+		p.next()
+		switch {
+		case strings.EqualFold(tok.text, "Inf"):
+			return numberLit{math.Inf(1)}, nil
+		case strings.EqualFold(tok.text, "NaN"):
+			return numberLit{math.NaN()}, nil
+		case aggregations[tok.text] && (p.peek().text == "(" || p.peek().text == "by" || p.peek().text == "without"):
+			return p.aggregation(tok.text)
+		case p.peek().text == "(":
+			return p.call(tok.text)
+		}
+		return p.selector(tok.text)
+	}
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
+
+// call parses the arguments of a function call.
+func (p *parser) call(fn string) (node, error) {
+	if _, ok := functions[fn]; !ok {
+		return nil, fmt.Errorf("unknown function %q", fn)
+	}
+
+	p.next()
+	call := callNode{fn: fn}
+	for !p.accept(")") {
+		arg, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		call.args = append(call.args, arg)
+		if !p.accept(",") && p.peek().text != ")" {
+			return nil, fmt.Errorf("expected \",\" or \")\" in call to %s, got %q", fn, p.peek().text)
+		}
+	}
+	return call, nil
+}
+
+// aggregation parses an aggregation with its grouping clause before or after the arguments.
+func (p *parser) aggregation(op string) (node, error) {
+	agg := aggregateNode{op: op}
+	grouping := func() error {
+		var err error
+		switch {
+		case p.accept("by"):
+			agg.grouping, err = p.labelList()
+		case p.accept("without"):
+			agg.without = true
+			agg.grouping, err = p.labelList()
+		}
+		return err
+	}
+
+	if err := grouping(); err != nil {
+		return nil, err
+	}
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	if params[op] {
+		param, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		agg.param = param
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+	}
+	expr, err := p.expr(0)
+	if err != nil {
+		return nil, err
+	}
+	agg.expr = expr
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if agg.grouping == nil && !agg.without {
+		if err := grouping(); err != nil {
+			return nil, err
+		}
+	}
+	return agg, nil
+}
+
+// selector parses the optional matchers and range of a selector for metric.
+func (p *parser) selector(metric string) (node, error) {
+	var sel selectorNode
+	if metric != "" {
+		sel.matchers = append(sel.matchers, matcher{name: "__name__", op: "=", value: metric})
+	}
+
+	if p.accept("{") {
+		for !p.accept("}") {
+			name := p.next()
+			if name.kind != tokIdent {
+				return nil, fmt.Errorf("expected label name, got %q", name.text)
+			}
+			op := p.next()
+			if op.kind != tokPunct || !(op.text == "=" || op.text == "!=" || op.text == "=~" || op.text == "!~") {
+				return nil, fmt.Errorf("expected label matcher operator, got %q", op.text)
+			}
+			value := p.next()
+			if value.kind != tokString {
+				return nil, fmt.Errorf("expected string for label %s, got %q", name.text, value.text)
+			}
+
+			m := matcher{name: name.text, op: op.text, value: value.text}
+			if op.text == "=~" || op.text == "!~" {
+				re, err := regexp.Compile("^(?:" + value.text + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("invalid regex for label %s: %w", name.text, err)
+				}
+				m.re = re
+			}
+			sel.matchers = append(sel.matchers, m)
+
+			if !p.accept(",") && p.peek().text != "}" {
+				return nil, fmt.Errorf("expected \",\" or \"}\" in selector, got %q", p.peek().text)
+			}
+		}
+	}
+	if len(sel.matchers) == 0 {
+		return nil, fmt.Errorf("selector must contain at least one matcher")
+	}
+
+	if tok := p.peek(); tok.kind == tokDuration {
+		p.next()
+		window, err := parseDuration(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return matrixNode{selector: sel, window: window}, nil
+	}
+	return sel, nil
+}
+
+// durationUnits are the PromQL duration units, longest first so "ms" wins over "m".
+var durationUnits = []struct {
+	suffix string
+	unit   time.Duration
+}{
+	{"ms", time.Millisecond},
+	{"s", time.Second},
+	{"m", time.Minute},
+	{"h", time.Hour},
+	{"d", 24 * time.Hour},
+	{"w", 7 * 24 * time.Hour},
+	{"y", 365 * 24 * time.Hour},
+}
+
+// parseDuration parses a PromQL duration such as 5m or 1h30m.
+func parseDuration(text string) (time.Duration, error) {
+	if strings.Contains(text, ":") {
+		return 0, fmt.Errorf("subqueries are not supported")
+	}
+
+	var total time.Duration
+	rest := text
+	for rest != "" {
+		digits := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsDigit(r) })
+		if digits <= 0 {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+		n, _ := strconv.Atoi(rest[:digits])
+		rest = rest[digits:]
+
+		matched := false
+		for _, u := range durationUnits {
+			if strings.HasPrefix(rest, u.suffix) {
+				total += time.Duration(n) * u.unit
+				rest = rest[len(u.suffix):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return 0, fmt.Errorf("invalid duration %q", text)
+		}
+	}
+	if total <= 0 {
+		return 0, fmt.Errorf("invalid duration %q", text)
+	}
+	return total, nil
+}
@@ -0,0 +1,74 @@
+package fakeprom
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"time"
+)
+
+// Pattern returns the utilization percentage of the GPU with the given fleet-wide ordinal at t.
+type Pattern func(gpu int, t time.Time) float64
+
+// Constant keeps every GPU at percent.
+func Constant(percent float64) Pattern {
+	return func(int, time.Time) float64 {
+		return percent
+	}
+}
+
+// Diurnal swings between low and high once a day, each GPU at its own phase.
+func Diurnal(low, high float64) Pattern {
+	return func(gpu int, t time.Time) float64 {
+		day := float64(24 * time.Hour)
+		phase := 2 * math.Pi * (float64(t.UnixNano()%int64(day))/day + float64(gpu)*0.137)
+		return low + (high-low)*(1+math.Sin(phase))/2
+	}
+}
+
+// Noisy varies around mean by up to spread, changing every minute. Values are
+// derived from the GPU and the minute, so repeated queries agree.
+func Noisy(mean, spread float64) Pattern {
+	return func(gpu int, t time.Time) float64 {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%d/%d", gpu, t.Unix()/60)
+		unit := float64(h.Sum64()%10001)/5000 - 1
+		return mean + spread*unit
+	}
+}
+
+// Mixed gives GPU i the pattern patterns[i%len(patterns)].
+func Mixed(patterns ...Pattern) Pattern {
+	return func(gpu int, t time.Time) float64 {
+		return patterns[gpu%len(patterns)](gpu, t)
+	}
+}
+
+// namedPatterns are the patterns selectable by name.
+var namedPatterns = map[string]Pattern{
+	"idle":    Constant(0),
+	"busy":    Noisy(92, 6),
+	"diurnal": Diurnal(10, 90),
+	"noisy":   Noisy(50, 40),
+	"mixed":   Mixed(Noisy(92, 6), Diurnal(10, 90), Constant(0), Noisy(3, 2)),
+}
+
+// PatternNames returns the names accepted by LookupPattern in sorted order.
+func PatternNames() []string {
+	names := make([]string, 0, len(namedPatterns))
+	for name := range namedPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupPattern returns the named utilization pattern.
+func LookupPattern(name string) (Pattern, error) {
+	pattern, ok := namedPatterns[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown utilization pattern %q (available: %v)", name, PatternNames())
+	}
+	return pattern, nil
+}
@@ -0,0 +1,324 @@
+package fakeprom
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxPoints bounds the samples per series of a range query, as Prometheus does.
+const maxPoints = 11000
+
+// Faults make the server misbehave like an overloaded or misconfigured Prometheus.
+type Faults struct {
+	// Latency delays every response, ending early if the request is canceled.
+	Latency time.Duration
+	// Status fails every request with the HTTP status, e.g. 503 or 401.
+	Status int
+	// ErrorRate fails that fraction of requests at random with 503.
+	ErrorRate float64
+	// FailMetrics fails queries selecting any of the named metrics with 503.
+	FailMetrics []string
+	// Warnings are attached to every successful response.
+	Warnings []string
+}
+
+// Server serves /api/v1/query and /api/v1/query_range for a fleet. It is safe
+// for concurrent use.
+type Server struct {
+	ev  *evaluator
+	now func() time.Time
+
+	mu      sync.Mutex
+	faults  Faults
+	queries []string
+}
+
+// Option configures optional Server behaviour.
+type Option func(*Server)
+
+// WithClock sets the evaluation time of instant queries that do not specify one.
+func WithClock(now func() time.Time) Option {
+	return func(s *Server) {
+		s.now = now
+	}
+}
+
+// WithFaults starts the server with faults injected.
+func WithFaults(faults Faults) Option {
+	return func(s *Server) {
+		s.faults = faults
+	}
+}
+
+// NewServer creates a server reporting the fleet's GPUs.
+func NewServer(fleet Fleet, opts ...Option) *Server {
+	fleet = fleet.withDefaults()
+	s := &Server{
+		ev:  &evaluator{series: fleet.series(), interval: fleet.ScrapeInterval},
+		now: time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// SetFaults replaces the injected faults, e.g. to take Prometheus down mid-test.
+func (s *Server) SetFaults(faults Faults) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.faults = faults
+}
+
+// Queries returns the queries received so far, in order.
+func (s *Server) Queries() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.queries...)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/-/healthy", "/-/ready":
+		w.Write([]byte("Prometheus Server is Ready.\n"))
+		return
+	case "/api/v1/query", "/api/v1/query_range":
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "GET, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		writeError(w, http.StatusBadRequest, "bad_data", err)
+		return
+	}
+
+	query := r.Form.Get("query")
+	s.mu.Lock()
+	s.queries = append(s.queries, query)
+	faults := s.faults
+	s.mu.Unlock()
+
+	if faults.Latency > 0 {
+		select {
+		case <-time.After(faults.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if faults.Status != 0 {
+		http.Error(w, http.StatusText(faults.Status), faults.Status)
+		return
+	}
+	if faults.ErrorRate > 0 && rand.Float64() < faults.ErrorRate {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	expr, err := parse(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad_data", fmt.Errorf("invalid parameter \"query\": %w", err))
+		return
+	}
+	if len(selectedMetrics(expr, faults.FailMetrics)) > 0 {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return
+	}
+
+	var data queryData
+	if r.URL.Path == "/api/v1/query" {
+		data, err = s.instant(expr, r.Form.Get("time"))
+	} else {
+		data, err = s.ranged(expr, r.Form.Get("start"), r.Form.Get("end"), r.Form.Get("step"))
+	}
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, "execution", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, apiResponse{Status: "success", Data: &data, Warnings: faults.Warnings})
+}
+
+// instant evaluates expr at the given time, or now if it is empty.
+func (s *Server) instant(expr node, at string) (queryData, error) {
+	t := s.now()
+	if at != "" {
+		var err error
+		if t, err = parseTime(at); err != nil {
+			return queryData{}, fmt.Errorf("invalid parameter \"time\": %w", err)
+		}
+	}
+
+	v, err := s.ev.eval(expr, t)
+	if err != nil {
+		return queryData{}, err
+	}
+
+	switch v := v.(type) {
+	case scalar:
+		return queryData{ResultType: "scalar", Result: samplePair(t, float64(v))}, nil
+	case vector:
+		result := make([]seriesResult, 0, len(v))
+		for _, smp := range v {
+			result = append(result, seriesResult{Metric: smp.labels, Value: samplePair(t, smp.value)})
+		}
+		sortResults(result)
+		return queryData{ResultType: "vector", Result: result}, nil
+	case matrix:
+		result := make([]seriesResult, 0, len(v.series))
+		for _, rs := range v.series {
+			values := make([][]interface{}, len(rs.points))
+			for i, p := range rs.points {
+				values[i] = samplePair(p.t, p.value)
+			}
+			result = append(result, seriesResult{Metric: rs.labels, Values: values})
+		}
+		sortResults(result)
+		return queryData{ResultType: "matrix", Result: result}, nil
+	}
+	return queryData{}, fmt.Errorf("unexpected result %T", v)
+}
+
+// ranged evaluates expr at every step from start to end.
+func (s *Server) ranged(expr node, startParam, endParam, stepParam string) (queryData, error) {
+	start, err := parseTime(startParam)
+	if err != nil {
+		return queryData{}, fmt.Errorf("invalid parameter \"start\": %w", err)
+	}
+	end, err := parseTime(endParam)
+	if err != nil {
+		return queryData{}, fmt.Errorf("invalid parameter \"end\": %w", err)
+	}
+	step, err := parseStep(stepParam)
+	if err != nil {
+		return queryData{}, fmt.Errorf("invalid parameter \"step\": %w", err)
+	}
+	if end.Before(start) {
+		return queryData{}, fmt.Errorf("end timestamp must not be before start time")
+	}
+	if end.Sub(start)/step >= maxPoints {
+		return queryData{}, fmt.Errorf("exceeded maximum resolution of %d points per timeseries", maxPoints)
+	}
+
+	bySeries := make(map[string]*seriesResult)
+	for t := start; !t.After(end); t = t.Add(step) {
+		v, err := s.ev.eval(expr, t)
+		if err != nil {
+			return queryData{}, err
+		}
+
+		var samples vector
+		switch v := v.(type) {
+		case scalar:
+			samples = vector{{labels: labels{}, value: float64(v)}}
+		case vector:
+			samples = v
+		default:
+			return queryData{}, fmt.Errorf("invalid expression type range vector for range query, must be scalar or instant vector")
+		}
+
+		for _, smp := range samples {
+			key := smp.labels.key()
+			if bySeries[key] == nil {
+				bySeries[key] = &seriesResult{Metric: smp.labels}
+			}
+			bySeries[key].Values = append(bySeries[key].Values, samplePair(t, smp.value))
+		}
+	}
+
+	result := make([]seriesResult, 0, len(bySeries))
+	for _, r := range bySeries {
+		result = append(result, *r)
+	}
+	sortResults(result)
+	return queryData{ResultType: "matrix", Result: result}, nil
+}
+
+// apiResponse is the envelope of every Prometheus API response.
+type apiResponse struct {
+	Status    string     `json:"status"`
+	Data      *queryData `json:"data,omitempty"`
+	ErrorType string     `json:"errorType,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	Warnings  []string   `json:"warnings,omitempty"`
+}
+
+type queryData struct {
+	ResultType string      `json:"resultType"`
+	Result     interface{} `json:"result"`
+}
+
+// seriesResult is a series of a vector (Value) or matrix (Values) result.
+type seriesResult struct {
+	Metric labels          `json:"metric"`
+	Value  []interface{}   `json:"value,omitempty"`
+	Values [][]interface{} `json:"values,omitempty"`
+}
+
+// samplePair renders a sample as Prometheus does: [unix seconds, "value"].
+func samplePair(t time.Time, v float64) []interface{} {
+	return []interface{}{float64(t.UnixMilli()) / 1000, formatValue(v)}
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func sortResults(result []seriesResult) {
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Metric.key() < result[j].Metric.key()
+	})
+}
+
+// parseTime parses a Unix timestamp in seconds or an RFC 3339 time.
+func parseTime(s string) (time.Time, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		whole, frac := math.Modf(seconds)
+		return time.Unix(int64(whole), int64(frac*1e9)), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q to a valid timestamp", s)
+}
+
+// parseStep parses a step in seconds or as a PromQL duration.
+func parseStep(s string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(s, 64); err == nil {
+		if seconds <= 0 {
+			return 0, fmt.Errorf("zero or negative query resolution step widths are not accepted")
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return parseDuration(s)
+}
+
+func writeError(w http.ResponseWriter, status int, errorType string, err error) {
+	writeJSON(w, status, apiResponse{Status: "error", ErrorType: errorType, Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, resp apiResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
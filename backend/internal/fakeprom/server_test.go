@@ -0,0 +1,211 @@
+package fakeprom
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// testTime is a scrape-aligned evaluation time.
+var testTime = time.Unix(1700000000, 0)
+
+// result is a decoded series of a query response.
+type result struct {
+	Metric map[string]string `json:"metric"`
+	Value  []interface{}     `json:"value"`
+	Values [][]interface{}   `json:"values"`
+}
+
+// get issues a query against server and decodes the response.
+func get(t *testing.T, server *httptest.Server, path string, params url.Values) (int, string, []result) {
+	t.Helper()
+
+	resp, err := http.Get(server.URL + path + "?" + params.Encode())
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status string `json:"status"`
+		Data   struct {
+			ResultType string   `json:"resultType"`
+			Result     []result `json:"result"`
+		} `json:"data"`
+	}
+	json.NewDecoder(resp.Body).Decode(&body)
+	return resp.StatusCode, body.Data.ResultType, body.Data.Result
+}
+
+func query(t *testing.T, server *httptest.Server, q string) []result {
+	t.Helper()
+
+	code, resultType, results := get(t, server, "/api/v1/query", url.Values{
+		"query": {q},
+		"time":  {strconv.FormatInt(testTime.Unix(), 10)},
+	})
+	if code != http.StatusOK || resultType != "vector" {
+		t.Fatalf("query %s: got status %d, result type %q", q, code, resultType)
+	}
+	return results
+}
+
+func sampleValue(t *testing.T, r result) float64 {
+	t.Helper()
+	v, err := strconv.ParseFloat(r.Value[1].(string), 64)
+	if err != nil {
+		t.Fatalf("invalid sample %v", r.Value)
+	}
+	return v
+}
+
+func newTestServer(t *testing.T, fleet Fleet, opts ...Option) (*Server, *httptest.Server) {
+	t.Helper()
+	fake := NewServer(fleet, opts...)
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestSelectors(t *testing.T) {
+	_, server := newTestServer(t, Fleet{Nodes: 2, GPUsPerNode: 4, Models: []string{"NVIDIA H100 80GB HBM3", "NVIDIA L4"}, Utilization: Constant(50)})
+
+	results := query(t, server, "nvidia_gpu_utilization_percent")
+	if len(results) != 8 {
+		t.Fatalf("expected 8 series, got %d", len(results))
+	}
+	first := results[0].Metric
+	if first["hostname"] != "gpu-node-00" || first["gpu_id"] != "0" || first["gpu_name"] != "NVIDIA H100 80GB HBM3" || first["uuid"] == "" {
+		t.Errorf("unexpected labels %v", first)
+	}
+	if v := sampleValue(t, results[0]); v != 50 {
+		t.Errorf("expected utilization 50, got %g", v)
+	}
+
+	results = query(t, server, `{__name__=~"nvidia_gpu_power_limit_watts|nvidia_gpu_temperature_celsius",hostname="gpu-node-01",gpu_id=~"0|1"}`)
+	if len(results) != 4 {
+		t.Fatalf("expected 4 series, got %d", len(results))
+	}
+	for _, r := range results {
+		if r.Metric["__name__"] == "nvidia_gpu_power_limit_watts" && sampleValue(t, r) != 72 {
+			t.Errorf("expected the L4 power limit, got %v", r.Value)
+		}
+	}
+}
+
+func TestAggregationsAndFunctions(t *testing.T) {
+	_, server := newTestServer(t, Fleet{Nodes: 3, GPUsPerNode: 2, Utilization: Constant(100), Naming: DCGMExporter()})
+
+	results := query(t, server, "count by (Hostname, modelName) (DCGM_FI_DEV_GPU_UTIL)")
+	if len(results) != 3 || sampleValue(t, results[0]) != 2 || len(results[0].Metric) != 2 {
+		t.Errorf("unexpected counts %+v", results)
+	}
+
+	results = query(t, server, "sum by (Hostname) (DCGM_FI_DEV_POWER_USAGE)")
+	if len(results) != 3 || sampleValue(t, results[0]) != 2*400*0.95 {
+		t.Errorf("unexpected power sums %+v", results)
+	}
+
+	// The energy counter grows at half the power limit, i.e. 200 Wh per hour for an A100
+	results = query(t, server, `increase(DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION{Hostname="gpu-node-00"}[3600s]) * 0.001 / 3600`)
+	if len(results) != 2 || sampleValue(t, results[0]) != 200 || results[0].Metric["__name__"] != "" {
+		t.Errorf("unexpected energy %+v", results)
+	}
+
+	results = query(t, server, "topk(1, avg_over_time(DCGM_FI_DEV_GPU_TEMP[5m]))")
+	if len(results) != 1 || sampleValue(t, results[0]) != 82 {
+		t.Errorf("unexpected topk %+v", results)
+	}
+}
+
+func TestKubeStateMetricsJoin(t *testing.T) {
+	_, server := newTestServer(t, Fleet{Nodes: 2, Allocated: 0.5, Namespaces: []string{"ml", "dev"}, KubeStateMetrics: true})
+
+	results := query(t, server, `(kube_pod_container_resource_requests{resource="nvidia.com/gpu"} > 0) * on (namespace, pod) group_left () (kube_pod_status_phase{phase="Running"} == 1)`)
+	if len(results) != 2 {
+		t.Fatalf("expected a request per node, got %+v", results)
+	}
+	r := results[1]
+	if r.Metric["namespace"] != "ml" || r.Metric["node"] != "gpu-node-00" || r.Metric["container"] != "trainer" || sampleValue(t, r) != 4 {
+		t.Errorf("unexpected request %+v", r)
+	}
+}
+
+func TestOfflineAndMissing(t *testing.T) {
+	_, server := newTestServer(t, Fleet{Nodes: 2, GPUsPerNode: 1, Offline: []string{"gpu-node-01"}, Missing: []string{"temperature"}})
+
+	if results := query(t, server, "nvidia_gpu_temperature_celsius"); len(results) != 0 {
+		t.Errorf("expected no temperature series, got %+v", results)
+	}
+	if results := query(t, server, "nvidia_gpu_utilization_percent"); len(results) != 1 || results[0].Metric["hostname"] != "gpu-node-00" {
+		t.Errorf("expected only the online node, got %+v", results)
+	}
+	results := query(t, server, "up == 0")
+	if len(results) != 1 || results[0].Metric["hostname"] != "gpu-node-01" {
+		t.Errorf("expected the offline node to be down, got %+v", results)
+	}
+}
+
+func TestQueryRange(t *testing.T) {
+	_, server := newTestServer(t, Fleet{GPUsPerNode: 2, Utilization: Diurnal(0, 100)})
+
+	code, resultType, results := get(t, server, "/api/v1/query_range", url.Values{
+		"query": {"avg_over_time(nvidia_gpu_utilization_percent[60s])"},
+		"start": {strconv.FormatInt(testTime.Unix(), 10)},
+		"end":   {strconv.FormatInt(testTime.Add(time.Hour).Unix(), 10)},
+		"step":  {"60"},
+	})
+	if code != http.StatusOK || resultType != "matrix" {
+		t.Fatalf("got status %d, result type %q", code, resultType)
+	}
+	if len(results) != 2 || len(results[0].Values) != 61 {
+		t.Fatalf("expected 2 series of 61 points, got %+v", results)
+	}
+	if ts := results[0].Values[1][0].(float64); ts != float64(testTime.Unix()+60) {
+		t.Errorf("unexpected second timestamp %v", ts)
+	}
+	if results[0].Values[0][1] == results[1].Values[0][1] {
+		t.Error("expected GPUs to follow the pattern at different phases")
+	}
+}
+
+func TestFaults(t *testing.T) {
+	fake, server := newTestServer(t, Fleet{}, WithFaults(Faults{FailMetrics: []string{"nvidia_gpu_temperature_celsius"}, Warnings: []string{"partial data"}}))
+
+	params := func(q string) url.Values { return url.Values{"query": {q}} }
+	if code, _, _ := get(t, server, "/api/v1/query", params(`{__name__=~"nvidia_gpu_.*_celsius"}`)); code != http.StatusServiceUnavailable {
+		t.Errorf("expected failing metric to return 503, got %d", code)
+	}
+	if code, _, _ := get(t, server, "/api/v1/query", params("nvidia_gpu_utilization_percent")); code != http.StatusOK {
+		t.Errorf("expected other metrics to succeed, got %d", code)
+	}
+	if code, _, _ := get(t, server, "/api/v1/query", params("sum(")); code != http.StatusBadRequest {
+		t.Errorf("expected invalid query to return 400, got %d", code)
+	}
+
+	fake.SetFaults(Faults{Status: http.StatusUnauthorized})
+	if code, _, _ := get(t, server, "/api/v1/query", params("up")); code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", code)
+	}
+	if got := len(fake.Queries()); got != 4 {
+		t.Errorf("expected 4 recorded queries, got %d", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, q := range []string{
+		`up{job=}`,
+		`rate(up[5x])`,
+		`unknown_fn(up)`,
+		`sum by (job (up)`,
+		`(up)[5m:1m]`,
+	} {
+		if _, err := parse(q); err == nil {
+			t.Errorf("expected error parsing %s", q)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// Package federation queries several Prometheus-backed clusters concurrently and
+// merges their results, tagging every item with the cluster it came from.
+package federation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+var _ metrics.Source = (*Federation)(nil)
+
+// Cluster is a named Prometheus endpoint.
+type Cluster struct {
+	Name   string
+	Client *prometheus.Client
+}
+
+// Federation fans queries out to its clusters. A single cluster may be left
+// unnamed, in which case results are not tagged and behave as before federation.
+type Federation struct {
+	clusters []Cluster
+}
+
+// New creates a federation over clusters. When more than one cluster is given,
+// each must have a unique, non-empty name.
+func New(clusters ...Cluster) (*Federation, error) {
+	if len(clusters) == 0 {
+		return nil, fmt.Errorf("at least one cluster is required")
+	}
+
+	seen := make(map[string]bool, len(clusters))
+	for _, c := range clusters {
+		if c.Client == nil {
+			return nil, fmt.Errorf("cluster %q has no Prometheus client", c.Name)
+		}
+		if c.Name == "" && len(clusters) > 1 {
+			return nil, fmt.Errorf("cluster names are required when federating several clusters")
+		}
+		if seen[c.Name] {
+			return nil, fmt.Errorf("duplicate cluster %q", c.Name)
+		}
+		seen[c.Name] = true
+	}
+
+	return &Federation{clusters: clusters}, nil
+}
+
+// Names returns the configured cluster names in order, or nil for a single unnamed cluster.
+func (f *Federation) Names() []string {
+	var names []string
+	for _, c := range f.clusters {
+		if c.Name != "" {
+			names = append(names, c.Name)
+		}
+	}
+	return names
+}
+
+// Validate checks that every name refers to a configured cluster.
+func (f *Federation) Validate(names []string) error {
+	for _, name := range names {
+		if !f.has(name) {
+			return fmt.Errorf("%w %q", metrics.ErrUnknownCluster, name)
+		}
+	}
+	return nil
+}
+
+// has reports whether a cluster named name is configured.
+func (f *Federation) has(name string) bool {
+	for _, c := range f.clusters {
+		if c.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// selected returns the clusters chosen by the context, in configured order.
+func (f *Federation) selected(ctx context.Context) []Cluster {
+	names := metrics.ClustersFrom(ctx)
+	if len(names) == 0 {
+		return f.clusters
+	}
+
+	var clusters []Cluster
+	for _, c := range f.clusters {
+		for _, name := range names {
+			if c.Name == name {
+				clusters = append(clusters, c)
+				break
+			}
+		}
+	}
+	return clusters
+}
+
+// result is the outcome of a query against a single cluster.
+type result[T any] struct {
+	cluster  string
+	value    T
+	warnings []models.Warning
+	err      error
+}
+
+// fanOut runs query against every selected cluster concurrently and returns the
+// results in configured order.
+func fanOut[T any](ctx context.Context, f *Federation, query func(context.Context, *prometheus.Client) (T, []models.Warning, error)) []result[T] {
+	clusters := f.selected(ctx)
+	results := make([]result[T], len(clusters))
+
+	var wg sync.WaitGroup
+	for i, c := range clusters {
+		wg.Add(1)
+		go func(i int, c Cluster) {
+			defer wg.Done()
+			value, warnings, err := query(ctx, c.Client)
+			results[i] = result[T]{cluster: c.Name, value: value, warnings: warnings, err: err}
+		}(i, c)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// merge passes each successful value to collect and tags warnings with their cluster.
+// A failed cluster becomes a warning; an error is only returned when every cluster failed.
+func merge[T any](results []result[T], collect func(cluster string, value T)) ([]models.Warning, error) {
+	var warnings []models.Warning
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			warnings = append(warnings, models.Warning{Cluster: r.cluster, Metric: "cluster", Reason: models.FailureReason(r.err)})
+			errs = append(errs, clusterError(r.cluster, r.err))
+			continue
+		}
+		for _, w := range r.warnings {
+			w.Cluster = r.cluster
+			warnings = append(warnings, w)
+		}
+		collect(r.cluster, r.value)
+	}
+
+	if len(errs) > 0 && len(errs) == len(results) {
+		return nil, errors.Join(errs...)
+	}
+	return warnings, nil
+}
+
+// clusterError prefixes err with the cluster name, leaving unnamed clusters' errors untouched.
+func clusterError(cluster string, err error) error {
+	if cluster == "" {
+		return err
+	}
+	return fmt.Errorf("cluster %s: %w", cluster, err)
+}
+
+// GetGPUMetrics retrieves current GPU metrics from every selected cluster.
+func (f *Federation) GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+	return f.SelectGPUMetrics(ctx, metrics.GPUSelector{})
+}
+
+// SelectGPUMetrics retrieves metrics for the GPUs matching sel from every selected cluster.
+func (f *Federation) SelectGPUMetrics(ctx context.Context, sel metrics.GPUSelector) ([]models.GPUMetrics, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) ([]models.GPUMetrics, []models.Warning, error) {
+		return c.SelectGPUMetrics(ctx, sel)
+	})
+
+	metrics := make([]models.GPUMetrics, 0)
+	warnings, err := merge(results, func(cluster string, value []models.GPUMetrics) {
+		for _, m := range value {
+			m.Cluster = cluster
+			metrics = append(metrics, m)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return metrics, warnings, nil
+}
+
+// GetGPUUtilization retrieves the latest utilization of every GPU in every selected cluster.
+func (f *Federation) GetGPUUtilization(ctx context.Context) ([]metrics.UtilizationSample, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) ([]metrics.UtilizationSample, []models.Warning, error) {
+		resp, err := c.GetGPUUtilization(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		return utilizationSamples(resp, c.Schema().Labels), nil, nil
+	})
+
+	var samples []metrics.UtilizationSample
+	warnings, err := merge(results, func(cluster string, value []metrics.UtilizationSample) {
+		for _, sample := range value {
+			sample.Cluster = cluster
+			samples = append(samples, sample)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return samples, warnings, nil
+}
+
+// utilizationSamples reads a utilization vector whose series carry labels,
+// skipping samples that do not identify a GPU or hold no number.
+func utilizationSamples(resp *prometheus.PrometheusResponse, labels prometheus.LabelNames) []metrics.UtilizationSample {
+	var samples []metrics.UtilizationSample
+	for _, result := range resp.Data.Result {
+		gpuIndex, err := strconv.Atoi(result.Metric[labels.GPUIndex])
+		if err != nil || len(result.Value) < 2 {
+			continue
+		}
+		ts, ok := result.Value[0].(float64)
+		if !ok {
+			continue
+		}
+		valueStr, _ := result.Value[1].(string)
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			continue
+		}
+
+		sec, frac := math.Modf(ts)
+		samples = append(samples, metrics.UtilizationSample{
+			NodeName:    result.Metric[labels.Node],
+			GPUIndex:    gpuIndex,
+			Utilization: value,
+			Timestamp:   time.Unix(int64(sec), int64(frac*1e9)).UTC(),
+		})
+	}
+	return samples
+}
+
+// GetGPUNodes retrieves GPU nodes from every selected cluster.
+func (f *Federation) GetGPUNodes(ctx context.Context) ([]models.GPUNode, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) ([]models.GPUNode, []models.Warning, error) {
+		nodes, err := c.GetGPUNodes(ctx)
+		return nodes, nil, err
+	})
+
+	nodes := make([]models.GPUNode, 0)
+	warnings, err := merge(results, func(cluster string, value []models.GPUNode) {
+		for _, node := range value {
+			node.Cluster = cluster
+			nodes = append(nodes, node)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return nodes, warnings, nil
+}
+
+// GetGPUMetricsRange retrieves per-GPU time series from every selected cluster.
+func (f *Federation) GetGPUMetricsRange(ctx context.Context, start, end time.Time, step time.Duration) ([]models.GPUTimeSeries, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) ([]models.GPUTimeSeries, []models.Warning, error) {
+		return c.GetGPUMetricsRange(ctx, start, end, step)
+	})
+
+	series := make([]models.GPUTimeSeries, 0)
+	warnings, err := merge(results, func(cluster string, value []models.GPUTimeSeries) {
+		for _, s := range value {
+			s.Cluster = cluster
+			series = append(series, s)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return series, warnings, nil
+}
+
+// GetGPUPods retrieves pod GPU allocations from every selected cluster.
+func (f *Federation) GetGPUPods(ctx context.Context) ([]models.GPUPodAllocation, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) ([]models.GPUPodAllocation, []models.Warning, error) {
+		return c.GetGPUPods(ctx)
+	})
+
+	pods := make([]models.GPUPodAllocation, 0)
+	warnings, err := merge(results, func(cluster string, value []models.GPUPodAllocation) {
+		for _, pod := range value {
+			pod.Cluster = cluster
+			for i := range pod.GPUs {
+				pod.GPUs[i].Cluster = cluster
+			}
+			pods = append(pods, pod)
+		}
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return pods, warnings, nil
+}
+
+// GetIdleGPUs builds one idle GPU report across every selected cluster.
+func (f *Federation) GetIdleGPUs(ctx context.Context, criteria metrics.IdleCriteria) (*models.IdleGPUReport, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) (*models.IdleGPUReport, []models.Warning, error) {
+		return c.GetIdleGPUs(ctx, criteria)
+	})
+
+	report := &models.IdleGPUReport{
+		Lookback:   criteria.Lookback.String(),
+		MaxAverage: criteria.MaxAverage,
+		MaxP95:     criteria.MaxP95,
+		GPUs:       []models.IdleGPU{},
+	}
+	warnings, err := merge(results, func(cluster string, value *models.IdleGPUReport) {
+		for _, gpu := range value.GPUs {
+			gpu.Cluster = cluster
+			report.GPUs = append(report.GPUs, gpu)
+		}
+		report.TotalWastedGPUHours += value.TotalWastedGPUHours
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sort.SliceStable(report.GPUs, func(i, j int) bool {
+		return report.GPUs[i].WastedGPUHours > report.GPUs[j].WastedGPUHours
+	})
+	return report, warnings, nil
+}
+
+// GetGPUNode retrieves a single node. The node must exist in exactly one selected cluster.
+func (f *Federation) GetGPUNode(ctx context.Context, nodeName string) (*models.GPUNodeDetail, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) (*models.GPUNodeDetail, []models.Warning, error) {
+		return c.GetGPUNode(ctx, nodeName)
+	})
+
+	detail, warnings, err := findOne(results)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range detail.value.GPUs {
+		detail.value.GPUs[i].Cluster = detail.cluster
+	}
+	detail.value.Cluster = detail.cluster
+	return detail.value, warnings, nil
+}
+
+// GetGPUDevice retrieves a single GPU. Its node must exist in exactly one selected cluster.
+func (f *Federation) GetGPUDevice(ctx context.Context, nodeName string, gpuIndex int) (*models.GPUDetail, []models.Warning, error) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) (*models.GPUDetail, []models.Warning, error) {
+		return c.GetGPUDevice(ctx, nodeName, gpuIndex)
+	})
+
+	device, warnings, err := findOne(results)
+	if err != nil {
+		return nil, nil, err
+	}
+	device.value.Cluster = device.cluster
+	return device.value, warnings, nil
+}
+
+// findOne returns the single cluster that found the requested item. Clusters
+// reporting metrics.ErrNotFound are skipped; other failures become warnings,
+// unless they leave the item unfound.
+func findOne[T any](results []result[T]) (result[T], []models.Warning, error) {
+	var found []result[T]
+	var warnings []models.Warning
+	var errs []error
+	for _, r := range results {
+		switch {
+		case r.err == nil:
+			found = append(found, r)
+		case errors.Is(r.err, metrics.ErrNotFound):
+		default:
+			warnings = append(warnings, models.Warning{Cluster: r.cluster, Metric: "cluster", Reason: models.FailureReason(r.err)})
+			errs = append(errs, clusterError(r.cluster, r.err))
+		}
+	}
+
+	switch {
+	case len(found) > 1:
+		clusters := make([]string, len(found))
+		for i, r := range found {
+			clusters[i] = r.cluster
+		}
+		return result[T]{}, nil, fmt.Errorf("%w: %s", metrics.ErrAmbiguous, strings.Join(clusters, ", "))
+	case len(found) == 0 && len(errs) > 0:
+		// The item may live in a cluster that could not be queried
+		return result[T]{}, nil, errors.Join(errs...)
+	case len(found) == 0:
+		return result[T]{}, nil, metrics.ErrNotFound
+	}
+
+	for _, w := range found[0].warnings {
+		w.Cluster = found[0].cluster
+		warnings = append(warnings, w)
+	}
+	return found[0], warnings, nil
+}
@@ -0,0 +1,199 @@
+package federation
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+// clusterServer serves one A100 on each of the given nodes, honouring hostname matchers.
+func clusterServer(t *testing.T, nodes ...string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		var series []string
+		for _, node := range nodes {
+			if strings.Contains(query, "hostname=") && !strings.Contains(query, `hostname="`+node+`"`) {
+				continue
+			}
+			series = append(series, `{"metric":{"hostname":"`+node+`","gpu_id":"0","gpu_name":"NVIDIA A100"},"value":[1700000000,"40"]}`)
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[` + strings.Join(series, ",") + `]}}`))
+	}))
+}
+
+// downServer fails every query.
+func downServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+}
+
+func newFederation(t *testing.T, servers map[string]*httptest.Server, order ...string) *Federation {
+	t.Helper()
+	var clusters []Cluster
+	for _, name := range order {
+		clusters = append(clusters, Cluster{Name: name, Client: prometheus.NewClient(servers[name].URL)})
+	}
+	f, err := New(clusters...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return f
+}
+
+func TestNew(t *testing.T) {
+	client := prometheus.NewClient("http://localhost:9090")
+
+	if _, err := New(Cluster{Client: client}); err != nil {
+		t.Errorf("expected a single unnamed cluster to be accepted, got %v", err)
+	}
+	if _, err := New(Cluster{Name: "a", Client: client}, Cluster{Client: client}); err == nil {
+		t.Error("expected unnamed cluster among several to be rejected")
+	}
+	if _, err := New(Cluster{Name: "a", Client: client}, Cluster{Name: "a", Client: client}); err == nil {
+		t.Error("expected duplicate cluster names to be rejected")
+	}
+	if _, err := New(); err == nil {
+		t.Error("expected an empty federation to be rejected")
+	}
+}
+
+func TestFederatedMetrics(t *testing.T) {
+	servers := map[string]*httptest.Server{
+		"east": clusterServer(t, "node1"),
+		"west": clusterServer(t, "node1", "node2"),
+		"down": downServer(t),
+	}
+	for _, s := range servers {
+		defer s.Close()
+	}
+	f := newFederation(t, servers, "east", "west", "down")
+
+	gpus, warnings, err := f.GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("expected partial result, got %v", err)
+	}
+	if len(gpus) != 3 {
+		t.Fatalf("expected 3 GPUs, got %d", len(gpus))
+	}
+	perCluster := map[string]int{}
+	for _, m := range gpus {
+		perCluster[m.Cluster]++
+	}
+	if perCluster["east"] != 1 || perCluster["west"] != 2 {
+		t.Errorf("unexpected cluster tags: %v", perCluster)
+	}
+
+	found := false
+	for _, w := range warnings {
+		if w.Cluster == "down" && w.Metric == "cluster" {
+			found = true
+			if strings.Contains(w.Reason, "unavailable") {
+				t.Errorf("expected response body to be withheld, got %q", w.Reason)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected a warning for the failed cluster, got %+v", warnings)
+	}
+
+	nodes, _, err := f.GetGPUNodes(context.Background())
+	if err != nil || len(nodes) != 3 {
+		t.Fatalf("expected 3 nodes, got %+v, %v", nodes, err)
+	}
+
+	// Selecting clusters skips the others entirely
+	ctx := metrics.WithClusters(context.Background(), []string{"west"})
+	gpus, warnings, err = f.GetGPUMetrics(ctx)
+	if err != nil || len(gpus) != 2 || len(warnings) != 0 {
+		t.Errorf("expected only west, got %+v, %+v, %v", gpus, warnings, err)
+	}
+
+	// Every selected cluster failing is an error
+	ctx = metrics.WithClusters(context.Background(), []string{"down"})
+	if _, _, err := f.GetGPUMetrics(ctx); err == nil || !strings.Contains(err.Error(), "cluster down") {
+		t.Errorf("expected error naming the failed cluster, got %v", err)
+	}
+}
+
+func TestFederatedUtilization(t *testing.T) {
+	servers := map[string]*httptest.Server{
+		"east": clusterServer(t, "node1"),
+		"down": downServer(t),
+	}
+	for _, s := range servers {
+		defer s.Close()
+	}
+	f := newFederation(t, servers, "east", "down")
+
+	samples, warnings, err := f.GetGPUUtilization(context.Background())
+	if err != nil || len(samples) != 1 || len(warnings) != 1 {
+		t.Fatalf("expected one sample and a warning, got %+v, %+v, %v", samples, warnings, err)
+	}
+	want := metrics.UtilizationSample{Cluster: "east", NodeName: "node1", GPUIndex: 0, Utilization: 40, Timestamp: time.Unix(1700000000, 0).UTC()}
+	if samples[0] != want {
+		t.Errorf("expected %+v, got %+v", want, samples[0])
+	}
+}
+
+func TestFederatedNode(t *testing.T) {
+	servers := map[string]*httptest.Server{
+		"east": clusterServer(t, "node1"),
+		"west": clusterServer(t, "node1", "node2"),
+	}
+	for _, s := range servers {
+		defer s.Close()
+	}
+	f := newFederation(t, servers, "east", "west")
+
+	node, _, err := f.GetGPUNode(context.Background(), "node2")
+	if err != nil || node.Cluster != "west" || node.GPUs[0].Cluster != "west" {
+		t.Fatalf("expected node2 from west, got %+v, %v", node, err)
+	}
+
+	if _, _, err := f.GetGPUNode(context.Background(), "node1"); !errors.Is(err, metrics.ErrAmbiguous) {
+		t.Errorf("expected ambiguity error, got %v", err)
+	}
+
+	node, _, err = f.GetGPUNode(metrics.WithClusters(context.Background(), []string{"east"}), "node1")
+	if err != nil || node.Cluster != "east" {
+		t.Errorf("expected node1 from east, got %+v, %v", node, err)
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	servers := map[string]*httptest.Server{
+		"east": clusterServer(t, "node1", "node2"),
+		"down": downServer(t),
+	}
+	for _, s := range servers {
+		defer s.Close()
+	}
+	f := newFederation(t, servers, "east", "down")
+
+	summaries, _ := f.Summaries(context.Background())
+	if len(summaries) != 2 {
+		t.Fatalf("expected 2 summaries, got %d", len(summaries))
+	}
+
+	east, down := summaries[0], summaries[1]
+	if !east.Healthy || east.NodeCount != 2 || east.GPUCount != 2 || east.AverageUtilization != 40 {
+		t.Errorf("unexpected east summary: %+v", east)
+	}
+	if down.Healthy || down.Error == "" {
+		t.Errorf("expected down cluster to be unhealthy, got %+v", down)
+	}
+
+	if err := f.Validate([]string{"east", "north"}); !errors.Is(err, metrics.ErrUnknownCluster) {
+		t.Errorf("expected unknown cluster error, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package federation
+
+import (
+	"context"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/prometheus"
+	"k8s-gpu-monitoring/internal/promql"
+)
+
+// Summaries reports the health and GPU totals of every selected cluster. An
+// unreachable cluster is reported as unhealthy rather than failing the call.
+func (f *Federation) Summaries(ctx context.Context) ([]models.ClusterSummary, []models.Warning) {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) ([]models.GPUMetrics, []models.Warning, error) {
+		return c.GetGPUMetrics(ctx)
+	})
+
+	now := time.Now()
+	summaries := make([]models.ClusterSummary, 0, len(results))
+	var warnings []models.Warning
+	for _, r := range results {
+		summary := models.ClusterSummary{Name: r.cluster, CheckedAt: now}
+		if r.err != nil {
+			summary.Error = models.FailureReason(r.err)
+			summaries = append(summaries, summary)
+			continue
+		}
+
+		summary.Healthy = true
+		nodes := make(map[string]bool)
+		for _, m := range r.value {
+			nodes[m.NodeName] = true
+			summary.AverageUtilization += m.Utilization
+			summary.MemoryUsed += m.MemoryUsed
+			summary.MemoryTotal += m.MemoryTotal
+			summary.PowerDraw += m.PowerDraw
+		}
+		summary.NodeCount = len(nodes)
+		summary.GPUCount = len(r.value)
+		if summary.GPUCount > 0 {
+			summary.AverageUtilization /= float64(summary.GPUCount)
+		}
+		summaries = append(summaries, summary)
+
+		for _, w := range r.warnings {
+			w.Cluster = r.cluster
+			warnings = append(warnings, w)
+		}
+	}
+
+	return summaries, warnings
+}
+
+// Ping checks connectivity to every configured cluster, returning the error of each unreachable one by name.
+func (f *Federation) Ping(ctx context.Context) map[string]error {
+	results := fanOut(ctx, f, func(ctx context.Context, c *prometheus.Client) (struct{}, []models.Warning, error) {
+		_, err := c.Query(ctx, promql.Select("up").String())
+		return struct{}{}, nil, err
+	})
+
+	failures := make(map[string]error)
+	for _, r := range results {
+		if r.err != nil {
+			failures[r.cluster] = r.err
+		}
+	}
+	return failures
+}
+
+// CacheStats returns the query cache counters summed over every cluster, or false if caching is disabled.
+func (f *Federation) CacheStats() (metrics.CacheStats, bool) {
+	var total metrics.CacheStats
+	enabled := false
+	for _, c := range f.clusters {
+		stats, ok := c.Client.CacheStats()
+		if !ok {
+			continue
+		}
+		enabled = true
+		total.Hits += stats.Hits
+		total.Misses += stats.Misses
+		total.Coalesced += stats.Coalesced
+		total.Stale += stats.Stale
+		total.Bypassed += stats.Bypassed
+		total.Entries += stats.Entries
+	}
+	return total, enabled
+}
+
+// BackendStatuses returns the circuit breaker state of every cluster's Prometheus client that has one.
+func (f *Federation) BackendStatuses() []metrics.BackendStatus {
+	var statuses []metrics.BackendStatus
+	for _, c := range f.clusters {
+		if status, ok := c.Client.BreakerStatus(); ok {
+			statuses = append(statuses, metrics.BackendStatus{
+				Cluster:             c.Name,
+				State:               status.State,
+				ConsecutiveFailures: status.ConsecutiveFailures,
+				OpenedAt:            status.OpenedAt,
+			})
+		}
+	}
+	return statuses
+}
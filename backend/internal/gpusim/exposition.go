@@ -0,0 +1,183 @@
+package gpusim
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+// ContentType is the media type of the text exposition format.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// dcgmXIDErrors and dcgmThrottleReasons are reported by dcgm-exporter only;
+// nvidia_gpu_exporter shows throttling through the SM clock alone.
+const (
+	dcgmXIDErrors       = "DCGM_FI_DEV_XID_ERRORS"
+	dcgmThrottleReasons = "DCGM_FI_DEV_CLOCK_THROTTLE_REASONS"
+)
+
+// Clock throttle reason bits as reported by NVML.
+const (
+	throttleGPUIdle    = 0x1
+	throttleSWThermal  = 0x20
+	throttleHWSlowdown = 0x40
+)
+
+// family is a metric exported for every GPU.
+type family struct {
+	name, help, kind string
+	value            func(g *gpu) float64
+}
+
+// families returns the metrics exported under the simulator's schema.
+func (s *Simulator) families() []family {
+	m := s.schema.Metrics
+	memory := func(bytes float64) float64 {
+		return math.Round(bytes / s.schema.MemoryBytesPerUnit)
+	}
+	all := []family{
+		{m.Utilization, "GPU utilization (in %).", "gauge", func(g *gpu) float64 { return g.utilization }},
+		{m.MemoryUsed, "Framebuffer memory used.", "gauge", func(g *gpu) float64 { return memory(g.memoryUsed()) }},
+		{m.MemoryTotal, "Total framebuffer memory.", "gauge", func(g *gpu) float64 { return memory(g.model.MemoryBytes) }},
+		{m.MemoryFree, "Framebuffer memory free.", "gauge", func(g *gpu) float64 { return memory(g.model.MemoryBytes) - memory(g.memoryUsed()) }},
+		{m.MemoryUtilization, "Memory utilization (in %).", "gauge", func(g *gpu) float64 { return g.memoryUtilization }},
+		{m.Temperature, "GPU temperature (in C).", "gauge", func(g *gpu) float64 { return math.Round(g.temperature) }},
+		{m.PowerDraw, "Power draw (in W).", "gauge", func(g *gpu) float64 { return math.Round(g.powerDraw*1000) / 1000 }},
+		{m.PowerLimit, "Enforced power limit (in W).", "gauge", func(g *gpu) float64 { return g.model.PowerLimit }},
+		{m.EnergyTotal, "Total energy consumption since boot.", "counter", func(g *gpu) float64 { return math.Round(g.energy / s.schema.EnergyJoulesPerUnit) }},
+		{m.SMClock, "SM clock frequency (in MHz).", "gauge", func(g *gpu) float64 { return g.smClock }},
+		{m.MemoryClock, "Memory clock frequency (in MHz).", "gauge", func(g *gpu) float64 { return g.model.MemoryClock }},
+		{m.ECCCorrected, "Total number of single-bit volatile ECC errors.", "counter", func(g *gpu) float64 { return g.eccCorrected }},
+		{m.ECCUncorrected, "Total number of double-bit volatile ECC errors.", "counter", func(g *gpu) float64 { return g.eccUncorrected }},
+	}
+	if s.schema.Name == prometheus.SchemaDCGMExporter {
+		all = append(all,
+			family{dcgmXIDErrors, "Value of the last XID error encountered.", "gauge", func(g *gpu) float64 { return float64(g.xid) }},
+			family{dcgmThrottleReasons, "Current clock throttle reasons.", "gauge", (*gpu).throttleReasons},
+		)
+	}
+
+	families := all[:0]
+	for _, f := range all {
+		if f.name != "" {
+			families = append(families, f)
+		}
+	}
+	return families
+}
+
+// memoryUsed returns the memory allocated by the GPU's workload in bytes.
+func (g *gpu) memoryUsed() float64 {
+	return g.model.MemoryBytes * g.memoryShare
+}
+
+// throttleReasons returns the NVML clock throttle reason bitmask.
+func (g *gpu) throttleReasons() float64 {
+	switch {
+	case g.throttled:
+		return throttleSWThermal | throttleHWSlowdown
+	case g.utilization == 0:
+		return throttleGPUIdle
+	}
+	return 0
+}
+
+// labels renders the label set identifying the GPU, in exporter order.
+func (s *Simulator) labels(g *gpu) string {
+	l := s.schema.Labels
+	pairs := [][2]string{
+		{l.GPUIndex, strconv.Itoa(g.index)},
+		{l.UUID, g.uuid},
+		{l.GPUName, g.model.Name},
+		{l.Node, g.node},
+		{l.PCIBusID, g.pciBusID},
+		{l.DriverVersion, driverVersion},
+	}
+	if g.owner != nil {
+		pairs = append(pairs,
+			[2]string{l.Container, g.owner.container},
+			[2]string{l.Namespace, g.owner.namespace},
+			[2]string{l.Pod, g.owner.pod},
+		)
+	}
+
+	var b strings.Builder
+	for _, p := range pairs {
+		if p[0] == "" {
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(p[0])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(p[1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// WriteMetrics writes the current readings of the GPUs on node, or of every
+// GPU if node is empty, in the text exposition format.
+func (s *Simulator) WriteMetrics(w io.Writer, node string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var gpus []*gpu
+	labels := make(map[*gpu]string)
+	for _, g := range s.gpus {
+		if node == "" || g.node == node {
+			gpus = append(gpus, g)
+			labels[g] = s.labels(g)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, f := range s.families() {
+		bw.WriteString("# HELP " + f.name + " " + f.help + "\n")
+		bw.WriteString("# TYPE " + f.name + " " + f.kind + "\n")
+		for _, g := range gpus {
+			bw.WriteString(f.name + "{" + labels[g] + "} ")
+			bw.WriteString(strconv.FormatFloat(f.value(g), 'f', -1, 64))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// Handler serves every GPU's readings on /metrics and a single node's, as its
+// own exporter would, on /nodes/{node}/metrics. Each scrape advances the
+// simulation to the current time.
+func (s *Simulator) Handler(now func() time.Time) http.Handler {
+	known := make(map[string]bool, len(s.nodes))
+	for _, node := range s.nodes {
+		known[node] = true
+	}
+	serve := func(w http.ResponseWriter, node string) {
+		s.Step(now())
+		w.Header().Set("Content-Type", ContentType)
+		s.WriteMetrics(w, node)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /metrics", func(w http.ResponseWriter, r *http.Request) {
+		serve(w, "")
+	})
+	mux.HandleFunc("GET /nodes/{node}/metrics", func(w http.ResponseWriter, r *http.Request) {
+		node := r.PathValue("node")
+		if !known[node] {
+			http.NotFound(w, r)
+			return
+		}
+		serve(w, node)
+	})
+	return mux
+}
@@ -0,0 +1,142 @@
+// Package gpusim simulates a fleet of GPUs running realistic workloads and
+// renders their readings in the Prometheus text exposition format of a GPU
+// exporter, for demos and load tests without GPUs.
+package gpusim
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Model describes the fixed characteristics of a GPU model.
+type Model struct {
+	Name        string
+	MemoryBytes float64
+	// PowerLimit and IdlePower are in watts.
+	PowerLimit float64
+	IdlePower  float64
+	// MaxSMClock and MemoryClock are in MHz.
+	MaxSMClock  float64
+	MemoryClock float64
+	// SlowdownTemp is the temperature in °C at which the GPU throttles its clocks.
+	SlowdownTemp float64
+}
+
+const gib = 1024 * 1024 * 1024
+
+// models holds the specifications of common data center GPUs.
+var models = map[string]Model{
+	"NVIDIA A100-SXM4-80GB": {MemoryBytes: 80 * gib, PowerLimit: 400, IdlePower: 55, MaxSMClock: 1410, MemoryClock: 1593, SlowdownTemp: 89},
+	"NVIDIA A100-PCIE-40GB": {MemoryBytes: 40 * gib, PowerLimit: 250, IdlePower: 35, MaxSMClock: 1410, MemoryClock: 1215, SlowdownTemp: 88},
+	"NVIDIA H100 80GB HBM3": {MemoryBytes: 80 * gib, PowerLimit: 700, IdlePower: 70, MaxSMClock: 1980, MemoryClock: 2619, SlowdownTemp: 87},
+	"NVIDIA L4":             {MemoryBytes: 24 * gib, PowerLimit: 72, IdlePower: 16, MaxSMClock: 2040, MemoryClock: 6251, SlowdownTemp: 85},
+	"Tesla T4":              {MemoryBytes: 16 * gib, PowerLimit: 70, IdlePower: 10, MaxSMClock: 1590, MemoryClock: 5001, SlowdownTemp: 85},
+	"Tesla V100-SXM2-32GB":  {MemoryBytes: 32 * gib, PowerLimit: 300, IdlePower: 40, MaxSMClock: 1530, MemoryClock: 877, SlowdownTemp: 87},
+}
+
+// ModelNames returns the names of the known models in sorted order.
+func ModelNames() []string {
+	names := make([]string, 0, len(models))
+	for name := range models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupModel returns the specification of a known GPU model.
+func LookupModel(name string) (Model, error) {
+	model, ok := models[name]
+	if !ok {
+		return Model{}, fmt.Errorf("unknown GPU model %q (available: %s)", name, strings.Join(ModelNames(), ", "))
+	}
+	model.Name = name
+	return model, nil
+}
+
+// Profile is the kind of workload a GPU runs.
+type Profile string
+
+// Workload profiles.
+const (
+	// ProfileTraining runs near full load with dips at every checkpoint and evaluation.
+	ProfileTraining Profile = "training"
+	// ProfileInference follows the daily request rate of an online service.
+	ProfileInference Profile = "inference"
+	// ProfileIdle is held by a pod, e.g. a notebook, with a model loaded but no work.
+	ProfileIdle Profile = "idle"
+	// ProfileFree is not allocated to any pod.
+	ProfileFree Profile = "free"
+)
+
+// profiles lists the profiles in the order they are assigned from a mix.
+var profiles = []Profile{ProfileTraining, ProfileInference, ProfileIdle, ProfileFree}
+
+// DefaultMix is the share of GPUs running each profile when none is configured.
+var DefaultMix = map[Profile]float64{
+	ProfileTraining:  4,
+	ProfileInference: 3,
+	ProfileIdle:      2,
+	ProfileFree:      1,
+}
+
+// ParseMix parses comma-separated profile=weight pairs such as "training=3,idle=1".
+func ParseMix(s string) (map[Profile]float64, error) {
+	mix := make(map[Profile]float64)
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, weight, ok := strings.Cut(item, "=")
+		profile := Profile(strings.TrimSpace(name))
+		if !ok || !containsProfile(profile) {
+			return nil, fmt.Errorf("%q is not profile=weight with a profile of %v", item, profiles)
+		}
+		var w float64
+		if _, err := fmt.Sscanf(strings.TrimSpace(weight), "%g", &w); err != nil || w < 0 || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("invalid weight in %q", item)
+		}
+		mix[profile] = w
+	}
+	return mix, nil
+}
+
+func containsProfile(p Profile) bool {
+	for _, profile := range profiles {
+		if profile == p {
+			return true
+		}
+	}
+	return false
+}
+
+// owner is the pod container holding a GPU.
+type owner struct {
+	namespace, pod, container string
+}
+
+// profileOwner returns the pod running profile on a GPU. Training jobs take
+// every training GPU of a node; other pods hold a single GPU.
+func profileOwner(profile Profile, node string, index int) *owner {
+	switch profile {
+	case ProfileTraining:
+		return &owner{namespace: "ml-training", pod: "train-" + node, container: "trainer"}
+	case ProfileInference:
+		return &owner{namespace: "inference", pod: fmt.Sprintf("serve-%s-%d", node, index), container: "server"}
+	case ProfileIdle:
+		return &owner{namespace: "research", pod: fmt.Sprintf("notebook-%s-%d", node, index), container: "jupyter"}
+	}
+	return nil
+}
+
+// uuid derives a stable GPU UUID from its node and index.
+func uuid(node string, index int) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%d", node, index)
+	sum := h.Sum64()
+	return fmt.Sprintf("GPU-%08x-%04x-%04x-%04x-%012x", uint32(sum>>32), uint16(sum>>16), uint16(sum), uint16(index), sum&0xffffffffffff)
+}
@@ -0,0 +1,320 @@
+package gpusim
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+const (
+	// ambientTemp is the inlet temperature in °C that idle GPUs settle towards.
+	ambientTemp = 30
+	// thermalTimeConstant is how quickly a GPU's temperature follows its power draw.
+	thermalTimeConstant = 90 * time.Second
+	// throttleHysteresis is how far below the slowdown temperature a GPU must cool to restore its clocks.
+	throttleHysteresis = 4
+	// throttlePower is the fraction of its demanded power a throttled GPU draws.
+	throttlePower = 0.7
+	// idleSMClock is the SM clock in MHz of a GPU without work.
+	idleSMClock = 210
+
+	// trainingCycle is the interval between checkpoints of a training job, and
+	// checkpointStall how long the GPUs wait for each checkpoint to be written.
+	trainingCycle   = 30 * time.Minute
+	checkpointStall = 2 * time.Minute
+
+	// xidHold is how long an XID error code stays reported after it occurred.
+	xidHold = 5 * time.Minute
+	// eccCorrectedRate is the expected single-bit ECC errors per hour of a busy GPU.
+	eccCorrectedRate = 0.5
+)
+
+// xidCodes are the XID errors commonly seen on busy data center GPUs: graphics
+// engine exceptions, MMU faults, stopped GPUs, double-bit ECC errors, row
+// remapping and NVLink errors.
+var xidCodes = []int{13, 31, 43, 48, 63, 74, 94}
+
+// driverVersion is reported by every simulated GPU.
+const driverVersion = "550.54.15"
+
+// Config describes the simulated fleet.
+type Config struct {
+	// Schema names the exported metrics and labels; nvidia_gpu_exporter's by default.
+	Schema prometheus.Schema
+	// Nodes and GPUsPerNode size the fleet; 32 nodes of 8 GPUs by default.
+	Nodes       int
+	GPUsPerNode int
+	// Models are assigned to nodes round-robin; all A100s by default.
+	Models []string
+	// Mix weights the profiles assigned to GPUs; DefaultMix if empty.
+	Mix map[Profile]float64
+	// HotNodes is the fraction of nodes whose poor cooling makes busy GPUs throttle.
+	HotNodes float64
+	// XIDRate is the expected number of XID errors per GPU per hour.
+	XIDRate float64
+	// Seed makes the fleet layout and its readings reproducible.
+	Seed uint64
+}
+
+// gpu is the state of a simulated GPU.
+type gpu struct {
+	node     string
+	index    int
+	model    Model
+	uuid     string
+	pciBusID string
+	profile  Profile
+	owner    *owner
+	// cooling is the temperature rise in °C per watt drawn.
+	cooling float64
+	// phase offsets the GPU's workload cycle in seconds.
+	phase float64
+	// memoryShare is the fraction of memory the workload allocates.
+	memoryShare float64
+
+	utilization       float64
+	memoryUtilization float64
+	temperature       float64
+	powerDraw         float64
+	smClock           float64
+	throttled         bool
+	// energy is the energy consumed in joules.
+	energy         float64
+	xid            int
+	xidAt          time.Time
+	eccCorrected   float64
+	eccUncorrected float64
+}
+
+// Simulator advances a fleet of GPUs through time. It is safe for concurrent use.
+type Simulator struct {
+	schema  prometheus.Schema
+	xidRate float64
+	nodes   []string
+
+	mu   sync.Mutex
+	rng  *rand.Rand
+	gpus []*gpu
+	last time.Time
+}
+
+// New creates a simulator whose GPUs start idle at start.
+func New(cfg Config, start time.Time) (*Simulator, error) {
+	if cfg.Schema.Name == "" {
+		cfg.Schema = prometheus.DefaultSchema()
+	}
+	if cfg.Nodes <= 0 {
+		cfg.Nodes = 32
+	}
+	if cfg.GPUsPerNode <= 0 {
+		cfg.GPUsPerNode = 8
+	}
+	if len(cfg.Models) == 0 {
+		cfg.Models = []string{"NVIDIA A100-SXM4-80GB"}
+	}
+	if len(cfg.Mix) == 0 {
+		cfg.Mix = DefaultMix
+	}
+	var totalWeight float64
+	for _, w := range cfg.Mix {
+		totalWeight += w
+	}
+	if totalWeight <= 0 {
+		return nil, fmt.Errorf("profile mix has no positive weight")
+	}
+
+	nodeModels := make([]Model, len(cfg.Models))
+	for i, name := range cfg.Models {
+		model, err := LookupModel(name)
+		if err != nil {
+			return nil, err
+		}
+		nodeModels[i] = model
+	}
+
+	rng := rand.New(rand.NewPCG(cfg.Seed, cfg.Seed^0x9e3779b97f4a7c15))
+	s := &Simulator{schema: cfg.Schema, xidRate: cfg.XIDRate, rng: rng, last: start}
+	for n := 0; n < cfg.Nodes; n++ {
+		node := fmt.Sprintf("gpu-node-%02d", n)
+		s.nodes = append(s.nodes, node)
+		model := nodeModels[n%len(nodeModels)]
+
+		// Normal cooling holds a fully loaded GPU well below its slowdown
+		// temperature; poor cooling pushes it past.
+		peak := 72.0
+		if rng.Float64() < cfg.HotNodes {
+			peak = model.SlowdownTemp + 6
+		}
+		cooling := (peak - ambientTemp) / model.PowerLimit
+		checkpointPhase := rng.Float64() * trainingCycle.Seconds()
+
+		for i := 0; i < cfg.GPUsPerNode; i++ {
+			profile := pickProfile(rng, cfg.Mix, totalWeight)
+			g := &gpu{
+				node:        node,
+				index:       i,
+				model:       model,
+				uuid:        uuid(node, i),
+				pciBusID:    fmt.Sprintf("00000000:%02X:00.0", 0x10+i*0x10),
+				profile:     profile,
+				owner:       profileOwner(profile, node, i),
+				cooling:     cooling,
+				memoryShare: memoryShare(rng, profile),
+			}
+			switch profile {
+			case ProfileTraining:
+				// The GPUs of a training job checkpoint together.
+				g.phase = checkpointPhase
+			case ProfileInference:
+				// Replicas of a service see roughly the same traffic.
+				g.phase = (rng.Float64() - 0.5) * time.Hour.Seconds()
+			}
+			g.temperature = ambientTemp + cooling*model.IdlePower
+			g.powerDraw = model.IdlePower
+			g.smClock = idleSMClock
+			s.gpus = append(s.gpus, g)
+		}
+	}
+	return s, nil
+}
+
+// pickProfile draws a profile according to the mix weights.
+func pickProfile(rng *rand.Rand, mix map[Profile]float64, total float64) Profile {
+	r := rng.Float64() * total
+	for _, p := range profiles {
+		if r < mix[p] {
+			return p
+		}
+		r -= mix[p]
+	}
+	return ProfileFree
+}
+
+// memoryShare returns the fraction of memory a workload of profile allocates.
+func memoryShare(rng *rand.Rand, profile Profile) float64 {
+	switch profile {
+	case ProfileTraining:
+		return 0.85 + rng.Float64()*0.12
+	case ProfileInference:
+		return 0.35 + rng.Float64()*0.2
+	case ProfileIdle:
+		return 0.2 + rng.Float64()*0.2
+	}
+	return 0
+}
+
+// Nodes returns the names of the simulated nodes.
+func (s *Simulator) Nodes() []string {
+	return append([]string(nil), s.nodes...)
+}
+
+// GPUCount returns the number of simulated GPUs.
+func (s *Simulator) GPUCount() int {
+	return len(s.gpus)
+}
+
+// Step advances every GPU to now. Steps backwards in time are ignored.
+func (s *Simulator) Step(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dt := now.Sub(s.last)
+	if dt <= 0 {
+		return
+	}
+	s.last = now
+	for _, g := range s.gpus {
+		g.step(now, dt, s.rng, s.xidRate)
+	}
+}
+
+// step advances the GPU by dt, ending at now.
+func (g *gpu) step(now time.Time, dt time.Duration, rng *rand.Rand, xidRate float64) {
+	seconds := dt.Seconds()
+	load := g.load(now, rng)
+
+	g.utilization = math.Round(load)
+	switch g.profile {
+	case ProfileTraining:
+		g.memoryUtilization = math.Round(load * 0.55)
+	case ProfileInference:
+		g.memoryUtilization = math.Round(load * 0.35)
+	default:
+		g.memoryUtilization = 0
+	}
+
+	power := g.model.IdlePower + (g.model.PowerLimit-g.model.IdlePower)*load/100*(0.9+0.1*rng.Float64())
+	if g.throttled {
+		power *= throttlePower
+	}
+	g.powerDraw = power
+	g.energy += power * seconds
+
+	target := ambientTemp + g.cooling*power
+	g.temperature += (target - g.temperature) * (1 - math.Exp(-seconds/thermalTimeConstant.Seconds()))
+	switch {
+	case g.temperature >= g.model.SlowdownTemp:
+		g.throttled = true
+	case g.temperature < g.model.SlowdownTemp-throttleHysteresis:
+		g.throttled = false
+	}
+
+	switch {
+	case load < 1:
+		g.smClock = idleSMClock
+	case g.throttled:
+		g.smClock = math.Round(g.model.MaxSMClock * 0.65)
+	default:
+		g.smClock = g.model.MaxSMClock
+	}
+
+	if g.xid != 0 && now.Sub(g.xidAt) > xidHold {
+		g.xid = 0
+	}
+	if happened(rng, xidRate, seconds) {
+		g.xid = xidCodes[rng.IntN(len(xidCodes))]
+		g.xidAt = now
+		if g.xid == 48 {
+			g.eccUncorrected++
+		}
+	}
+	if load > 50 && happened(rng, eccCorrectedRate, seconds) {
+		g.eccCorrected++
+	}
+}
+
+// happened reports whether an event with the given hourly rate occurred in seconds.
+func happened(rng *rand.Rand, perHour, seconds float64) bool {
+	return perHour > 0 && rng.Float64() < 1-math.Exp(-perHour*seconds/3600)
+}
+
+// load returns the GPU's utilization in percent at t.
+func (g *gpu) load(t time.Time, rng *rand.Rand) float64 {
+	switch g.profile {
+	case ProfileTraining:
+		cycle := math.Mod(float64(t.Unix())+g.phase, trainingCycle.Seconds())
+		if cycle < checkpointStall.Seconds() {
+			return 2 + rng.Float64()*6
+		}
+		return clamp(96+rng.NormFloat64()*2.5, 0, 100)
+	case ProfileInference:
+		// Traffic bottoms out at 02:00 UTC and peaks twelve hours later.
+		hour := float64(t.Unix()%86400)/3600 + g.phase/3600
+		diurnal := (1 - math.Cos(2*math.Pi*(hour-2)/24)) / 2
+		return clamp(10+70*diurnal+rng.NormFloat64()*6, 0, 100)
+	case ProfileIdle:
+		// A notebook occasionally runs a cell.
+		if rng.Float64() < 0.05 {
+			return 1 + rng.Float64()*4
+		}
+	}
+	return 0
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
@@ -0,0 +1,267 @@
+package gpusim
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+// testStart is 00:00 UTC, when inference traffic is low.
+var testStart = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// sample is a parsed exposition line.
+type sample struct {
+	labels map[string]string
+	value  float64
+}
+
+// scrape renders the simulator's metrics and parses them by metric name.
+func scrape(t *testing.T, sim *Simulator, node string) (map[string][]sample, string) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := sim.WriteMetrics(&buf, node); err != nil {
+		t.Fatalf("WriteMetrics: %v", err)
+	}
+	text := buf.String()
+
+	metrics := make(map[string][]sample)
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, rest, _ := strings.Cut(line, "{")
+		labelText, valueText, _ := strings.Cut(rest, "} ")
+		labels := make(map[string]string)
+		for _, pair := range strings.Split(labelText, `",`) {
+			k, v, _ := strings.Cut(pair, `="`)
+			labels[k] = strings.TrimSuffix(v, `"`)
+		}
+		value, err := strconv.ParseFloat(valueText, 64)
+		if err != nil {
+			t.Fatalf("invalid line %q", line)
+		}
+		metrics[name] = append(metrics[name], sample{labels: labels, value: value})
+	}
+	return metrics, text
+}
+
+// run steps sim from testStart for d in scrape-interval steps, calling fn after each.
+func run(sim *Simulator, d time.Duration, fn func(now time.Time)) {
+	for now := testStart.Add(15 * time.Second); !now.After(testStart.Add(d)); now = now.Add(15 * time.Second) {
+		sim.Step(now)
+		if fn != nil {
+			fn(now)
+		}
+	}
+}
+
+func newSim(t *testing.T, cfg Config) *Simulator {
+	t.Helper()
+	sim, err := New(cfg, testStart)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return sim
+}
+
+func TestExpositionSchemas(t *testing.T) {
+	for _, name := range prometheus.SchemaNames() {
+		t.Run(name, func(t *testing.T) {
+			schema, _ := prometheus.LookupSchema(name)
+			sim := newSim(t, Config{Schema: schema, Nodes: 2, GPUsPerNode: 4, Mix: map[Profile]float64{ProfileTraining: 1}})
+			run(sim, time.Minute, nil)
+			metrics, text := scrape(t, sim, "")
+
+			if !strings.Contains(text, "# TYPE "+schema.Metrics.Utilization+" gauge\n") {
+				t.Errorf("expected a TYPE line for %s", schema.Metrics.Utilization)
+			}
+			util := metrics[schema.Metrics.Utilization]
+			if len(util) != 8 {
+				t.Fatalf("expected 8 utilization samples, got %d", len(util))
+			}
+			first := util[0].labels
+			if first[schema.Labels.Node] != "gpu-node-00" || first[schema.Labels.GPUIndex] != "0" || first[schema.Labels.UUID] == "" || first[schema.Labels.GPUName] != "NVIDIA A100-SXM4-80GB" {
+				t.Errorf("unexpected labels %v", first)
+			}
+			if schema.Labels.Pod != "" && first[schema.Labels.Pod] != "train-gpu-node-00" {
+				t.Errorf("expected the training pod, got %v", first)
+			}
+
+			used, free := metrics[schema.Metrics.MemoryUsed][0].value, metrics[schema.Metrics.MemoryFree][0].value
+			if total := (used + free) * schema.MemoryBytesPerUnit; total != 80*gib {
+				t.Errorf("expected used + free to be 80 GiB, got %g bytes", total)
+			}
+			if _, ok := metrics[dcgmXIDErrors]; ok != (name == prometheus.SchemaDCGMExporter) {
+				t.Errorf("XID errors exported: %v", ok)
+			}
+			if schema.Metrics.EnergyTotal != "" && metrics[schema.Metrics.EnergyTotal][0].value <= 0 {
+				t.Error("expected the energy counter to increase")
+			}
+		})
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	mean := func(cfg Config, d time.Duration) (util, memory float64) {
+		sim := newSim(t, cfg)
+		var n float64
+		run(sim, d, func(time.Time) {
+			for _, g := range sim.gpus {
+				util += g.utilization
+				memory += g.memoryShare
+				n++
+			}
+		})
+		return util / n, memory / n
+	}
+
+	if util, memory := mean(Config{Nodes: 4, Mix: map[Profile]float64{ProfileTraining: 1}}, time.Hour); util < 85 || util > 95 || memory < 0.85 {
+		t.Errorf("expected training to stay busy between checkpoints, got utilization %g, memory %g", util, memory)
+	}
+	if util, memory := mean(Config{Nodes: 4, Mix: map[Profile]float64{ProfileIdle: 1}}, time.Hour); util > 1 || memory < 0.2 {
+		t.Errorf("expected idle reservations to hold memory without work, got utilization %g, memory %g", util, memory)
+	}
+	if util, memory := mean(Config{Nodes: 4, Mix: map[Profile]float64{ProfileFree: 1}}, time.Hour); util != 0 || memory != 0 {
+		t.Errorf("expected free GPUs to be unused, got utilization %g, memory %g", util, memory)
+	}
+
+	// Inference traffic peaks in the afternoon.
+	sim := newSim(t, Config{Nodes: 4, Mix: map[Profile]float64{ProfileInference: 1}})
+	var night, afternoon float64
+	run(sim, 24*time.Hour, func(now time.Time) {
+		for _, g := range sim.gpus {
+			switch now.Hour() {
+			case 2:
+				night += g.utilization
+			case 14:
+				afternoon += g.utilization
+			}
+		}
+	})
+	if afternoon < 3*night {
+		t.Errorf("expected a diurnal pattern, got %g at night and %g in the afternoon", night, afternoon)
+	}
+}
+
+func TestThermalThrottling(t *testing.T) {
+	schema, _ := prometheus.LookupSchema(prometheus.SchemaDCGMExporter)
+	hot := newSim(t, Config{Schema: schema, Nodes: 2, HotNodes: 1, Mix: map[Profile]float64{ProfileTraining: 1}})
+	var throttled int
+	run(hot, time.Hour, func(time.Time) {
+		for _, g := range hot.gpus {
+			if g.throttled {
+				throttled++
+				if g.smClock >= g.model.MaxSMClock || g.throttleReasons() != throttleSWThermal|throttleHWSlowdown {
+					t.Fatalf("throttled GPU reports clock %g, reasons %g", g.smClock, g.throttleReasons())
+				}
+			}
+			if g.temperature > g.model.SlowdownTemp+6 {
+				t.Fatalf("temperature %g exceeds the hot node's peak", g.temperature)
+			}
+		}
+	})
+	if throttled == 0 {
+		t.Error("expected busy GPUs on hot nodes to throttle")
+	}
+
+	cool := newSim(t, Config{Nodes: 2, Mix: map[Profile]float64{ProfileTraining: 1}})
+	run(cool, time.Hour, func(time.Time) {
+		for _, g := range cool.gpus {
+			if g.throttled {
+				t.Fatalf("GPU on a well-cooled node throttled at %g°C", g.temperature)
+			}
+		}
+	})
+}
+
+func TestXIDErrors(t *testing.T) {
+	schema, _ := prometheus.LookupSchema(prometheus.SchemaDCGMExporter)
+	sim := newSim(t, Config{Schema: schema, Nodes: 1, XIDRate: 60})
+	run(sim, 10*time.Minute, nil)
+
+	metrics, _ := scrape(t, sim, "")
+	var reported int
+	for _, s := range metrics[dcgmXIDErrors] {
+		if s.value != 0 {
+			reported++
+		}
+	}
+	if reported == 0 {
+		t.Error("expected XID errors at a rate of one per minute")
+	}
+
+	quiet := newSim(t, Config{Schema: schema, Nodes: 1})
+	run(quiet, time.Hour, nil)
+	metrics, _ = scrape(t, quiet, "")
+	for _, s := range metrics[dcgmXIDErrors] {
+		if s.value != 0 {
+			t.Fatalf("unexpected XID error %g without an error rate", s.value)
+		}
+	}
+}
+
+func TestSeedReproducible(t *testing.T) {
+	render := func(seed uint64) string {
+		sim := newSim(t, Config{Nodes: 3, HotNodes: 0.5, XIDRate: 1, Seed: seed})
+		run(sim, 30*time.Minute, nil)
+		_, text := scrape(t, sim, "")
+		return text
+	}
+	if render(7) != render(7) {
+		t.Error("expected the same seed to produce the same readings")
+	}
+	if render(7) == render(8) {
+		t.Error("expected different seeds to produce different readings")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	sim := newSim(t, Config{Nodes: 3, GPUsPerNode: 2})
+	server := httptest.NewServer(sim.Handler(func() time.Time { return testStart.Add(time.Minute) }))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/nodes/gpu-node-01/metrics")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	var body bytes.Buffer
+	body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != ContentType {
+		t.Fatalf("got status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	if n := strings.Count(body.String(), "nvidia_gpu_utilization_percent{"); n != 2 || strings.Contains(body.String(), "gpu-node-00") {
+		t.Errorf("expected only gpu-node-01's 2 GPUs, got:\n%s", body.String())
+	}
+
+	resp, err = http.Get(server.URL + "/nodes/gpu-node-09/metrics")
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown node, got %d", resp.StatusCode)
+	}
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := ParseMix("training=3, idle=1")
+	if err != nil || mix[ProfileTraining] != 3 || mix[ProfileIdle] != 1 || len(mix) != 2 {
+		t.Errorf("unexpected mix %v, %v", mix, err)
+	}
+	for _, s := range []string{"gaming=1", "training", "idle=-1"} {
+		if _, err := ParseMix(s); err == nil {
+			t.Errorf("expected error parsing %q", s)
+		}
+	}
+	if _, err := New(Config{Mix: map[Profile]float64{ProfileIdle: 0}}, testStart); err == nil {
+		t.Error("expected error for a mix without weight")
+	}
+}
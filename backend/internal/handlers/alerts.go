@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+
+	"k8s-gpu-monitoring/internal/alerts"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// AlertHandler serves the state of the alerting engine.
+type AlertHandler struct {
+	engine *alerts.Engine
+}
+
+// NewAlertHandler creates an alert handler reading from engine.
+func NewAlertHandler(engine *alerts.Engine) *AlertHandler {
+	return &AlertHandler{engine: engine}
+}
+
+// GetAlerts handles GET /api/v1/alerts - returns pending and firing alerts.
+// The state query parameter narrows the list to pending or firing alerts, and
+// cluster to alerts of the named clusters.
+func (h *AlertHandler) GetAlerts(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	if state != "" && state != models.AlertPending && state != models.AlertFiring {
+		writeJSON(w, http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Error:   "invalid state: expected pending or firing",
+		})
+		return
+	}
+
+	clusters := parseListParam(r.URL.Query(), "cluster")
+	scope, scoped := requestScope(r)
+	active := h.engine.Alerts()
+	filtered := make([]models.Alert, 0, len(active))
+	for _, alert := range active {
+		if scoped && !scope.Allows(alert.NodeName, alert.Owner) {
+			continue
+		}
+		if len(clusters) > 0 && !clusters[alert.Cluster] {
+			continue
+		}
+		if state == "" || alert.State == state {
+			filtered = append(filtered, alert)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    filtered,
+		Message: "Alerts retrieved successfully",
+	})
+}
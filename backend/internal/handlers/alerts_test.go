@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/alerts"
+	"k8s-gpu-monitoring/internal/auth"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+func TestGetAlerts(t *testing.T) {
+	rules := []alerts.Rule{
+		{Name: "Hot", Metric: "temperature", Operator: ">", Threshold: 80},
+		{Name: "Busy", Metric: "utilization", Operator: ">", Threshold: 90, For: alerts.Duration(time.Hour)},
+	}
+	engine := alerts.NewEngine(rules, nil, nil, time.Minute)
+	engine.Evaluate([]models.GPUMetrics{{NodeName: "node1", Temperature: 85, Utilization: 95}}, nil, time.Now())
+
+	handler := NewAlertHandler(engine)
+
+	tests := []struct {
+		query      string
+		wantStatus int
+		wantCount  int
+	}{
+		{"", http.StatusOK, 2},
+		{"?state=firing", http.StatusOK, 1},
+		{"?state=pending", http.StatusOK, 1},
+		{"?state=resolved", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.GetAlerts(w, httptest.NewRequest(http.MethodGet, "/api/v1/alerts"+tt.query, nil))
+
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: got status %d, want %d", tt.query, w.Code, tt.wantStatus)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		var response struct {
+			Data []models.Alert `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: decoding response: %v", tt.query, err)
+		}
+		if len(response.Data) != tt.wantCount {
+			t.Errorf("%s: got %d alerts, want %d", tt.query, len(response.Data), tt.wantCount)
+		}
+	}
+}
+
+func TestGetAlertsScoped(t *testing.T) {
+	rules := []alerts.Rule{{Name: "Hot", Metric: "temperature", Operator: ">", Threshold: 80}}
+	engine := alerts.NewEngine(rules, nil, nil, time.Minute)
+	engine.Evaluate([]models.GPUMetrics{
+		{NodeName: "node1", Temperature: 85, Owner: &models.GPUOwner{Namespace: "ml", Pod: "train"}},
+		{NodeName: "node2", Temperature: 85},
+	}, nil, time.Now())
+
+	tests := []struct {
+		name      string
+		grant     *auth.Grant
+		wantNodes []string
+	}{
+		{"authorization disabled", nil, []string{"node1", "node2"}},
+		{"admin", &auth.Grant{Admin: true}, []string{"node1", "node2"}},
+		{"namespace", &auth.Grant{Clauses: []auth.Clause{{Namespaces: []string{"ml"}}}}, []string{"node1"}},
+		{"node", &auth.Grant{Clauses: []auth.Clause{{Nodes: []string{"node2"}}}}, []string{"node2"}},
+		{"other namespace", &auth.Grant{Clauses: []auth.Clause{{Namespaces: []string{"finance"}}}}, nil},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/alerts", nil)
+		if tt.grant != nil {
+			r = r.WithContext(auth.WithGrant(r.Context(), *tt.grant))
+		}
+		w := httptest.NewRecorder()
+		NewAlertHandler(engine).GetAlerts(w, r)
+
+		var response struct {
+			Data []models.Alert `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &response)
+
+		var nodes []string
+		for _, alert := range response.Data {
+			nodes = append(nodes, alert.NodeName)
+		}
+		if !reflect.DeepEqual(nodes, tt.wantNodes) {
+			t.Errorf("%s: got nodes %v, want %v", tt.name, nodes, tt.wantNodes)
+		}
+	}
+}
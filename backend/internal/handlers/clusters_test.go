@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"k8s-gpu-monitoring/internal/fakeprom"
+	"k8s-gpu-monitoring/internal/federation"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+func TestClusterParameter(t *testing.T) {
+	up := httptest.NewServer(fakeprom.NewServer(fakeprom.Fleet{GPUsPerNode: 1}))
+	defer up.Close()
+	down := httptest.NewServer(fakeprom.NewServer(fakeprom.Fleet{}, fakeprom.WithFaults(fakeprom.Faults{Status: http.StatusServiceUnavailable})))
+	defer down.Close()
+
+	clusters, err := federation.New(
+		federation.Cluster{Name: "east", Client: prometheus.NewClient(up.URL)},
+		federation.Cluster{Name: "west", Client: prometheus.NewClient(down.URL)},
+	)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := NewGPUHandler(clusters)
+
+	tests := []struct {
+		query        string
+		wantStatus   int
+		wantGPUs     int
+		wantWarnings bool
+	}{
+		{"", http.StatusOK, 1, true},
+		{"?cluster=east", http.StatusOK, 1, false},
+		{"?cluster=west", http.StatusInternalServerError, 0, false},
+		{"?cluster=north", http.StatusBadRequest, 0, false},
+	}
+
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		handler.GetGPUMetrics(w, httptest.NewRequest(http.MethodGet, "/api/v1/gpu/metrics"+tt.query, nil))
+
+		if w.Code != tt.wantStatus {
+			t.Fatalf("%s: got status %d, want %d", tt.query, w.Code, tt.wantStatus)
+		}
+		if tt.wantStatus != http.StatusOK {
+			continue
+		}
+
+		var response struct {
+			Data     []models.GPUMetrics `json:"data"`
+			Warnings []models.Warning    `json:"warnings"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s: decoding response: %v", tt.query, err)
+		}
+		if len(response.Data) != tt.wantGPUs || response.Data[0].Cluster != "east" {
+			t.Errorf("%s: unexpected GPUs %+v", tt.query, response.Data)
+		}
+		if (len(response.Warnings) > 0) != tt.wantWarnings {
+			t.Errorf("%s: unexpected warnings %+v", tt.query, response.Warnings)
+		}
+	}
+
+	// One reachable cluster keeps the service healthy
+	w := httptest.NewRecorder()
+	handler.HealthCheck(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected degraded health to return 200, got %d", w.Code)
+	}
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// gpuFilter selects GPUs by cluster, node, model, owning namespace and metric ranges.
+// Empty sets and nil bounds match everything.
+type gpuFilter struct {
+	clusters   map[string]bool
+	nodes      map[string]bool
+	gpuNames   map[string]bool
+	namespaces map[string]bool
+
+	minUtilization, maxUtilization *float64
+	minTemperature, maxTemperature *float64
+}
+
+// parseGPUFilter reads filters from query parameters. List parameters may be
+// repeated or hold comma-separated values.
+func parseGPUFilter(query url.Values) (gpuFilter, error) {
+	f := gpuFilter{
+		clusters:   parseListParam(query, "cluster"),
+		nodes:      parseListParam(query, "node"),
+		gpuNames:   parseListParam(query, "gpu_name"),
+		namespaces: parseListParam(query, "namespace"),
+	}
+
+	bounds := []struct {
+		key string
+		dst **float64
+	}{
+		{"min_utilization", &f.minUtilization},
+		{"max_utilization", &f.maxUtilization},
+		{"min_temperature", &f.minTemperature},
+		{"max_temperature", &f.maxTemperature},
+	}
+	for _, b := range bounds {
+		value := query.Get(b.key)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return gpuFilter{}, fmt.Errorf("invalid %s: %q is not a number", b.key, value)
+		}
+		*b.dst = &v
+	}
+
+	return f, nil
+}
+
+// parseListParam collects the non-empty values of a repeated or comma-separated parameter.
+func parseListParam(query url.Values, key string) map[string]bool {
+	var set map[string]bool
+	for _, value := range query[key] {
+		for _, item := range strings.Split(value, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if set == nil {
+				set = make(map[string]bool)
+			}
+			set[item] = true
+		}
+	}
+	return set
+}
+
+// selector returns the part of the filter that can be pushed down into PromQL label matchers.
+func (f gpuFilter) selector() metrics.GPUSelector {
+	return metrics.GPUSelector{
+		Nodes:      sortedKeys(f.nodes),
+		GPUNames:   sortedKeys(f.gpuNames),
+		Namespaces: sortedKeys(f.namespaces),
+	}
+}
+
+// sortedKeys returns the members of a set in sorted order.
+func sortedKeys(set map[string]bool) []string {
+	if len(set) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// matches reports whether a GPU passes the filter.
+func (f gpuFilter) matches(m models.GPUMetrics) bool {
+	if len(f.clusters) > 0 && !f.clusters[m.Cluster] {
+		return false
+	}
+	if len(f.nodes) > 0 && !f.nodes[m.NodeName] {
+		return false
+	}
+	if len(f.gpuNames) > 0 && !f.gpuNames[m.GPUName] {
+		return false
+	}
+	if len(f.namespaces) > 0 && (m.Owner == nil || !f.namespaces[m.Owner.Namespace]) {
+		return false
+	}
+	if !inRange(m.Utilization, f.minUtilization, f.maxUtilization) {
+		return false
+	}
+	if !inRange(m.Temperature, f.minTemperature, f.maxTemperature) {
+		return false
+	}
+	return true
+}
+
+// inRange reports whether value lies within the optional inclusive bounds.
+func inRange(value float64, min, max *float64) bool {
+	if min != nil && value < *min {
+		return false
+	}
+	if max != nil && value > *max {
+		return false
+	}
+	return true
+}
+
+// apply returns the GPUs that pass the filter.
+func (f gpuFilter) apply(metrics []models.GPUMetrics) []models.GPUMetrics {
+	filtered := make([]models.GPUMetrics, 0, len(metrics))
+	for _, m := range metrics {
+		if f.matches(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
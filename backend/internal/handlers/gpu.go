@@ -3,28 +3,72 @@ package handlers
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"k8s-gpu-monitoring/internal/auth"
+	"k8s-gpu-monitoring/internal/cache"
+	"k8s-gpu-monitoring/internal/metrics"
 	"k8s-gpu-monitoring/internal/models"
-	"k8s-gpu-monitoring/internal/prometheus"
 )
 
-// GPUHandler handles GPU-related HTTP requests with Prometheus backend.
+// GPUHandler handles GPU-related HTTP requests, serving them from a metrics
+// source such as Prometheus federated across one or more clusters.
 type GPUHandler struct {
-	promClient *prometheus.Client
+	clusters     metrics.Source
+	idleCriteria metrics.IdleCriteria
 }
 
-// NewGPUHandler creates a new GPU handler with the provided Prometheus client.
-func NewGPUHandler(promClient *prometheus.Client) *GPUHandler {
-	return &GPUHandler{
-		promClient: promClient,
+// HandlerOption configures a GPUHandler.
+type HandlerOption func(*GPUHandler)
+
+// WithIdleCriteria sets the default thresholds of the idle GPU report.
+func WithIdleCriteria(criteria metrics.IdleCriteria) HandlerOption {
+	return func(h *GPUHandler) {
+		h.idleCriteria = criteria
+	}
+}
+
+// NewGPUHandler creates a new GPU handler querying the provided metrics source.
+func NewGPUHandler(clusters metrics.Source, opts ...HandlerOption) *GPUHandler {
+	h := &GPUHandler{
+		clusters:     clusters,
+		idleCriteria: DefaultIdleCriteria,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Register adds the GPU routes to mux.
+func (h *GPUHandler) Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/health", h.HealthCheck)
+	mux.HandleFunc("GET /api/v1/health", h.HealthDetails)
+	mux.HandleFunc("GET /api/v1/gpu/metrics", h.GetGPUMetrics)
+	mux.HandleFunc("GET /api/v1/gpu/metrics/range", h.GetGPUMetricsRange)
+	mux.HandleFunc("GET /api/v1/gpu/nodes", h.GetGPUNodes)
+	mux.HandleFunc("GET /api/v1/gpu/nodes/{node}", h.GetGPUNode)
+	mux.HandleFunc("GET /api/v1/gpu/nodes/{node}/gpus/{index}", h.GetGPUDevice)
+	mux.HandleFunc("GET /api/v1/gpu/pods", h.GetGPUPods)
+	mux.HandleFunc("GET /api/v1/gpu/idle", h.GetIdleGPUs)
+	mux.HandleFunc("GET /api/v1/gpu/utilization", h.GetGPUUtilization)
+	mux.HandleFunc("GET /api/v1/clusters", h.GetClusters)
 }
 
 // writeJSONResponse writes a JSON response with proper headers.
 func (h *GPUHandler) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
+	writeJSON(w, statusCode, data)
+}
+
+// writeJSON writes data as a JSON response with proper headers.
+func writeJSON(w http.ResponseWriter, statusCode int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(statusCode)
 
@@ -44,22 +88,145 @@ func (h *GPUHandler) writeErrorResponse(w http.ResponseWriter, statusCode int, m
 	h.writeJSONResponse(w, statusCode, response)
 }
 
+// requestContext derives a query context with timeout, bypassing the query cache
+// when the client sends Cache-Control: no-cache and restricting queries to the
+// caller's authorized scope.
+func requestContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc) {
+	ctx := r.Context()
+	if strings.Contains(strings.ToLower(r.Header.Get("Cache-Control")), "no-cache") {
+		ctx = cache.WithBypass(ctx)
+	}
+	if scope, ok := requestScope(r); ok {
+		ctx = metrics.WithScope(ctx, scope)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// queryContext derives the request context and restricts it to the clusters
+// named by the cluster query parameter, rejecting unknown names.
+func (h *GPUHandler) queryContext(r *http.Request, timeout time.Duration) (context.Context, context.CancelFunc, error) {
+	clusters := sortedKeys(parseListParam(r.URL.Query(), "cluster"))
+	if err := h.clusters.Validate(clusters); err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := requestContext(r, timeout)
+	return metrics.WithClusters(ctx, clusters), cancel, nil
+}
+
+// requestScope returns the GPUs the caller is restricted to, or false for
+// unrestricted callers: admins and requests when authorization is disabled.
+func requestScope(r *http.Request) (metrics.Scope, bool) {
+	grant, ok := auth.GrantFrom(r.Context())
+	if !ok || grant.Admin {
+		return metrics.Scope{}, false
+	}
+	scope := metrics.Scope{Clauses: make([]metrics.ScopeClause, 0, len(grant.Clauses))}
+	for _, c := range grant.Clauses {
+		scope.Clauses = append(scope.Clauses, metrics.ScopeClause{Namespaces: c.Namespaces, Nodes: c.Nodes})
+	}
+	return scope, true
+}
+
+// successMessage builds the response message, noting when the result is partial.
+func successMessage(subject string, warnings []models.Warning) string {
+	if len(warnings) > 0 {
+		return subject + " partially retrieved"
+	}
+	return subject + " retrieved successfully"
+}
+
+// logWarnings logs each metric missing from a partial result.
+func logWarnings(subject string, warnings []models.Warning) {
+	for _, warning := range warnings {
+		if warning.Cluster != "" {
+			log.Printf("Partial %s: %s missing from cluster %s: %s", subject, warning.Metric, warning.Cluster, warning.Reason)
+			continue
+		}
+		log.Printf("Partial %s: %s missing: %s", subject, warning.Metric, warning.Reason)
+	}
+}
+
 // GetGPUMetrics handles GET /api/v1/gpu/metrics - returns comprehensive GPU metrics.
+// Results can be filtered, sorted and paginated with query parameters; node,
+// gpu_name and namespace filters are applied in PromQL.
 func (h *GPUHandler) GetGPUMetrics(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	filter, err := parseGPUFilter(r.URL.Query())
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(r.URL.Query())
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	defer cancel()
 
-	metrics, err := h.promClient.GetGPUMetrics(ctx)
+	gpus, warnings, err := h.clusters.SelectGPUMetrics(ctx, filter.selector())
 	if err != nil {
 		log.Printf("Error getting GPU metrics: %v", err)
 		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU metrics")
 		return
 	}
+	logWarnings("GPU metrics", warnings)
+
+	gpus = filter.apply(gpus)
+	page.sortGPUs(gpus)
+	gpus, pagination := page.page(gpus)
 
 	response := models.APIResponse{
-		Success: true,
-		Data:    metrics,
-		Message: "GPU metrics retrieved successfully",
+		Success:    true,
+		Data:       gpus,
+		Message:    successMessage("GPU metrics", warnings),
+		Warnings:   warnings,
+		Pagination: pagination,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// GetGPUMetricsRange handles GET /api/v1/gpu/metrics/range - returns per-GPU time series.
+func (h *GPUHandler) GetGPUMetricsRange(w http.ResponseWriter, r *http.Request) {
+	query := models.MetricsQuery{
+		StartTime: r.URL.Query().Get("start"),
+		EndTime:   r.URL.Query().Get("end"),
+		Step:      r.URL.Query().Get("step"),
+	}
+
+	start, end, step, err := parseRangeQuery(query, time.Now())
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	series, warnings, err := h.clusters.GetGPUMetricsRange(ctx, start, end, step)
+	if err != nil {
+		log.Printf("Error getting GPU metrics range: %v", err)
+		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU metrics range")
+		return
+	}
+	logWarnings("GPU metrics range", warnings)
+
+	response := models.APIResponse{
+		Success:  true,
+		Data:     series,
+		Message:  successMessage("GPU metrics range", warnings),
+		Warnings: warnings,
 	}
 
 	h.writeJSONResponse(w, http.StatusOK, response)
@@ -67,20 +234,132 @@ func (h *GPUHandler) GetGPUMetrics(w http.ResponseWriter, r *http.Request) {
 
 // GetGPUNodes handles GET /api/v1/gpu/nodes - returns GPU-enabled nodes.
 func (h *GPUHandler) GetGPUNodes(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	defer cancel()
 
-	nodes, err := h.promClient.GetGPUNodes(ctx)
+	nodes, warnings, err := h.clusters.GetGPUNodes(ctx)
 	if err != nil {
 		log.Printf("Error getting GPU nodes: %v", err)
 		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU nodes")
 		return
 	}
+	logWarnings("GPU nodes", warnings)
 
 	response := models.APIResponse{
-		Success: true,
-		Data:    nodes,
-		Message: "GPU nodes retrieved successfully",
+		Success:  true,
+		Data:     nodes,
+		Message:  successMessage("GPU nodes", warnings),
+		Warnings: warnings,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// GetGPUNode handles GET /api/v1/gpu/nodes/{node} - returns a single node and its GPUs.
+func (h *GPUHandler) GetGPUNode(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("node")
+
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	node, warnings, err := h.clusters.GetGPUNode(ctx, nodeName)
+	if errors.Is(err, metrics.ErrNotFound) {
+		h.writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("GPU node %q not found", nodeName))
+		return
+	}
+	if errors.Is(err, metrics.ErrAmbiguous) {
+		h.writeErrorResponse(w, http.StatusConflict, fmt.Sprintf("GPU node %q exists in several clusters, select one with the cluster parameter", nodeName))
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting GPU node %s: %v", nodeName, err)
+		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU node")
+		return
+	}
+	logWarnings("GPU node", warnings)
+
+	response := models.APIResponse{
+		Success:  true,
+		Data:     node,
+		Message:  successMessage("GPU node", warnings),
+		Warnings: warnings,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// GetGPUDevice handles GET /api/v1/gpu/nodes/{node}/gpus/{index} - returns a single GPU with device detail.
+func (h *GPUHandler) GetGPUDevice(w http.ResponseWriter, r *http.Request) {
+	nodeName := r.PathValue("node")
+	gpuIndex, err := strconv.Atoi(r.PathValue("index"))
+	if err != nil || gpuIndex < 0 {
+		h.writeErrorResponse(w, http.StatusBadRequest, "GPU index must be a non-negative integer")
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	device, warnings, err := h.clusters.GetGPUDevice(ctx, nodeName, gpuIndex)
+	if errors.Is(err, metrics.ErrNotFound) {
+		h.writeErrorResponse(w, http.StatusNotFound, fmt.Sprintf("GPU %d on node %q not found", gpuIndex, nodeName))
+		return
+	}
+	if errors.Is(err, metrics.ErrAmbiguous) {
+		h.writeErrorResponse(w, http.StatusConflict, fmt.Sprintf("GPU node %q exists in several clusters, select one with the cluster parameter", nodeName))
+		return
+	}
+	if err != nil {
+		log.Printf("Error getting GPU %s:%d: %v", nodeName, gpuIndex, err)
+		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU")
+		return
+	}
+	logWarnings("GPU detail", warnings)
+
+	response := models.APIResponse{
+		Success:  true,
+		Data:     device,
+		Message:  successMessage("GPU", warnings),
+		Warnings: warnings,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// GetGPUPods handles GET /api/v1/gpu/pods - returns GPUs allocated to each pod.
+func (h *GPUHandler) GetGPUPods(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	pods, warnings, err := h.clusters.GetGPUPods(ctx)
+	if err != nil {
+		log.Printf("Error getting GPU pods: %v", err)
+		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU pods")
+		return
+	}
+	logWarnings("GPU pods", warnings)
+
+	response := models.APIResponse{
+		Success:  true,
+		Data:     pods,
+		Message:  successMessage("GPU pods", warnings),
+		Warnings: warnings,
 	}
 
 	h.writeJSONResponse(w, http.StatusOK, response)
@@ -88,64 +367,240 @@ func (h *GPUHandler) GetGPUNodes(w http.ResponseWriter, r *http.Request) {
 
 // GetGPUUtilization handles GET /api/v1/gpu/utilization - returns simplified utilization data.
 func (h *GPUHandler) GetGPUUtilization(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	defer cancel()
 
-	// Query for GPU utilization using kube-prometheus-stack metric names
-	query := `nvidia_gpu_utilization_percent`
-
-	resp, err := h.promClient.Query(ctx, query)
+	samples, warnings, err := h.clusters.GetGPUUtilization(ctx)
 	if err != nil {
 		log.Printf("Error getting GPU utilization: %v", err)
 		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve GPU utilization")
 		return
 	}
+	logWarnings("GPU utilization", warnings)
 
-	// Simplify response structure for lightweight API usage
+	// Simplify response structure for lightweight API usage, keeping the string
+	// index and value and the Unix seconds timestamp of the Prometheus format
 	var utilization []map[string]interface{}
-	for _, result := range resp.Data.Result {
-		if len(result.Value) >= 2 {
-			util := map[string]interface{}{
-				"node":        result.Metric["hostname"],
-				"gpu_index":   result.Metric["gpu_id"],
-				"utilization": result.Value[1],
-				"timestamp":   result.Value[0],
-			}
-			utilization = append(utilization, util)
+	for _, sample := range samples {
+		util := map[string]interface{}{
+			"node":        sample.NodeName,
+			"gpu_index":   strconv.Itoa(sample.GPUIndex),
+			"utilization": strconv.FormatFloat(sample.Utilization, 'f', -1, 64),
+			"timestamp":   float64(sample.Timestamp.UnixMilli()) / 1000,
+		}
+		if sample.Cluster != "" {
+			util["cluster"] = sample.Cluster
 		}
+		utilization = append(utilization, util)
 	}
 
 	response := models.APIResponse{
-		Success: true,
-		Data:    utilization,
-		Message: "GPU utilization retrieved successfully",
+		Success:  true,
+		Data:     utilization,
+		Message:  successMessage("GPU utilization", warnings),
+		Warnings: warnings,
 	}
 
 	h.writeJSONResponse(w, http.StatusOK, response)
 }
 
-// HealthCheck handles GET /api/health - verifies service and Prometheus connectivity.
-func (h *GPUHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	// Verify Prometheus server connectivity
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+// GetClusters handles GET /api/v1/clusters - returns the health and GPU totals of each cluster.
+func (h *GPUHandler) GetClusters(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
 	defer cancel()
 
-	_, err := h.promClient.Query(ctx, "up")
-	if err != nil {
-		log.Printf("Health check failed: %v", err)
+	summaries, warnings := h.clusters.Summaries(ctx)
+	for _, summary := range summaries {
+		if !summary.Healthy {
+			log.Printf("Cluster %s unhealthy: %s", summary.Name, summary.Error)
+		}
+	}
+	logWarnings("cluster summary", warnings)
+
+	response := models.APIResponse{
+		Success:  true,
+		Data:     summaries,
+		Message:  successMessage("Cluster summaries", warnings),
+		Warnings: warnings,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// HealthCheck handles GET /api/health - verifies service and Prometheus connectivity.
+// The service stays healthy while at least one cluster is reachable. The endpoint is
+// public, so it reports the status alone; HealthDetails serves the rest.
+func (h *GPUHandler) HealthCheck(w http.ResponseWriter, r *http.Request) {
+	status, _ := h.health(r)
+	if status == "unhealthy" {
 		h.writeErrorResponse(w, http.StatusServiceUnavailable, "Prometheus connection failed")
 		return
 	}
 
 	response := models.APIResponse{
 		Success: true,
-		Message: "Service is healthy",
-		Data: map[string]interface{}{
-			"status":    "healthy",
-			"timestamp": time.Now().Format(time.RFC3339),
-			"version":   "1.0.0",
-		},
+		Message: "Service is " + status,
+		Data:    map[string]interface{}{"status": status},
 	}
 
 	h.writeJSONResponse(w, http.StatusOK, response)
 }
+
+// HealthDetails handles GET /api/v1/health - reports the health of every cluster,
+// the query cache counters and the circuit breaker states.
+func (h *GPUHandler) HealthDetails(w http.ResponseWriter, r *http.Request) {
+	status, failures := h.health(r)
+
+	data := map[string]interface{}{
+		"status":    status,
+		"timestamp": time.Now().Format(time.RFC3339),
+		"version":   "1.0.0",
+	}
+	if names := h.clusters.Names(); len(names) > 0 {
+		clusters := make(map[string]string, len(names))
+		for _, name := range names {
+			clusters[name] = "healthy"
+			if _, failed := failures[name]; failed {
+				clusters[name] = "unreachable"
+			}
+		}
+		data["clusters"] = clusters
+	}
+	if stats, ok := h.clusters.CacheStats(); ok {
+		data["cache"] = stats
+	}
+	if breakers := h.clusters.BackendStatuses(); len(breakers) > 0 {
+		data["circuit_breakers"] = breakers
+	}
+
+	if status == "unhealthy" {
+		h.writeJSONResponse(w, http.StatusServiceUnavailable, models.APIResponse{
+			Success: false,
+			Error:   "Prometheus connection failed",
+			Data:    data,
+		})
+		return
+	}
+
+	response := models.APIResponse{
+		Success: true,
+		Message: "Service is " + status,
+		Data:    data,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// health pings every cluster, never from a cached response, and returns the
+// service status with the connection error of each unreachable cluster.
+func (h *GPUHandler) health(r *http.Request) (string, map[string]error) {
+	ctx, cancel := context.WithTimeout(cache.WithBypass(r.Context()), 5*time.Second)
+	defer cancel()
+
+	failures := h.clusters.Ping(ctx)
+	for name, err := range failures {
+		log.Printf("Health check failed for cluster %q: %v", name, err)
+	}
+
+	switch {
+	case len(failures) > 0 && len(failures) >= max(len(h.clusters.Names()), 1):
+		return "unhealthy", failures
+	case len(failures) > 0:
+		return "degraded", failures
+	default:
+		return "healthy", failures
+	}
+}
+
+const (
+	// defaultRangeWindow is used when the caller omits start.
+	defaultRangeWindow = time.Hour
+	// maxRangePoints mirrors Prometheus' 11,000 points per series limit.
+	maxRangePoints = 11000
+	// targetRangePoints is the resolution aimed for when the caller omits step.
+	targetRangePoints = 240
+)
+
+// parseRangeQuery converts the string fields of a MetricsQuery into a validated time range.
+// start and end accept RFC3339 or Unix seconds; step accepts Go durations ("30s") or seconds.
+func parseRangeQuery(q models.MetricsQuery, now time.Time) (time.Time, time.Time, time.Duration, error) {
+	end := now
+	if q.EndTime != "" {
+		t, err := parseTimeParam(q.EndTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid end: %w", err)
+		}
+		end = t
+	}
+
+	start := end.Add(-defaultRangeWindow)
+	if q.StartTime != "" {
+		t, err := parseTimeParam(q.StartTime)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid start: %w", err)
+		}
+		start = t
+	}
+
+	if !start.Before(end) {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("start must be before end")
+	}
+
+	window := end.Sub(start)
+	step := window / targetRangePoints
+	if q.Step != "" {
+		d, err := parseDurationParam(q.Step)
+		if err != nil {
+			return time.Time{}, time.Time{}, 0, fmt.Errorf("invalid step: %w", err)
+		}
+		step = d
+	}
+	if step < time.Second {
+		step = time.Second
+	}
+
+	if window/step > maxRangePoints {
+		return time.Time{}, time.Time{}, 0, fmt.Errorf("range too large: %d points exceeds limit of %d, increase step", window/step, maxRangePoints)
+	}
+
+	return start, end, step, nil
+}
+
+// parseTimeParam parses RFC3339 timestamps or (fractional) Unix seconds.
+func parseTimeParam(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	sec, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is neither RFC3339 nor Unix seconds", value)
+	}
+	whole, frac := math.Modf(sec)
+	return time.Unix(int64(whole), int64(frac*1e9)), nil
+}
+
+// parseDurationParam parses Go durations or plain seconds.
+func parseDurationParam(value string) (time.Duration, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		if d <= 0 {
+			return 0, fmt.Errorf("must be positive")
+		}
+		return d, nil
+	}
+	sec, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%q is neither a duration nor seconds", value)
+	}
+	if sec <= 0 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return time.Duration(sec * float64(time.Second)), nil
+}
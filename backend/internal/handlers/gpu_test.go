@@ -1,255 +1,538 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"k8s-gpu-monitoring/internal/cache"
+	"k8s-gpu-monitoring/internal/fakeprom"
+	"k8s-gpu-monitoring/internal/federation"
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/metrics/fake"
 	"k8s-gpu-monitoring/internal/models"
 	"k8s-gpu-monitoring/internal/prometheus"
 )
 
-// PrometheusClient interface for testing
-type PrometheusClient interface {
-	GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, error)
-	GetGPUNodes(ctx context.Context) ([]models.GPUNode, error)
-	Query(ctx context.Context, query string) (*prometheus.PrometheusResponse, error)
+// testGPUs spans two clusters that both have a node named node1.
+func testGPUs() []models.GPUMetrics {
+	now := time.Now()
+	return []models.GPUMetrics{
+		{Cluster: "east", NodeName: "node1", GPUIndex: 0, GPUName: "NVIDIA A100", Utilization: 75.5, MemoryUsed: 8, MemoryTotal: 16, PowerDraw: 250, PowerLimit: 300, Timestamp: now},
+		{Cluster: "east", NodeName: "node1", GPUIndex: 1, GPUName: "NVIDIA A100", Utilization: 10, MemoryUsed: 2, MemoryTotal: 16, PowerDraw: 90, PowerLimit: 300, Timestamp: now},
+		{Cluster: "east", NodeName: "node2", GPUIndex: 0, GPUName: "NVIDIA H100", Utilization: 95, MemoryUsed: 70, MemoryTotal: 80, PowerDraw: 600, PowerLimit: 700, Timestamp: now},
+		{Cluster: "west", NodeName: "node1", GPUIndex: 0, GPUName: "NVIDIA L4", Utilization: 0, MemoryUsed: 0, MemoryTotal: 24, PowerDraw: 20, PowerLimit: 72, Timestamp: now},
+	}
 }
 
-type mockPrometheusClient struct {
-	shouldReturnError bool
+// newTestServer serves the GPU routes from src.
+func newTestServer(t *testing.T, src *fake.Source) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	NewGPUHandler(src).Register(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
 }
 
-func (m *mockPrometheusClient) GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, error) {
-	if m.shouldReturnError {
-		return nil, errors.New("mock prometheus error")
+// getAPI fetches path from server and decodes the API response, leaving data undecoded.
+func getAPI(t *testing.T, server *httptest.Server, path string) (int, models.APIResponse, json.RawMessage) {
+	t.Helper()
+
+	resp, err := http.Get(server.URL + path)
+	if err != nil {
+		t.Fatalf("GET %s: %v", path, err)
 	}
+	defer resp.Body.Close()
 
-	return []models.GPUMetrics{
-		{
-			NodeName:          "node1",
-			GPUIndex:          0,
-			GPUName:           "NVIDIA Tesla V100",
-			Utilization:       75.5,
-			MemoryUsed:        8.0,
-			MemoryTotal:       16.0,
-			MemoryFree:        8.0,
-			MemoryUtilization: 50.0,
-			Temperature:       65.0,
-			PowerDraw:         250.0,
-			PowerLimit:        300.0,
-		},
-	}, nil
-}
+	if got := resp.Header.Get("Content-Type"); got != "application/json" {
+		t.Errorf("GET %s: got Content-Type %q", path, got)
+	}
 
-func (m *mockPrometheusClient) GetGPUNodes(ctx context.Context) ([]models.GPUNode, error) {
-	if m.shouldReturnError {
-		return nil, errors.New("mock prometheus error")
+	var body struct {
+		models.APIResponse
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatalf("GET %s: failed to decode response: %v", path, err)
 	}
+	return resp.StatusCode, body.APIResponse, body.Data
+}
 
-	return []models.GPUNode{
+func TestHealthCheck(t *testing.T) {
+	tests := []struct {
+		name         string
+		down         map[string]error
+		expectedCode int
+		wantMessage  string
+	}{
+		{
+			name:         "all clusters reachable",
+			expectedCode: http.StatusOK,
+			wantMessage:  "Service is healthy",
+		},
+		{
+			name:         "one cluster unreachable",
+			down:         map[string]error{"west": errors.New("connection refused")},
+			expectedCode: http.StatusOK,
+			wantMessage:  "Service is degraded",
+		},
 		{
-			NodeName:  "node1",
-			GPUCount:  2,
-			GPUModels: []string{"NVIDIA Tesla V100"},
+			name:         "every cluster unreachable",
+			down:         map[string]error{"east": errors.New("connection refused"), "west": errors.New("connection refused")},
+			expectedCode: http.StatusServiceUnavailable,
 		},
-	}, nil
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, &fake.Source{
+				Clusters: []string{"east", "west"},
+				Down:     tt.down,
+				Cache:    &metrics.CacheStats{Hits: 1},
+				Backends: []metrics.BackendStatus{{Cluster: "east", State: "closed"}},
+			})
+
+			for _, path := range []string{"/api/health", "/api/v1/health"} {
+				code, response, data := getAPI(t, server, path)
+				if code != tt.expectedCode {
+					t.Errorf("%s: expected status %d, got %d", path, tt.expectedCode, code)
+				}
+				if response.Success != (tt.expectedCode == http.StatusOK) {
+					t.Errorf("%s: unexpected success %v", path, response.Success)
+				}
+				if tt.wantMessage != "" && response.Message != tt.wantMessage {
+					t.Errorf("%s: expected message %q, got %q", path, tt.wantMessage, response.Message)
+				}
+
+				var fields map[string]json.RawMessage
+				json.Unmarshal(data, &fields)
+				_, detailed := fields["clusters"]
+				if path == "/api/health" && (detailed || len(fields) > 1) {
+					t.Errorf("expected the public health check to report only its status, got %s", data)
+				}
+				if path == "/api/v1/health" && (!detailed || fields["cache"] == nil || fields["circuit_breakers"] == nil) {
+					t.Errorf("expected health details, got %s", data)
+				}
+			}
+		})
+	}
 }
 
-func (m *mockPrometheusClient) Query(ctx context.Context, query string) (*prometheus.PrometheusResponse, error) {
-	if m.shouldReturnError {
-		return nil, errors.New("mock prometheus error")
-	}
-
-	return &prometheus.PrometheusResponse{
-		Status: "success",
-		Data: struct {
-			ResultType string `json:"resultType"`
-			Result     []struct {
-				Metric map[string]string `json:"metric"`
-				Value  []interface{}     `json:"value"`
-			} `json:"result"`
-		}{
-			ResultType: "vector",
-			Result: []struct {
-				Metric map[string]string `json:"metric"`
-				Value  []interface{}     `json:"value"`
-			}{
-				{
-					Metric: map[string]string{
-						"node": "node1",
-						"gpu":  "0",
-					},
-					Value: []interface{}{
-						1234567890.0,
-						"75.5",
-					},
-				},
-			},
+func TestGetGPUMetrics(t *testing.T) {
+	tests := []struct {
+		name         string
+		source       *fake.Source
+		query        string
+		expectedCode int
+		wantGPUs     int
+		wantWarnings int
+	}{
+		{
+			name:         "successful metrics retrieval",
+			source:       &fake.Source{Clusters: []string{"east", "west"}, GPUs: testGPUs()},
+			expectedCode: http.StatusOK,
+			wantGPUs:     4,
 		},
-	}, nil
-}
+		{
+			name:         "filtered and paginated",
+			source:       &fake.Source{Clusters: []string{"east", "west"}, GPUs: testGPUs()},
+			query:        "?node=node1&sort=utilization&order=desc&limit=2",
+			expectedCode: http.StatusOK,
+			wantGPUs:     2,
+		},
+		{
+			name:         "partial result",
+			source:       &fake.Source{Clusters: []string{"east", "west"}, GPUs: testGPUs(), Down: map[string]error{"west": errors.New("timeout")}},
+			expectedCode: http.StatusOK,
+			wantGPUs:     3,
+			wantWarnings: 1,
+		},
+		{
+			name:         "prometheus error",
+			source:       &fake.Source{Errors: map[string]error{"SelectGPUMetrics": errors.New("prometheus error")}},
+			expectedCode: http.StatusInternalServerError,
+		},
+		{
+			name:         "unknown cluster",
+			source:       &fake.Source{Clusters: []string{"east", "west"}, GPUs: testGPUs()},
+			query:        "?cluster=north",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
 
-// GPUHandlerWrapper for testing with interface
-type GPUHandlerWrapper struct {
-	promClient PrometheusClient
-}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newTestServer(t, tt.source)
 
-func NewGPUHandlerWrapper(promClient PrometheusClient) *GPUHandlerWrapper {
-	return &GPUHandlerWrapper{
-		promClient: promClient,
+			code, response, data := getAPI(t, server, "/api/v1/gpu/metrics"+tt.query)
+			if code != tt.expectedCode {
+				t.Fatalf("expected status %d, got %d", tt.expectedCode, code)
+			}
+			if code != http.StatusOK {
+				if response.Success || response.Error == "" {
+					t.Errorf("expected an error response, got %+v", response)
+				}
+				return
+			}
+
+			var gpus []models.GPUMetrics
+			if err := json.Unmarshal(data, &gpus); err != nil {
+				t.Fatalf("failed to decode data: %v", err)
+			}
+			if !response.Success || len(gpus) != tt.wantGPUs {
+				t.Errorf("expected %d GPUs, got %d (success %v)", tt.wantGPUs, len(gpus), response.Success)
+			}
+			if len(response.Warnings) != tt.wantWarnings {
+				t.Errorf("expected %d warnings, got %v", tt.wantWarnings, response.Warnings)
+			}
+		})
+	}
+
+	// Sorting runs on the handler side, after the source returned its GPUs
+	server := newTestServer(t, &fake.Source{Clusters: []string{"east", "west"}, GPUs: testGPUs()})
+	_, _, data := getAPI(t, server, "/api/v1/gpu/metrics?node=node1&sort=utilization&order=desc&limit=2")
+	var gpus []models.GPUMetrics
+	if err := json.Unmarshal(data, &gpus); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+	if len(gpus) != 2 || gpus[0].Utilization != 75.5 || gpus[1].Utilization != 10 {
+		t.Errorf("unexpected page %+v", gpus)
 	}
 }
 
-func (h *GPUHandlerWrapper) writeJSONResponse(w http.ResponseWriter, statusCode int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(data)
+func TestGetGPUMetricsLargeFleet(t *testing.T) {
+	var fleet []models.GPUMetrics
+	for i := range 250 {
+		fleet = append(fleet, models.GPUMetrics{Cluster: "east", NodeName: fmt.Sprintf("node%03d", i/8), GPUIndex: i % 8, GPUName: "NVIDIA A100"})
+	}
+	server := newTestServer(t, &fake.Source{Clusters: []string{"east"}, GPUs: fleet})
+
+	// Without paging parameters the whole fleet is returned
+	_, response, data := getAPI(t, server, "/api/v1/gpu/metrics")
+	var gpus []models.GPUMetrics
+	if err := json.Unmarshal(data, &gpus); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+	if len(gpus) != 250 || response.Pagination != nil {
+		t.Fatalf("expected all 250 GPUs unpaged, got %d (pagination %+v)", len(gpus), response.Pagination)
+	}
+
+	// An offset alone pages with the default limit
+	_, response, data = getAPI(t, server, "/api/v1/gpu/metrics?offset=200")
+	gpus = nil
+	if err := json.Unmarshal(data, &gpus); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+	if len(gpus) != 50 || response.Pagination == nil || response.Pagination.Total != 250 || response.Pagination.Limit != defaultPageLimit {
+		t.Fatalf("expected the last 50 GPUs, got %d (pagination %+v)", len(gpus), response.Pagination)
+	}
 }
 
-func (h *GPUHandlerWrapper) HealthCheck(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
-	defer cancel()
+func TestGPUEndpoints(t *testing.T) {
+	src := &fake.Source{
+		Clusters: []string{"east", "west"},
+		GPUs:     testGPUs(),
+		Series: []models.GPUTimeSeries{
+			{Cluster: "east", NodeName: "node2", GPUIndex: 0, Utilization: []models.DataPoint{{Timestamp: time.Now().Add(-time.Minute), Value: 90}}},
+		},
+		Pods: []models.GPUPodAllocation{
+			{Cluster: "east", NodeName: "node2", Namespace: "ml", Pod: "trainer-0", Container: "trainer"},
+		},
+		Idle: []models.IdleGPU{
+			{GPUMetrics: testGPUs()[3], AverageUtilization: 0, P95Utilization: 0, WastedGPUHours: 6},
+		},
+	}
+	server := newTestServer(t, src)
 
-	_, err := h.promClient.Query(ctx, "up")
-	if err != nil {
-		response := models.APIResponse{
-			Success: false,
-			Error:   "Prometheus connection failed",
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantItems  int
+	}{
+		{"/api/v1/gpu/metrics/range?step=1m", http.StatusOK, 1},
+		{"/api/v1/gpu/metrics/range?step=never", http.StatusBadRequest, 0},
+		{"/api/v1/gpu/nodes", http.StatusOK, 3},
+		{"/api/v1/gpu/nodes?cluster=west", http.StatusOK, 1},
+		{"/api/v1/gpu/nodes/node2", http.StatusOK, -1},
+		{"/api/v1/gpu/nodes/node1", http.StatusConflict, 0},
+		{"/api/v1/gpu/nodes/node1?cluster=west", http.StatusOK, -1},
+		{"/api/v1/gpu/nodes/node9", http.StatusNotFound, 0},
+		{"/api/v1/gpu/nodes/node2/gpus/0", http.StatusOK, -1},
+		{"/api/v1/gpu/nodes/node2/gpus/1", http.StatusNotFound, 0},
+		{"/api/v1/gpu/nodes/node2/gpus/x", http.StatusBadRequest, 0},
+		{"/api/v1/gpu/pods", http.StatusOK, 1},
+		{"/api/v1/gpu/pods?cluster=west", http.StatusOK, 0},
+		{"/api/v1/gpu/idle", http.StatusOK, -1},
+		{"/api/v1/gpu/idle?lookback=forever", http.StatusBadRequest, 0},
+		{"/api/v1/gpu/utilization", http.StatusOK, 4},
+		{"/api/v1/clusters", http.StatusOK, 2},
+		{"/api/v1/clusters?cluster=north", http.StatusBadRequest, 0},
+	}
+
+	for _, tt := range tests {
+		code, response, data := getAPI(t, server, tt.path)
+		if code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d (%s)", tt.path, code, tt.wantStatus, response.Error)
+			continue
+		}
+		if code != http.StatusOK || tt.wantItems < 0 {
+			continue
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			t.Fatalf("%s: failed to decode data: %v", tt.path, err)
+		}
+		if len(items) != tt.wantItems {
+			t.Errorf("%s: got %d items, want %d", tt.path, len(items), tt.wantItems)
 		}
-		h.writeJSONResponse(w, http.StatusServiceUnavailable, response)
-		return
 	}
 
-	response := models.APIResponse{
-		Success: true,
-		Message: "Service is healthy",
+	if calls := src.Calls("GetGPUNode"); calls != 4 {
+		t.Errorf("expected 4 GetGPUNode calls, got %d", calls)
 	}
-	h.writeJSONResponse(w, http.StatusOK, response)
 }
 
-func (h *GPUHandlerWrapper) GetGPUMetrics(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
-	defer cancel()
+// TestGPUEndpointsAgainstPrometheus serves every route from Prometheus clients
+// reading fake Prometheus servers, one per cluster, with dcgm-exporter naming.
+func TestGPUEndpointsAgainstPrometheus(t *testing.T) {
+	schema, _ := prometheus.LookupSchema(prometheus.SchemaDCGMExporter)
+	naming := fakeprom.DCGMExporter()
 
-	metrics, err := h.promClient.GetGPUMetrics(ctx)
+	var clusters []federation.Cluster
+	for _, fleet := range []struct {
+		name  string
+		fleet fakeprom.Fleet
+	}{
+		{"east", fakeprom.Fleet{Naming: naming, Nodes: 2, GPUsPerNode: 4, Allocated: 0.5, Namespaces: []string{"ml"}, Utilization: fakeprom.Mixed(fakeprom.Constant(90), fakeprom.Constant(0))}},
+		{"west", fakeprom.Fleet{Naming: naming, NodeNames: []string{"node-w"}, GPUsPerNode: 2, Models: []string{"NVIDIA L4"}, Utilization: fakeprom.Constant(0)}},
+	} {
+		server := httptest.NewServer(fakeprom.NewServer(fleet.fleet))
+		t.Cleanup(server.Close)
+		clusters = append(clusters, federation.Cluster{Name: fleet.name, Client: prometheus.NewClient(server.URL, prometheus.WithSchema(schema), prometheus.WithQueryBatching())})
+	}
+	fed, err := federation.New(clusters...)
 	if err != nil {
-		response := models.APIResponse{
-			Success: false,
-			Error:   "Failed to retrieve GPU metrics",
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	NewGPUHandler(fed).Register(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	tests := []struct {
+		path      string
+		wantItems int
+	}{
+		{"/api/v1/gpu/metrics", 10},
+		{"/api/v1/gpu/metrics?namespace=ml", 4},
+		{"/api/v1/gpu/metrics?gpu_name=NVIDIA%20L4&cluster=west", 2},
+		{"/api/v1/gpu/metrics?min_utilization=50", 4},
+		{"/api/v1/gpu/metrics/range?step=5m", 10},
+		{"/api/v1/gpu/nodes", 3},
+		{"/api/v1/gpu/nodes/gpu-node-01", -1},
+		{"/api/v1/gpu/nodes/node-w/gpus/1", -1},
+		{"/api/v1/gpu/pods", 2},
+		{"/api/v1/gpu/idle?lookback=1h", -1},
+		{"/api/v1/gpu/utilization", 10},
+		{"/api/v1/clusters", 2},
+	}
+	for _, tt := range tests {
+		code, response, data := getAPI(t, server, tt.path)
+		if code != http.StatusOK {
+			t.Errorf("%s: got status %d (%s)", tt.path, code, response.Error)
+			continue
+		}
+		if len(response.Warnings) > 0 {
+			t.Errorf("%s: unexpected warnings %+v", tt.path, response.Warnings)
+		}
+		if tt.wantItems < 0 {
+			continue
+		}
+
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			t.Fatalf("%s: failed to decode data: %v", tt.path, err)
+		}
+		if len(items) != tt.wantItems {
+			t.Errorf("%s: got %d items, want %d", tt.path, len(items), tt.wantItems)
 		}
-		h.writeJSONResponse(w, http.StatusInternalServerError, response)
-		return
 	}
 
-	response := models.APIResponse{
-		Success: true,
-		Data:    metrics,
-		Message: "GPU metrics retrieved successfully",
+	// Half of the east GPUs and every west GPU sit idle
+	_, _, data := getAPI(t, server, "/api/v1/gpu/idle?lookback=1h")
+	var report models.IdleGPUReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+	if len(report.GPUs) != 6 {
+		t.Errorf("expected 6 idle GPUs, got %+v", report.GPUs)
 	}
-	h.writeJSONResponse(w, http.StatusOK, response)
 }
 
-func TestHealthCheck(t *testing.T) {
+func TestGPUEndpointErrors(t *testing.T) {
+	failure := errors.New("prometheus error")
+	src := &fake.Source{Errors: map[string]error{
+		"GetGPUMetricsRange": failure,
+		"GetGPUNodes":        failure,
+		"GetGPUNode":         failure,
+		"GetGPUDevice":       failure,
+		"GetGPUPods":         failure,
+		"GetIdleGPUs":        failure,
+		"GetGPUUtilization":  failure,
+	}}
+	server := newTestServer(t, src)
+
+	paths := []string{
+		"/api/v1/gpu/metrics/range",
+		"/api/v1/gpu/nodes",
+		"/api/v1/gpu/nodes/node1",
+		"/api/v1/gpu/nodes/node1/gpus/0",
+		"/api/v1/gpu/pods",
+		"/api/v1/gpu/idle",
+		"/api/v1/gpu/utilization",
+	}
+	for _, path := range paths {
+		code, response, _ := getAPI(t, server, path)
+		if code != http.StatusInternalServerError {
+			t.Errorf("%s: got status %d, want %d", path, code, http.StatusInternalServerError)
+		}
+		if response.Success || response.Error == "" || response.Error == failure.Error() {
+			t.Errorf("%s: expected a generic error, got %+v", path, response)
+		}
+	}
+}
+
+func TestIdleReportFromSource(t *testing.T) {
+	gpus := testGPUs()
+	src := &fake.Source{
+		Clusters: []string{"east", "west"},
+		Idle: []models.IdleGPU{
+			{GPUMetrics: gpus[1], AverageUtilization: 4, P95Utilization: 8, WastedGPUHours: 2},
+			{GPUMetrics: gpus[3], AverageUtilization: 0, P95Utilization: 0, WastedGPUHours: 6},
+		},
+	}
+	server := newTestServer(t, src)
+
+	_, _, data := getAPI(t, server, "/api/v1/gpu/idle?max_avg_utilization=2")
+	var report models.IdleGPUReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("failed to decode data: %v", err)
+	}
+	if len(report.GPUs) != 1 || report.GPUs[0].Cluster != "west" || report.TotalWastedGPUHours != 6 {
+		t.Errorf("unexpected report %+v", report)
+	}
+	if report.Lookback != DefaultIdleCriteria.Lookback.String() {
+		t.Errorf("unexpected lookback %q", report.Lookback)
+	}
+}
+
+func TestParseRangeQuery(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
 	tests := []struct {
-		name         string
-		mockError    bool
-		expectedCode int
+		name      string
+		query     models.MetricsQuery
+		wantStart time.Time
+		wantEnd   time.Time
+		wantStep  time.Duration
+		wantErr   bool
 	}{
 		{
-			name:         "successful health check",
-			mockError:    false,
-			expectedCode: http.StatusOK,
+			name:      "defaults to last hour",
+			query:     models.MetricsQuery{},
+			wantStart: now.Add(-time.Hour),
+			wantEnd:   now,
+			wantStep:  15 * time.Second,
 		},
 		{
-			name:         "prometheus connection error",
-			mockError:    true,
-			expectedCode: http.StatusServiceUnavailable,
+			name: "rfc3339 with duration step",
+			query: models.MetricsQuery{
+				StartTime: "2024-01-01T00:00:00Z",
+				EndTime:   "2024-01-01T08:00:00Z",
+				Step:      "5m",
+			},
+			wantStart: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			wantEnd:   time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+			wantStep:  5 * time.Minute,
+		},
+		{
+			name:      "unix seconds with numeric step",
+			query:     models.MetricsQuery{StartTime: "1704067200", EndTime: "1704070800", Step: "60"},
+			wantStart: time.Unix(1704067200, 0),
+			wantEnd:   time.Unix(1704070800, 0),
+			wantStep:  time.Minute,
+		},
+		{
+			name:    "start after end",
+			query:   models.MetricsQuery{StartTime: "1704070800", EndTime: "1704067200"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid step",
+			query:   models.MetricsQuery{Step: "often"},
+			wantErr: true,
+		},
+		{
+			name:    "too many points",
+			query:   models.MetricsQuery{StartTime: "0", EndTime: "1704067200", Step: "1s"},
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := &mockPrometheusClient{shouldReturnError: tt.mockError}
-			handler := NewGPUHandlerWrapper(mockClient)
-
-			req := httptest.NewRequest("GET", "/api/health", nil)
-			w := httptest.NewRecorder()
-
-			handler.HealthCheck(w, req)
-
-			if w.Code != tt.expectedCode {
-				t.Errorf("expected status %d, got %d", tt.expectedCode, w.Code)
+			start, end, step, err := parseRangeQuery(tt.query, now)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
 			}
-
-			var response models.APIResponse
-			err := json.NewDecoder(w.Body).Decode(&response)
 			if err != nil {
-				t.Fatalf("failed to decode response: %v", err)
+				t.Fatalf("unexpected error: %v", err)
 			}
-
-			if tt.mockError && response.Success {
-				t.Error("expected success to be false for error case")
-			}
-			if !tt.mockError && !response.Success {
-				t.Error("expected success to be true for success case")
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) || step != tt.wantStep {
+				t.Errorf("got (%v, %v, %v), want (%v, %v, %v)", start, end, step, tt.wantStart, tt.wantEnd, tt.wantStep)
 			}
 		})
 	}
 }
 
-func TestGetGPUMetrics(t *testing.T) {
+func TestRequestContextCacheControl(t *testing.T) {
 	tests := []struct {
-		name         string
-		mockError    bool
-		expectedCode int
-		checkData    bool
+		name       string
+		header     string
+		wantBypass bool
 	}{
-		{
-			name:         "successful metrics retrieval",
-			mockError:    false,
-			expectedCode: http.StatusOK,
-			checkData:    true,
-		},
-		{
-			name:         "prometheus error",
-			mockError:    true,
-			expectedCode: http.StatusInternalServerError,
-			checkData:    false,
-		},
+		{name: "no header", header: "", wantBypass: false},
+		{name: "no-cache", header: "no-cache", wantBypass: true},
+		{name: "mixed directives", header: "max-age=0, No-Cache", wantBypass: true},
+		{name: "other directive", header: "max-age=60", wantBypass: false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			mockClient := &mockPrometheusClient{shouldReturnError: tt.mockError}
-			handler := NewGPUHandlerWrapper(mockClient)
-
 			req := httptest.NewRequest("GET", "/api/v1/gpu/metrics", nil)
-			w := httptest.NewRecorder()
-
-			handler.GetGPUMetrics(w, req)
-
-			if w.Code != tt.expectedCode {
-				t.Errorf("expected status %d, got %d", tt.expectedCode, w.Code)
+			if tt.header != "" {
+				req.Header.Set("Cache-Control", tt.header)
 			}
 
-			var response models.APIResponse
-			err := json.NewDecoder(w.Body).Decode(&response)
-			if err != nil {
-				t.Fatalf("failed to decode response: %v", err)
-			}
+			ctx, cancel := requestContext(req, time.Second)
+			defer cancel()
 
-			if tt.checkData {
-				if !response.Success {
-					t.Error("expected success to be true")
-				}
-				if response.Data == nil {
-					t.Error("expected data to be present")
-				}
+			if got := cache.Bypassed(ctx); got != tt.wantBypass {
+				t.Errorf("expected bypass %v, got %v", tt.wantBypass, got)
+			}
+			if _, ok := ctx.Deadline(); !ok {
+				t.Error("expected context deadline")
 			}
 		})
 	}
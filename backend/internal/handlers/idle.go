@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// maxIdleLookback bounds the idle report window to keep range queries affordable.
+const maxIdleLookback = 31 * 24 * time.Hour
+
+// DefaultIdleCriteria flags GPUs averaging at most 5% with a p95 of at most 10% over six hours.
+var DefaultIdleCriteria = metrics.IdleCriteria{
+	Lookback:   6 * time.Hour,
+	MaxAverage: 5,
+	MaxP95:     10,
+}
+
+// GetIdleGPUs handles GET /api/v1/gpu/idle - returns GPUs that stayed underutilized
+// over the lookback window, ranked by wasted GPU-hours.
+func (h *GPUHandler) GetIdleGPUs(w http.ResponseWriter, r *http.Request) {
+	criteria, err := parseIdleCriteria(r.URL.Query(), h.idleCriteria)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel, err := h.queryContext(r, 30*time.Second)
+	if err != nil {
+		h.writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	defer cancel()
+
+	report, warnings, err := h.clusters.GetIdleGPUs(ctx, criteria)
+	if err != nil {
+		log.Printf("Error getting idle GPUs: %v", err)
+		h.writeErrorResponse(w, http.StatusInternalServerError, "Failed to retrieve idle GPUs")
+		return
+	}
+	logWarnings("idle GPU report", warnings)
+
+	response := models.APIResponse{
+		Success:  true,
+		Data:     report,
+		Message:  successMessage("Idle GPU report", warnings),
+		Warnings: warnings,
+	}
+
+	h.writeJSONResponse(w, http.StatusOK, response)
+}
+
+// parseIdleCriteria overrides the defaults with lookback, max_avg_utilization,
+// max_p95_utilization and allocated_only query parameters.
+func parseIdleCriteria(query url.Values, defaults metrics.IdleCriteria) (metrics.IdleCriteria, error) {
+	criteria := defaults
+
+	if value := query.Get("lookback"); value != "" {
+		lookback, err := parseDurationParam(value)
+		if err != nil || lookback <= 0 || lookback > maxIdleLookback {
+			return metrics.IdleCriteria{}, fmt.Errorf("invalid lookback: %q (expected a duration up to %s)", value, maxIdleLookback)
+		}
+		criteria.Lookback = lookback
+	}
+
+	thresholds := []struct {
+		key string
+		dst *float64
+	}{
+		{"max_avg_utilization", &criteria.MaxAverage},
+		{"max_p95_utilization", &criteria.MaxP95},
+	}
+	for _, t := range thresholds {
+		value := query.Get(t.key)
+		if value == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil || v < 0 || v > 100 {
+			return metrics.IdleCriteria{}, fmt.Errorf("invalid %s: %q (expected 0-100)", t.key, value)
+		}
+		*t.dst = v
+	}
+
+	if value := query.Get("allocated_only"); value != "" {
+		allocated, err := strconv.ParseBool(value)
+		if err != nil {
+			return metrics.IdleCriteria{}, fmt.Errorf("invalid allocated_only: %q", value)
+		}
+		criteria.AllocatedOnly = allocated
+	}
+
+	return criteria, nil
+}
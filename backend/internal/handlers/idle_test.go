@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+)
+
+func TestParseIdleCriteria(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    metrics.IdleCriteria
+		wantErr bool
+	}{
+		{name: "defaults", query: "", want: DefaultIdleCriteria},
+		{
+			name:  "overrides",
+			query: "lookback=24h&max_avg_utilization=2.5&max_p95_utilization=15&allocated_only=true",
+			want:  metrics.IdleCriteria{Lookback: 24 * time.Hour, MaxAverage: 2.5, MaxP95: 15, AllocatedOnly: true},
+		},
+		{name: "lookback too long", query: "lookback=1000h", wantErr: true},
+		{name: "threshold above 100", query: "max_p95_utilization=101", wantErr: true},
+		{name: "bad bool", query: "allocated_only=maybe", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := parseIdleCriteria(query, DefaultIdleCriteria)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
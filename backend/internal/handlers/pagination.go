@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+const (
+	// defaultPageLimit is used when the caller sends offset without limit.
+	defaultPageLimit = 100
+	// maxPageLimit caps a single page.
+	maxPageLimit = 1000
+)
+
+// gpuSortKeys maps sort parameter values to the GPUMetrics field compared.
+var gpuSortKeys = map[string]func(a, b models.GPUMetrics) int{
+	"cluster":            func(a, b models.GPUMetrics) int { return strings.Compare(a.Cluster, b.Cluster) },
+	"node_name":          func(a, b models.GPUMetrics) int { return strings.Compare(a.NodeName, b.NodeName) },
+	"gpu_index":          func(a, b models.GPUMetrics) int { return cmp.Compare(a.GPUIndex, b.GPUIndex) },
+	"gpu_name":           func(a, b models.GPUMetrics) int { return strings.Compare(a.GPUName, b.GPUName) },
+	"utilization":        func(a, b models.GPUMetrics) int { return cmp.Compare(a.Utilization, b.Utilization) },
+	"memory_used":        func(a, b models.GPUMetrics) int { return cmp.Compare(a.MemoryUsed, b.MemoryUsed) },
+	"memory_utilization": func(a, b models.GPUMetrics) int { return cmp.Compare(a.MemoryUtilization, b.MemoryUtilization) },
+	"temperature":        func(a, b models.GPUMetrics) int { return cmp.Compare(a.Temperature, b.Temperature) },
+	"power_draw":         func(a, b models.GPUMetrics) int { return cmp.Compare(a.PowerDraw, b.PowerDraw) },
+}
+
+// pageRequest holds sort and pagination parameters for list endpoints. A zero
+// limit means the caller did not ask for paging and gets the full result.
+type pageRequest struct {
+	sortKey    string
+	descending bool
+	offset     int
+	limit      int
+}
+
+// parsePageRequest reads sort, order, offset and limit. sort may also carry a
+// leading "-" for descending order. Paging applies only when offset or limit
+// is given; offset alone pages with defaultPageLimit.
+func parsePageRequest(query url.Values) (pageRequest, error) {
+	p := pageRequest{sortKey: "node_name"}
+
+	if key := query.Get("sort"); key != "" {
+		if strings.HasPrefix(key, "-") {
+			p.descending = true
+			key = strings.TrimPrefix(key, "-")
+		}
+		if _, ok := gpuSortKeys[key]; !ok {
+			return pageRequest{}, fmt.Errorf("invalid sort: %q", key)
+		}
+		p.sortKey = key
+	}
+
+	switch order := query.Get("order"); order {
+	case "":
+	case "asc":
+		p.descending = false
+	case "desc":
+		p.descending = true
+	default:
+		return pageRequest{}, fmt.Errorf("invalid order: %q (expected asc or desc)", order)
+	}
+
+	if value := query.Get("offset"); value != "" {
+		offset, err := strconv.Atoi(value)
+		if err != nil || offset < 0 {
+			return pageRequest{}, fmt.Errorf("invalid offset: %q", value)
+		}
+		p.offset = offset
+		p.limit = defaultPageLimit
+	}
+
+	if value := query.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return pageRequest{}, fmt.Errorf("invalid limit: %q (expected 1-%d)", value, maxPageLimit)
+		}
+		p.limit = limit
+	}
+
+	return p, nil
+}
+
+// sortGPUs orders GPUs by the requested key, breaking ties by cluster, node and
+// index so that pages are stable between calls.
+func (p pageRequest) sortGPUs(metrics []models.GPUMetrics) {
+	compare := gpuSortKeys[p.sortKey]
+	sort.SliceStable(metrics, func(i, j int) bool {
+		if c := compare(metrics[i], metrics[j]); c != 0 {
+			if p.descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		if c := strings.Compare(metrics[i].Cluster, metrics[j].Cluster); c != 0 {
+			return c < 0
+		}
+		if c := strings.Compare(metrics[i].NodeName, metrics[j].NodeName); c != 0 {
+			return c < 0
+		}
+		return metrics[i].GPUIndex < metrics[j].GPUIndex
+	})
+}
+
+// page returns the requested window of sorted GPUs and its pagination metadata.
+// Unpaged requests return every GPU and no metadata.
+func (p pageRequest) page(metrics []models.GPUMetrics) ([]models.GPUMetrics, *models.Pagination) {
+	if p.limit == 0 {
+		return metrics, nil
+	}
+
+	total := len(metrics)
+	start := min(p.offset, total)
+	end := min(start+p.limit, total)
+
+	pagination := &models.Pagination{
+		Total:    total,
+		Offset:   p.offset,
+		Limit:    p.limit,
+		Returned: end - start,
+	}
+	if end < total {
+		next := end
+		pagination.NextOffset = &next
+	}
+
+	return metrics[start:end], pagination
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+func TestParsePageRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    pageRequest
+		wantErr bool
+	}{
+		{name: "defaults", query: "", want: pageRequest{sortKey: "node_name"}},
+		{name: "offset only", query: "offset=20", want: pageRequest{sortKey: "node_name", offset: 20, limit: defaultPageLimit}},
+		{name: "order desc", query: "sort=utilization&order=desc&limit=5&offset=10", want: pageRequest{sortKey: "utilization", descending: true, offset: 10, limit: 5}},
+		{name: "dash prefix", query: "sort=-temperature", want: pageRequest{sortKey: "temperature", descending: true}},
+		{name: "unknown sort key", query: "sort=uuid", wantErr: true},
+		{name: "bad order", query: "order=up", wantErr: true},
+		{name: "negative offset", query: "offset=-1", wantErr: true},
+		{name: "zero limit", query: "limit=0", wantErr: true},
+		{name: "limit too large", query: "limit=1001", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := parsePageRequest(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortAndPageGPUs(t *testing.T) {
+	metrics := []models.GPUMetrics{
+		{NodeName: "node-b", GPUIndex: 0, Utilization: 50},
+		{NodeName: "node-a", GPUIndex: 1, Utilization: 90},
+		{NodeName: "node-a", GPUIndex: 0, Utilization: 50},
+		{NodeName: "node-c", GPUIndex: 0, Utilization: 10},
+	}
+
+	p := pageRequest{sortKey: "utilization", descending: true, limit: 2}
+	p.sortGPUs(metrics)
+
+	// Equal utilization falls back to node and index order regardless of direction
+	wantOrder := []string{"node-a:1", "node-a:0", "node-b:0", "node-c:0"}
+	for i, m := range metrics {
+		if key := gpuKey(m.Cluster, m.NodeName, m.GPUIndex); key != wantOrder[i] {
+			t.Fatalf("position %d: got %s, want %s", i, key, wantOrder[i])
+		}
+	}
+
+	page, pagination := p.page(metrics)
+	if len(page) != 2 || pagination.Total != 4 || pagination.Returned != 2 {
+		t.Fatalf("unexpected first page: %d items, %+v", len(page), pagination)
+	}
+	if pagination.NextOffset == nil || *pagination.NextOffset != 2 {
+		t.Fatalf("expected next offset 2, got %v", pagination.NextOffset)
+	}
+
+	p.offset = 2
+	page, pagination = p.page(metrics)
+	if len(page) != 2 || pagination.NextOffset != nil {
+		t.Fatalf("unexpected last page: %d items, %+v", len(page), pagination)
+	}
+
+	p.offset = 10
+	page, pagination = p.page(metrics)
+	if len(page) != 0 || pagination.Returned != 0 || pagination.Total != 4 {
+		t.Fatalf("expected empty page past the end, got %d items, %+v", len(page), pagination)
+	}
+
+	page, pagination = pageRequest{sortKey: "utilization"}.page(metrics)
+	if len(page) != 4 || pagination != nil {
+		t.Fatalf("expected every GPU without paging, got %d items, %+v", len(page), pagination)
+	}
+}
+
+func TestGPUFilter(t *testing.T) {
+	query, _ := url.ParseQuery("node=node-a,node-b&namespace=ml&min_utilization=20&max_temperature=80")
+	filter, err := parseGPUFilter(query)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	sel := filter.selector()
+	if len(sel.Nodes) != 2 || sel.Nodes[0] != "node-a" || sel.Nodes[1] != "node-b" || sel.GPUNames != nil || !reflect.DeepEqual(sel.Namespaces, []string{"ml"}) {
+		t.Fatalf("unexpected selector: %+v", sel)
+	}
+
+	owner := &models.GPUOwner{Namespace: "ml", Pod: "train"}
+	tests := []struct {
+		name string
+		gpu  models.GPUMetrics
+		want bool
+	}{
+		{"matches", models.GPUMetrics{NodeName: "node-a", Utilization: 20, Temperature: 80, Owner: owner}, true},
+		{"other node", models.GPUMetrics{NodeName: "node-c", Utilization: 50, Temperature: 60, Owner: owner}, false},
+		{"unowned", models.GPUMetrics{NodeName: "node-a", Utilization: 50, Temperature: 60}, false},
+		{"below utilization", models.GPUMetrics{NodeName: "node-a", Utilization: 19.9, Temperature: 60, Owner: owner}, false},
+		{"too hot", models.GPUMetrics{NodeName: "node-b", Utilization: 50, Temperature: 81, Owner: owner}, false},
+	}
+	for _, tt := range tests {
+		if got := filter.matches(tt.gpu); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+
+	if _, err := parseGPUFilter(url.Values{"min_temperature": {"hot"}}); err == nil {
+		t.Error("expected error for non-numeric bound")
+	}
+}
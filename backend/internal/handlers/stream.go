@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/stream"
+)
+
+// StreamHandler serves live GPU metrics over Server-Sent Events.
+type StreamHandler struct {
+	hub          *stream.Hub
+	clusters     metrics.Source
+	heartbeat    time.Duration
+	writeTimeout time.Duration
+}
+
+// DefaultHeartbeat is the heartbeat interval used when NewStreamHandler is given a
+// non-positive one.
+const DefaultHeartbeat = 15 * time.Second
+
+// NewStreamHandler creates a stream handler fanning out snapshots from hub, which
+// polls clusters.
+func NewStreamHandler(hub *stream.Hub, clusters metrics.Source, heartbeat time.Duration) *StreamHandler {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeat
+	}
+	return &StreamHandler{
+		hub:          hub,
+		clusters:     clusters,
+		heartbeat:    heartbeat,
+		writeTimeout: 10 * time.Second,
+	}
+}
+
+// StreamGPUMetrics handles GET /api/v1/gpu/stream - pushes GPU metrics as they are polled.
+// Query parameters cluster, node, gpu_name and namespace filter the GPUs sent; mode=diff
+// sends only changed and removed GPUs after the initial snapshot.
+func (h *StreamHandler) StreamGPUMetrics(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseGPUFilter(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := h.clusters.Validate(sortedKeys(filter.clusters)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	diffMode := r.URL.Query().Get("mode") == "diff"
+	scope, scoped := requestScope(r)
+
+	// The server WriteTimeout would cut long-lived streams; deadlines are set per event instead
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("Stream write deadline unsupported: %v", err)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	snapshots, unsubscribe := h.hub.Subscribe()
+	defer unsubscribe()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+
+	send := func(event string, data interface{}) bool {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			log.Printf("Error encoding stream event: %v", err)
+			return false
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(h.writeTimeout))
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	// Ask EventSource clients to wait one heartbeat interval before reconnecting
+	fmt.Fprintf(w, "retry: %d\n\n", h.heartbeat.Milliseconds())
+	rc.Flush()
+
+	var previous map[string]models.GPUMetrics
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case <-heartbeat.C:
+			if !send("heartbeat", map[string]interface{}{"timestamp": time.Now()}) {
+				return
+			}
+
+		case snapshot, ok := <-snapshots:
+			if !ok {
+				return
+			}
+
+			if snapshot.Err != nil {
+				log.Printf("Error polling GPU metrics for stream: %v", snapshot.Err)
+				if !send("error", models.APIResponse{Success: false, Error: "Failed to retrieve GPU metrics"}) {
+					return
+				}
+				continue
+			}
+
+			// The hub polls the whole fleet for every client, so enforce the caller's scope here
+			gpus := filter.apply(snapshot.Metrics)
+			if scoped {
+				visible := gpus[:0]
+				for _, m := range gpus {
+					if scope.Allows(m.NodeName, m.Owner) {
+						visible = append(visible, m)
+					}
+				}
+				gpus = visible
+			}
+			current := indexGPUs(gpus)
+			warnings := streamWarnings(snapshot.Warnings, filter, scoped)
+
+			if !diffMode || previous == nil {
+				ok = send("snapshot", models.StreamSnapshot{
+					Timestamp: snapshot.Time,
+					GPUs:      gpus,
+					Warnings:  warnings,
+				})
+			} else if diff := diffGPUs(previous, current); len(diff.Updated) > 0 || len(diff.Removed) > 0 {
+				diff.Timestamp = snapshot.Time
+				diff.Warnings = warnings
+				ok = send("diff", diff)
+			}
+			if !ok {
+				return
+			}
+			previous = current
+		}
+	}
+}
+
+// streamWarnings returns the warnings about the clusters the filter selects, as a
+// query restricted to those clusters would report them. The hub queries the whole
+// fleet, so scoped callers get the warnings without the queries, which may name
+// scrape targets outside their scope.
+func streamWarnings(warnings []models.Warning, filter gpuFilter, scoped bool) []models.Warning {
+	var visible []models.Warning
+	for _, w := range warnings {
+		if len(filter.clusters) > 0 && !filter.clusters[w.Cluster] {
+			continue
+		}
+		if scoped {
+			w.Query = ""
+		}
+		visible = append(visible, w)
+	}
+	return visible
+}
+
+// gpuKey identifies a GPU across snapshots. Untagged GPUs keep the plain "node:index" form.
+func gpuKey(cluster, nodeName string, gpuIndex int) string {
+	if cluster == "" {
+		return fmt.Sprintf("%s:%d", nodeName, gpuIndex)
+	}
+	return fmt.Sprintf("%s/%s:%d", cluster, nodeName, gpuIndex)
+}
+
+// indexGPUs maps GPUs by node and index.
+func indexGPUs(gpus []models.GPUMetrics) map[string]models.GPUMetrics {
+	index := make(map[string]models.GPUMetrics, len(gpus))
+	for _, m := range gpus {
+		index[gpuKey(m.Cluster, m.NodeName, m.GPUIndex)] = m
+	}
+	return index
+}
+
+// diffGPUs returns GPUs whose readings changed or appeared, and GPUs that disappeared.
+// Timestamps are ignored since every poll refreshes them.
+func diffGPUs(previous, current map[string]models.GPUMetrics) models.StreamDiff {
+	diff := models.StreamDiff{
+		Updated: make([]models.GPUMetrics, 0),
+		Removed: make([]models.GPURef, 0),
+	}
+
+	for key, m := range current {
+		old, found := previous[key]
+		old.Timestamp = m.Timestamp
+		if !found || !reflect.DeepEqual(old, m) {
+			diff.Updated = append(diff.Updated, m)
+		}
+	}
+	for key, m := range previous {
+		if _, found := current[key]; !found {
+			diff.Removed = append(diff.Removed, models.GPURef{Cluster: m.Cluster, NodeName: m.NodeName, GPUIndex: m.GPUIndex, UUID: m.UUID})
+		}
+	}
+
+	sort.Slice(diff.Updated, func(i, j int) bool {
+		return gpuKey(diff.Updated[i].Cluster, diff.Updated[i].NodeName, diff.Updated[i].GPUIndex) < gpuKey(diff.Updated[j].Cluster, diff.Updated[j].NodeName, diff.Updated[j].GPUIndex)
+	})
+	sort.Slice(diff.Removed, func(i, j int) bool {
+		return gpuKey(diff.Removed[i].Cluster, diff.Removed[i].NodeName, diff.Removed[i].GPUIndex) < gpuKey(diff.Removed[j].Cluster, diff.Removed[j].NodeName, diff.Removed[j].GPUIndex)
+	})
+	return diff
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/auth"
+	"k8s-gpu-monitoring/internal/metrics/fake"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/stream"
+)
+
+// readEvent reads the next server-sent event, skipping retry and blank lines.
+func readEvent(t *testing.T, reader *bufio.Reader) (string, string) {
+	t.Helper()
+
+	var event, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestStreamGPUMetrics(t *testing.T) {
+	polls := make(chan []models.GPUMetrics, 2)
+	polls <- []models.GPUMetrics{
+		{NodeName: "node1", GPUIndex: 0, Utilization: 10},
+		{NodeName: "node1", GPUIndex: 1, Utilization: 20},
+		{NodeName: "node2", GPUIndex: 0, Utilization: 30},
+	}
+	polls <- []models.GPUMetrics{
+		{NodeName: "node1", GPUIndex: 0, Utilization: 10},
+		{NodeName: "node1", GPUIndex: 1, Utilization: 95},
+		{NodeName: "node2", GPUIndex: 0, Utilization: 50},
+	}
+
+	hub := stream.NewHub(func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+		select {
+		case metrics := <-polls:
+			return metrics, nil, nil
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		}
+	}, 10*time.Millisecond)
+	defer hub.Close()
+
+	handler := NewStreamHandler(hub, &fake.Source{}, 5*time.Millisecond)
+	server := httptest.NewServer(http.HandlerFunc(handler.StreamGPUMetrics))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "?node=node1&mode=diff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+
+	var snapshot models.StreamSnapshot
+	var diff models.StreamDiff
+	for diff.Updated == nil {
+		event, data := readEvent(t, reader)
+		switch event {
+		case "snapshot":
+			json.Unmarshal([]byte(data), &snapshot)
+		case "diff":
+			json.Unmarshal([]byte(data), &diff)
+		}
+	}
+
+	if len(snapshot.GPUs) != 2 {
+		t.Errorf("expected node1's 2 GPUs in snapshot, got %+v", snapshot.GPUs)
+	}
+	if len(diff.Updated) != 1 || diff.Updated[0].GPUIndex != 1 || diff.Updated[0].Utilization != 95 {
+		t.Errorf("expected only node1 GPU 1 in diff, got %+v", diff.Updated)
+	}
+	// With no further polls queued, only heartbeats should follow
+	if event, _ := readEvent(t, reader); event != "heartbeat" {
+		t.Errorf("expected heartbeat, got %q", event)
+	}
+}
+
+func TestStreamWarnings(t *testing.T) {
+	hub := stream.NewHub(func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+		return []models.GPUMetrics{{Cluster: "east", NodeName: "node1"}}, []models.Warning{
+			{Cluster: "east", Metric: "target", Query: "10.0.0.2:9400", Reason: "backend unreachable"},
+			{Cluster: "west", Metric: "cluster", Reason: "backend unreachable"},
+		}, nil
+	}, 10*time.Millisecond)
+	defer hub.Close()
+
+	handler := NewStreamHandler(hub, &fake.Source{Clusters: []string{"east", "west"}}, time.Second)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		grant := auth.Grant{Clauses: []auth.Clause{{Nodes: []string{"node1"}}}}
+		handler.StreamGPUMetrics(w, r.WithContext(auth.WithGrant(r.Context(), grant)))
+	}))
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "?cluster=nowhere")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown cluster, got %d", resp.StatusCode)
+	}
+
+	resp, err = server.Client().Get(server.URL + "?cluster=east")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	reader := bufio.NewReader(resp.Body)
+	event, data := readEvent(t, reader)
+	for event != "snapshot" {
+		event, data = readEvent(t, reader)
+	}
+	var snapshot models.StreamSnapshot
+	if err := json.Unmarshal([]byte(data), &snapshot); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []models.Warning{{Cluster: "east", Metric: "target", Reason: "backend unreachable"}}
+	if !reflect.DeepEqual(snapshot.Warnings, want) {
+		t.Errorf("expected only east's warning without its query, got %+v", snapshot.Warnings)
+	}
+}
+
+func TestDiffGPUs(t *testing.T) {
+	previous := indexGPUs([]models.GPUMetrics{
+		{NodeName: "a", GPUIndex: 0, Utilization: 1, Timestamp: time.Unix(1, 0)},
+		{NodeName: "a", GPUIndex: 1, Utilization: 1},
+	})
+	current := indexGPUs([]models.GPUMetrics{
+		{NodeName: "a", GPUIndex: 0, Utilization: 1, Timestamp: time.Unix(2, 0)},
+		{NodeName: "b", GPUIndex: 0, Utilization: 5},
+	})
+
+	diff := diffGPUs(previous, current)
+	if len(diff.Updated) != 1 || diff.Updated[0].NodeName != "b" {
+		t.Errorf("expected only new GPU b:0 updated, got %+v", diff.Updated)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].NodeName != "a" || diff.Removed[0].GPUIndex != 1 {
+		t.Errorf("expected a:1 removed, got %+v", diff.Removed)
+	}
+}
@@ -0,0 +1,114 @@
+package history
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// Compact rolls the closed buckets of each resolution up into the next coarser
+// one and deletes the segments that fell out of retention. It is idempotent: a
+// rollup resumes after the latest bucket written, so a crash midway only
+// repeats the work that was lost.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+
+	now := s.now()
+	for i := raw + 1; i < numTiers; i++ {
+		if err := s.rollup(s.tiers[i-1], s.tiers[i], now); err != nil {
+			return fmt.Errorf("rolling up history: %w", err)
+		}
+	}
+	for _, t := range s.tiers {
+		if err := s.expire(t, now); err != nil {
+			return fmt.Errorf("expiring history: %w", err)
+		}
+	}
+	return nil
+}
+
+// rollup aggregates the records of src into the buckets of dst that ended by
+// now and were not written yet. Callers must hold s.mu.
+func (s *Store) rollup(src, dst *tier, now time.Time) error {
+	through := now.Truncate(dst.resolution)
+	var from time.Time
+	if !dst.last.IsZero() {
+		from = dst.last.Add(dst.resolution)
+	}
+	if !from.Before(through) {
+		return nil
+	}
+
+	records, err := s.read(src, from, through)
+	if err != nil {
+		return err
+	}
+
+	// Records are in time order, so buckets complete one after another. A GPU
+	// changing hands within a bucket gets one point per owner, so that each
+	// owner is charged only for its own snapshots.
+	var bucket record
+	index := make(map[string]int)
+	flush := func() error {
+		if len(bucket.points) == 0 {
+			return nil
+		}
+		err := s.append(dst, bucket)
+		bucket = record{}
+		clear(index)
+		return err
+	}
+	for _, rec := range records {
+		start := rec.at.Truncate(dst.resolution)
+		if !start.Equal(bucket.at) {
+			if err := flush(); err != nil {
+				return err
+			}
+			bucket.at = start
+		}
+		for _, p := range rec.points {
+			key := p.ownerKey()
+			if i, ok := index[key]; ok {
+				bucket.points[i].merge(p)
+				continue
+			}
+			index[key] = len(bucket.points)
+			bucket.points = append(bucket.points, p)
+		}
+	}
+	return flush()
+}
+
+// expire deletes the segments of a tier holding only records older than its
+// retention. Callers must hold s.mu.
+func (s *Store) expire(t *tier, now time.Time) error {
+	segments, err := listSegments(t.dir)
+	if err != nil {
+		return err
+	}
+
+	cutoff := now.Add(-t.retention)
+	removed := false
+	for _, seg := range segments {
+		if seg.start.Add(t.span).After(cutoff) {
+			break
+		}
+		if t.file != nil && t.fileStart.Equal(seg.start) {
+			t.file.Close()
+			t.file = nil
+		}
+		if err := os.Remove(seg.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		removed = true
+	}
+	if removed {
+		return syncDir(t.dir)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+var _ metrics.Source = (*Fallback)(nil)
+
+// Fallback is a metrics.Source serving range queries and idle reports from the
+// store when the wrapped source cannot: when they start beyond the source's
+// retention, or when the source fails. Every other method is served by the
+// wrapped source.
+type Fallback struct {
+	metrics.Source
+	store     *Store
+	retention time.Duration
+}
+
+// NewFallback wraps source, whose own history reaches back retention. A zero
+// retention falls back only when source fails.
+func NewFallback(source metrics.Source, store *Store, retention time.Duration) *Fallback {
+	return &Fallback{Source: source, store: store, retention: retention}
+}
+
+// GetGPUMetricsRange returns the averaged GPU time series from the wrapped
+// source, or from the store with a warning saying why.
+func (f *Fallback) GetGPUMetricsRange(ctx context.Context, start, end time.Time, step time.Duration) ([]models.GPUTimeSeries, []models.Warning, error) {
+	if f.retention > 0 && start.Before(f.store.now().Add(-f.retention)) {
+		series, warnings, err := f.store.Range(ctx, start, end, step, Average)
+		if err != nil {
+			return nil, nil, err
+		}
+		return series, append(warnings, f.warning(fmt.Sprintf("range starts beyond the source retention of %s", f.retention))), nil
+	}
+
+	series, warnings, err := f.Source.GetGPUMetricsRange(ctx, start, end, step)
+	if err == nil || ctx.Err() != nil {
+		return series, warnings, err
+	}
+
+	// Keep the source's error when the store has nothing to offer either
+	stored, storedWarnings, storeErr := f.store.Range(ctx, start, end, step, Average)
+	if storeErr != nil || len(stored) == 0 {
+		return nil, nil, err
+	}
+	return stored, append(storedWarnings, f.warning("source unavailable")), nil
+}
+
+// GetIdleGPUs returns the idle GPU report from the wrapped source, or from the
+// store with a warning saying why.
+func (f *Fallback) GetIdleGPUs(ctx context.Context, criteria metrics.IdleCriteria) (*models.IdleGPUReport, []models.Warning, error) {
+	if f.retention > 0 && criteria.Lookback > f.retention {
+		report, warnings, err := f.store.Idle(ctx, criteria)
+		if err != nil {
+			return nil, nil, err
+		}
+		return report, append(warnings, f.warning(fmt.Sprintf("lookback exceeds the source retention of %s", f.retention))), nil
+	}
+
+	report, warnings, err := f.Source.GetIdleGPUs(ctx, criteria)
+	if err == nil || ctx.Err() != nil {
+		return report, warnings, err
+	}
+
+	// Keep the source's error when the store has nothing to offer either
+	stored, storedWarnings, storeErr := f.store.Idle(ctx, criteria)
+	if storeErr != nil {
+		return nil, nil, err
+	}
+	return stored, append(storedWarnings, f.warning("source unavailable")), nil
+}
+
+// warning tells that a range was served from the local history.
+func (f *Fallback) warning(reason string) models.Warning {
+	return models.Warning{Metric: "history", Reason: "served from local history: " + reason}
+}
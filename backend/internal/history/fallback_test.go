@@ -0,0 +1,109 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/metrics/fake"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+func TestFallback(t *testing.T) {
+	clock := &testClock{}
+	store := openStore(t, t.TempDir(), clock, DefaultRetention())
+	recordMinutes(t, store, clock, 0, 120)
+
+	source := &fake.Source{
+		GPUs: []models.GPUMetrics{gpu("", "node-a", 0, 99)},
+		Series: []models.GPUTimeSeries{{
+			NodeName:    "node-a",
+			Utilization: []models.DataPoint{{Timestamp: clock.t, Value: 99}},
+		}},
+	}
+	fallback := NewFallback(source, store, time.Hour)
+	ctx := context.Background()
+	now := clock.t
+
+	// Within the source's retention the source answers
+	series, warnings, err := fallback.GetGPUMetricsRange(ctx, now.Add(-time.Hour), now, time.Minute)
+	if err != nil || len(warnings) != 0 || len(series) != 1 || series[0].Utilization[0].Value != 99 {
+		t.Errorf("expected the source's series, got %+v, %+v, %v", series, warnings, err)
+	}
+
+	// Beyond it the store does, without asking the source
+	calls := source.Calls("GetGPUMetricsRange")
+	series, warnings, err = fallback.GetGPUMetricsRange(ctx, now.Add(-2*time.Hour), now, time.Hour)
+	if err != nil || len(series) != 1 || len(series[0].Utilization) != 3 || series[0].Utilization[2].Value != 90.5 {
+		t.Errorf("expected the stored history, got %+v, %v", series, err)
+	}
+	if len(warnings) != 1 || warnings[0].Metric != "history" || source.Calls("GetGPUMetricsRange") != calls {
+		t.Errorf("expected a history warning only, got %+v", warnings)
+	}
+
+	// A failing source is replaced by the store
+	unavailable := errors.New("prometheus unavailable")
+	source.Update(func(s *fake.Source) {
+		s.Errors = map[string]error{"GetGPUMetricsRange": unavailable}
+	})
+	series, warnings, err = fallback.GetGPUMetricsRange(ctx, now.Add(-10*time.Minute), now, time.Minute)
+	if err != nil || len(series) != 1 || len(series[0].Utilization) != 11 || len(warnings) != 1 || warnings[0].Reason != "served from local history: source unavailable" {
+		t.Errorf("expected the stored history, got %+v, %+v, %v", series, warnings, err)
+	}
+
+	// Unless the store holds nothing for the range either
+	if _, _, err := fallback.GetGPUMetricsRange(ctx, now.Add(time.Hour), now.Add(2*time.Hour), time.Minute); !errors.Is(err, unavailable) {
+		t.Errorf("expected the source's error, got %v", err)
+	}
+
+	// Everything else is served by the source
+	if metrics, _, err := fallback.GetGPUMetrics(ctx); err != nil || len(metrics) != 1 || metrics[0].Utilization != 99 {
+		t.Errorf("expected the source's metrics, got %+v, %v", metrics, err)
+	}
+}
+
+func TestFallbackIdle(t *testing.T) {
+	clock := &testClock{}
+	store := openStore(t, t.TempDir(), clock, DefaultRetention())
+	for i := 0; i <= 30; i++ {
+		clock.t = testStart.Add(time.Duration(i) * time.Minute)
+		idle := gpu("", "node-a", 0, float64(i%3))
+		idle.Owner = &models.GPUOwner{Namespace: "ml", Pod: "notebook"}
+		if err := store.Record([]models.GPUMetrics{idle, gpu("", "node-b", 0, 80)}); err != nil {
+			t.Fatalf("Record at minute %d: %v", i, err)
+		}
+	}
+
+	unavailable := errors.New("prometheus unavailable")
+	source := &fake.Source{Errors: map[string]error{"GetIdleGPUs": unavailable}}
+	fallback := NewFallback(source, store, time.Hour)
+	ctx := context.Background()
+	criteria := metrics.IdleCriteria{Lookback: 20 * time.Minute, MaxAverage: 5, MaxP95: 10, AllocatedOnly: true}
+
+	// A failing source is replaced by the store
+	report, warnings, err := fallback.GetIdleGPUs(ctx, criteria)
+	if err != nil || len(report.GPUs) != 1 || report.GPUs[0].NodeName != "node-a" || report.GPUs[0].Owner == nil {
+		t.Fatalf("expected node-a idle from the stored history, got %+v, %v", report, err)
+	}
+	if gpu := report.GPUs[0]; gpu.AverageUtilization > 2 || gpu.P95Utilization != 2 || gpu.ObservedHours != criteria.Lookback.Hours() {
+		t.Errorf("unexpected summary %+v", gpu)
+	}
+	if len(warnings) != 1 || warnings[0].Reason != "served from local history: source unavailable" {
+		t.Errorf("expected a history warning, got %+v", warnings)
+	}
+
+	// Beyond the source's retention the store answers without asking the source
+	calls := source.Calls("GetIdleGPUs")
+	report, warnings, err = NewFallback(source, store, 10*time.Minute).GetIdleGPUs(ctx, criteria)
+	if err != nil || len(report.GPUs) != 1 || len(warnings) != 1 || source.Calls("GetIdleGPUs") != calls {
+		t.Errorf("expected the stored report, got %+v, %+v, %v", report, warnings, err)
+	}
+
+	// The source's error is kept when the store holds nothing for the lookback
+	clock.t = clock.t.Add(time.Hour)
+	if _, _, err := fallback.GetIdleGPUs(ctx, criteria); !errors.Is(err, unavailable) {
+		t.Errorf("expected the source's error, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// errNoHistory is returned by Idle when no visible GPU has samples in the lookback.
+var errNoHistory = errors.New("no local history for the lookback")
+
+// Idle finds the GPUs visible to ctx whose average and p95 utilization stayed
+// at or below the thresholds over the lookback, ranked by wasted GPU-hours. It
+// reads the finest resolution still holding the lookback; each snapshot or
+// rollup bucket is one sample, observed for the snapshots it aggregates.
+func (s *Store) Idle(ctx context.Context, criteria metrics.IdleCriteria) (*models.IdleGPUReport, []models.Warning, error) {
+	if criteria.Lookback <= 0 {
+		return nil, nil, fmt.Errorf("lookback must be positive")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, nil, errClosed
+	}
+
+	now := s.now()
+	start := now.Add(-criteria.Lookback)
+	t, cutoff, warnings := s.tierFor(start)
+
+	// Buckets starting before start still cover part of the lookback
+	records, err := s.read(t, start.Add(-t.resolution+time.Millisecond), now.Add(time.Millisecond))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	type gpuHistory struct {
+		latest    point
+		at        time.Time
+		owner     *models.GPUOwner
+		values    []float64
+		snapshots uint64
+	}
+	visible := visibleTo(ctx)
+	gpus := make(map[string]*gpuHistory)
+	for _, rec := range records {
+		if rec.at.Before(cutoff) {
+			continue
+		}
+		for _, p := range rec.points {
+			if !visible(&p) {
+				continue
+			}
+			g := gpus[p.key()]
+			if g == nil {
+				g = &gpuHistory{}
+				gpus[p.key()] = g
+			}
+			// The identity of the latest point and the latest known owner win
+			g.latest, g.at = p, rec.at.Add(t.resolution)
+			if owner := p.owner(); owner != nil {
+				g.owner = owner
+			}
+			g.values = append(g.values, p.avg[utilization])
+			g.snapshots += p.count
+		}
+	}
+
+	if len(gpus) == 0 {
+		return nil, nil, errNoHistory
+	}
+
+	report := &models.IdleGPUReport{
+		Lookback:   criteria.Lookback.String(),
+		MaxAverage: criteria.MaxAverage,
+		MaxP95:     criteria.MaxP95,
+		GPUs:       []models.IdleGPU{},
+	}
+	for _, g := range gpus {
+		average, p95 := models.UtilizationSummary(g.values)
+		if average > criteria.MaxAverage || p95 > criteria.MaxP95 || (criteria.AllocatedOnly && g.owner == nil) {
+			continue
+		}
+
+		p := g.latest
+		observed := math.Min(float64(g.snapshots)*s.interval.Hours(), criteria.Lookback.Hours())
+		report.GPUs = append(report.GPUs, models.IdleGPU{
+			GPUMetrics: models.GPUMetrics{
+				Cluster:     p.cluster,
+				NodeName:    p.nodeName,
+				GPUIndex:    p.gpuIndex,
+				GPUName:     p.gpuName,
+				UUID:        p.uuid,
+				Owner:       g.owner,
+				Utilization: p.avg[utilization],
+				MemoryUsed:  p.avg[memoryUsed],
+				MemoryTotal: p.avg[memoryTotal],
+				MemoryFree:  p.avg[memoryFree],
+				Temperature: p.avg[temperature],
+				PowerDraw:   p.avg[powerDraw],
+				Timestamp:   g.at,
+			},
+			AverageUtilization: average,
+			P95Utilization:     p95,
+			ObservedHours:      observed,
+			WastedGPUHours:     observed * (100 - average) / 100,
+		})
+		report.TotalWastedGPUHours += observed * (100 - average) / 100
+	}
+
+	sort.Slice(report.GPUs, func(i, j int) bool {
+		a, b := report.GPUs[i], report.GPUs[j]
+		if a.WastedGPUHours != b.WastedGPUHours {
+			return a.WastedGPUHours > b.WastedGPUHours
+		}
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.NodeName != b.NodeName {
+			return a.NodeName < b.NodeName
+		}
+		return a.GPUIndex < b.GPUIndex
+	})
+	return report, warnings, nil
+}
@@ -0,0 +1,286 @@
+package history
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Each segment file is a sequence of frames: a little-endian uint32 payload
+// length, the CRC-32C of the payload, then the payload. A frame that is cut
+// short or fails its checksum ends the segment; on open the tail after the last
+// good frame is truncated, so a crash mid-append loses at most that record.
+const (
+	frameHeaderSize = 8
+	maxFrameSize    = 64 << 20
+	segmentExt      = ".seg"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// Indexes of the values kept per GPU, matching the series of models.GPUTimeSeries.
+const (
+	utilization = iota
+	memoryUsed
+	memoryTotal
+	memoryFree
+	temperature
+	powerDraw
+	numValues
+)
+
+// point is a GPU's values at a snapshot, or aggregated over a rollup bucket.
+type point struct {
+	cluster   string
+	nodeName  string
+	gpuIndex  int
+	gpuName   string
+	uuid      string
+	namespace string
+	pod       string
+	container string
+	// count is the number of snapshots aggregated; raw points have 1 and max equal to avg.
+	count uint64
+	avg   [numValues]float64
+	max   [numValues]float64
+}
+
+// record is a snapshot of every GPU, or a rollup bucket starting at at.
+type record struct {
+	at     time.Time
+	points []point
+}
+
+// appendFrame appends rec, framed, to buf.
+func appendFrame(buf []byte, rec record) []byte {
+	start := len(buf)
+	buf = append(buf, make([]byte, frameHeaderSize)...)
+
+	buf = binary.AppendVarint(buf, rec.at.UnixMilli())
+	buf = binary.AppendUvarint(buf, uint64(len(rec.points)))
+	for _, p := range rec.points {
+		for _, s := range []string{p.cluster, p.nodeName, p.gpuName, p.uuid, p.namespace, p.pod, p.container} {
+			buf = binary.AppendUvarint(buf, uint64(len(s)))
+			buf = append(buf, s...)
+		}
+		buf = binary.AppendUvarint(buf, uint64(p.gpuIndex))
+		buf = binary.AppendUvarint(buf, p.count)
+		for _, v := range p.avg {
+			buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+		}
+		if p.count > 1 {
+			for _, v := range p.max {
+				buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v))
+			}
+		}
+	}
+
+	payload := buf[start+frameHeaderSize:]
+	binary.LittleEndian.PutUint32(buf[start:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[start+4:], crc32.Checksum(payload, castagnoli))
+	return buf
+}
+
+// decoder reads the fields of a record payload, remembering the first error.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uvarint() uint64 {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	v, n := binary.Varint(d.b)
+	if n <= 0 {
+		d.fail()
+		return 0
+	}
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uvarint()
+	if d.err != nil || n > uint64(len(d.b)) {
+		d.fail()
+		return ""
+	}
+	s := string(d.b[:n])
+	d.b = d.b[n:]
+	return s
+}
+
+func (d *decoder) float() float64 {
+	if len(d.b) < 8 {
+		d.fail()
+		return 0
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(d.b))
+	d.b = d.b[8:]
+	return v
+}
+
+func (d *decoder) fail() {
+	if d.err == nil {
+		d.err = errors.New("malformed record")
+	}
+	d.b = nil
+}
+
+// decodeRecord decodes a frame payload.
+func decodeRecord(payload []byte) (record, error) {
+	d := &decoder{b: payload}
+	rec := record{at: time.UnixMilli(d.varint())}
+	n := d.uvarint()
+	if d.err == nil && n > uint64(len(d.b)) {
+		d.fail()
+	}
+	for i := uint64(0); i < n && d.err == nil; i++ {
+		p := point{
+			cluster:   d.string(),
+			nodeName:  d.string(),
+			gpuName:   d.string(),
+			uuid:      d.string(),
+			namespace: d.string(),
+			pod:       d.string(),
+			container: d.string(),
+			gpuIndex:  int(d.uvarint()),
+			count:     d.uvarint(),
+		}
+		for j := range p.avg {
+			p.avg[j] = d.float()
+		}
+		if p.count > 1 {
+			for j := range p.max {
+				p.max[j] = d.float()
+			}
+		} else {
+			p.max = p.avg
+		}
+		rec.points = append(rec.points, p)
+	}
+	if d.err == nil && len(d.b) > 0 {
+		d.fail()
+	}
+	return rec, d.err
+}
+
+// readSegment returns the records of a segment file and the size of its valid
+// prefix, stopping at the first torn or corrupt frame.
+func readSegment(path string) ([]record, int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var records []record
+	offset := 0
+	for len(data)-offset >= frameHeaderSize {
+		size := int(binary.LittleEndian.Uint32(data[offset:]))
+		sum := binary.LittleEndian.Uint32(data[offset+4:])
+		end := offset + frameHeaderSize + size
+		if size > maxFrameSize || end > len(data) {
+			break
+		}
+		payload := data[offset+frameHeaderSize : end]
+		if crc32.Checksum(payload, castagnoli) != sum {
+			break
+		}
+		rec, err := decodeRecord(payload)
+		if err != nil {
+			break
+		}
+		records = append(records, rec)
+		offset = end
+	}
+	return records, int64(offset), nil
+}
+
+// segment is a segment file holding the records from start.
+type segment struct {
+	start time.Time
+	path  string
+}
+
+// listSegments returns the segment files in dir in time order.
+func listSegments(dir string) ([]segment, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), segmentExt)
+		if !ok || entry.IsDir() {
+			continue
+		}
+		seconds, err := strconv.ParseInt(name, 10, 64)
+		if err != nil {
+			continue
+		}
+		segments = append(segments, segment{start: time.Unix(seconds, 0), path: filepath.Join(dir, entry.Name())})
+	}
+	sort.Slice(segments, func(i, j int) bool { return segments[i].start.Before(segments[j].start) })
+	return segments, nil
+}
+
+// segmentPath returns the path of the segment file starting at start.
+func segmentPath(dir string, start time.Time) string {
+	return filepath.Join(dir, strconv.FormatInt(start.Unix(), 10)+segmentExt)
+}
+
+// repairSegment truncates a segment file after its last good frame and
+// returns its records.
+func repairSegment(path string) ([]record, error) {
+	records, valid, err := readSegment(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() == valid {
+		return records, nil
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := f.Truncate(valid); err != nil {
+		return nil, fmt.Errorf("truncating %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, fmt.Errorf("truncating %s: %w", path, err)
+	}
+	return records, nil
+}
+
+// syncDir flushes a directory so that created and removed files survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
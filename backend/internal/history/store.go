@@ -0,0 +1,531 @@
+// Package history keeps a local, on-disk history of GPU metrics, so that range
+// queries can still be answered once Prometheus has dropped the data or while
+// it is unreachable.
+//
+// Snapshots are kept raw for a short period and rolled up into 5 minute and
+// 1 hour buckets holding the average and maximum of every value, which are kept
+// for much longer. Each resolution is a directory of append-only segment files
+// covering a fixed span of time; expired segments are deleted whole.
+package history
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// Indexes of the resolutions, from finest to coarsest.
+const (
+	raw = iota
+	fiveMinute
+	hourly
+	numTiers
+)
+
+var errClosed = errors.New("history store is closed")
+
+// FetchFunc retrieves the current GPU metrics.
+type FetchFunc func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error)
+
+// Aggregation selects how the values within a step are combined.
+type Aggregation int
+
+const (
+	// Average is the mean of the snapshots within a step.
+	Average Aggregation = iota
+	// Max is the highest snapshot within a step.
+	Max
+)
+
+// Retention is how long each resolution is kept.
+type Retention struct {
+	Raw        time.Duration
+	FiveMinute time.Duration
+	Hourly     time.Duration
+}
+
+// DefaultRetention keeps raw snapshots for 2 days, 5 minute rollups for 30 days
+// and hourly rollups for a year.
+func DefaultRetention() Retention {
+	return Retention{Raw: 48 * time.Hour, FiveMinute: 30 * 24 * time.Hour, Hourly: 365 * 24 * time.Hour}
+}
+
+// Validate checks that every resolution is kept for a positive period.
+func (r Retention) Validate() error {
+	if r.Raw <= 0 || r.FiveMinute <= 0 || r.Hourly <= 0 {
+		return fmt.Errorf("history retention must be positive")
+	}
+	return nil
+}
+
+// tier is the directory of segment files holding one resolution.
+type tier struct {
+	dir string
+	// resolution is the bucket size of rollups, 0 for raw snapshots.
+	resolution time.Duration
+	// span is the period of time a segment file covers.
+	span      time.Duration
+	retention time.Duration
+	// last is the time of the latest record, zero when there is none.
+	last time.Time
+
+	file      *os.File // the segment being appended to
+	fileStart time.Time
+}
+
+// Store is an embedded time-series store of GPU metrics.
+type Store struct {
+	dir          string
+	retention    Retention
+	interval     time.Duration
+	fetchTimeout time.Duration
+	now          func() time.Time
+
+	mu     sync.Mutex
+	tiers  [numTiers]*tier
+	closed bool
+}
+
+// Option configures a Store.
+type Option func(*Store)
+
+// WithRetention sets how long each resolution is kept.
+func WithRetention(retention Retention) Option {
+	return func(s *Store) {
+		s.retention = retention
+	}
+}
+
+// WithInterval sets how often Run takes a snapshot.
+func WithInterval(interval time.Duration) Option {
+	return func(s *Store) {
+		if interval > 0 {
+			s.interval = interval
+		}
+	}
+}
+
+// WithClock replaces time.Now, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(s *Store) {
+		s.now = now
+	}
+}
+
+// Open opens the store in dir, creating it when needed. A record torn by a
+// crash while it was being written is discarded.
+func Open(dir string, opts ...Option) (*Store, error) {
+	s := &Store{
+		dir:          dir,
+		retention:    DefaultRetention(),
+		interval:     time.Minute,
+		fetchTimeout: 30 * time.Second,
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if err := s.retention.Validate(); err != nil {
+		return nil, err
+	}
+
+	specs := [numTiers]struct {
+		name       string
+		resolution time.Duration
+		span       time.Duration
+		retention  time.Duration
+	}{
+		raw:        {"raw", 0, time.Hour, s.retention.Raw},
+		fiveMinute: {"5m", 5 * time.Minute, 24 * time.Hour, s.retention.FiveMinute},
+		hourly:     {"1h", time.Hour, 7 * 24 * time.Hour, s.retention.Hourly},
+	}
+	for i, spec := range specs {
+		t := &tier{dir: filepath.Join(dir, spec.name), resolution: spec.resolution, span: spec.span, retention: spec.retention}
+		if err := os.MkdirAll(t.dir, 0o755); err != nil {
+			return nil, fmt.Errorf("creating history directory: %w", err)
+		}
+		last, err := lastRecord(t.dir)
+		if err != nil {
+			return nil, fmt.Errorf("opening history %s: %w", spec.name, err)
+		}
+		t.last = last
+		s.tiers[i] = t
+	}
+	return s, nil
+}
+
+// lastRecord returns the time of the latest record in a tier directory,
+// repairing the segments it reads.
+func lastRecord(dir string) (time.Time, error) {
+	segments, err := listSegments(dir)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for i := len(segments) - 1; i >= 0; i-- {
+		records, err := repairSegment(segments[i].path)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if len(records) > 0 {
+			return records[len(records)-1].at, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// Close closes the segment files. Later writes fail.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closed = true
+	var errs []error
+	for _, t := range s.tiers {
+		if t.file != nil {
+			errs = append(errs, t.file.Close())
+			t.file = nil
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run snapshots the metrics fetch returns immediately and then every interval,
+// compacting after each snapshot, until ctx is canceled.
+func (s *Store) Run(ctx context.Context, fetch FetchFunc) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		s.snapshotOnce(ctx, fetch)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshotOnce records the current metrics and compacts the store.
+func (s *Store) snapshotOnce(ctx context.Context, fetch FetchFunc) {
+	fetchCtx, cancel := context.WithTimeout(ctx, s.fetchTimeout)
+	metrics, _, err := fetch(fetchCtx)
+	cancel()
+
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("Error fetching GPU metrics for history: %v", err)
+	} else if err := s.Record(metrics); err != nil {
+		log.Printf("Error recording GPU metrics history: %v", err)
+	}
+	if err := s.Compact(); err != nil {
+		log.Printf("Error compacting GPU metrics history: %v", err)
+	}
+}
+
+// Record appends a snapshot of metrics taken now. The write is flushed to disk
+// before Record returns.
+func (s *Store) Record(metrics []models.GPUMetrics) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return errClosed
+	}
+
+	// Records are stored with millisecond precision
+	now := s.now().Truncate(time.Millisecond)
+	t := s.tiers[raw]
+	if !now.After(t.last) {
+		return fmt.Errorf("snapshot at %s is not after the last one at %s", now.Format(time.RFC3339Nano), t.last.Format(time.RFC3339Nano))
+	}
+
+	rec := record{at: now, points: make([]point, 0, len(metrics))}
+	for _, m := range metrics {
+		p := point{
+			cluster:  m.Cluster,
+			nodeName: m.NodeName,
+			gpuIndex: m.GPUIndex,
+			gpuName:  m.GPUName,
+			uuid:     m.UUID,
+			count:    1,
+			avg:      [numValues]float64{m.Utilization, m.MemoryUsed, m.MemoryTotal, m.MemoryFree, m.Temperature, m.PowerDraw},
+		}
+		p.max = p.avg
+		if m.Owner != nil {
+			p.namespace, p.pod, p.container = m.Owner.Namespace, m.Owner.Pod, m.Owner.Container
+		}
+		rec.points = append(rec.points, p)
+	}
+	return s.append(t, rec)
+}
+
+// append writes rec to the tier's segment covering it and syncs the file.
+// Callers must hold s.mu and append in time order.
+func (s *Store) append(t *tier, rec record) error {
+	start := rec.at.Truncate(t.span)
+	if t.file == nil || !start.Equal(t.fileStart) {
+		if t.file != nil {
+			t.file.Close()
+			t.file = nil
+		}
+
+		// Drop whatever a failed write left behind before appending after it
+		path := segmentPath(t.dir, start)
+		if _, err := os.Stat(path); err == nil {
+			if _, err := repairSegment(path); err != nil {
+				return err
+			}
+		}
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening history segment: %w", err)
+		}
+		if err := syncDir(t.dir); err != nil {
+			f.Close()
+			return fmt.Errorf("syncing history directory: %w", err)
+		}
+		t.file, t.fileStart = f, start
+	}
+
+	if _, err := t.file.Write(appendFrame(nil, rec)); err != nil {
+		t.file.Close()
+		t.file = nil
+		return fmt.Errorf("writing history segment: %w", err)
+	}
+	if err := t.file.Sync(); err != nil {
+		t.file.Close()
+		t.file = nil
+		return fmt.Errorf("syncing history segment: %w", err)
+	}
+	t.last = rec.at
+	return nil
+}
+
+// read returns the records of a tier from from up to, but excluding, to.
+// Callers must hold s.mu.
+func (s *Store) read(t *tier, from, to time.Time) ([]record, error) {
+	segments, err := listSegments(t.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []record
+	for _, seg := range segments {
+		if !seg.start.Add(t.span).After(from) || !seg.start.Before(to) {
+			continue
+		}
+		segRecords, _, err := readSegment(seg.path)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range segRecords {
+			if !rec.at.Before(from) && rec.at.Before(to) {
+				records = append(records, rec)
+			}
+		}
+	}
+	return records, nil
+}
+
+// Range returns the history of the GPUs visible to ctx from start to end at
+// every step, combining the values within a step with agg. It reads the finest
+// resolution still holding start and warns when even the hourly rollups do not
+// reach back that far. Rollups cover closed buckets only, so they lag behind
+// the latest snapshot by up to their resolution.
+func (s *Store) Range(ctx context.Context, start, end time.Time, step time.Duration, agg Aggregation) ([]models.GPUTimeSeries, []models.Warning, error) {
+	if step <= 0 {
+		return nil, nil, fmt.Errorf("step must be positive")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, nil, errClosed
+	}
+
+	t, cutoff, warnings := s.tierFor(start)
+
+	// A rollup bucket is complete, and placed, at its end. A step takes the
+	// snapshots or buckets since the previous step, and at least one interval
+	// or resolution.
+	window := max(step, t.resolution)
+	if t.resolution == 0 {
+		window = max(window, s.interval)
+	}
+	records, err := s.read(t, start.Add(-window-t.resolution), end.Add(time.Millisecond))
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading history: %w", err)
+	}
+
+	type gpuHistory struct {
+		series models.GPUTimeSeries
+		times  []time.Time
+		points []point
+	}
+	visible := visibleTo(ctx)
+	gpus := make(map[string]*gpuHistory)
+	for _, rec := range records {
+		if rec.at.Before(cutoff) {
+			continue
+		}
+		at := rec.at.Add(t.resolution)
+		for _, p := range rec.points {
+			if !visible(&p) {
+				continue
+			}
+			key := p.key()
+			g := gpus[key]
+			if g == nil {
+				g = &gpuHistory{series: models.GPUTimeSeries{Cluster: p.cluster, NodeName: p.nodeName, GPUIndex: p.gpuIndex}}
+				gpus[key] = g
+			}
+			g.series.GPUName = p.gpuName
+			g.times = append(g.times, at)
+			g.points = append(g.points, p)
+		}
+	}
+
+	series := make([]models.GPUTimeSeries, 0, len(gpus))
+	for _, g := range gpus {
+		ts := g.series
+		ts.Utilization = []models.DataPoint{}
+		ts.MemoryUsed = []models.DataPoint{}
+		ts.MemoryTotal = []models.DataPoint{}
+		ts.MemoryFree = []models.DataPoint{}
+		ts.Temperature = []models.DataPoint{}
+		ts.PowerDraw = []models.DataPoint{}
+		values := [numValues]*[]models.DataPoint{&ts.Utilization, &ts.MemoryUsed, &ts.MemoryTotal, &ts.MemoryFree, &ts.Temperature, &ts.PowerDraw}
+
+		first, next := 0, 0
+		for at := start; !at.After(end); at = at.Add(step) {
+			for first < len(g.times) && !g.times[first].After(at.Add(-window)) {
+				first++
+			}
+			for next < len(g.times) && !g.times[next].After(at) {
+				next++
+			}
+			if first >= next {
+				continue
+			}
+
+			combined := g.points[first]
+			for _, p := range g.points[first+1 : next] {
+				combined.merge(p)
+			}
+			for i, points := range values {
+				v := combined.avg[i]
+				if agg == Max {
+					v = combined.max[i]
+				}
+				*points = append(*points, models.DataPoint{Timestamp: at.UTC(), Value: v})
+			}
+		}
+		if len(ts.Utilization) > 0 {
+			series = append(series, ts)
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		a, b := series[i], series[j]
+		if a.Cluster != b.Cluster {
+			return a.Cluster < b.Cluster
+		}
+		if a.NodeName != b.NodeName {
+			return a.NodeName < b.NodeName
+		}
+		return a.GPUIndex < b.GPUIndex
+	})
+	return series, warnings, nil
+}
+
+// tierFor returns the finest resolution still holding start and the time its
+// retention reaches back to, warning when even the hourly rollups do not reach
+// start. Callers must hold s.mu.
+func (s *Store) tierFor(start time.Time) (*tier, time.Time, []models.Warning) {
+	now := s.now()
+	t := s.tiers[hourly]
+	for _, candidate := range s.tiers {
+		if !start.Before(now.Add(-candidate.retention)) {
+			t = candidate
+			break
+		}
+	}
+	var warnings []models.Warning
+	cutoff := now.Add(-t.retention)
+	if start.Before(cutoff) {
+		warnings = append(warnings, models.Warning{Metric: "history", Reason: fmt.Sprintf("local history keeps only the last %s", t.retention)})
+	}
+	return t, cutoff, warnings
+}
+
+// visibleTo returns a filter keeping the points of the clusters selected in ctx
+// that the caller's scope allows.
+func visibleTo(ctx context.Context) func(p *point) bool {
+	scope, scoped := metrics.ScopeFrom(ctx)
+	clusters := metrics.ClustersFrom(ctx)
+	return func(p *point) bool {
+		if len(clusters) > 0 && !contains(clusters, p.cluster) {
+			return false
+		}
+		return !scoped || scope.Allows(p.nodeName, p.owner())
+	}
+}
+
+// key identifies the GPU a point belongs to.
+func (p *point) key() string {
+	return fmt.Sprintf("%s/%s/%d", p.cluster, p.nodeName, p.gpuIndex)
+}
+
+// ownerKey identifies the GPU together with the pod container holding it.
+func (p *point) ownerKey() string {
+	return fmt.Sprintf("%s/%s/%s/%s", p.key(), p.namespace, p.pod, p.container)
+}
+
+// owner returns the pod container holding the GPU, or nil when it is unallocated.
+func (p *point) owner() *models.GPUOwner {
+	if p.namespace == "" && p.pod == "" {
+		return nil
+	}
+	return &models.GPUOwner{Namespace: p.namespace, Pod: p.pod, Container: p.container}
+}
+
+// merge folds a later point of the same GPU into p, weighting averages by
+// the number of snapshots. The identity of the later point wins; the owner of
+// p is kept, so rollups only merge points of the same owner.
+func (p *point) merge(later point) {
+	total := float64(p.count + later.count)
+	for i := range p.avg {
+		p.avg[i] = (p.avg[i]*float64(p.count) + later.avg[i]*float64(later.count)) / total
+		p.max[i] = max(p.max[i], later.max[i])
+	}
+	p.count += later.count
+	p.gpuName, p.uuid = later.gpuName, later.uuid
+}
+
+// contains reports whether values holds value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
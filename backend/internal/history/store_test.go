@@ -0,0 +1,357 @@
+package history
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// testStart is a Monday midnight, so segments of every span start there.
+var testStart = time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+// testClock is a manually advanced clock.
+type testClock struct {
+	t time.Time
+}
+
+func (c *testClock) now() time.Time { return c.t }
+
+func openStore(t *testing.T, dir string, clock *testClock, retention Retention) *Store {
+	t.Helper()
+	s, err := Open(dir, WithClock(clock.now), WithRetention(retention))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// gpu returns the metrics of a GPU whose utilization and temperature are util.
+func gpu(cluster, node string, index int, util float64) models.GPUMetrics {
+	return models.GPUMetrics{
+		Cluster:     cluster,
+		NodeName:    node,
+		GPUIndex:    index,
+		GPUName:     "NVIDIA A100",
+		Utilization: util,
+		MemoryUsed:  60,
+		MemoryTotal: 80,
+		MemoryFree:  20,
+		Temperature: util,
+		PowerDraw:   300,
+	}
+}
+
+// recordMinutes records a snapshot of one GPU every minute from minute from to
+// minute to of the test, with the utilization equal to the minute.
+func recordMinutes(t *testing.T, s *Store, clock *testClock, from, to int) {
+	t.Helper()
+	for i := from; i <= to; i++ {
+		clock.t = testStart.Add(time.Duration(i) * time.Minute)
+		if err := s.Record([]models.GPUMetrics{gpu("", "node-a", 0, float64(i))}); err != nil {
+			t.Fatalf("Record at minute %d: %v", i, err)
+		}
+	}
+}
+
+// values returns the timestamps, as minutes of the test, and utilization of a series.
+func values(points []models.DataPoint) ([]int, []float64) {
+	var minutes []int
+	var vs []float64
+	for _, p := range points {
+		minutes = append(minutes, int(p.Timestamp.Sub(testStart)/time.Minute))
+		vs = append(vs, p.Value)
+	}
+	return minutes, vs
+}
+
+func TestRecordAndRange(t *testing.T) {
+	clock := &testClock{}
+	s := openStore(t, t.TempDir(), clock, DefaultRetention())
+
+	for i := 0; i < 3; i++ {
+		clock.t = testStart.Add(time.Duration(i) * time.Minute)
+		held := gpu("prod", "node-a", 1, float64(10*i))
+		held.Owner = &models.GPUOwner{Namespace: "ml", Pod: "train-0", Source: models.OwnerSourceExporter}
+		err := s.Record([]models.GPUMetrics{gpu("prod", "node-b", 0, 50), held, gpu("staging", "node-a", 0, 1)})
+		if err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := s.Record([]models.GPUMetrics{gpu("prod", "node-a", 1, 0)}); err == nil {
+		t.Error("expected an error recording a snapshot that is not after the last one")
+	}
+
+	ctx := context.Background()
+	series, warnings, err := s.Range(ctx, testStart, testStart.Add(2*time.Minute), time.Minute, Average)
+	if err != nil || len(warnings) != 0 {
+		t.Fatalf("Range: %v, %+v", err, warnings)
+	}
+	if len(series) != 3 || series[0].NodeName != "node-a" || series[0].GPUIndex != 1 || series[1].NodeName != "node-b" || series[2].Cluster != "staging" {
+		t.Fatalf("unexpected series %+v", series)
+	}
+	minutes, utils := values(series[0].Utilization)
+	if len(minutes) != 3 || minutes[2] != 2 || utils[0] != 0 || utils[2] != 20 {
+		t.Errorf("unexpected utilization at %v: %v", minutes, utils)
+	}
+	if series[0].GPUName != "NVIDIA A100" || series[0].MemoryTotal[0].Value != 80 || series[0].PowerDraw[0].Value != 300 || !series[0].Utilization[0].Timestamp.Equal(testStart) {
+		t.Errorf("unexpected series values %+v", series[0])
+	}
+
+	scoped := metrics.WithScope(ctx, metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{"ml"}}}})
+	if series, _, _ := s.Range(scoped, testStart, testStart.Add(2*time.Minute), time.Minute, Average); len(series) != 1 || series[0].GPUIndex != 1 {
+		t.Errorf("expected only the GPU of namespace ml, got %+v", series)
+	}
+	selected := metrics.WithClusters(ctx, []string{"staging"})
+	if series, _, _ := s.Range(selected, testStart, testStart.Add(2*time.Minute), time.Minute, Average); len(series) != 1 || series[0].Cluster != "staging" {
+		t.Errorf("expected only the staging GPU, got %+v", series)
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.Record([]models.GPUMetrics{gpu("", "node-a", 0, 1)}); err == nil {
+		t.Error("expected an error recording into a closed store")
+	}
+}
+
+func TestRollups(t *testing.T) {
+	clock := &testClock{}
+	dir := t.TempDir()
+	s := openStore(t, dir, clock, Retention{Raw: time.Hour, FiveMinute: 24 * time.Hour, Hourly: 30 * 24 * time.Hour})
+
+	recordMinutes(t, s, clock, 0, 119)
+	clock.t = testStart.Add(2 * time.Hour)
+	for i := 0; i < 2; i++ {
+		if err := s.Compact(); err != nil {
+			t.Fatalf("Compact: %v", err)
+		}
+	}
+	// Compacting again must not write the buckets twice
+	if records, _ := s.read(s.tiers[fiveMinute], time.Time{}, clock.t); len(records) != 24 {
+		t.Errorf("expected 24 buckets of 5 minutes, got %d", len(records))
+	}
+
+	// Starting before the raw retention reads the 5 minute rollups; a bucket is placed at its end
+	end := testStart.Add(2 * time.Hour)
+	series, _, err := s.Range(context.Background(), testStart, end, 15*time.Minute, Average)
+	if err != nil || len(series) != 1 {
+		t.Fatalf("Range: %+v, %v", series, err)
+	}
+	minutes, avgs := values(series[0].Utilization)
+	if len(minutes) != 8 || minutes[0] != 15 || avgs[0] != 7 || avgs[7] != 112 {
+		t.Errorf("unexpected averages at %v: %v", minutes, avgs)
+	}
+	series, _, _ = s.Range(context.Background(), testStart, end, 15*time.Minute, Max)
+	if _, maxes := values(series[0].Utilization); len(maxes) != 8 || maxes[0] != 14 || maxes[7] != 119 || series[0].MemoryTotal[0].Value != 80 {
+		t.Errorf("unexpected maximums %v", maxes)
+	}
+
+	// A day later only the hourly rollups reach back to the start
+	clock.t = end.Add(24 * time.Hour)
+	series, warnings, _ := s.Range(context.Background(), testStart, end, time.Hour, Average)
+	minutes, avgs = values(series[0].Utilization)
+	if len(minutes) != 2 || minutes[0] != 60 || avgs[0] != 29.5 || avgs[1] != 89.5 || len(warnings) != 0 {
+		t.Errorf("unexpected hourly averages at %v: %v, %+v", minutes, avgs, warnings)
+	}
+	series, _, _ = s.Range(context.Background(), testStart, end, time.Hour, Max)
+	if _, maxes := values(series[0].Utilization); len(maxes) != 2 || maxes[0] != 59 || maxes[1] != 119 {
+		t.Errorf("unexpected hourly maximums %v", maxes)
+	}
+}
+
+func TestRollupOwnerChange(t *testing.T) {
+	clock := &testClock{}
+	s := openStore(t, t.TempDir(), clock, Retention{Raw: time.Hour, FiveMinute: 24 * time.Hour, Hourly: 30 * 24 * time.Hour})
+
+	// The GPU moves from namespace a to namespace b in the middle of the first bucket
+	for i := 0; i < 5; i++ {
+		clock.t = testStart.Add(time.Duration(i) * time.Minute)
+		held := gpu("", "node-a", 0, float64(i))
+		held.Owner = &models.GPUOwner{Namespace: "a", Pod: "train-0"}
+		if i >= 3 {
+			held.Owner = &models.GPUOwner{Namespace: "b", Pod: "infer-0"}
+		}
+		if err := s.Record([]models.GPUMetrics{held}); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	clock.t = testStart.Add(2 * time.Hour)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
+	records, _ := s.read(s.tiers[fiveMinute], time.Time{}, clock.t)
+	if len(records) != 1 || len(records[0].points) != 2 {
+		t.Fatalf("expected one bucket with a point per owner, got %+v", records)
+	}
+
+	// Each namespace sees only the snapshots taken while it held the GPU
+	for _, tt := range []struct {
+		namespace string
+		want      float64
+	}{{"a", 1}, {"b", 3.5}} {
+		scoped := metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{tt.namespace}}}})
+		series, _, err := s.Range(scoped, testStart, testStart.Add(5*time.Minute), 5*time.Minute, Average)
+		if err != nil || len(series) != 1 {
+			t.Fatalf("Range for %s: %+v, %v", tt.namespace, series, err)
+		}
+		if minutes, avgs := values(series[0].Utilization); len(avgs) != 1 || minutes[0] != 5 || avgs[0] != tt.want {
+			t.Errorf("expected namespace %s to average %v, got %v at %v", tt.namespace, tt.want, avgs, minutes)
+		}
+	}
+
+	// Unscoped callers still see the whole bucket
+	series, _, _ := s.Range(context.Background(), testStart, testStart.Add(5*time.Minute), 5*time.Minute, Average)
+	if _, avgs := values(series[0].Utilization); len(avgs) != 1 || avgs[0] != 2 {
+		t.Errorf("expected the bucket to average 2, got %v", avgs)
+	}
+}
+
+func TestRetentionBoundaries(t *testing.T) {
+	clock := &testClock{}
+	dir := t.TempDir()
+	s := openStore(t, dir, clock, Retention{Raw: time.Hour, FiveMinute: 6 * time.Hour, Hourly: 48 * time.Hour})
+	ctx := context.Background()
+
+	recordMinutes(t, s, clock, 0, 240)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	now := clock.t
+
+	// Raw snapshots exactly at the retention boundary are kept, older ones are not
+	series, _, _ := s.Range(ctx, now.Add(-time.Hour), now, 10*time.Minute, Max)
+	minutes, utils := values(series[0].Utilization)
+	if len(minutes) != 7 || minutes[0] != 180 || utils[0] != 180 || utils[1] != 190 {
+		t.Errorf("expected raw snapshots from minute 180, got %v at %v", utils, minutes)
+	}
+
+	// A millisecond earlier only the 5 minute rollups reach back; the bucket ending at minute 240 is past the last step
+	series, _, _ = s.Range(ctx, now.Add(-time.Hour-time.Millisecond), now, 10*time.Minute, Max)
+	minutes, utils = values(series[0].Utilization)
+	if len(minutes) != 7 || minutes[0] != 179 || utils[0] != 174 || minutes[6] != 239 || utils[6] != 234 {
+		t.Errorf("expected 5 minute rollups, got %v at %v", utils, minutes)
+	}
+
+	// Whole raw segments older than the retention are deleted: hours 3 and 4 remain
+	if segments, _ := listSegments(s.tiers[raw].dir); len(segments) != 2 || !segments[0].start.Equal(testStart.Add(3*time.Hour)) {
+		t.Errorf("unexpected raw segments %+v", segments)
+	}
+
+	// Six hours later the 5 minute rollups start exactly at the boundary
+	clock.t = now.Add(6 * time.Hour)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if segments, _ := listSegments(s.tiers[raw].dir); len(segments) != 0 {
+		t.Errorf("expected every raw segment to be deleted, got %+v", segments)
+	}
+	series, _, _ = s.Range(ctx, now, clock.t, 5*time.Minute, Average)
+	minutes, utils = values(series[0].Utilization)
+	if len(minutes) != 1 || minutes[0] != 245 || utils[0] != 240 {
+		t.Errorf("expected the last 5 minute bucket only, got %v at %v", utils, minutes)
+	}
+	series, _, _ = s.Range(ctx, now.Add(-time.Millisecond), clock.t, time.Hour, Max)
+	minutes, utils = values(series[0].Utilization)
+	if len(minutes) != 3 || minutes[0] != 239 || utils[0] != 179 || utils[2] != 240 {
+		t.Errorf("expected the hourly rollups, got %v at %v", utils, minutes)
+	}
+
+	// Only the hourly bucket at the retention boundary is left, and the rest is reported missing
+	clock.t = now.Add(48 * time.Hour)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	series, warnings, _ := s.Range(ctx, testStart, clock.t, time.Hour, Average)
+	if len(series) != 1 || len(warnings) != 1 || warnings[0].Metric != "history" {
+		t.Fatalf("expected the last hour and a warning, got %+v, %+v", series, warnings)
+	}
+	if minutes, utils = values(series[0].Utilization); len(minutes) != 1 || minutes[0] != 300 || utils[0] != 240 {
+		t.Errorf("expected the bucket of hour 4 only, got %v at %v", utils, minutes)
+	}
+	if segments, _ := listSegments(s.tiers[hourly].dir); len(segments) != 1 {
+		t.Errorf("expected the hourly segment to outlive its oldest records, got %+v", segments)
+	}
+}
+
+func TestCrashRecovery(t *testing.T) {
+	clock := &testClock{}
+	dir := t.TempDir()
+	retention := Retention{Raw: time.Hour, FiveMinute: 24 * time.Hour, Hourly: 30 * 24 * time.Hour}
+	s := openStore(t, dir, clock, retention)
+	recordMinutes(t, s, clock, 0, 9)
+	clock.t = testStart.Add(10 * time.Minute)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	s.Close()
+
+	// A crash midway through appending leaves half a frame behind
+	segment := segmentPath(filepath.Join(dir, "raw"), testStart)
+	before, _ := os.Stat(segment)
+	torn := appendFrame(nil, record{at: testStart.Add(10 * time.Minute), points: []point{{nodeName: "node-a", count: 1}}})
+	appendFile(t, segment, torn[:len(torn)/2])
+
+	// The rollup of the second bucket is lost, as if the crash came before it was synced
+	rollup := segmentPath(filepath.Join(dir, "5m"), testStart)
+	info, _ := os.Stat(rollup)
+	data, _ := os.ReadFile(rollup)
+	os.WriteFile(rollup, data[:info.Size()/2+1], 0o644)
+
+	s = openStore(t, dir, clock, retention)
+	if after, _ := os.Stat(segment); after.Size() != before.Size() {
+		t.Errorf("expected the torn frame to be truncated to %d bytes, got %d", before.Size(), after.Size())
+	}
+	recordMinutes(t, s, clock, 10, 15)
+	if err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+
+	series, _, _ := s.Range(context.Background(), testStart, testStart.Add(15*time.Minute), time.Minute, Average)
+	if minutes, _ := values(series[0].Utilization); len(minutes) != 16 {
+		t.Errorf("expected 16 snapshots after recovery, got %v", minutes)
+	}
+	records, _ := s.read(s.tiers[fiveMinute], time.Time{}, clock.t)
+	if len(records) != 3 || records[1].points[0].count != 5 || records[1].points[0].avg[utilization] != 7 {
+		t.Errorf("expected the lost bucket to be rolled up again, got %+v", records)
+	}
+
+	// A corrupt checksum ends the segment too
+	s.Close()
+	data, _ = os.ReadFile(segment)
+	data[len(data)-1] ^= 0xff
+	os.WriteFile(segment, data, 0o644)
+	s = openStore(t, dir, clock, retention)
+	series, _, _ = s.Range(context.Background(), testStart, testStart.Add(15*time.Minute), time.Minute, Average)
+	if minutes, _ := values(series[0].Utilization); len(minutes) != 15 {
+		t.Errorf("expected the corrupt snapshot to be dropped, got %v", minutes)
+	}
+	clock.t = clock.t.Add(time.Minute)
+	if err := s.Record([]models.GPUMetrics{gpu("", "node-a", 0, 16)}); err != nil {
+		t.Errorf("expected appends to continue after recovery, got %v", err)
+	}
+}
+
+func appendFile(t *testing.T, path string, data []byte) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOpenRejectsRetention(t *testing.T) {
+	if _, err := Open(t.TempDir(), WithRetention(Retention{Raw: time.Hour})); err == nil {
+		t.Error("expected an error for a zero retention")
+	}
+}
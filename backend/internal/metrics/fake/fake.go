@@ -0,0 +1,466 @@
+// Package fake provides an in-memory metrics.Source for tests.
+package fake
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+var _ metrics.Source = (*Source)(nil)
+
+// Source is a configurable in-memory metrics.Source. Nodes, node and device
+// detail, utilization and cluster summaries are all derived from GPUs, so they
+// stay consistent with each other. Like the Prometheus-backed source it honours
+// the cluster selection and caller scope on the context, and reports clusters
+// marked Down as warnings, failing only when every selected cluster is down.
+//
+// Configure the fields before use, or through Update while requests are in flight.
+type Source struct {
+	// Clusters are the configured cluster names; empty means a single unnamed cluster.
+	Clusters []string
+	// GPUs are the current GPU metrics. Their Cluster must name one of Clusters.
+	GPUs []models.GPUMetrics
+	// Series, Pods and Idle are returned, filtered, by the corresponding methods.
+	Series []models.GPUTimeSeries
+	Pods   []models.GPUPodAllocation
+	Idle   []models.IdleGPU
+	// Warnings are attached to every successful result.
+	Warnings []models.Warning
+	// Errors makes the named method fail with the error, e.g. Errors["GetGPUNodes"].
+	Errors map[string]error
+	// Down marks clusters as unreachable with the given error.
+	Down map[string]error
+	// Cache and Backends are reported by CacheStats and BackendStatuses; a nil Cache disables caching.
+	Cache    *metrics.CacheStats
+	Backends []metrics.BackendStatus
+
+	mu    sync.Mutex
+	calls map[string]int
+}
+
+// Update applies change while holding the lock, for reconfiguring a Source in use.
+func (s *Source) Update(change func(s *Source)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	change(s)
+}
+
+// Calls returns how many times the named method was called.
+func (s *Source) Calls(method string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.calls[method]
+}
+
+// begin records a call to method and returns the GPUs visible to ctx, with a
+// warning for each selected cluster that is down. Callers must hold s.mu.
+func (s *Source) begin(ctx context.Context, method string) ([]models.GPUMetrics, []models.Warning, error) {
+	if s.calls == nil {
+		s.calls = make(map[string]int)
+	}
+	s.calls[method]++
+
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if err := s.Errors[method]; err != nil {
+		return nil, nil, err
+	}
+
+	clusters, warnings, err := s.reachable(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	scope, scoped := metrics.ScopeFrom(ctx)
+	var gpus []models.GPUMetrics
+	for _, m := range s.GPUs {
+		if clusters[m.Cluster] && (!scoped || scope.Allows(m.NodeName, m.Owner)) {
+			gpus = append(gpus, m)
+		}
+	}
+	return gpus, append(warnings, s.Warnings...), nil
+}
+
+// selected returns the cluster names chosen by ctx, in configured order.
+func (s *Source) selected(ctx context.Context) []string {
+	names := s.Names()
+	if len(names) == 0 {
+		names = []string{""}
+	}
+
+	chosen := metrics.ClustersFrom(ctx)
+	if len(chosen) == 0 {
+		return names
+	}
+	var clusters []string
+	for _, name := range names {
+		for _, c := range chosen {
+			if c == name {
+				clusters = append(clusters, name)
+				break
+			}
+		}
+	}
+	return clusters
+}
+
+// reachable returns the selected clusters that are up, warning about the others.
+func (s *Source) reachable(ctx context.Context) (map[string]bool, []models.Warning, error) {
+	clusters := make(map[string]bool)
+	var warnings []models.Warning
+	var errs []error
+	for _, name := range s.selected(ctx) {
+		if err := s.Down[name]; err != nil {
+			warnings = append(warnings, models.Warning{Cluster: name, Metric: "cluster", Reason: models.FailureReason(err)})
+			errs = append(errs, fmt.Errorf("cluster %s: %w", name, err))
+			continue
+		}
+		clusters[name] = true
+	}
+	if len(clusters) == 0 && len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+	return clusters, warnings, nil
+}
+
+// Names returns the configured cluster names.
+func (s *Source) Names() []string {
+	return append([]string(nil), s.Clusters...)
+}
+
+// Validate checks that every name refers to a configured cluster.
+func (s *Source) Validate(names []string) error {
+	for _, name := range names {
+		found := false
+		for _, c := range s.Clusters {
+			found = found || c == name
+		}
+		if !found {
+			return fmt.Errorf("%w %q", metrics.ErrUnknownCluster, name)
+		}
+	}
+	return nil
+}
+
+// GetGPUMetrics returns the visible GPUs.
+func (s *Source) GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.begin(ctx, "GetGPUMetrics")
+}
+
+// SelectGPUMetrics returns the visible GPUs matching sel.
+func (s *Source) SelectGPUMetrics(ctx context.Context, sel metrics.GPUSelector) ([]models.GPUMetrics, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpus, warnings, err := s.begin(ctx, "SelectGPUMetrics")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	matched := []models.GPUMetrics{}
+	for _, m := range gpus {
+		if sel.Matches(m.NodeName, m.GPUName, m.Owner) {
+			matched = append(matched, m)
+		}
+	}
+	return matched, warnings, nil
+}
+
+// GetGPUMetricsRange returns the series of visible GPUs, trimmed to the points between start and end.
+func (s *Source) GetGPUMetricsRange(ctx context.Context, start, end time.Time, step time.Duration) ([]models.GPUTimeSeries, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpus, warnings, err := s.begin(ctx, "GetGPUMetricsRange")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	visible := make(map[string]bool, len(gpus))
+	for _, m := range gpus {
+		visible[gpuKey(m.Cluster, m.NodeName, m.GPUIndex)] = true
+	}
+
+	trim := func(points []models.DataPoint) []models.DataPoint {
+		var kept []models.DataPoint
+		for _, p := range points {
+			if !p.Timestamp.Before(start) && !p.Timestamp.After(end) {
+				kept = append(kept, p)
+			}
+		}
+		return kept
+	}
+
+	series := []models.GPUTimeSeries{}
+	for _, ts := range s.Series {
+		if !visible[gpuKey(ts.Cluster, ts.NodeName, ts.GPUIndex)] {
+			continue
+		}
+		ts.Utilization = trim(ts.Utilization)
+		ts.MemoryUsed = trim(ts.MemoryUsed)
+		ts.MemoryTotal = trim(ts.MemoryTotal)
+		ts.MemoryFree = trim(ts.MemoryFree)
+		ts.Temperature = trim(ts.Temperature)
+		ts.PowerDraw = trim(ts.PowerDraw)
+		series = append(series, ts)
+	}
+	return series, warnings, nil
+}
+
+// GetGPUUtilization returns the utilization of visible GPUs in reachable clusters.
+func (s *Source) GetGPUUtilization(ctx context.Context) ([]metrics.UtilizationSample, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpus, warnings, err := s.begin(ctx, "GetGPUUtilization")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var samples []metrics.UtilizationSample
+	for _, m := range gpus {
+		samples = append(samples, metrics.UtilizationSample{
+			Cluster:     m.Cluster,
+			NodeName:    m.NodeName,
+			GPUIndex:    m.GPUIndex,
+			Utilization: m.Utilization,
+			Timestamp:   m.Timestamp,
+		})
+	}
+	return samples, warnings, nil
+}
+
+// GetGPUNodes groups the visible GPUs into nodes.
+func (s *Source) GetGPUNodes(ctx context.Context) ([]models.GPUNode, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpus, warnings, err := s.begin(ctx, "GetGPUNodes")
+	if err != nil {
+		return nil, nil, err
+	}
+	return models.GroupNodes(gpus), warnings, nil
+}
+
+// GetGPUNode returns the detail of a node hosting visible GPUs in exactly one selected cluster.
+func (s *Source) GetGPUNode(ctx context.Context, nodeName string) (*models.GPUNodeDetail, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpus, warnings, err := s.begin(ctx, "GetGPUNode")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var onNode []models.GPUMetrics
+	for _, m := range gpus {
+		if m.NodeName == nodeName {
+			onNode = append(onNode, m)
+		}
+	}
+	if err := unique(onNode); err != nil {
+		return nil, nil, err
+	}
+
+	detail := &models.GPUNodeDetail{GPUNode: models.GroupNodes(onNode)[0], GPUs: onNode}
+	for _, m := range onNode {
+		detail.AverageUtilization += m.Utilization
+		detail.MemoryUsed += m.MemoryUsed
+		detail.MemoryTotal += m.MemoryTotal
+	}
+	detail.AverageUtilization /= float64(len(onNode))
+	return detail, warnings, nil
+}
+
+// GetGPUDevice returns a visible GPU whose node exists in exactly one selected cluster.
+func (s *Source) GetGPUDevice(ctx context.Context, nodeName string, gpuIndex int) (*models.GPUDetail, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	gpus, warnings, err := s.begin(ctx, "GetGPUDevice")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var matched []models.GPUMetrics
+	for _, m := range gpus {
+		if m.NodeName == nodeName && m.GPUIndex == gpuIndex {
+			matched = append(matched, m)
+		}
+	}
+	if err := unique(matched); err != nil {
+		return nil, nil, err
+	}
+	return &models.GPUDetail{GPUMetrics: matched[0]}, warnings, nil
+}
+
+// GetGPUPods returns the pod allocations in reachable clusters visible to the caller.
+func (s *Source) GetGPUPods(ctx context.Context) ([]models.GPUPodAllocation, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, warnings, err := s.begin(ctx, "GetGPUPods")
+	if err != nil {
+		return nil, nil, err
+	}
+	clusters, _, _ := s.reachable(ctx)
+	scope, scoped := metrics.ScopeFrom(ctx)
+
+	pods := []models.GPUPodAllocation{}
+	for _, p := range s.Pods {
+		owner := &models.GPUOwner{Namespace: p.Namespace, Pod: p.Pod, Container: p.Container}
+		if clusters[p.Cluster] && (!scoped || scope.Allows(p.NodeName, owner)) {
+			pods = append(pods, p)
+		}
+	}
+	return pods, warnings, nil
+}
+
+// GetIdleGPUs reports the GPUs of Idle within criteria in reachable clusters
+// visible to the caller, most wasteful first.
+func (s *Source) GetIdleGPUs(ctx context.Context, criteria metrics.IdleCriteria) (*models.IdleGPUReport, []models.Warning, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, warnings, err := s.begin(ctx, "GetIdleGPUs")
+	if err != nil {
+		return nil, nil, err
+	}
+	clusters, _, _ := s.reachable(ctx)
+	scope, scoped := metrics.ScopeFrom(ctx)
+
+	report := &models.IdleGPUReport{
+		Lookback:   criteria.Lookback.String(),
+		MaxAverage: criteria.MaxAverage,
+		MaxP95:     criteria.MaxP95,
+		GPUs:       []models.IdleGPU{},
+	}
+	for _, gpu := range s.Idle {
+		if !clusters[gpu.Cluster] || (scoped && !scope.Allows(gpu.NodeName, gpu.Owner)) {
+			continue
+		}
+		if gpu.AverageUtilization > criteria.MaxAverage || gpu.P95Utilization > criteria.MaxP95 || (criteria.AllocatedOnly && gpu.Owner == nil) {
+			continue
+		}
+		report.GPUs = append(report.GPUs, gpu)
+		report.TotalWastedGPUHours += gpu.WastedGPUHours
+	}
+	sort.SliceStable(report.GPUs, func(i, j int) bool {
+		return report.GPUs[i].WastedGPUHours > report.GPUs[j].WastedGPUHours
+	})
+	return report, warnings, nil
+}
+
+// Summaries reports the totals of every selected cluster, marking clusters that are down as unhealthy.
+func (s *Source) Summaries(ctx context.Context) ([]models.ClusterSummary, []models.Warning) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	var summaries []models.ClusterSummary
+	for _, cluster := range s.selected(ctx) {
+		summary := models.ClusterSummary{Name: cluster, CheckedAt: now}
+		if err := s.Down[cluster]; err != nil {
+			summary.Error = models.FailureReason(err)
+			summaries = append(summaries, summary)
+			continue
+		}
+
+		summary.Healthy = true
+		nodes := make(map[string]bool)
+		for _, m := range s.GPUs {
+			if m.Cluster != cluster {
+				continue
+			}
+			nodes[m.NodeName] = true
+			summary.GPUCount++
+			summary.AverageUtilization += m.Utilization
+			summary.MemoryUsed += m.MemoryUsed
+			summary.MemoryTotal += m.MemoryTotal
+			summary.PowerDraw += m.PowerDraw
+		}
+		summary.NodeCount = len(nodes)
+		if summary.GPUCount > 0 {
+			summary.AverageUtilization /= float64(summary.GPUCount)
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, append([]models.Warning(nil), s.Warnings...)
+}
+
+// Ping returns the error of every selected cluster marked down.
+func (s *Source) Ping(ctx context.Context) map[string]error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	failures := make(map[string]error)
+	for _, cluster := range s.selected(ctx) {
+		if err := s.Down[cluster]; err != nil {
+			failures[cluster] = err
+		}
+	}
+	return failures
+}
+
+// CacheStats returns Cache, or false if it is nil.
+func (s *Source) CacheStats() (metrics.CacheStats, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Cache == nil {
+		return metrics.CacheStats{}, false
+	}
+	return *s.Cache, true
+}
+
+// BackendStatuses returns Backends.
+func (s *Source) BackendStatuses() []metrics.BackendStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]metrics.BackendStatus(nil), s.Backends...)
+}
+
+// unique returns metrics.ErrNotFound for no GPUs and metrics.ErrAmbiguous
+// when they span several clusters.
+func unique(gpus []models.GPUMetrics) error {
+	if len(gpus) == 0 {
+		return metrics.ErrNotFound
+	}
+
+	var clusters []string
+	for _, m := range gpus {
+		if !contains(clusters, m.Cluster) {
+			clusters = append(clusters, m.Cluster)
+		}
+	}
+	if len(clusters) > 1 {
+		return fmt.Errorf("%w: %s", metrics.ErrAmbiguous, strings.Join(clusters, ", "))
+	}
+	return nil
+}
+
+// gpuKey identifies a GPU across clusters.
+func gpuKey(cluster, nodeName string, gpuIndex int) string {
+	return fmt.Sprintf("%s/%s:%d", cluster, nodeName, gpuIndex)
+}
+
+// contains reports whether values holds value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
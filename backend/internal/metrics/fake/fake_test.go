@@ -0,0 +1,89 @@
+package fake
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+func testSource() *Source {
+	return &Source{
+		Clusters: []string{"east", "west"},
+		GPUs: []models.GPUMetrics{
+			{Cluster: "east", NodeName: "node1", GPUIndex: 0, GPUName: "A100", Owner: &models.GPUOwner{Namespace: "ml"}},
+			{Cluster: "east", NodeName: "node1", GPUIndex: 1, GPUName: "A100"},
+			{Cluster: "west", NodeName: "node1", GPUIndex: 0, GPUName: "L4"},
+		},
+	}
+}
+
+func TestClusterSelection(t *testing.T) {
+	src := testSource()
+	src.Down = map[string]error{"west": errors.New("connection refused")}
+
+	gpus, warnings, err := src.GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gpus) != 2 || len(warnings) != 1 || warnings[0].Cluster != "west" {
+		t.Errorf("expected east GPUs with a west warning, got %+v %+v", gpus, warnings)
+	}
+
+	if _, _, err := src.GetGPUMetrics(metrics.WithClusters(context.Background(), []string{"west"})); err == nil {
+		t.Error("expected error when every selected cluster is down")
+	}
+	if err := src.Validate([]string{"north"}); !errors.Is(err, metrics.ErrUnknownCluster) {
+		t.Errorf("expected ErrUnknownCluster, got %v", err)
+	}
+	if calls := src.Calls("GetGPUMetrics"); calls != 2 {
+		t.Errorf("expected 2 calls, got %d", calls)
+	}
+}
+
+func TestScope(t *testing.T) {
+	src := testSource()
+	ctx := metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{"ml"}}}})
+
+	gpus, _, err := src.GetGPUMetrics(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gpus) != 1 || gpus[0].GPUIndex != 0 || gpus[0].Cluster != "east" {
+		t.Errorf("expected only the ml GPU, got %+v", gpus)
+	}
+}
+
+func TestLookups(t *testing.T) {
+	src := testSource()
+	ctx := context.Background()
+
+	if _, _, err := src.GetGPUNode(ctx, "node1"); !errors.Is(err, metrics.ErrAmbiguous) {
+		t.Errorf("expected ErrAmbiguous, got %v", err)
+	}
+	if _, _, err := src.GetGPUDevice(ctx, "node2", 0); !errors.Is(err, metrics.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	node, _, err := src.GetGPUNode(metrics.WithClusters(ctx, []string{"east"}), "node1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if node.GPUCount != 2 || len(node.GPUs) != 2 || len(node.GPUModels) != 1 {
+		t.Errorf("unexpected node %+v", node)
+	}
+
+	nodes, _, err := src.GetGPUNodes(ctx)
+	if err != nil || len(nodes) != 2 {
+		t.Errorf("expected a node per cluster, got %+v (%v)", nodes, err)
+	}
+
+	src.Update(func(s *Source) {
+		s.Errors = map[string]error{"GetGPUNodes": errors.New("boom")}
+	})
+	if _, _, err := src.GetGPUNodes(ctx); err == nil {
+		t.Error("expected configured error")
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// Scope restricts the GPUs a caller may see to the GPUs matching any of its
+// clauses. A scope without clauses allows nothing.
+type Scope struct {
+	Clauses []ScopeClause
+}
+
+// ScopeClause matches GPUs on Nodes that are allocated to pods in Namespaces.
+// Empty fields do not restrict; when both are set a GPU must satisfy both.
+type ScopeClause struct {
+	Namespaces []string
+	Nodes      []string
+}
+
+// Allows reports whether a GPU on nodeName held by owner is visible within the scope.
+// GPUs without a known owner are hidden from namespace-scoped clauses.
+func (s Scope) Allows(nodeName string, owner *models.GPUOwner) bool {
+	for _, c := range s.Clauses {
+		if c.allows(nodeName, owner) {
+			return true
+		}
+	}
+	return false
+}
+
+// allows reports whether a GPU on nodeName held by owner matches the clause.
+func (c ScopeClause) allows(nodeName string, owner *models.GPUOwner) bool {
+	if len(c.Nodes) > 0 && !contains(c.Nodes, nodeName) {
+		return false
+	}
+	if len(c.Namespaces) > 0 && (owner == nil || !contains(c.Namespaces, owner.Namespace)) {
+		return false
+	}
+	return true
+}
+
+// contains reports whether values holds value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeKey stores the caller's Scope in a context.
+type scopeKey struct{}
+
+// WithScope returns a context whose queries only see GPUs within s.
+func WithScope(ctx context.Context, s Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// ScopeFrom returns the scope attached to ctx, if any.
+func ScopeFrom(ctx context.Context) (Scope, bool) {
+	s, ok := ctx.Value(scopeKey{}).(Scope)
+	return s, ok
+}
@@ -0,0 +1,43 @@
+// Package metrics defines the source of GPU metrics the API handlers consume.
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// Source provides GPU metrics for one or more clusters. Queries honour the
+// clusters selected with WithClusters and the caller's Scope attached with
+// WithScope. Lookups of a single node or GPU return ErrNotFound when nothing
+// matches and ErrAmbiguous when several clusters match.
+//
+// *federation.Federation serves it from one *prometheus.Client per cluster,
+// which shares these request and result types, and *scrape.Scraper from
+// exporter endpoints directly; package fake provides an in-memory one for tests.
+type Source interface {
+	// Names returns the configured cluster names in order.
+	Names() []string
+	// Validate returns ErrUnknownCluster if any name is not configured.
+	Validate(names []string) error
+
+	GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error)
+	SelectGPUMetrics(ctx context.Context, sel GPUSelector) ([]models.GPUMetrics, []models.Warning, error)
+	GetGPUMetricsRange(ctx context.Context, start, end time.Time, step time.Duration) ([]models.GPUTimeSeries, []models.Warning, error)
+	GetGPUUtilization(ctx context.Context) ([]UtilizationSample, []models.Warning, error)
+	GetGPUNodes(ctx context.Context) ([]models.GPUNode, []models.Warning, error)
+	GetGPUNode(ctx context.Context, nodeName string) (*models.GPUNodeDetail, []models.Warning, error)
+	GetGPUDevice(ctx context.Context, nodeName string, gpuIndex int) (*models.GPUDetail, []models.Warning, error)
+	GetGPUPods(ctx context.Context) ([]models.GPUPodAllocation, []models.Warning, error)
+	GetIdleGPUs(ctx context.Context, criteria IdleCriteria) (*models.IdleGPUReport, []models.Warning, error)
+
+	// Summaries reports the health and GPU totals of every selected cluster.
+	Summaries(ctx context.Context) ([]models.ClusterSummary, []models.Warning)
+	// Ping returns the connection error of each unreachable cluster by name.
+	Ping(ctx context.Context) map[string]error
+	// CacheStats returns the query cache counters, or false if caching is disabled.
+	CacheStats() (CacheStats, bool)
+	// BackendStatuses returns the state of every backend guarded by a circuit breaker.
+	BackendStatuses() []BackendStatus
+}
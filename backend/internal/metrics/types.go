@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"k8s-gpu-monitoring/internal/cache"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+var (
+	// ErrUnknownCluster is returned when a request names a cluster that is not configured.
+	ErrUnknownCluster = errors.New("unknown cluster")
+	// ErrNotFound is returned when the requested node or GPU reports no metrics.
+	ErrNotFound = errors.New("not found")
+	// ErrAmbiguous is returned when a node exists in more than one selected cluster.
+	ErrAmbiguous = errors.New("found in more than one cluster")
+)
+
+type clustersKey struct{}
+
+// WithClusters restricts queries made with the returned context to the named clusters.
+// An empty list selects every cluster.
+func WithClusters(ctx context.Context, names []string) context.Context {
+	if len(names) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, clustersKey{}, names)
+}
+
+// ClustersFrom returns the cluster names chosen by WithClusters, or nil for every cluster.
+func ClustersFrom(ctx context.Context) []string {
+	names, _ := ctx.Value(clustersKey{}).([]string)
+	return names
+}
+
+// GPUSelector narrows a metrics query to GPUs on Nodes with a model in GPUNames,
+// held by pods in Namespaces. Empty fields match everything.
+type GPUSelector struct {
+	Nodes      []string
+	GPUNames   []string
+	Namespaces []string
+}
+
+// Matches reports whether a GPU on nodeName of model gpuName held by owner is selected.
+func (s GPUSelector) Matches(nodeName, gpuName string, owner *models.GPUOwner) bool {
+	if len(s.Nodes) > 0 && !contains(s.Nodes, nodeName) {
+		return false
+	}
+	if len(s.GPUNames) > 0 && !contains(s.GPUNames, gpuName) {
+		return false
+	}
+	if len(s.Namespaces) > 0 && (owner == nil || !contains(s.Namespaces, owner.Namespace)) {
+		return false
+	}
+	return true
+}
+
+// IdleCriteria configures idle GPU detection. Utilization thresholds are percentages.
+type IdleCriteria struct {
+	Lookback      time.Duration
+	MaxAverage    float64
+	MaxP95        float64
+	AllocatedOnly bool
+}
+
+// UtilizationSample is the latest utilization reading of one GPU.
+type UtilizationSample struct {
+	Cluster     string
+	NodeName    string
+	GPUIndex    int
+	Utilization float64
+	Timestamp   time.Time
+}
+
+// CacheStats are the counters of a source's query cache.
+type CacheStats = cache.Stats
+
+// BackendStatus is the circuit breaker state of the backend serving one cluster.
+type BackendStatus struct {
+	Cluster             string     `json:"cluster,omitempty"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
@@ -1,9 +1,14 @@
 package middleware
 
 import (
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
+
+	"k8s-gpu-monitoring/internal/auth"
+	"k8s-gpu-monitoring/internal/models"
 )
 
 // Logger middleware for request logging with response time and status code.
@@ -30,21 +35,100 @@ func Logger(next http.Handler) http.Handler {
 
 // CORS allows all origins for development simplicity
 func CORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Set CORS headers
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Max-Age", "86400")
-
-		// Handle preflight requests
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	return CORSOrigins(nil)(next)
+}
 
-		next.ServeHTTP(w, r)
-	})
+// CORSOrigins allows cross-origin requests from the listed origins only. An empty
+// list allows every origin.
+func CORSOrigins(origins []string) func(http.Handler) http.Handler {
+	allowed := make(map[string]bool, len(origins))
+	for _, origin := range origins {
+		allowed[origin] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// Set CORS headers
+			if len(allowed) == 0 {
+				w.Header().Set("Access-Control-Allow-Origin", "*")
+			} else {
+				w.Header().Add("Vary", "Origin")
+				if origin := r.Header.Get("Origin"); allowed[origin] {
+					w.Header().Set("Access-Control-Allow-Origin", origin)
+				}
+			}
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Cache-Control, "+auth.APIKeyHeader)
+			w.Header().Set("Access-Control-Max-Age", "86400")
+
+			// Handle preflight requests
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Auth rejects requests without valid credentials and attaches the authenticated
+// principal to the request context. Requests for which public returns true pass
+// through unauthenticated.
+func Auth(authenticator *auth.Authenticator, public func(r *http.Request) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if public(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			principal, err := authenticator.Authenticate(r)
+			if err != nil {
+				challenge := `Bearer realm="gpu-monitoring"`
+				message := "Authentication required"
+				if !errors.Is(err, auth.ErrNoCredentials) {
+					log.Printf("Authentication failed for %s %s: %v", r.Method, r.URL.Path, err)
+					challenge += `, error="invalid_token"`
+					message = "Invalid credentials"
+				}
+
+				w.Header().Set("WWW-Authenticate", challenge)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(models.APIResponse{Success: false, Error: message})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+		})
+	}
+}
+
+// Authorize attaches the principal's grant under policy to the request context and
+// rejects authenticated principals the policy grants nothing. It must run after Auth;
+// requests without a principal, such as public paths, pass through unchanged.
+func Authorize(policy *auth.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok := auth.PrincipalFrom(r.Context())
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			grant, ok := policy.Grant(principal)
+			if !ok {
+				log.Printf("Authorization denied for %s (%s) on %s %s", principal.Subject, principal.Method, r.Method, r.URL.Path)
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(models.APIResponse{Success: false, Error: "Access denied"})
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(auth.WithGrant(r.Context(), grant)))
+		})
+	}
 }
 
 // Recovery middleware for panic recovery with error logging.
@@ -80,3 +164,8 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.statusCode = code
 	rw.ResponseWriter.WriteHeader(code)
 }
+
+// Unwrap exposes the underlying ResponseWriter to http.ResponseController.
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"k8s-gpu-monitoring/internal/auth"
+)
+
+func TestAuth(t *testing.T) {
+	keys, _ := auth.ParseAPIKeys("ci:" + auth.HashAPIKey("secret"))
+	public := func(r *http.Request) bool { return r.URL.Path == "/api/health" }
+
+	var got *auth.Principal
+	handler := Auth(auth.NewAuthenticator(nil, keys), public)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = auth.PrincipalFrom(r.Context())
+	}))
+
+	tests := []struct {
+		name          string
+		path          string
+		key           string
+		wantStatus    int
+		wantPrincipal bool
+		wantChallenge string
+	}{
+		{"public path", "/api/health", "", http.StatusOK, false, ""},
+		{"missing credentials", "/api/v1/gpu/metrics", "", http.StatusUnauthorized, false, `Bearer realm="gpu-monitoring"`},
+		{"invalid key", "/api/v1/gpu/metrics", "wrong", http.StatusUnauthorized, false, `error="invalid_token"`},
+		{"valid key", "/api/v1/gpu/metrics", "secret", http.StatusOK, true, ""},
+	}
+
+	for _, tt := range tests {
+		got = nil
+		r := httptest.NewRequest(http.MethodGet, tt.path, nil)
+		if tt.key != "" {
+			r.Header.Set(auth.APIKeyHeader, tt.key)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if w.Code != tt.wantStatus {
+			t.Errorf("%s: got status %d, want %d", tt.name, w.Code, tt.wantStatus)
+		}
+		if (got != nil) != tt.wantPrincipal {
+			t.Errorf("%s: got principal %+v", tt.name, got)
+		}
+		if !strings.Contains(w.Header().Get("WWW-Authenticate"), tt.wantChallenge) {
+			t.Errorf("%s: got challenge %q", tt.name, w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+func TestCORSOrigins(t *testing.T) {
+	handler := CORSOrigins([]string{"https://gpu.example"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for origin, want := range map[string]string{
+		"https://gpu.example":  "https://gpu.example",
+		"https://evil.example": "",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/api/v1/gpu/metrics", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+
+		if got := w.Header().Get("Access-Control-Allow-Origin"); got != want {
+			t.Errorf("%s: got allowed origin %q, want %q", origin, got, want)
+		}
+	}
+}
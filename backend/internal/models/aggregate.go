@@ -0,0 +1,61 @@
+package models
+
+import (
+	"math"
+	"sort"
+)
+
+// GroupNodes groups GPUs into nodes per cluster, ordered by cluster and node name.
+func GroupNodes(gpus []GPUMetrics) []GPUNode {
+	nodes := make([]GPUNode, 0)
+	index := make(map[string]int)
+	for _, m := range gpus {
+		key := m.Cluster + "/" + m.NodeName
+		i, ok := index[key]
+		if !ok {
+			i = len(nodes)
+			index[key] = i
+			nodes = append(nodes, GPUNode{Cluster: m.Cluster, NodeName: m.NodeName, GPUModels: []string{}})
+		}
+
+		node := &nodes[i]
+		node.GPUCount++
+		node.PowerDraw += m.PowerDraw
+		node.PowerLimit += m.PowerLimit
+		if m.GPUName != "" && !contains(node.GPUModels, m.GPUName) {
+			node.GPUModels = append(node.GPUModels, m.GPUName)
+		}
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if nodes[i].Cluster != nodes[j].Cluster {
+			return nodes[i].Cluster < nodes[j].Cluster
+		}
+		return nodes[i].NodeName < nodes[j].NodeName
+	})
+	return nodes
+}
+
+// UtilizationSummary returns the mean and nearest-rank 95th percentile of a
+// non-empty set of utilization values.
+func UtilizationSummary(values []float64) (float64, float64) {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+	return sum / float64(len(sorted)), sorted[rank]
+}
+
+// contains reports whether values holds value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGroupNodes(t *testing.T) {
+	nodes := GroupNodes([]GPUMetrics{
+		{Cluster: "west", NodeName: "node1", GPUName: "A100", PowerDraw: 100, PowerLimit: 400},
+		{Cluster: "east", NodeName: "node2", GPUName: "A100", PowerDraw: 50, PowerLimit: 300},
+		{Cluster: "east", NodeName: "node1", GPUName: "A100", PowerDraw: 200, PowerLimit: 400},
+		{Cluster: "east", NodeName: "node1", GPUName: "H100", PowerDraw: 300, PowerLimit: 700},
+	})
+
+	want := []GPUNode{
+		{Cluster: "east", NodeName: "node1", GPUCount: 2, GPUModels: []string{"A100", "H100"}, PowerDraw: 500, PowerLimit: 1100},
+		{Cluster: "east", NodeName: "node2", GPUCount: 1, GPUModels: []string{"A100"}, PowerDraw: 50, PowerLimit: 300},
+		{Cluster: "west", NodeName: "node1", GPUCount: 1, GPUModels: []string{"A100"}, PowerDraw: 100, PowerLimit: 400},
+	}
+	if !reflect.DeepEqual(nodes, want) {
+		t.Errorf("got %+v, want %+v", nodes, want)
+	}
+
+	if nodes := GroupNodes(nil); nodes == nil || len(nodes) != 0 {
+		t.Errorf("expected an empty non-nil slice, got %#v", nodes)
+	}
+}
+
+func TestUtilizationSummary(t *testing.T) {
+	values := make([]float64, 20)
+	for i := range values {
+		values[i] = float64(20 - i)
+	}
+
+	average, p95 := UtilizationSummary(values)
+	if average != 10.5 || p95 != 19 {
+		t.Errorf("got avg=%v p95=%v, want 10.5 and 19", average, p95)
+	}
+	if values[0] != 20 {
+		t.Error("expected the input to be left unsorted")
+	}
+}
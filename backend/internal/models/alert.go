@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Alert states reported in Alert.State
+const (
+	AlertPending  = "pending"
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+)
+
+// Alert is a rule condition tracked for a single GPU
+type Alert struct {
+	Rule       string     `json:"rule"`
+	Severity   string     `json:"severity,omitempty"`
+	State      string     `json:"state"`
+	Cluster    string     `json:"cluster,omitempty"`
+	NodeName   string     `json:"node_name"`
+	GPUIndex   int        `json:"gpu_index"`
+	GPUName    string     `json:"gpu_name"`
+	UUID       string     `json:"uuid,omitempty"`
+	Metric     string     `json:"metric"`
+	Operator   string     `json:"operator"`
+	Threshold  float64    `json:"threshold"`
+	Value      float64    `json:"value"`
+	Summary    string     `json:"summary,omitempty"`
+	Owner      *GPUOwner  `json:"owner,omitempty"`
+	ActiveAt   time.Time  `json:"active_at"`
+	FiredAt    *time.Time `json:"fired_at,omitempty"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+}
@@ -4,9 +4,11 @@ import "time"
 
 // GPUMetrics represents GPU metrics data structure
 type GPUMetrics struct {
+	Cluster           string    `json:"cluster,omitempty"`
 	NodeName          string    `json:"node_name"`
 	GPUIndex          int       `json:"gpu_index"`
 	GPUName           string    `json:"gpu_name"`
+	UUID              string    `json:"uuid,omitempty"`
 	Utilization       float64   `json:"utilization"`
 	MemoryUsed        float64   `json:"memory_used"`
 	MemoryTotal       float64   `json:"memory_total"`
@@ -15,22 +17,150 @@ type GPUMetrics struct {
 	Temperature       float64   `json:"temperature"`
 	PowerDraw         float64   `json:"power_draw"`
 	PowerLimit        float64   `json:"power_limit"`
+	PowerHeadroom     float64   `json:"power_headroom"`
+	EnergyConsumed    float64   `json:"energy_consumed"` // watt-hours over EnergyWindow
+	EnergyWindow      string    `json:"energy_window,omitempty"`
+	Owner             *GPUOwner `json:"owner,omitempty"`
 	Timestamp         time.Time `json:"timestamp"`
 }
 
+// GPUDetail represents a single GPU's current metrics with device information
+type GPUDetail struct {
+	GPUMetrics
+	DriverVersion        string  `json:"driver_version,omitempty"`
+	PCIBusID             string  `json:"pci_bus_id,omitempty"`
+	SMClock              float64 `json:"sm_clock_mhz"`
+	MemoryClock          float64 `json:"memory_clock_mhz"`
+	ECCCorrectedErrors   float64 `json:"ecc_corrected_errors"`
+	ECCUncorrectedErrors float64 `json:"ecc_uncorrected_errors"`
+}
+
+// GPUNodeDetail represents a single node with the current metrics of each of its GPUs
+type GPUNodeDetail struct {
+	GPUNode
+	AverageUtilization float64      `json:"average_utilization"`
+	MemoryUsed         float64      `json:"memory_used"`
+	MemoryTotal        float64      `json:"memory_total"`
+	GPUs               []GPUMetrics `json:"gpus"`
+}
+
+// GPUOwner identifies the Kubernetes workload a GPU is allocated to
+type GPUOwner struct {
+	Namespace string `json:"namespace"`
+	Pod       string `json:"pod"`
+	Container string `json:"container,omitempty"`
+	// Source is "exporter" when read from per-device labels, "resource_request" when inferred from kube-state-metrics
+	Source string `json:"source"`
+}
+
+// GPURef references a single GPU device
+type GPURef struct {
+	Cluster  string `json:"cluster,omitempty"`
+	NodeName string `json:"node_name"`
+	GPUIndex int    `json:"gpu_index"`
+	UUID     string `json:"uuid,omitempty"`
+}
+
+// GPUPodAllocation represents the GPUs held by a single pod container
+type GPUPodAllocation struct {
+	Cluster            string   `json:"cluster,omitempty"`
+	Namespace          string   `json:"namespace"`
+	Pod                string   `json:"pod"`
+	Container          string   `json:"container,omitempty"`
+	NodeName           string   `json:"node_name"`
+	RequestedGPUs      int      `json:"requested_gpus"`
+	GPUs               []GPURef `json:"gpus"`
+	AverageUtilization float64  `json:"average_utilization"`
+	MemoryUsed         float64  `json:"memory_used"`
+}
+
+// DataPoint represents a single timestamped sample
+type DataPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+// GPUTimeSeries represents historical metrics for a single GPU
+type GPUTimeSeries struct {
+	Cluster     string      `json:"cluster,omitempty"`
+	NodeName    string      `json:"node_name"`
+	GPUIndex    int         `json:"gpu_index"`
+	GPUName     string      `json:"gpu_name"`
+	Utilization []DataPoint `json:"utilization"`
+	MemoryUsed  []DataPoint `json:"memory_used"`
+	MemoryTotal []DataPoint `json:"memory_total"`
+	MemoryFree  []DataPoint `json:"memory_free"`
+	Temperature []DataPoint `json:"temperature"`
+	PowerDraw   []DataPoint `json:"power_draw"`
+}
+
+// IdleGPU is a GPU whose utilization stayed below the idle thresholds over the lookback window
+type IdleGPU struct {
+	GPUMetrics
+	AverageUtilization float64 `json:"average_utilization"`
+	P95Utilization     float64 `json:"p95_utilization"`
+	ObservedHours      float64 `json:"observed_hours"`
+	WastedGPUHours     float64 `json:"wasted_gpu_hours"`
+}
+
+// IdleGPUReport lists idle GPUs ranked by wasted GPU-hours
+type IdleGPUReport struct {
+	Lookback            string    `json:"lookback"`
+	MaxAverage          float64   `json:"max_average_utilization"`
+	MaxP95              float64   `json:"max_p95_utilization"`
+	TotalWastedGPUHours float64   `json:"total_wasted_gpu_hours"`
+	GPUs                []IdleGPU `json:"gpus"`
+}
+
+// ClusterSummary reports the health and GPU totals of a federated cluster
+type ClusterSummary struct {
+	Name               string    `json:"name"`
+	Healthy            bool      `json:"healthy"`
+	Error              string    `json:"error,omitempty"`
+	NodeCount          int       `json:"node_count"`
+	GPUCount           int       `json:"gpu_count"`
+	AverageUtilization float64   `json:"average_utilization"`
+	MemoryUsed         float64   `json:"memory_used"`
+	MemoryTotal        float64   `json:"memory_total"`
+	PowerDraw          float64   `json:"power_draw"`
+	CheckedAt          time.Time `json:"checked_at"`
+}
+
 // GPUNode represents GPU node information
 type GPUNode struct {
-	NodeName  string   `json:"node_name"`
-	GPUCount  int      `json:"gpu_count"`
-	GPUModels []string `json:"gpu_models"`
+	Cluster    string   `json:"cluster,omitempty"`
+	NodeName   string   `json:"node_name"`
+	GPUCount   int      `json:"gpu_count"`
+	GPUModels  []string `json:"gpu_models"`
+	PowerDraw  float64  `json:"power_draw"`
+	PowerLimit float64  `json:"power_limit"`
 }
 
 // APIResponse represents standard API response structure
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Message string      `json:"message,omitempty"`
+	Success    bool        `json:"success"`
+	Data       interface{} `json:"data,omitempty"`
+	Error      string      `json:"error,omitempty"`
+	Message    string      `json:"message,omitempty"`
+	Warnings   []Warning   `json:"warnings,omitempty"`
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+// Pagination describes the page of a list returned in APIResponse.Data
+type Pagination struct {
+	Total      int  `json:"total"`
+	Offset     int  `json:"offset"`
+	Limit      int  `json:"limit"`
+	Returned   int  `json:"returned"`
+	NextOffset *int `json:"next_offset,omitempty"`
+}
+
+// Warning describes a metric missing from a partial result
+type Warning struct {
+	Cluster string `json:"cluster,omitempty"`
+	Metric  string `json:"metric"`
+	Query   string `json:"query,omitempty"`
+	Reason  string `json:"reason"`
 }
 
 // MetricsQuery represents Prometheus query parameters
@@ -40,3 +170,18 @@ type MetricsQuery struct {
 	EndTime   string `json:"end_time,omitempty"`
 	Step      string `json:"step,omitempty"`
 }
+
+// StreamSnapshot is the payload of a "snapshot" server-sent event
+type StreamSnapshot struct {
+	Timestamp time.Time    `json:"timestamp"`
+	GPUs      []GPUMetrics `json:"gpus"`
+	Warnings  []Warning    `json:"warnings,omitempty"`
+}
+
+// StreamDiff is the payload of a "diff" server-sent event
+type StreamDiff struct {
+	Timestamp time.Time    `json:"timestamp"`
+	Updated   []GPUMetrics `json:"updated"`
+	Removed   []GPURef     `json:"removed"`
+	Warnings  []Warning    `json:"warnings,omitempty"`
+}
@@ -0,0 +1,29 @@
+package models
+
+// Owner sources reported in GPUOwner.
+const (
+	OwnerSourceExporter        = "exporter"
+	OwnerSourceResourceRequest = "resource_request"
+)
+
+// OwnerFromLabels reads the owning pod from the exporter labels named podLabel,
+// namespaceLabel and containerLabel. It returns nil if the GPU is unallocated or
+// the exporter does not label series with their pod.
+func OwnerFromLabels(metric map[string]string, podLabel, namespaceLabel, containerLabel string) *GPUOwner {
+	if podLabel == "" || namespaceLabel == "" {
+		return nil
+	}
+
+	pod := metric[podLabel]
+	namespace := metric[namespaceLabel]
+	if pod == "" || namespace == "" {
+		return nil
+	}
+
+	return &GPUOwner{
+		Namespace: namespace,
+		Pod:       pod,
+		Container: metric[containerLabel],
+		Source:    OwnerSourceExporter,
+	}
+}
@@ -0,0 +1,22 @@
+package models
+
+import "testing"
+
+func TestOwnerFromLabels(t *testing.T) {
+	owner := OwnerFromLabels(map[string]string{
+		"pod":       "trainer-0",
+		"namespace": "ml",
+		"container": "main",
+	}, "pod", "namespace", "container")
+	if owner == nil || owner.Pod != "trainer-0" || owner.Namespace != "ml" || owner.Container != "main" || owner.Source != OwnerSourceExporter {
+		t.Errorf("unexpected owner: %+v", owner)
+	}
+
+	if owner := OwnerFromLabels(map[string]string{"pod": ""}, "pod", "namespace", "container"); owner != nil {
+		t.Errorf("expected nil owner for unallocated GPU, got %+v", owner)
+	}
+
+	if owner := OwnerFromLabels(map[string]string{"pod": "x", "namespace": "y"}, "", "", ""); owner != nil {
+		t.Errorf("expected nil owner for schema without pod labels, got %+v", owner)
+	}
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// Reasoner is implemented by errors that can describe themselves to API clients.
+type Reasoner interface {
+	// Reason summarises the error without echoing response bodies.
+	Reason() string
+}
+
+// FailureReason summarises a metrics source error for a Warning without echoing
+// response bodies to API clients. The first error in the chain implementing
+// Reasoner describes itself; otherwise timeouts, cancellations and network
+// errors are told apart.
+func FailureReason(err error) string {
+	var reasoner Reasoner
+	var dnsErr *net.DNSError
+	var netErr net.Error
+
+	switch {
+	case errors.As(err, &reasoner):
+		return reasoner.Reason()
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		return "request timed out"
+	case errors.Is(err, context.Canceled):
+		return "request canceled"
+	case errors.As(err, &dnsErr):
+		return "name resolution failed"
+	case errors.As(err, &netErr):
+		return "backend unreachable"
+	default:
+		return "request failed"
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+)
+
+type testReasonError struct{}
+
+func (testReasonError) Error() string  { return "body: secret" }
+func (testReasonError) Reason() string { return "backend returned status 500" }
+
+func TestFailureReason(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("query: %w", testReasonError{}), "backend returned status 500"},
+		{fmt.Errorf("query: %w", context.DeadlineExceeded), "request timed out"},
+		{context.Canceled, "request canceled"},
+		{&net.DNSError{Err: "no such host", Name: "exporter"}, "name resolution failed"},
+		{&net.OpError{Op: "dial", Err: errors.New("connection refused")}, "backend unreachable"},
+		{errors.New("decoding response: unexpected EOF"), "request failed"},
+	}
+	for _, tt := range tests {
+		if got := FailureReason(tt.err); got != tt.want {
+			t.Errorf("FailureReason(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
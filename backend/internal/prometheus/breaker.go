@@ -0,0 +1,194 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without contacting Prometheus while the circuit breaker is open.
+var ErrCircuitOpen error = circuitOpenError{}
+
+// circuitOpenError is the type of ErrCircuitOpen.
+type circuitOpenError struct{}
+
+// Error implements the error interface.
+func (circuitOpenError) Error() string { return "prometheus circuit breaker open" }
+
+// Reason implements models.Reasoner.
+func (circuitOpenError) Reason() string { return "prometheus unavailable, circuit breaker open" }
+
+// errTrialPending is returned while a half-open breaker waits for its trial
+// request. It matches ErrCircuitOpen, but unlike an open breaker it does not mean
+// the sibling queries of a fan-out would fail, one of them being the trial.
+var errTrialPending error = trialPendingError{}
+
+// trialPendingError is the type of errTrialPending.
+type trialPendingError struct{ circuitOpenError }
+
+// Is makes errTrialPending match ErrCircuitOpen.
+func (trialPendingError) Is(target error) bool { return target == ErrCircuitOpen }
+
+// Circuit breaker states reported in BreakerStatus.
+const (
+	BreakerClosed   = "closed"
+	BreakerOpen     = "open"
+	BreakerHalfOpen = "half_open"
+)
+
+// BreakerPolicy configures the circuit breaker.
+type BreakerPolicy struct {
+	// FailureThreshold is the number of consecutive failed operations that opens the
+	// breaker. The parallel queries of one call count as a single operation.
+	FailureThreshold int
+	// OpenTimeout is how long the breaker fails fast before letting a trial request through.
+	OpenTimeout time.Duration
+}
+
+// DefaultBreakerPolicy opens after five consecutive failed operations and retries after 30 seconds.
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		FailureThreshold: 5,
+		OpenTimeout:      30 * time.Second,
+	}
+}
+
+// WithCircuitBreaker fails requests fast while Prometheus is unavailable.
+func WithCircuitBreaker(policy BreakerPolicy) Option {
+	return func(c *Client) {
+		if policy.FailureThreshold > 0 {
+			c.breaker = newBreaker(policy)
+		}
+	}
+}
+
+// BreakerStatus reports the state of a circuit breaker.
+type BreakerStatus struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// breaker is a consecutive-failure circuit breaker. While open it rejects requests;
+// after OpenTimeout it lets a single trial through, closing on success and
+// reopening on failure.
+type breaker struct {
+	policy BreakerPolicy
+	now    func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	trial    bool
+}
+
+// operation groups the requests of one call, such as the parallel queries of a
+// fan-out, so that the breaker counts their failures once. failed is guarded by
+// the breaker's mutex.
+type operation struct {
+	failed bool
+}
+
+// operationKey stores the current operation in a context.
+type operationKey struct{}
+
+// withOperation returns a context whose requests form a single operation.
+func withOperation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, operationKey{}, &operation{})
+}
+
+// newBreaker creates a closed breaker.
+func newBreaker(policy BreakerPolicy) *breaker {
+	return &breaker{policy: policy, now: time.Now, state: BreakerClosed}
+}
+
+// allow reports whether a request may proceed, returning an error matching
+// ErrCircuitOpen otherwise.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Sub(b.openedAt) < b.policy.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		b.state = BreakerHalfOpen
+		b.trial = true
+		return nil
+	case BreakerHalfOpen:
+		// Only the trial request probes Prometheus
+		if b.trial {
+			return errTrialPending
+		}
+		b.trial = true
+		return nil
+	default:
+		return nil
+	}
+}
+
+// record updates the breaker with the outcome of an allowed request made with ctx.
+// Query errors prove Prometheus is up, and requests the caller canceled or timed
+// out say nothing about it; any other error, including a 5xx, is a failure.
+// Only the first failure of an operation is counted.
+func (b *breaker) record(ctx context.Context, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.trial = false
+	switch {
+	case err != nil && ctx.Err() != nil:
+		// A half-open breaker lets the next request probe instead
+	case err == nil || isQueryError(err):
+		b.state = BreakerClosed
+		b.failures = 0
+	default:
+		op, _ := ctx.Value(operationKey{}).(*operation)
+		if op != nil && op.failed && b.state != BreakerHalfOpen {
+			return
+		}
+		if op != nil {
+			op.failed = true
+		}
+		b.failures++
+		if b.state == BreakerHalfOpen || b.failures >= b.policy.FailureThreshold {
+			b.state = BreakerOpen
+			b.openedAt = b.now()
+		}
+	}
+}
+
+// isQueryError reports whether err is Prometheus rejecting a query, a 400 or
+// 422 with an error payload, rather than failing to serve it.
+func isQueryError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorType == "" {
+		return false
+	}
+	return apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnprocessableEntity
+}
+
+// status returns a snapshot of the breaker.
+func (b *breaker) status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
+
+// BreakerStatus returns the circuit breaker state, or false if no breaker is configured.
+func (c *Client) BreakerStatus() (BreakerStatus, bool) {
+	if c.breaker == nil {
+		return BreakerStatus{}, false
+	}
+	return c.breaker.status(), true
+}
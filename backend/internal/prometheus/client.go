@@ -2,22 +2,54 @@ package prometheus
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
-	"io"
+	"math"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"k8s-gpu-monitoring/internal/cache"
+	"k8s-gpu-monitoring/internal/metrics"
 	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/promql"
 )
 
 // Client represents a Prometheus HTTP API client.
 type Client struct {
 	baseURL    string
 	httpClient *http.Client
+	schema     Schema
+	// energyWindow is the lookback over which EnergyConsumed is reported.
+	energyWindow time.Duration
+	// queryCache and rangeCache are nil when caching is disabled.
+	queryCache *cache.Cache[*PrometheusResponse]
+	rangeCache *cache.Cache[*PrometheusRangeResponse]
+	// headers and credentials are added to every request.
+	headers     http.Header
+	credentials credentials
+	tlsConfig   *tls.Config
+	// retry is zero, making a single attempt, unless WithRetry is given.
+	retry RetryPolicy
+	// breaker is nil when the circuit breaker is disabled.
+	breaker *breaker
+	// maxConcurrency bounds the queries one call runs at once; batchQueries combines its selectors.
+	maxConcurrency int
+	batchQueries   bool
+}
+
+// Option configures optional Client behaviour.
+type Option func(*Client)
+
+// WithSchema sets the metric schema used to build queries and read labels.
+func WithSchema(schema Schema) Option {
+	return func(c *Client) {
+		c.schema = schema
+	}
 }
 
 // PrometheusResponse represents the response structure from Prometheus API.
@@ -32,94 +64,321 @@ type PrometheusResponse struct {
 	} `json:"data"`
 	Error     string `json:"error,omitempty"`
 	ErrorType string `json:"errorType,omitempty"`
+	// Warnings are non-fatal problems Prometheus reports alongside the result.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// PrometheusRangeResponse represents the response structure from the Prometheus range query API.
+type PrometheusRangeResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		ResultType string `json:"resultType"`
+		Result     []struct {
+			Metric map[string]string `json:"metric"`
+			Values [][]interface{}   `json:"values"`
+		} `json:"result"`
+	} `json:"data"`
+	Error     string `json:"error,omitempty"`
+	ErrorType string `json:"errorType,omitempty"`
+	// Warnings are non-fatal problems Prometheus reports alongside the result.
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// WithEnergyWindow sets the lookback window for per-GPU energy consumption.
+func WithEnergyWindow(window time.Duration) Option {
+	return func(c *Client) {
+		if window > 0 {
+			c.energyWindow = window
+		}
+	}
+}
+
+// APIError is returned when Prometheus responds with a non-200 status or an error payload.
+type APIError struct {
+	StatusCode int
+	ErrorType  string
+	Message    string
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	if e.ErrorType != "" {
+		return fmt.Sprintf("prometheus query failed: %s - %s", e.ErrorType, e.Message)
+	}
+	return fmt.Sprintf("prometheus API error: status %d, body: %s", e.StatusCode, e.Message)
+}
+
+// Reason implements models.Reasoner.
+func (e *APIError) Reason() string {
+	if e.ErrorType != "" {
+		return fmt.Sprintf("prometheus %s error: %s", e.ErrorType, e.Message)
+	}
+	return fmt.Sprintf("prometheus returned status %d", e.StatusCode)
 }
 
-// NewClient creates a new Prometheus client.
-func NewClient(baseURL string) *Client {
-	return &Client{
+// WithCache caches query responses for ttl, coalescing concurrent identical queries.
+// When Prometheus fails, responses up to staleTTL past expiry are served instead.
+func WithCache(ttl, staleTTL time.Duration) Option {
+	return func(c *Client) {
+		if ttl <= 0 {
+			return
+		}
+		c.queryCache = cache.New[*PrometheusResponse](ttl, staleTTL, c.httpClient.Timeout)
+		c.rangeCache = cache.New[*PrometheusRangeResponse](ttl, staleTTL, c.httpClient.Timeout)
+	}
+}
+
+// NewClient creates a new Prometheus client using the default metric schema unless overridden.
+func NewClient(baseURL string, opts ...Option) *Client {
+	c := &Client{
 		baseURL: strings.TrimSuffix(baseURL, "/"),
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		schema:         DefaultSchema(),
+		energyWindow:   time.Hour,
+		headers:        make(http.Header),
+		maxConcurrency: defaultMaxConcurrency,
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	if c.tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = c.tlsConfig
+		c.httpClient.Transport = transport
+	}
+	return c
+}
+
+// Schema returns the metric schema the client queries with.
+func (c *Client) Schema() Schema {
+	return c.schema
 }
 
-// Query executes a PromQL query.
+// CacheStats returns the combined query cache counters, or false if caching is disabled.
+func (c *Client) CacheStats() (metrics.CacheStats, bool) {
+	if c.queryCache == nil {
+		return metrics.CacheStats{}, false
+	}
+
+	instant, ranged := c.queryCache.Stats(), c.rangeCache.Stats()
+	return metrics.CacheStats{
+		Hits:      instant.Hits + ranged.Hits,
+		Misses:    instant.Misses + ranged.Misses,
+		Coalesced: instant.Coalesced + ranged.Coalesced,
+		Stale:     instant.Stale + ranged.Stale,
+		Bypassed:  instant.Bypassed + ranged.Bypassed,
+		Entries:   instant.Entries + ranged.Entries,
+	}, true
+}
+
+// Query executes a PromQL query, served from the cache when enabled.
 func (c *Client) Query(ctx context.Context, query string) (*PrometheusResponse, error) {
+	if c.queryCache == nil {
+		return c.query(ctx, query)
+	}
+	return c.queryCache.Get(ctx, query, func(ctx context.Context) (*PrometheusResponse, error) {
+		return c.query(ctx, query)
+	})
+}
+
+// query executes a PromQL query against Prometheus.
+func (c *Client) query(ctx context.Context, query string) (*PrometheusResponse, error) {
 	queryURL := fmt.Sprintf("%s/api/v1/query", c.baseURL)
 
 	params := url.Values{}
 	params.Add("query", query)
 	params.Add("time", strconv.FormatInt(time.Now().Unix(), 10))
 
-	req, err := http.NewRequestWithContext(ctx, "GET", queryURL+"?"+params.Encode(), nil)
+	body, err := c.get(ctx, queryURL+"?"+params.Encode())
 	if err != nil {
-		return nil, fmt.Errorf("creating request: %w", err)
+		return nil, err
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("executing request: %w", err)
+	var promResp PrometheusResponse
+	if err := json.Unmarshal(body, &promResp); err != nil {
+		return nil, fmt.Errorf("unmarshaling response: %w", err)
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("reading response body: %w", err)
+	if promResp.Status != "success" {
+		return nil, &APIError{StatusCode: http.StatusOK, ErrorType: promResp.ErrorType, Message: promResp.Error}
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("prometheus API error: status %d, body: %s", resp.StatusCode, string(body))
+	return &promResp, nil
+}
+
+// QueryRange executes a PromQL range query between start and end at the given step,
+// served from the cache when enabled.
+func (c *Client) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*PrometheusRangeResponse, error) {
+	if c.rangeCache == nil {
+		return c.queryRange(ctx, query, start, end, step)
 	}
+	key := fmt.Sprintf("%s|%d|%d|%s", query, start.Unix(), end.Unix(), step)
+	return c.rangeCache.Get(ctx, key, func(ctx context.Context) (*PrometheusRangeResponse, error) {
+		return c.queryRange(ctx, query, start, end, step)
+	})
+}
 
-	var promResp PrometheusResponse
+// queryRange executes a PromQL range query against Prometheus.
+func (c *Client) queryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*PrometheusRangeResponse, error) {
+	queryURL := fmt.Sprintf("%s/api/v1/query_range", c.baseURL)
+
+	params := url.Values{}
+	params.Add("query", query)
+	params.Add("start", strconv.FormatInt(start.Unix(), 10))
+	params.Add("end", strconv.FormatInt(end.Unix(), 10))
+	params.Add("step", strconv.FormatFloat(step.Seconds(), 'f', -1, 64))
+
+	body, err := c.get(ctx, queryURL+"?"+params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	var promResp PrometheusRangeResponse
 	if err := json.Unmarshal(body, &promResp); err != nil {
 		return nil, fmt.Errorf("unmarshaling response: %w", err)
 	}
 
 	if promResp.Status != "success" {
-		return nil, fmt.Errorf("prometheus query failed: %s - %s", promResp.ErrorType, promResp.Error)
+		return nil, &APIError{StatusCode: http.StatusOK, ErrorType: promResp.ErrorType, Message: promResp.Error}
+	}
+
+	if promResp.Data.ResultType != "matrix" {
+		return nil, fmt.Errorf("unexpected result type %q for range query", promResp.Data.ResultType)
 	}
 
 	return &promResp, nil
 }
 
 // GetGPUMetrics retrieves GPU metrics from Prometheus with concurrent queries.
-func (c *Client) GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, error) {
-	// Execute multiple queries concurrently
-	queries := map[string]string{
-		"utilization":        `nvidia_gpu_utilization_percent`,
-		"memory_used":        `nvidia_gpu_used_memory_bytes`,
-		"memory_total":       `nvidia_gpu_total_memory_bytes`,
-		"memory_free":        `nvidia_gpu_free_memory_bytes`,
-		"memory_utilization": `nvidia_gpu_memory_utilization_percent`,
-		"temperature":        `nvidia_gpu_temperature_celsius`,
-	}
-
-	results := make(map[string]*PrometheusResponse)
-	errors := make(chan error, len(queries))
-
-	// Execute queries concurrently
-	for name, query := range queries {
-		go func(name, query string) {
-			resp, err := c.Query(ctx, query)
-			if err != nil {
-				errors <- fmt.Errorf("query %s failed: %w", name, err)
-				return
-			}
-			results[name] = resp
-			errors <- nil
-		}(name, query)
+// Metrics whose query fails or returns no series are reported as warnings; an
+// error is only returned when no query succeeded.
+func (c *Client) GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+	return c.getGPUMetrics(ctx, nil)
+}
+
+// GetGPUUtilization retrieves the raw utilization series of the GPUs within the caller's scope.
+func (c *Client) GetGPUUtilization(ctx context.Context) (*PrometheusResponse, error) {
+	matchers, scope, ok := c.scopeQuery(ctx, nil)
+	if !ok {
+		return &PrometheusResponse{Status: "success"}, nil
+	}
+
+	resp, err := c.Query(ctx, matchers.selector(c.schema.Metrics.Utilization).String())
+	if err != nil {
+		return nil, fmt.Errorf("getting GPU utilization: %w", err)
 	}
+	if scope == nil {
+		return resp, nil
+	}
+
+	labelSets := make([]map[string]string, len(resp.Data.Result))
+	for i, result := range resp.Data.Result {
+		labelSets[i] = result.Metric
+	}
+	allowed := c.allowedGPUs(ctx, *scope, labelSets)
 
-	// Wait for all queries to complete
-	for i := 0; i < len(queries); i++ {
-		if err := <-errors; err != nil {
-			return nil, err
+	// Filter a copy so the cached response is left intact
+	filtered := *resp
+	filtered.Data.Result = nil
+	labels := c.schema.Labels
+	for _, result := range resp.Data.Result {
+		gpuIndex, err := strconv.Atoi(result.Metric[labels.GPUIndex])
+		if err != nil || !allowed[gpuKey(result.Metric[labels.Node], gpuIndex)] {
+			continue
 		}
+		filtered.Data.Result = append(filtered.Data.Result, result)
+	}
+	return &filtered, nil
+}
+
+// SelectGPUMetrics retrieves metrics for the GPUs matching sel, filtering in PromQL
+// rather than after fetching the whole fleet. Models and namespaces are only
+// filtered when the exporter labels series with them.
+func (c *Client) SelectGPUMetrics(ctx context.Context, sel metrics.GPUSelector) ([]models.GPUMetrics, []models.Warning, error) {
+	matchers := labelMatchers{}
+	if len(sel.Nodes) > 0 {
+		matchers[c.schema.Labels.Node] = sel.Nodes
+	}
+	if len(sel.GPUNames) > 0 && c.schema.Labels.GPUName != "" {
+		matchers[c.schema.Labels.GPUName] = sel.GPUNames
+	}
+	if len(sel.Namespaces) > 0 && c.schema.Labels.Namespace != "" {
+		matchers[c.schema.Labels.Namespace] = sel.Namespaces
+	}
+	return c.getGPUMetrics(ctx, matchers)
+}
+
+// gpuMetricTypes are the metric types making up models.GPUMetrics.
+var gpuMetricTypes = []string{
+	"utilization",
+	"memory_used",
+	"memory_total",
+	"memory_free",
+	"memory_utilization",
+	"temperature",
+	"power_draw",
+	"power_limit",
+}
+
+// getGPUMetrics retrieves GPU metrics for the series selected by matchers,
+// restricted to the caller's scope.
+func (c *Client) getGPUMetrics(ctx context.Context, matchers labelMatchers) ([]models.GPUMetrics, []models.Warning, error) {
+	matchers, scope, ok := c.scopeQuery(ctx, matchers)
+	if !ok {
+		return []models.GPUMetrics{}, nil, nil
+	}
+
+	var extra map[string]string
+	if energy := c.schema.energyQuery(c.energyWindow, matchers); energy != "" {
+		extra = map[string]string{"energy_consumed": energy}
+	}
+
+	queries, results, failures := c.queryMetrics(ctx, matchers, gpuMetricTypes, extra)
+	if len(results) == 0 && len(failures) > 0 {
+		return nil, nil, joinFailures(failures)
+	}
+
+	gpus, err := c.parseGPUMetrics(results)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.attributeOwners(ctx, gpus)
+
+	// Owners inferred from resource requests and clauses joined by OR cannot be matched in PromQL, so enforce the scope here too
+	if scope != nil {
+		visible := gpus[:0]
+		for _, m := range gpus {
+			if scope.Allows(m.NodeName, m.Owner) {
+				visible = append(visible, m)
+			}
+		}
+		gpus = visible
+	}
+
+	return gpus, buildWarnings(queries, failures, seriesCounts(results), responseWarnings(results)), nil
+}
+
+// seriesCounts returns the number of series each query returned.
+func seriesCounts(results map[string]*PrometheusResponse) map[string]int {
+	counts := make(map[string]int, len(results))
+	for name, resp := range results {
+		counts[name] = len(resp.Data.Result)
 	}
+	return counts
+}
 
-	return c.parseGPUMetrics(results)
+// responseWarnings returns the warnings Prometheus attached to each query's response.
+func responseWarnings(results map[string]*PrometheusResponse) map[string][]string {
+	warnings := make(map[string][]string)
+	for name, resp := range results {
+		if len(resp.Warnings) > 0 {
+			warnings[name] = resp.Warnings
+		}
+	}
+	return warnings
 }
 
 // parseGPUMetrics parses Prometheus response into GPUMetrics.
@@ -127,11 +386,13 @@ func (c *Client) parseGPUMetrics(results map[string]*PrometheusResponse) ([]mode
 	// Group metrics by node and GPU index
 	metricsMap := make(map[string]*models.GPUMetrics) // key: "node_name:gpu_index"
 
+	labels := c.schema.Labels
+
 	for metricType, response := range results {
 		for _, result := range response.Data.Result {
-			nodeName := result.Metric["hostname"]
-			gpuIndex := result.Metric["gpu_id"]
-			gpuName := result.Metric["gpu_name"]
+			nodeName := result.Metric[labels.Node]
+			gpuIndex := result.Metric[labels.GPUIndex]
+			gpuName := result.Metric[labels.GPUName]
 
 			if nodeName == "" || gpuIndex == "" {
 				continue
@@ -149,6 +410,17 @@ func (c *Client) parseGPUMetrics(results map[string]*PrometheusResponse) ([]mode
 				}
 			}
 
+			// Identity labels may only be present on some of the series
+			if metricsMap[key].GPUName == "" {
+				metricsMap[key].GPUName = gpuName
+			}
+			if metricsMap[key].UUID == "" {
+				metricsMap[key].UUID = result.Metric[labels.UUID]
+			}
+			if metricsMap[key].Owner == nil {
+				metricsMap[key].Owner = models.OwnerFromLabels(result.Metric, labels.Pod, labels.Namespace, labels.Container)
+			}
+
 			// Parse and extract value
 			if len(result.Value) >= 2 {
 				valueStr, ok := result.Value[1].(string)
@@ -166,15 +438,22 @@ func (c *Client) parseGPUMetrics(results map[string]*PrometheusResponse) ([]mode
 				case "utilization":
 					metricsMap[key].Utilization = value // Already in percentage format
 				case "memory_used":
-					metricsMap[key].MemoryUsed = value / (1024 * 1024 * 1024) // bytes to GB
+					metricsMap[key].MemoryUsed = c.schema.memoryToGB(value)
 				case "memory_total":
-					metricsMap[key].MemoryTotal = value / (1024 * 1024 * 1024) // bytes to GB
+					metricsMap[key].MemoryTotal = c.schema.memoryToGB(value)
 				case "memory_free":
-					metricsMap[key].MemoryFree = value / (1024 * 1024 * 1024) // bytes to GB
+					metricsMap[key].MemoryFree = c.schema.memoryToGB(value)
 				case "memory_utilization":
 					metricsMap[key].MemoryUtilization = value // Already in percentage format
 				case "temperature":
 					metricsMap[key].Temperature = value
+				case "power_draw":
+					metricsMap[key].PowerDraw = value
+				case "power_limit":
+					metricsMap[key].PowerLimit = value
+				case "energy_consumed":
+					metricsMap[key].EnergyConsumed = value
+					metricsMap[key].EnergyWindow = c.energyWindow.String()
 				}
 			}
 		}
@@ -183,6 +462,13 @@ func (c *Client) parseGPUMetrics(results map[string]*PrometheusResponse) ([]mode
 	// Convert to slice
 	var gpuMetrics []models.GPUMetrics
 	for _, metrics := range metricsMap {
+		// Exporters without a total memory metric report used and free only
+		if c.schema.Metrics.MemoryTotal == "" {
+			metrics.MemoryTotal = metrics.MemoryUsed + metrics.MemoryFree
+		}
+		if metrics.PowerLimit > 0 {
+			metrics.PowerHeadroom = metrics.PowerLimit - metrics.PowerDraw
+		}
 		gpuMetrics = append(gpuMetrics, *metrics)
 	}
 
@@ -191,59 +477,257 @@ func (c *Client) parseGPUMetrics(results map[string]*PrometheusResponse) ([]mode
 
 // GetGPUNodes retrieves GPU node information.
 func (c *Client) GetGPUNodes(ctx context.Context) ([]models.GPUNode, error) {
-	query := `group by (hostname, gpu_name) (nvidia_gpu_utilization_percent)`
+	// Scoped callers only see nodes hosting GPUs visible to them, summed over those GPUs
+	if _, scoped := metrics.ScopeFrom(ctx); scoped {
+		gpus, _, err := c.getGPUMetrics(ctx, nil)
+		if err != nil {
+			return nil, fmt.Errorf("getting GPU nodes: %w", err)
+		}
+		return models.GroupNodes(gpus), nil
+	}
 
-	resp, err := c.Query(ctx, query)
-	if err != nil {
+	// A fixed set of aggregations covers every node: GPUs counted per node and
+	// model, plus power summed per node
+	labels := c.schema.Labels
+	queries := map[string]string{
+		"inventory": promql.Count(promql.Select(c.schema.Metrics.Utilization)).By(labels.Node, labels.GPUName).String(),
+	}
+	if metric := c.schema.Metrics.PowerDraw; metric != "" {
+		queries["power_draw"] = promql.Sum(promql.Select(metric)).By(labels.Node).String()
+	}
+	if metric := c.schema.Metrics.PowerLimit; metric != "" {
+		queries["power_limit"] = promql.Sum(promql.Select(metric)).By(labels.Node).String()
+	}
+
+	results, failures := c.queryAll(ctx, queries)
+	if err, ok := failures["inventory"]; ok {
 		return nil, fmt.Errorf("getting GPU nodes: %w", err)
 	}
 
-	var nodes []models.GPUNode
 	nodeMap := make(map[string]*models.GPUNode)
+	for _, result := range results["inventory"].Data.Result {
+		nodeName := result.Metric[labels.Node]
+		if nodeName == "" {
+			continue
+		}
+
+		node := nodeMap[nodeName]
+		if node == nil {
+			node = &models.GPUNode{NodeName: nodeName, GPUModels: make([]string, 0)}
+			nodeMap[nodeName] = node
+		}
+
+		if count, ok := sampleValue(result.Value); ok {
+			node.GPUCount += int(count)
+		}
+		if gpuName := result.Metric[labels.GPUName]; gpuName != "" && !contains(node.GPUModels, gpuName) {
+			node.GPUModels = append(node.GPUModels, gpuName)
+		}
+	}
+
+	// Power is best effort; exporters without power metrics leave the totals at zero
+	addNodeSums(results["power_draw"], labels.Node, nodeMap, func(node *models.GPUNode, watts float64) { node.PowerDraw = watts })
+	addNodeSums(results["power_limit"], labels.Node, nodeMap, func(node *models.GPUNode, watts float64) { node.PowerLimit = watts })
 
+	nodes := make([]models.GPUNode, 0, len(nodeMap))
+	for _, node := range nodeMap {
+		nodes = append(nodes, *node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].NodeName < nodes[j].NodeName })
+
+	return nodes, nil
+}
+
+// addNodeSums applies the per-node sums in resp, if any, to the matching nodes.
+func addNodeSums(resp *PrometheusResponse, nodeLabel string, nodeMap map[string]*models.GPUNode, set func(node *models.GPUNode, value float64)) {
+	if resp == nil {
+		return
+	}
 	for _, result := range resp.Data.Result {
-		nodeName := result.Metric["hostname"]
-		gpuName := result.Metric["gpu_name"]
-		if nodeName == "" {
+		node := nodeMap[result.Metric[nodeLabel]]
+		if node == nil {
 			continue
 		}
+		if value, ok := sampleValue(result.Value); ok {
+			set(node, value)
+		}
+	}
+}
 
-		if nodeMap[nodeName] == nil {
-			nodeMap[nodeName] = &models.GPUNode{
-				NodeName:  nodeName,
-				GPUModels: make([]string, 0),
-			}
+// GetGPUMetricsRange retrieves per-GPU time series between start and end with concurrent range queries.
+// Like GetGPUMetrics, concurrency is bounded and failed or empty metrics are reported as warnings.
+func (c *Client) GetGPUMetricsRange(ctx context.Context, start, end time.Time, step time.Duration) ([]models.GPUTimeSeries, []models.Warning, error) {
+	matchers, scope, ok := c.scopeQuery(ctx, nil)
+	if !ok {
+		return []models.GPUTimeSeries{}, nil, nil
+	}
+
+	queries := c.schema.queries(
+		matchers,
+		"utilization",
+		"memory_used",
+		"memory_total",
+		"memory_free",
+		"temperature",
+		"power_draw",
+	)
+
+	results, failures := c.queryRangeAll(ctx, queries, start, end, step)
+
+	if len(results) == 0 && len(failures) > 0 {
+		return nil, nil, joinFailures(failures)
+	}
+
+	seriesCounts := make(map[string]int, len(results))
+	promWarnings := make(map[string][]string)
+	for name, resp := range results {
+		seriesCounts[name] = len(resp.Data.Result)
+		if len(resp.Warnings) > 0 {
+			promWarnings[name] = resp.Warnings
 		}
+	}
 
-		// Add GPU model if not already present
-		if gpuName != "" {
-			found := false
-			for _, existingModel := range nodeMap[nodeName].GPUModels {
-				if existingModel == gpuName {
-					found = true
-					break
-				}
+	series := c.parseGPUTimeSeries(results)
+	if scope != nil {
+		var labelSets []map[string]string
+		for _, resp := range results {
+			for _, result := range resp.Data.Result {
+				labelSets = append(labelSets, result.Metric)
 			}
-			if !found {
-				nodeMap[nodeName].GPUModels = append(nodeMap[nodeName].GPUModels, gpuName)
+		}
+		allowed := c.allowedGPUs(ctx, *scope, labelSets)
+
+		visible := series[:0]
+		for _, s := range series {
+			if allowed[gpuKey(s.NodeName, s.GPUIndex)] {
+				visible = append(visible, s)
 			}
 		}
+		series = visible
+	}
+
+	return series, buildWarnings(queries, failures, seriesCounts, promWarnings), nil
+}
+
+// parseGPUTimeSeries parses Prometheus range responses into GPUTimeSeries.
+func (c *Client) parseGPUTimeSeries(results map[string]*PrometheusRangeResponse) []models.GPUTimeSeries {
+	seriesMap := make(map[string]*models.GPUTimeSeries) // key: "node_name:gpu_index"
+	labels := c.schema.Labels
+
+	for metricType, response := range results {
+		for _, result := range response.Data.Result {
+			nodeName := result.Metric[labels.Node]
+			gpuIndex := result.Metric[labels.GPUIndex]
+			gpuName := result.Metric[labels.GPUName]
+
+			if nodeName == "" || gpuIndex == "" {
+				continue
+			}
 
-		// Separate query to get GPU count
-		countQuery := fmt.Sprintf(`count by (hostname) (nvidia_gpu_utilization_percent{hostname="%s"})`, nodeName)
-		countResp, err := c.Query(ctx, countQuery)
-		if err == nil && len(countResp.Data.Result) > 0 && len(countResp.Data.Result[0].Value) >= 2 {
-			if countStr, ok := countResp.Data.Result[0].Value[1].(string); ok {
-				if count, err := strconv.Atoi(countStr); err == nil {
-					nodeMap[nodeName].GPUCount = count
+			key := fmt.Sprintf("%s:%s", nodeName, gpuIndex)
+
+			if seriesMap[key] == nil {
+				idx, _ := strconv.Atoi(gpuIndex)
+				seriesMap[key] = &models.GPUTimeSeries{
+					NodeName: nodeName,
+					GPUIndex: idx,
+					GPUName:  gpuName,
 				}
 			}
+			if seriesMap[key].GPUName == "" {
+				seriesMap[key].GPUName = gpuName
+			}
+
+			points := parseSamplePoints(result.Values)
+
+			switch metricType {
+			case "utilization":
+				seriesMap[key].Utilization = points
+			case "memory_used":
+				seriesMap[key].MemoryUsed = scalePoints(points, c.schema.memoryToGB(1))
+			case "memory_total":
+				seriesMap[key].MemoryTotal = scalePoints(points, c.schema.memoryToGB(1))
+			case "memory_free":
+				seriesMap[key].MemoryFree = scalePoints(points, c.schema.memoryToGB(1))
+			case "temperature":
+				seriesMap[key].Temperature = points
+			case "power_draw":
+				seriesMap[key].PowerDraw = points
+			}
 		}
 	}
 
-	for _, node := range nodeMap {
-		nodes = append(nodes, *node)
+	series := make([]models.GPUTimeSeries, 0, len(seriesMap))
+	for _, s := range seriesMap {
+		if c.schema.Metrics.MemoryTotal == "" {
+			s.MemoryTotal = sumPoints(s.MemoryUsed, s.MemoryFree)
+		}
+		series = append(series, *s)
 	}
 
-	return nodes, nil
+	// Keep output order stable for clients rendering charts
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].NodeName != series[j].NodeName {
+			return series[i].NodeName < series[j].NodeName
+		}
+		return series[i].GPUIndex < series[j].GPUIndex
+	})
+
+	return series
+}
+
+// parseSamplePoints converts Prometheus [timestamp, "value"] pairs into data points, skipping malformed samples.
+func parseSamplePoints(values [][]interface{}) []models.DataPoint {
+	points := make([]models.DataPoint, 0, len(values))
+	for _, v := range values {
+		if len(v) < 2 {
+			continue
+		}
+
+		ts, ok := v[0].(float64)
+		if !ok {
+			continue
+		}
+
+		valueStr, ok := v[1].(string)
+		if !ok {
+			continue
+		}
+
+		value, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil {
+			continue
+		}
+
+		sec, frac := math.Modf(ts)
+		points = append(points, models.DataPoint{
+			Timestamp: time.Unix(int64(sec), int64(frac*1e9)).UTC(),
+			Value:     value,
+		})
+	}
+	return points
+}
+
+// scalePoints multiplies every point value by factor in place and returns the slice.
+func scalePoints(points []models.DataPoint, factor float64) []models.DataPoint {
+	for i := range points {
+		points[i].Value *= factor
+	}
+	return points
+}
+
+// sumPoints adds two series sample by sample where their timestamps align.
+func sumPoints(a, b []models.DataPoint) []models.DataPoint {
+	byTime := make(map[int64]float64, len(b))
+	for _, p := range b {
+		byTime[p.Timestamp.UnixNano()] = p.Value
+	}
+
+	sum := make([]models.DataPoint, 0, len(a))
+	for _, p := range a {
+		if v, ok := byTime[p.Timestamp.UnixNano()]; ok {
+			sum = append(sum, models.DataPoint{Timestamp: p.Timestamp, Value: p.Value + v})
+		}
+	}
+	return sum
 }
@@ -0,0 +1,264 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/fakeprom"
+)
+
+const matrixBody = `{
+  "status": "success",
+  "data": {
+    "resultType": "matrix",
+    "result": [
+      {
+        "metric": {"hostname": "node1", "gpu_id": "0", "gpu_name": "NVIDIA A100"},
+        "values": [[1700000000, "10"], [1700000060, "20.5"], [1700000120, "bad"]]
+      }
+    ]
+  }
+}`
+
+func TestQueryRange(t *testing.T) {
+	var gotQuery map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/query_range" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		gotQuery = map[string]string{
+			"query": r.URL.Query().Get("query"),
+			"start": r.URL.Query().Get("start"),
+			"end":   r.URL.Query().Get("end"),
+			"step":  r.URL.Query().Get("step"),
+		}
+		w.Write([]byte(matrixBody))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	start := time.Unix(1700000000, 0)
+	end := time.Unix(1700003600, 0)
+
+	resp, err := client.QueryRange(context.Background(), "up", start, end, time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotQuery["start"] != "1700000000" || gotQuery["end"] != "1700003600" || gotQuery["step"] != "60" {
+		t.Errorf("unexpected query parameters: %v", gotQuery)
+	}
+	if len(resp.Data.Result) != 1 || len(resp.Data.Result[0].Values) != 3 {
+		t.Fatalf("unexpected result: %+v", resp.Data.Result)
+	}
+}
+
+func TestQueryRangeRejectsVector(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	_, err := client.QueryRange(context.Background(), "up", time.Unix(0, 0), time.Unix(60, 0), time.Second)
+	if err == nil {
+		t.Fatal("expected error for non-matrix result")
+	}
+}
+
+func TestGetGPUMetricsRange(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(matrixBody))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	series, _, err := client.GetGPUMetricsRange(context.Background(), time.Unix(1700000000, 0), time.Unix(1700000120, 0), time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(series) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(series))
+	}
+
+	s := series[0]
+	if s.NodeName != "node1" || s.GPUIndex != 0 || s.GPUName != "NVIDIA A100" {
+		t.Errorf("unexpected series identity: %+v", s)
+	}
+	if len(s.Utilization) != 2 {
+		t.Fatalf("expected malformed sample to be skipped, got %d points", len(s.Utilization))
+	}
+	if s.Utilization[1].Value != 20.5 || !s.Utilization[1].Timestamp.Equal(time.Unix(1700000060, 0)) {
+		t.Errorf("unexpected second point: %+v", s.Utilization[1])
+	}
+}
+
+// fakePrometheus serves fleet, named after schema, from a fake Prometheus.
+func fakePrometheus(tb testing.TB, schema Schema, fleet fakeprom.Fleet, opts ...fakeprom.Option) (*fakeprom.Server, *httptest.Server) {
+	tb.Helper()
+
+	data, err := json.Marshal(schema)
+	if err != nil {
+		tb.Fatalf("marshaling schema: %v", err)
+	}
+	if err := json.Unmarshal(data, &fleet.Naming); err != nil {
+		tb.Fatalf("converting schema: %v", err)
+	}
+
+	fake := fakeprom.NewServer(fleet, opts...)
+	server := httptest.NewServer(fake)
+	tb.Cleanup(server.Close)
+	return fake, server
+}
+
+func TestGetGPUMetricsPartialResults(t *testing.T) {
+	_, server := fakePrometheus(t, DefaultSchema(), fakeprom.Fleet{
+		GPUsPerNode: 1,
+		Utilization: fakeprom.Constant(50),
+		Missing:     []string{"memory_free"},
+	}, fakeprom.WithFaults(fakeprom.Faults{FailMetrics: []string{"nvidia_gpu_temperature_celsius"}}))
+
+	client := NewClient(server.URL)
+	metrics, warnings, err := client.GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("expected partial result, got error: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].Utilization != 50 {
+		t.Fatalf("unexpected metrics: %+v", metrics)
+	}
+
+	reasons := make(map[string]string)
+	for _, w := range warnings {
+		reasons[w.Metric] = w.Reason
+	}
+	if reasons["temperature"] != "prometheus returned status 503" {
+		t.Errorf("expected temperature failure warning, got %q", reasons["temperature"])
+	}
+	if reasons["memory_free"] != "no series found" {
+		t.Errorf("expected missing memory_free warning, got %q", reasons["memory_free"])
+	}
+	if _, ok := reasons["utilization"]; ok {
+		t.Error("did not expect a warning for utilization")
+	}
+}
+
+func TestGetGPUMetricsAllFailed(t *testing.T) {
+	_, server := fakePrometheus(t, DefaultSchema(), fakeprom.Fleet{}, fakeprom.WithFaults(fakeprom.Faults{Status: http.StatusBadGateway}))
+
+	client := NewClient(server.URL)
+	if _, _, err := client.GetGPUMetrics(context.Background()); err == nil {
+		t.Fatal("expected error when every query fails")
+	}
+}
+
+// TestGetGPUMetricsSchemas reads a realistic fleet through each built-in schema,
+// with and without batched selectors.
+func TestGetGPUMetricsSchemas(t *testing.T) {
+	for _, name := range SchemaNames() {
+		for _, batch := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/batch=%t", name, batch), func(t *testing.T) {
+				schema, _ := LookupSchema(name)
+				_, server := fakePrometheus(t, schema, fakeprom.Fleet{
+					Nodes:       2,
+					GPUsPerNode: 4,
+					Models:      []string{"NVIDIA H100 80GB HBM3"},
+					Utilization: fakeprom.Constant(100),
+					Allocated:   1,
+				})
+
+				opts := []Option{WithSchema(schema)}
+				if batch {
+					opts = append(opts, WithQueryBatching())
+				}
+				metrics, warnings, err := NewClient(server.URL, opts...).GetGPUMetrics(context.Background())
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if len(metrics) != 8 || len(warnings) != 0 {
+					t.Fatalf("expected 8 GPUs without warnings, got %d, %+v", len(metrics), warnings)
+				}
+
+				m := metrics[0]
+				if m.GPUName != "NVIDIA H100 80GB HBM3" || m.UUID == "" || m.Utilization != 100 {
+					t.Errorf("unexpected identity %+v", m)
+				}
+				if math.Abs(m.MemoryTotal-80) > 1e-9 || math.Abs(m.MemoryUsed+m.MemoryFree-m.MemoryTotal) > 1e-9 {
+					t.Errorf("unexpected memory %g used + %g free of %g", m.MemoryUsed, m.MemoryFree, m.MemoryTotal)
+				}
+				if m.PowerLimit != 700 || m.PowerHeadroom != 700-m.PowerDraw || m.EnergyConsumed <= 0 {
+					t.Errorf("unexpected power %+v", m)
+				}
+				if (schema.Labels.Pod != "") != (m.Owner != nil) {
+					t.Errorf("expected owners only from exporters labelling pods, got %+v", m.Owner)
+				}
+			})
+		}
+	}
+}
+
+// nodeFleetServer serves a fleet of nodes with eight A100s each.
+func nodeFleetServer(tb testing.TB, nodes []string) (*fakeprom.Server, *httptest.Server) {
+	tb.Helper()
+	return fakePrometheus(tb, DefaultSchema(), fakeprom.Fleet{NodeNames: nodes, Utilization: fakeprom.Constant(50)})
+}
+
+func TestGetGPUNodes(t *testing.T) {
+	fake, server := nodeFleetServer(t, []string{"node-b", `node"a`})
+
+	nodes, err := NewClient(server.URL).GetGPUNodes(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("expected 2 nodes, got %+v", nodes)
+	}
+
+	// Hostnames are never interpolated into PromQL, so quotes are harmless
+	node := nodes[0]
+	if node.NodeName != `node"a` || node.GPUCount != 8 || math.Abs(node.PowerDraw-1760) > 1e-9 || node.PowerLimit != 3200 {
+		t.Errorf("unexpected node %+v", node)
+	}
+	if len(node.GPUModels) != 1 || node.GPUModels[0] != fakeprom.DefaultModel {
+		t.Errorf("unexpected GPU models %v", node.GPUModels)
+	}
+	if got := len(fake.Queries()); got != 3 {
+		t.Errorf("expected 3 queries, got %d", got)
+	}
+}
+
+// BenchmarkGetGPUNodes shows the number of Prometheus queries does not grow with the number of nodes.
+func BenchmarkGetGPUNodes(b *testing.B) {
+	for _, size := range []int{10, 200, 1000} {
+		b.Run(fmt.Sprintf("nodes=%d", size), func(b *testing.B) {
+			nodes := make([]string, size)
+			for i := range nodes {
+				nodes[i] = fmt.Sprintf("gpu-node-%04d", i)
+			}
+
+			fake, server := nodeFleetServer(b, nodes)
+			client := NewClient(server.URL)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				result, err := client.GetGPUNodes(context.Background())
+				if err != nil || len(result) != size {
+					b.Fatalf("expected %d nodes, got %d, %v", size, len(result), err)
+				}
+			}
+			b.StopTimer()
+
+			perOp := float64(len(fake.Queries())) / float64(b.N)
+			if perOp != 3 {
+				b.Fatalf("expected 3 queries per call, got %g", perOp)
+			}
+			b.ReportMetric(perOp, "queries/op")
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package prometheus
+
+import (
+	"context"
+	"sort"
+	"strconv"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// GetGPUNode retrieves the current metrics of every GPU on a single node.
+func (c *Client) GetGPUNode(ctx context.Context, nodeName string) (*models.GPUNodeDetail, []models.Warning, error) {
+	gpus, warnings, err := c.getGPUMetrics(ctx, labelMatchers{c.schema.Labels.Node: {nodeName}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(gpus) == 0 {
+		return nil, nil, metrics.ErrNotFound
+	}
+
+	sort.Slice(gpus, func(i, j int) bool {
+		return gpus[i].GPUIndex < gpus[j].GPUIndex
+	})
+
+	detail := &models.GPUNodeDetail{
+		GPUNode: models.GPUNode{
+			NodeName:  nodeName,
+			GPUCount:  len(gpus),
+			GPUModels: make([]string, 0),
+		},
+		GPUs: gpus,
+	}
+
+	seenModels := make(map[string]bool)
+	for _, m := range gpus {
+		if m.GPUName != "" && !seenModels[m.GPUName] {
+			seenModels[m.GPUName] = true
+			detail.GPUModels = append(detail.GPUModels, m.GPUName)
+		}
+		detail.PowerDraw += m.PowerDraw
+		detail.PowerLimit += m.PowerLimit
+		detail.AverageUtilization += m.Utilization
+		detail.MemoryUsed += m.MemoryUsed
+		detail.MemoryTotal += m.MemoryTotal
+	}
+	detail.AverageUtilization /= float64(len(gpus))
+
+	return detail, warnings, nil
+}
+
+// GetGPUDevice retrieves the current metrics and device information of a single GPU.
+func (c *Client) GetGPUDevice(ctx context.Context, nodeName string, gpuIndex int) (*models.GPUDetail, []models.Warning, error) {
+	labels := c.schema.Labels
+	matchers := labelMatchers{
+		labels.Node:     {nodeName},
+		labels.GPUIndex: {strconv.Itoa(gpuIndex)},
+	}
+
+	gpus, warnings, err := c.getGPUMetrics(ctx, matchers)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(gpus) == 0 {
+		return nil, nil, metrics.ErrNotFound
+	}
+
+	detail := &models.GPUDetail{GPUMetrics: gpus[0]}
+
+	queries, results, failures := c.queryMetrics(ctx, matchers,
+		[]string{"sm_clock", "memory_clock", "ecc_corrected", "ecc_uncorrected"},
+		map[string]string{"info": matchers.selector(c.schema.Metrics.Utilization).String()},
+	)
+	for metricType, resp := range results {
+		for _, result := range resp.Data.Result {
+			if metricType == "info" {
+				detail.DriverVersion = result.Metric[labels.DriverVersion]
+				detail.PCIBusID = result.Metric[labels.PCIBusID]
+				continue
+			}
+
+			value, ok := sampleValue(result.Value)
+			if !ok {
+				continue
+			}
+
+			switch metricType {
+			case "sm_clock":
+				detail.SMClock = value
+			case "memory_clock":
+				detail.MemoryClock = value
+			case "ecc_corrected":
+				detail.ECCCorrectedErrors = value
+			case "ecc_uncorrected":
+				detail.ECCUncorrectedErrors = value
+			}
+		}
+	}
+
+	delete(queries, "info")
+	delete(failures, "info")
+	delete(results, "info")
+	warnings = append(warnings, buildWarnings(queries, failures, seriesCounts(results), responseWarnings(results))...)
+
+	return detail, warnings, nil
+}
+
+// sampleValue parses the value of a Prometheus [timestamp, "value"] sample.
+func sampleValue(value []interface{}) (float64, bool) {
+	if len(value) < 2 {
+		return 0, false
+	}
+
+	valueStr, ok := value[1].(string)
+	if !ok {
+		return 0, false
+	}
+
+	v, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
@@ -0,0 +1,171 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"k8s-gpu-monitoring/internal/fakeprom"
+	"k8s-gpu-monitoring/internal/metrics"
+)
+
+func TestLabelMatchersString(t *testing.T) {
+	matchers := labelMatchers{"hostname": {`node"1\`}, "gpu_id": {"0"}}
+	want := `{gpu_id="0",hostname="node\"1\\"}`
+	if got := matchers.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	regex := labelMatchers{"gpu_name": {"NVIDIA A100", "Tesla T4 (PCIe)"}}
+	want = `{gpu_name=~"NVIDIA A100|Tesla T4 \\(PCIe\\)"}`
+	if got := regex.String(); got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	if got := labelMatchers(nil).String(); got != "" {
+		t.Errorf("expected empty matchers to render nothing, got %s", got)
+	}
+}
+
+// queryRecorder collects the queries received by a test server.
+type queryRecorder struct {
+	mu      sync.Mutex
+	queries []string
+}
+
+func (q *queryRecorder) add(query string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.queries = append(q.queries, query)
+}
+
+func (q *queryRecorder) all() []string {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return append([]string(nil), q.queries...)
+}
+
+// deviceFleet is a single A100, node1/0, at a constant 64% utilization.
+var deviceFleet = fakeprom.Fleet{
+	NodeNames:   []string{"node1"},
+	GPUsPerNode: 1,
+	Utilization: fakeprom.Constant(64),
+}
+
+func deviceServer(t *testing.T) (*fakeprom.Server, *httptest.Server) {
+	t.Helper()
+	return fakePrometheus(t, DefaultSchema(), deviceFleet)
+}
+
+func TestGetGPUDevice(t *testing.T) {
+	fake, server := deviceServer(t)
+
+	client := NewClient(server.URL)
+	device, _, err := client.GetGPUDevice(context.Background(), "node1", 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gpu := deviceFleet.GPUs()[0]
+	if device.Utilization != 64 || device.UUID != gpu.UUID {
+		t.Errorf("unexpected metrics: %+v", device.GPUMetrics)
+	}
+	if device.DriverVersion != gpu.DriverVersion || device.PCIBusID != gpu.PCIBusID {
+		t.Errorf("unexpected device info: driver=%q pci=%q", device.DriverVersion, device.PCIBusID)
+	}
+	// The SM clock scales with utilization between 30% and 100% of its maximum
+	if device.SMClock != 1055 || device.MemoryClock != gpu.Model.MemoryClock || device.ECCUncorrectedErrors != 0 {
+		t.Errorf("unexpected detail metrics: %+v", device)
+	}
+
+	for _, q := range fake.Queries() {
+		if strings.Contains(q, "kube_pod") {
+			continue
+		}
+		if !strings.Contains(q, `gpu_id="0"`) || !strings.Contains(q, `hostname="node1"`) {
+			t.Errorf("expected query to select the device, got %s", q)
+		}
+	}
+}
+
+func TestGetGPUDeviceNotFound(t *testing.T) {
+	_, server := deviceServer(t)
+
+	client := NewClient(server.URL)
+	if _, _, err := client.GetGPUDevice(context.Background(), "missing", 0); !errors.Is(err, metrics.ErrNotFound) {
+		t.Errorf("expected metrics.ErrNotFound, got %v", err)
+	}
+	if _, _, err := client.GetGPUNode(context.Background(), "missing"); !errors.Is(err, metrics.ErrNotFound) {
+		t.Errorf("expected metrics.ErrNotFound, got %v", err)
+	}
+}
+
+func TestGetGPUNode(t *testing.T) {
+	_, server := deviceServer(t)
+
+	client := NewClient(server.URL)
+	node, _, err := client.GetGPUNode(context.Background(), "node1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if node.NodeName != "node1" || node.GPUCount != 1 || len(node.GPUs) != 1 {
+		t.Errorf("unexpected node: %+v", node)
+	}
+	if len(node.GPUModels) != 1 || node.GPUModels[0] != fakeprom.DefaultModel {
+		t.Errorf("unexpected models: %v", node.GPUModels)
+	}
+	if node.AverageUtilization != 64 {
+		t.Errorf("expected average utilization 64, got %v", node.AverageUtilization)
+	}
+}
+
+func TestSelectGPUMetrics(t *testing.T) {
+	fake, server := deviceServer(t)
+
+	client := NewClient(server.URL)
+	gpus, _, err := client.SelectGPUMetrics(context.Background(), metrics.GPUSelector{
+		Nodes:    []string{"node1"},
+		GPUNames: []string{fakeprom.DefaultModel},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gpus) != 1 || gpus[0].NodeName != "node1" {
+		t.Fatalf("unexpected metrics: %+v", gpus)
+	}
+
+	for _, q := range fake.Queries() {
+		if strings.Contains(q, "kube_pod") {
+			continue
+		}
+		if !strings.Contains(q, `gpu_name="`+fakeprom.DefaultModel+`"`) || !strings.Contains(q, `hostname="node1"`) {
+			t.Errorf("expected selector to be pushed into the query, got %s", q)
+		}
+	}
+}
+
+func TestSelectGPUMetricsNamespaces(t *testing.T) {
+	var queries queryRecorder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.add(r.URL.Query().Get("query"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	schema, _ := LookupSchema(SchemaDCGMExporter)
+	client := NewClient(server.URL, WithSchema(schema))
+	if _, _, err := client.SelectGPUMetrics(context.Background(), metrics.GPUSelector{Namespaces: []string{"ml"}}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, q := range queries.all() {
+		if !strings.Contains(q, "kube_pod") && !strings.Contains(q, `namespace="ml"`) {
+			t.Errorf("expected namespace matcher in query, got %s", q)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/promql"
+	"k8s-gpu-monitoring/internal/workgroup"
+)
+
+// defaultMaxConcurrency bounds the queries a single call runs against Prometheus at once.
+const defaultMaxConcurrency = 4
+
+// batchQueryName is the queryAll name of the combined selector query.
+const batchQueryName = "__batch__"
+
+// WithMaxConcurrency limits how many queries a single call runs at once; n <= 0 removes the limit.
+func WithMaxConcurrency(n int) Option {
+	return func(c *Client) {
+		c.maxConcurrency = n
+	}
+}
+
+// WithQueryBatching fetches the plain metric selectors of a call in one
+// {__name__=~"..."} query instead of one query per metric.
+func WithQueryBatching() Option {
+	return func(c *Client) {
+		c.batchQueries = true
+	}
+}
+
+// fatal reports whether err means every other query of the call would fail the
+// same way, so that running them is pointless. A half-open breaker rejecting a
+// query is not fatal: canceling the others would abort the trial among them.
+func fatal(err error) bool {
+	if errors.Is(err, errTrialPending) {
+		return false
+	}
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden
+	}
+	return false
+}
+
+// queryAll executes named instant queries concurrently, returning the successful
+// responses and the per-query errors. At most maxConcurrency queries run at once,
+// and a fatal error cancels the queries still running or waiting.
+func (c *Client) queryAll(ctx context.Context, queries map[string]string) (map[string]*PrometheusResponse, map[string]error) {
+	return runQueries(ctx, c.maxConcurrency, "query", queries, c.Query)
+}
+
+// queryRangeAll executes named range queries between start and end like queryAll.
+func (c *Client) queryRangeAll(ctx context.Context, queries map[string]string, start, end time.Time, step time.Duration) (map[string]*PrometheusRangeResponse, map[string]error) {
+	return runQueries(ctx, c.maxConcurrency, "range query", queries, func(ctx context.Context, query string) (*PrometheusRangeResponse, error) {
+		return c.QueryRange(ctx, query, start, end, step)
+	})
+}
+
+// runQueries runs each named query with run, at most limit at once, as one
+// circuit breaker operation. Errors are reported per query, prefixed with kind,
+// and a fatal one cancels the queries still running or waiting.
+func runQueries[T any](ctx context.Context, limit int, kind string, queries map[string]string, run func(context.Context, string) (T, error)) (map[string]T, map[string]error) {
+	// The queries fail together when Prometheus is down, so the breaker counts them once
+	g, groupCtx := workgroup.WithContext(withOperation(ctx))
+	g.SetLimit(limit)
+
+	var mu sync.Mutex
+	results := make(map[string]T, len(queries))
+	failures := make(map[string]error)
+
+	for name, query := range queries {
+		g.Go(func() error {
+			if err := groupCtx.Err(); err != nil {
+				mu.Lock()
+				failures[name] = fmt.Errorf("%s %s failed: %w", kind, name, err)
+				mu.Unlock()
+				return nil
+			}
+
+			resp, err := run(groupCtx, query)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				failures[name] = fmt.Errorf("%s %s failed: %w", kind, name, err)
+				if fatal(err) {
+					return err
+				}
+				return nil
+			}
+			results[name] = resp
+			return nil
+		})
+	}
+
+	// Queries canceled because of a fatal error report that error, not the cancellation
+	if err := g.Wait(); err != nil && ctx.Err() == nil {
+		for name, failure := range failures {
+			if errors.Is(failure, context.Canceled) {
+				failures[name] = fmt.Errorf("%s %s failed: %w", kind, name, err)
+			}
+		}
+	}
+
+	return results, failures
+}
+
+// queryMetrics runs the selector of each metric type under matchers together
+// with the named expressions in extra. It returns the query run for each name,
+// the successful responses and the per-query errors. With batching enabled the
+// selectors share a single query whose series are split back out by metric name.
+func (c *Client) queryMetrics(ctx context.Context, matchers labelMatchers, metricTypes []string, extra map[string]string) (map[string]string, map[string]*PrometheusResponse, map[string]error) {
+	queries := c.schema.queries(matchers, metricTypes...)
+
+	// Metric types sharing a metric name are served by the same series
+	byMetric := make(map[string][]string)
+	for _, metricType := range metricTypes {
+		if metric := c.schema.metricName(metricType); metric != "" {
+			byMetric[metric] = append(byMetric[metric], metricType)
+		}
+	}
+
+	batch := ""
+	if c.batchQueries && len(byMetric) > 1 {
+		names := make([]string, 0, len(byMetric))
+		for metric := range byMetric {
+			names = append(names, metric)
+		}
+		batch = promql.Select("", promql.OneOf("__name__", names...)).Where(matchers.matchers()...).String()
+	}
+
+	toRun := make(map[string]string, len(queries)+len(extra)+1)
+	if batch != "" {
+		toRun[batchQueryName] = batch
+		for metricType := range queries {
+			queries[metricType] = batch
+		}
+	} else {
+		for name, query := range queries {
+			toRun[name] = query
+		}
+	}
+	for name, query := range extra {
+		queries[name] = query
+		toRun[name] = query
+	}
+
+	results, failures := c.queryAll(ctx, toRun)
+	if batch == "" {
+		return queries, results, failures
+	}
+
+	if err, ok := failures[batchQueryName]; ok {
+		delete(failures, batchQueryName)
+		for metricType := range queries {
+			if _, isExtra := extra[metricType]; !isExtra {
+				failures[metricType] = fmt.Errorf("query %s failed: %w", metricType, errors.Unwrap(err))
+			}
+		}
+		return queries, results, failures
+	}
+
+	resp := results[batchQueryName]
+	delete(results, batchQueryName)
+	for _, types := range byMetric {
+		for _, metricType := range types {
+			results[metricType] = &PrometheusResponse{Status: resp.Status}
+		}
+	}
+	for _, result := range resp.Data.Result {
+		for _, metricType := range byMetric[result.Metric["__name__"]] {
+			split := results[metricType]
+			split.Data.Result = append(split.Data.Result, result)
+		}
+	}
+
+	// Attribute Prometheus warnings to the first metric rather than every metric of the batch
+	if len(resp.Warnings) > 0 {
+		for _, metricType := range metricTypes {
+			if split, ok := results[metricType]; ok {
+				split.Warnings = resp.Warnings
+				break
+			}
+		}
+	}
+
+	return queries, results, failures
+}
@@ -0,0 +1,230 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestQueryAllLimit(t *testing.T) {
+	var running, peak atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		if r.URL.Path == "/api/v1/query_range" {
+			w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[]}}`))
+			return
+		}
+		w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+
+	queries := make(map[string]string)
+	for i := 0; i < 8; i++ {
+		queries[fmt.Sprintf("q%d", i)] = fmt.Sprintf("up%d", i)
+	}
+
+	results, failures := NewClient(server.URL, WithMaxConcurrency(2)).queryAll(context.Background(), queries)
+	if len(results) != 8 || len(failures) != 0 {
+		t.Fatalf("expected 8 results, got %d results and %v", len(results), failures)
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("expected at most 2 concurrent queries, got %d", got)
+	}
+
+	// The range queries of GetGPUMetricsRange share the limit
+	peak.Store(0)
+	client := NewClient(server.URL, WithMaxConcurrency(2))
+	if _, _, err := client.GetGPUMetricsRange(context.Background(), time.Unix(1700000000, 0), time.Unix(1700003600, 0), time.Minute); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := peak.Load(); got == 0 || got > 2 {
+		t.Errorf("expected at most 2 concurrent range queries, got %d", got)
+	}
+}
+
+func TestQueryAllCancelsOnFatalError(t *testing.T) {
+	var inFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") == "denied" {
+			time.Sleep(10 * time.Millisecond)
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		inFlight.Add(1)
+		defer inFlight.Add(-1)
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+			w.Write([]byte(emptyVector))
+		}
+	}))
+	defer server.Close()
+
+	queries := map[string]string{"denied": "denied", "slow1": "slow1", "slow2": "slow2", "slow3": "slow3"}
+	client := NewClient(server.URL, WithMaxConcurrency(len(queries)))
+
+	start := time.Now()
+	results, failures := client.queryAll(context.Background(), queries)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Fatalf("expected siblings to be canceled, took %s", elapsed)
+	}
+	if len(results) != 0 || len(failures) != 4 {
+		t.Fatalf("expected every query to fail, got %d results and %v", len(results), failures)
+	}
+	for name, err := range failures {
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected the 403 to be reported, got %v", name, err)
+		}
+	}
+
+	// No request outlives the call
+	deadline := time.Now().Add(time.Second)
+	for inFlight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if n := inFlight.Load(); n != 0 {
+		t.Errorf("expected no requests left running, got %d", n)
+	}
+}
+
+func TestQueryAllKeepsPartialResults(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("query") == "broken" {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+
+	results, failures := NewClient(server.URL).queryAll(context.Background(), map[string]string{"ok": "up", "broken": "broken"})
+	if len(results) != 1 || len(failures) != 1 || failures["broken"] == nil {
+		t.Errorf("expected a non-fatal error to leave siblings running, got %d results and %v", len(results), failures)
+	}
+}
+
+// batchServer answers the combined selector query with one series per metric
+// name and records the queries it receives.
+func batchServer(t *testing.T, queries *[]string, mu *sync.Mutex) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		mu.Lock()
+		*queries = append(*queries, query)
+		mu.Unlock()
+
+		if !strings.HasPrefix(query, `{__name__=~"`) {
+			w.Write([]byte(emptyVector))
+			return
+		}
+		w.Write([]byte(`{"status":"success","warnings":["partial response"],"data":{"resultType":"vector","result":[
+  {"metric":{"__name__":"nvidia_gpu_utilization_percent","hostname":"node1","gpu_id":"0"},"value":[1700000000,"50"]},
+  {"metric":{"__name__":"nvidia_gpu_temperature_celsius","hostname":"node1","gpu_id":"0"},"value":[1700000000,"60"]}]}}`))
+	}))
+}
+
+func TestQueryBatching(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	server := batchServer(t, &queries, &mu)
+	defer server.Close()
+
+	metrics, warnings, err := NewClient(server.URL, WithQueryBatching()).GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics) != 1 || metrics[0].Utilization != 50 || metrics[0].Temperature != 60 {
+		t.Fatalf("unexpected metrics %+v", metrics)
+	}
+
+	// One combined selector query plus the energy expression, besides owner attribution
+	gpuQueries := 0
+	for _, query := range queries {
+		if !strings.Contains(query, "kube_pod") {
+			gpuQueries++
+		}
+	}
+	if gpuQueries != 2 {
+		t.Errorf("expected 2 GPU queries, got %d: %v", gpuQueries, queries)
+	}
+
+	missing, passedThrough := 0, 0
+	for _, w := range warnings {
+		if w.Reason == "prometheus warning: partial response" {
+			passedThrough++
+		}
+		if w.Metric == "power_draw" {
+			missing++
+			if !strings.HasPrefix(w.Query, `{__name__=~"`) {
+				t.Errorf("expected the warning to show the combined query, got %q", w.Query)
+			}
+		}
+	}
+	if missing != 1 {
+		t.Errorf("expected a warning for the metric absent from the batch, got %+v", warnings)
+	}
+	if passedThrough != 1 {
+		t.Errorf("expected the Prometheus warning once, got %d", passedThrough)
+	}
+}
+
+func TestQueryBatchingFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Query().Get("query"), `{__name__=~"`) {
+			http.Error(w, "too many series", http.StatusUnprocessableEntity)
+			return
+		}
+		w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+
+	_, warnings, err := NewClient(server.URL, WithQueryBatching()).GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("expected the energy query to keep the call alive, got %v", err)
+	}
+	failed := make(map[string]bool)
+	for _, w := range warnings {
+		if strings.Contains(w.Reason, "422") {
+			failed[w.Metric] = true
+		}
+	}
+	if len(failed) != len(gpuMetricTypes) {
+		t.Errorf("expected every batched metric to report the failure, got %+v", warnings)
+	}
+}
+
+func TestConcurrentGetGPUMetrics(t *testing.T) {
+	var mu sync.Mutex
+	var queries []string
+	server := batchServer(t, &queries, &mu)
+	defer server.Close()
+
+	client := NewClient(server.URL, WithQueryBatching(), WithCache(time.Minute, 0), WithMaxConcurrency(1))
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := client.GetGPUMetrics(context.Background()); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,190 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/promql"
+)
+
+// idleTargetPoints is the number of samples per GPU aimed for over the lookback window.
+const idleTargetPoints = 240
+
+// GetIdleGPUs finds GPUs whose average and p95 utilization stayed at or below the
+// thresholds over the lookback window, ranked by wasted GPU-hours.
+func (c *Client) GetIdleGPUs(ctx context.Context, criteria metrics.IdleCriteria) (*models.IdleGPUReport, []models.Warning, error) {
+	if criteria.Lookback <= 0 {
+		return nil, nil, fmt.Errorf("lookback must be positive")
+	}
+
+	matchers, scope, ok := c.scopeQuery(ctx, nil)
+	if !ok {
+		return &models.IdleGPUReport{
+			Lookback:   criteria.Lookback.String(),
+			MaxAverage: criteria.MaxAverage,
+			MaxP95:     criteria.MaxP95,
+			GPUs:       []models.IdleGPU{},
+		}, nil, nil
+	}
+
+	step := (criteria.Lookback / idleTargetPoints).Truncate(time.Second)
+	if step < time.Second {
+		step = time.Second
+	}
+	end := time.Now()
+	start := end.Add(-criteria.Lookback)
+
+	// Average within each step so short bursts between samples are not missed
+	query := promql.AvgOverTime(matchers.selector(c.schema.Metrics.Utilization).Over(step)).String()
+	resp, err := c.QueryRange(ctx, query, start, end, step)
+	if err != nil {
+		return nil, nil, fmt.Errorf("querying utilization history: %w", err)
+	}
+
+	// Current readings and owners are best effort; the history alone identifies idle GPUs
+	current, warnings, err := c.GetGPUMetrics(ctx)
+	if err != nil {
+		warnings = append(warnings, models.Warning{Metric: "current", Reason: models.FailureReason(err)})
+	}
+	for _, message := range resp.Warnings {
+		warnings = append(warnings, models.Warning{Metric: "utilization", Query: query, Reason: "prometheus warning: " + message})
+	}
+
+	var allowed map[string]bool
+	if scope != nil {
+		labelSets := make([]map[string]string, len(resp.Data.Result))
+		for i, result := range resp.Data.Result {
+			labelSets[i] = result.Metric
+		}
+		allowed = c.allowedGPUs(ctx, *scope, labelSets)
+	}
+
+	gpus := c.findIdleGPUs(resp, current, criteria, step, allowed)
+
+	report := &models.IdleGPUReport{
+		Lookback:   criteria.Lookback.String(),
+		MaxAverage: criteria.MaxAverage,
+		MaxP95:     criteria.MaxP95,
+		GPUs:       gpus,
+	}
+	for _, gpu := range gpus {
+		report.TotalWastedGPUHours += gpu.WastedGPUHours
+	}
+
+	return report, warnings, nil
+}
+
+// idleSeries gathers the utilization samples of one GPU. Exporters that label
+// samples with the owning pod split a GPU into one series per owner.
+type idleSeries struct {
+	nodeName string
+	gpuIndex int
+	labels   map[string]string // of the series holding the latest sample
+	latest   time.Time
+	sums     map[time.Time]float64
+	counts   map[time.Time]int
+}
+
+// findIdleGPUs summarizes the utilization of each GPU and keeps the GPUs under
+// both thresholds. A non-nil allowed limits the result to the GPU keys it holds.
+func (c *Client) findIdleGPUs(resp *PrometheusRangeResponse, current []models.GPUMetrics, criteria metrics.IdleCriteria, step time.Duration, allowed map[string]bool) []models.IdleGPU {
+	byKey := make(map[string]models.GPUMetrics, len(current))
+	for _, m := range current {
+		byKey[gpuKey(m.NodeName, m.GPUIndex)] = m
+	}
+
+	labels := c.schema.Labels
+	var order []string
+	series := make(map[string]*idleSeries)
+	for _, result := range resp.Data.Result {
+		nodeName := result.Metric[labels.Node]
+		gpuIndex, err := strconv.Atoi(result.Metric[labels.GPUIndex])
+		key := gpuKey(nodeName, gpuIndex)
+		if nodeName == "" || err != nil || (allowed != nil && !allowed[key]) {
+			continue
+		}
+
+		points := parseSamplePoints(result.Values)
+		if len(points) == 0 {
+			continue
+		}
+
+		s := series[key]
+		if s == nil {
+			s = &idleSeries{
+				nodeName: nodeName,
+				gpuIndex: gpuIndex,
+				sums:     make(map[time.Time]float64),
+				counts:   make(map[time.Time]int),
+			}
+			series[key] = s
+			order = append(order, key)
+		}
+		for _, p := range points {
+			s.sums[p.Timestamp] += p.Value
+			s.counts[p.Timestamp]++
+		}
+		if last := points[len(points)-1].Timestamp; s.labels == nil || last.After(s.latest) {
+			s.labels, s.latest = result.Metric, last
+		}
+	}
+
+	gpus := make([]models.IdleGPU, 0)
+	for _, key := range order {
+		s := series[key]
+		// Samples reported by several series at once are averaged
+		values := make([]float64, 0, len(s.sums))
+		for ts, sum := range s.sums {
+			values = append(values, sum/float64(s.counts[ts]))
+		}
+		average, p95 := models.UtilizationSummary(values)
+		if average > criteria.MaxAverage || p95 > criteria.MaxP95 {
+			continue
+		}
+
+		metrics, found := byKey[key]
+		if !found {
+			// The GPU has no current readings, e.g. its node left the cluster
+			metrics = models.GPUMetrics{
+				NodeName: s.nodeName,
+				GPUIndex: s.gpuIndex,
+				GPUName:  s.labels[labels.GPUName],
+				UUID:     s.labels[labels.UUID],
+			}
+		}
+		// The pod that held the GPU while it idled is charged, not whoever holds it now
+		if owner := models.OwnerFromLabels(s.labels, labels.Pod, labels.Namespace, labels.Container); owner != nil {
+			metrics.Owner = owner
+		}
+		if criteria.AllocatedOnly && metrics.Owner == nil {
+			continue
+		}
+
+		observed := math.Min(float64(len(values))*step.Hours(), criteria.Lookback.Hours())
+		gpus = append(gpus, models.IdleGPU{
+			GPUMetrics:         metrics,
+			AverageUtilization: average,
+			P95Utilization:     p95,
+			ObservedHours:      observed,
+			WastedGPUHours:     observed * (100 - average) / 100,
+		})
+	}
+
+	sort.Slice(gpus, func(i, j int) bool {
+		if gpus[i].WastedGPUHours != gpus[j].WastedGPUHours {
+			return gpus[i].WastedGPUHours > gpus[j].WastedGPUHours
+		}
+		if gpus[i].NodeName != gpus[j].NodeName {
+			return gpus[i].NodeName < gpus[j].NodeName
+		}
+		return gpus[i].GPUIndex < gpus[j].GPUIndex
+	})
+
+	return gpus
+}
@@ -0,0 +1,111 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// idleMatrixBody holds three GPUs: node1/0 idle, node1/1 busy, node2/0 idle with a burst above the p95 threshold.
+const idleMatrixBody = `{"status":"success","data":{"resultType":"matrix","result":[
+  {"metric":{"hostname":"node1","gpu_id":"0"},"values":[[1700000000,"0"],[1700000060,"2"],[1700000120,"1"],[1700000180,"1"]]},
+  {"metric":{"hostname":"node1","gpu_id":"1"},"values":[[1700000000,"80"],[1700000060,"90"]]},
+  {"metric":{"hostname":"node2","gpu_id":"0","gpu_name":"Tesla T4"},"values":[[1700000000,"0"],[1700000060,"0"],[1700000120,"0"],[1700000180,"60"]]}
+]}}`
+
+func TestGetIdleGPUs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query().Get("query")
+		if r.URL.Path == "/api/v1/query_range" {
+			if !strings.HasPrefix(query, "avg_over_time(nvidia_gpu_utilization_percent[") {
+				t.Errorf("unexpected range query %s", query)
+			}
+			w.Write([]byte(idleMatrixBody))
+			return
+		}
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"hostname":"node1","gpu_id":"0","gpu_name":"NVIDIA A100"},"value":[1700000180,"1"]}]}}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	criteria := metrics.IdleCriteria{Lookback: 4 * time.Hour, MaxAverage: 5, MaxP95: 10}
+	report, _, err := client.GetIdleGPUs(context.Background(), criteria)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.GPUs) != 1 {
+		t.Fatalf("expected only node1/0 to be idle, got %+v", report.GPUs)
+	}
+
+	gpu := report.GPUs[0]
+	if gpu.NodeName != "node1" || gpu.GPUIndex != 0 || gpu.GPUName != "NVIDIA A100" {
+		t.Errorf("expected current readings to be merged, got %+v", gpu.GPUMetrics)
+	}
+	if gpu.AverageUtilization != 1 || gpu.P95Utilization != 2 {
+		t.Errorf("unexpected summary: avg=%v p95=%v", gpu.AverageUtilization, gpu.P95Utilization)
+	}
+	// Four one-minute samples observed
+	if want := 4.0 / 60; gpu.ObservedHours != want || gpu.WastedGPUHours != want*0.99 {
+		t.Errorf("unexpected hours: observed=%v wasted=%v", gpu.ObservedHours, gpu.WastedGPUHours)
+	}
+	if report.TotalWastedGPUHours != gpu.WastedGPUHours {
+		t.Errorf("unexpected total: %v", report.TotalWastedGPUHours)
+	}
+
+	criteria.AllocatedOnly = true
+	report, _, err = client.GetIdleGPUs(context.Background(), criteria)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.GPUs) != 0 {
+		t.Errorf("expected unowned GPUs to be excluded, got %+v", report.GPUs)
+	}
+}
+
+func TestFindIdleGPUsMergesOwnerSeries(t *testing.T) {
+	// dcgm-exporter reports node1/0 as one series per pod that held it
+	var resp PrometheusRangeResponse
+	body := `{"status":"success","data":{"resultType":"matrix","result":[
+  {"metric":{"Hostname":"node1","gpu":"0","pod":"train-a","namespace":"ml"},"values":[[1700000000,"0"],[1700000060,"2"]]},
+  {"metric":{"Hostname":"node1","gpu":"0","pod":"train-b","namespace":"ml"},"values":[[1700000120,"1"],[1700000180,"1"]]}
+]}}`
+	if err := json.Unmarshal([]byte(body), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+
+	schema, _ := LookupSchema(SchemaDCGMExporter)
+	client := NewClient("http://unused", WithSchema(schema))
+	current := []models.GPUMetrics{{
+		NodeName: "node1",
+		GPUIndex: 0,
+		GPUName:  "NVIDIA A100",
+		Owner:    &models.GPUOwner{Namespace: "ml", Pod: "train-c", Source: "exporter"},
+	}}
+	criteria := metrics.IdleCriteria{Lookback: 4 * time.Hour, MaxAverage: 5, MaxP95: 10, AllocatedOnly: true}
+
+	gpus := client.findIdleGPUs(&resp, current, criteria, time.Minute, nil)
+	if len(gpus) != 1 {
+		t.Fatalf("expected one entry for node1/0, got %+v", gpus)
+	}
+	gpu := gpus[0]
+	if gpu.AverageUtilization != 1 || gpu.P95Utilization != 2 {
+		t.Errorf("unexpected summary: avg=%v p95=%v", gpu.AverageUtilization, gpu.P95Utilization)
+	}
+	if want := 4.0 / 60; gpu.ObservedHours != want {
+		t.Errorf("expected samples of both series to be observed, got %v hours", gpu.ObservedHours)
+	}
+	if gpu.Owner == nil || gpu.Owner.Pod != "train-b" {
+		t.Errorf("expected the pod holding the GPU while idle to be charged, got %+v", gpu.Owner)
+	}
+	if gpu.GPUName != "NVIDIA A100" {
+		t.Errorf("expected current readings to be merged, got %+v", gpu.GPUMetrics)
+	}
+}
@@ -0,0 +1,227 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/promql"
+)
+
+// gpuResourceRequestsQuery selects GPU requests of running containers from kube-state-metrics.
+var gpuResourceRequestsQuery = promql.Mul(
+	promql.Gt(promql.Select("kube_pod_container_resource_requests", promql.Eq("resource", "nvidia.com/gpu")), promql.Number(0)),
+	promql.Eql(promql.Select("kube_pod_status_phase", promql.Eq("phase", "Running")), promql.Number(1)),
+).On("namespace", "pod").GroupLeft().String()
+
+// podGPURequest is a container's nvidia.com/gpu request as reported by kube-state-metrics.
+type podGPURequest struct {
+	Namespace string
+	Pod       string
+	Container string
+	NodeName  string
+	Count     int
+}
+
+// getPodGPURequests retrieves GPU requests of running pods from kube-state-metrics.
+func (c *Client) getPodGPURequests(ctx context.Context) ([]podGPURequest, error) {
+	resp, err := c.Query(ctx, gpuResourceRequestsQuery)
+	if err != nil {
+		return nil, fmt.Errorf("getting pod GPU requests: %w", err)
+	}
+
+	var requests []podGPURequest
+	for _, result := range resp.Data.Result {
+		if len(result.Value) < 2 {
+			continue
+		}
+
+		valueStr, ok := result.Value[1].(string)
+		if !ok {
+			continue
+		}
+
+		count, err := strconv.ParseFloat(valueStr, 64)
+		if err != nil || count <= 0 {
+			continue
+		}
+
+		requests = append(requests, podGPURequest{
+			Namespace: result.Metric["namespace"],
+			Pod:       result.Metric["pod"],
+			Container: result.Metric["container"],
+			NodeName:  result.Metric["node"],
+			Count:     int(count),
+		})
+	}
+
+	return requests, nil
+}
+
+// attributeOwners fills in owners for GPUs the exporter did not label, using kube-state-metrics
+// requests as a fallback. It is best effort: clusters without kube-state-metrics keep unowned GPUs.
+func (c *Client) attributeOwners(ctx context.Context, metrics []models.GPUMetrics) {
+	unowned := false
+	for _, m := range metrics {
+		if m.Owner == nil {
+			unowned = true
+			break
+		}
+	}
+	if !unowned {
+		return
+	}
+
+	requests, err := c.getPodGPURequests(ctx)
+	if err != nil {
+		return
+	}
+
+	applyRequestOwners(metrics, requests)
+}
+
+// applyRequestOwners assigns owners from resource requests to unowned GPUs.
+// Requests do not say which device a pod received, so the GPUs of a node are
+// only attributed when a single pod holds GPU requests there and it requested
+// exactly as many GPUs as the node has unowned.
+func applyRequestOwners(metrics []models.GPUMetrics, requests []podGPURequest) {
+	podsByNode := make(map[string][]podGPURequest)
+	for _, req := range requests {
+		if req.NodeName == "" {
+			continue
+		}
+
+		// Containers of the same pod add up to the pod's request
+		pods := podsByNode[req.NodeName]
+		duplicate := false
+		for i := range pods {
+			if pods[i].Namespace == req.Namespace && pods[i].Pod == req.Pod {
+				pods[i].Count += req.Count
+				duplicate = true
+				break
+			}
+		}
+		if !duplicate {
+			podsByNode[req.NodeName] = append(pods, req)
+		}
+	}
+
+	unowned := make(map[string]int)
+	for _, m := range metrics {
+		if m.Owner == nil {
+			unowned[m.NodeName]++
+		}
+	}
+
+	for i := range metrics {
+		if metrics[i].Owner != nil {
+			continue
+		}
+
+		pods := podsByNode[metrics[i].NodeName]
+		if len(pods) != 1 || pods[0].Count != unowned[metrics[i].NodeName] {
+			continue
+		}
+
+		metrics[i].Owner = &models.GPUOwner{
+			Namespace: pods[0].Namespace,
+			Pod:       pods[0].Pod,
+			Container: pods[0].Container,
+			Source:    models.OwnerSourceResourceRequest,
+		}
+	}
+}
+
+// GetGPUPods retrieves the GPUs held by each pod together with their current usage.
+func (c *Client) GetGPUPods(ctx context.Context) ([]models.GPUPodAllocation, []models.Warning, error) {
+	gpus, warnings, err := c.GetGPUMetrics(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// kube-state-metrics is optional; exporter labels alone still yield allocations
+	requests, err := c.getPodGPURequests(ctx)
+	if err != nil {
+		requests = nil
+	}
+
+	if scope, scoped := metrics.ScopeFrom(ctx); scoped {
+		visible := requests[:0]
+		for _, req := range requests {
+			if scope.Allows(req.NodeName, &models.GPUOwner{Namespace: req.Namespace, Pod: req.Pod}) {
+				visible = append(visible, req)
+			}
+		}
+		requests = visible
+	}
+
+	return buildPodAllocations(gpus, requests), warnings, nil
+}
+
+// buildPodAllocations groups attributed GPUs by pod container and merges in requested GPU counts.
+func buildPodAllocations(metrics []models.GPUMetrics, requests []podGPURequest) []models.GPUPodAllocation {
+	allocations := make(map[string]*models.GPUPodAllocation) // key: "namespace/pod/container"
+
+	get := func(namespace, pod, container, node string) *models.GPUPodAllocation {
+		key := fmt.Sprintf("%s/%s/%s", namespace, pod, container)
+		if allocations[key] == nil {
+			allocations[key] = &models.GPUPodAllocation{
+				Namespace: namespace,
+				Pod:       pod,
+				Container: container,
+				NodeName:  node,
+				GPUs:      make([]models.GPURef, 0),
+			}
+		}
+		return allocations[key]
+	}
+
+	for _, m := range metrics {
+		if m.Owner == nil {
+			continue
+		}
+
+		alloc := get(m.Owner.Namespace, m.Owner.Pod, m.Owner.Container, m.NodeName)
+		alloc.GPUs = append(alloc.GPUs, models.GPURef{
+			NodeName: m.NodeName,
+			GPUIndex: m.GPUIndex,
+			UUID:     m.UUID,
+		})
+		alloc.AverageUtilization += m.Utilization
+		alloc.MemoryUsed += m.MemoryUsed
+	}
+
+	for _, req := range requests {
+		alloc := get(req.Namespace, req.Pod, req.Container, req.NodeName)
+		alloc.RequestedGPUs += req.Count
+	}
+
+	result := make([]models.GPUPodAllocation, 0, len(allocations))
+	for _, alloc := range allocations {
+		if len(alloc.GPUs) > 0 {
+			alloc.AverageUtilization /= float64(len(alloc.GPUs))
+		}
+		sort.Slice(alloc.GPUs, func(i, j int) bool {
+			if alloc.GPUs[i].NodeName != alloc.GPUs[j].NodeName {
+				return alloc.GPUs[i].NodeName < alloc.GPUs[j].NodeName
+			}
+			return alloc.GPUs[i].GPUIndex < alloc.GPUs[j].GPUIndex
+		})
+		result = append(result, *alloc)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		if result[i].Pod != result[j].Pod {
+			return result[i].Pod < result[j].Pod
+		}
+		return result[i].Container < result[j].Container
+	})
+
+	return result
+}
@@ -0,0 +1,88 @@
+package prometheus
+
+import (
+	"testing"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+func TestApplyRequestOwners(t *testing.T) {
+	metrics := []models.GPUMetrics{
+		{NodeName: "node1", GPUIndex: 0},
+		{NodeName: "node1", GPUIndex: 1, Owner: &models.GPUOwner{Namespace: "ml", Pod: "labelled", Source: models.OwnerSourceExporter}},
+		{NodeName: "node2", GPUIndex: 0},
+		{NodeName: "node3", GPUIndex: 0},
+		{NodeName: "node4", GPUIndex: 0},
+		{NodeName: "node4", GPUIndex: 1},
+		{NodeName: "node4", GPUIndex: 2},
+		{NodeName: "node5", GPUIndex: 0},
+		{NodeName: "node5", GPUIndex: 1},
+	}
+	requests := []podGPURequest{
+		{Namespace: "ml", Pod: "solo", Container: "main", NodeName: "node1", Count: 1},
+		{Namespace: "a", Pod: "p1", NodeName: "node2", Count: 1},
+		{Namespace: "b", Pod: "p2", NodeName: "node2", Count: 1},
+		{Namespace: "ml", Pod: "small", Container: "main", NodeName: "node4", Count: 1},
+		{Namespace: "ml", Pod: "pair", Container: "main", NodeName: "node5", Count: 1},
+		{Namespace: "ml", Pod: "pair", Container: "sidecar", NodeName: "node5", Count: 1},
+	}
+
+	applyRequestOwners(metrics, requests)
+
+	if o := metrics[0].Owner; o == nil || o.Pod != "solo" || o.Source != models.OwnerSourceResourceRequest {
+		t.Errorf("expected single requesting pod to be attributed, got %+v", o)
+	}
+	if o := metrics[1].Owner; o.Pod != "labelled" {
+		t.Errorf("expected exporter owner to be kept, got %+v", o)
+	}
+	if metrics[2].Owner != nil {
+		t.Errorf("expected ambiguous node to stay unattributed, got %+v", metrics[2].Owner)
+	}
+	if metrics[3].Owner != nil {
+		t.Errorf("expected node without requests to stay unattributed, got %+v", metrics[3].Owner)
+	}
+	for _, m := range metrics[4:7] {
+		if m.Owner != nil {
+			t.Errorf("expected GPUs beyond the single pod's request to stay unattributed, got %+v on GPU %d", m.Owner, m.GPUIndex)
+		}
+	}
+	for _, m := range metrics[7:] {
+		if m.Owner == nil || m.Owner.Pod != "pair" {
+			t.Errorf("expected a pod whose containers request every GPU to be attributed, got %+v on GPU %d", m.Owner, m.GPUIndex)
+		}
+	}
+}
+
+func TestBuildPodAllocations(t *testing.T) {
+	owner := &models.GPUOwner{Namespace: "ml", Pod: "trainer-0", Container: "main", Source: models.OwnerSourceExporter}
+	metrics := []models.GPUMetrics{
+		{NodeName: "node1", GPUIndex: 1, Utilization: 80, MemoryUsed: 10, Owner: owner},
+		{NodeName: "node1", GPUIndex: 0, Utilization: 40, MemoryUsed: 6, Owner: owner},
+		{NodeName: "node1", GPUIndex: 2, Utilization: 0},
+	}
+	requests := []podGPURequest{
+		{Namespace: "ml", Pod: "trainer-0", Container: "main", NodeName: "node1", Count: 2},
+		{Namespace: "dev", Pod: "notebook", Container: "jupyter", NodeName: "node2", Count: 1},
+	}
+
+	allocations := buildPodAllocations(metrics, requests)
+	if len(allocations) != 2 {
+		t.Fatalf("expected 2 allocations, got %d", len(allocations))
+	}
+
+	notebook := allocations[0]
+	if notebook.Pod != "notebook" || notebook.RequestedGPUs != 1 || len(notebook.GPUs) != 0 {
+		t.Errorf("unexpected request-only allocation: %+v", notebook)
+	}
+
+	trainer := allocations[1]
+	if trainer.RequestedGPUs != 2 || len(trainer.GPUs) != 2 {
+		t.Fatalf("unexpected trainer allocation: %+v", trainer)
+	}
+	if trainer.GPUs[0].GPUIndex != 0 || trainer.GPUs[1].GPUIndex != 1 {
+		t.Errorf("expected GPUs sorted by index, got %+v", trainer.GPUs)
+	}
+	if trainer.AverageUtilization != 60 || trainer.MemoryUsed != 16 {
+		t.Errorf("unexpected usage: avg=%v mem=%v", trainer.AverageUtilization, trainer.MemoryUsed)
+	}
+}
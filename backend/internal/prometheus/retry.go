@@ -0,0 +1,149 @@
+package prometheus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures retries of requests that failed with a network error or a
+// retryable status (429, 502, 503, 504).
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts; values below 2 disable retries.
+	MaxAttempts int
+	// InitialBackoff is the upper bound of the first jittered wait, doubling per retry up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// DefaultRetryPolicy makes up to three attempts, waiting at most 200ms and then 400ms.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 200 * time.Millisecond,
+		MaxBackoff:     2 * time.Second,
+	}
+}
+
+// WithRetry retries failed requests according to policy.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.retry = policy
+	}
+}
+
+// backoff returns the jittered wait before retry number attempt (starting at 1).
+// Full jitter spreads retries from many callers instead of synchronizing them.
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	ceiling := p.InitialBackoff
+	for i := 1; i < attempt && ceiling < p.MaxBackoff; i++ {
+		ceiling *= 2
+	}
+	if p.MaxBackoff > 0 && ceiling > p.MaxBackoff {
+		ceiling = p.MaxBackoff
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(ceiling) + 1))
+}
+
+// retryable reports whether err indicates Prometheus was unavailable rather than
+// rejecting the query, so that trying again may succeed.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// get fetches requestURL through the circuit breaker, retrying transient failures,
+// and returns the body of a 200 response.
+func (c *Client) get(ctx context.Context, requestURL string) ([]byte, error) {
+	if c.breaker != nil {
+		if err := c.breaker.allow(); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := c.getWithRetry(ctx, requestURL)
+
+	if c.breaker != nil {
+		c.breaker.record(ctx, err)
+	}
+	return body, err
+}
+
+// getWithRetry fetches requestURL, retrying retryable failures with jittered
+// exponential backoff while the context deadline leaves time to wait.
+func (c *Client) getWithRetry(ctx context.Context, requestURL string) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		body, err := c.getOnce(ctx, requestURL)
+		if err == nil || attempt >= c.retry.MaxAttempts || !retryable(err) {
+			return body, err
+		}
+
+		wait := c.retry.backoff(attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return nil, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, err
+		case <-timer.C:
+		}
+	}
+}
+
+// getOnce makes a single request and returns the body of a 200 response.
+func (c *Client) getOnce(ctx context.Context, requestURL string) ([]byte, error) {
+	req, err := c.newRequest(ctx, requestURL)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("executing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		// Prometheus describes rejected queries in a JSON error payload
+		var payload struct {
+			ErrorType string `json:"errorType"`
+			Error     string `json:"error"`
+		}
+		if json.Unmarshal(body, &payload) == nil && payload.ErrorType != "" {
+			return nil, &APIError{StatusCode: resp.StatusCode, ErrorType: payload.ErrorType, Message: payload.Error}
+		}
+		return nil, &APIError{StatusCode: resp.StatusCode, Message: string(body)}
+	}
+
+	return body, nil
+}
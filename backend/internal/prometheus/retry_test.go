@@ -0,0 +1,275 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// flakyServer fails the first failures requests with status, then serves an empty vector.
+func flakyServer(t *testing.T, status, failures int, requests *atomic.Int32) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if int(requests.Add(1)) <= failures {
+			http.Error(w, "unavailable", status)
+			return
+		}
+		w.Write([]byte(emptyVector))
+	}))
+}
+
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 5 * time.Millisecond}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		failures     int
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"transient 503 recovers", http.StatusServiceUnavailable, 2, false, 3},
+		{"exhausted attempts", http.StatusServiceUnavailable, 5, true, 3},
+		{"bad query is not retried", http.StatusBadRequest, 5, true, 1},
+		{"rate limited recovers", http.StatusTooManyRequests, 1, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			server := flakyServer(t, tt.status, tt.failures, &requests)
+			defer server.Close()
+
+			_, err := NewClient(server.URL, WithRetry(fastRetry)).Query(context.Background(), "up")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("got %d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestRetryRespectsDeadline(t *testing.T) {
+	var requests atomic.Int32
+	server := flakyServer(t, http.StatusServiceUnavailable, 10, &requests)
+	defer server.Close()
+
+	// The backoff cannot fit in the remaining time, so the first error is returned at once
+	client := NewClient(server.URL, WithRetry(RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Hour, MaxBackoff: time.Hour}))
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err := client.Query(ctx, "up")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("expected the last 503, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected to give up before the deadline, took %s", elapsed)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	ceilings := map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 10: 300 * time.Millisecond}
+	for attempt, ceiling := range ceilings {
+		for i := 0; i < 50; i++ {
+			if wait := policy.backoff(attempt); wait < 0 || wait > ceiling {
+				t.Fatalf("attempt %d: wait %s outside [0, %s]", attempt, wait, ceiling)
+			}
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var requests atomic.Int32
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		if !healthy.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithCircuitBreaker(BreakerPolicy{FailureThreshold: 2, OpenTimeout: time.Minute}))
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := client.Query(context.Background(), "up"); err == nil {
+			t.Fatal("expected failure while Prometheus is down")
+		}
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerOpen || status.OpenedAt == nil {
+		t.Fatalf("expected open breaker, got %+v", status)
+	}
+
+	// While open, requests fail fast without reaching Prometheus
+	before := requests.Load()
+	_, err := client.Query(context.Background(), "up")
+	if !errors.Is(err, ErrCircuitOpen) || requests.Load() != before {
+		t.Fatalf("expected fast failure, got %v", err)
+	}
+	if reason := models.FailureReason(err); !strings.Contains(reason, "circuit breaker") {
+		t.Errorf("unexpected reason %q", reason)
+	}
+
+	// After the timeout a failed trial reopens the breaker
+	now = now.Add(2 * time.Minute)
+	if _, err := client.Query(context.Background(), "up"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the trial to reach Prometheus, got %v", err)
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerOpen {
+		t.Fatalf("expected failed trial to reopen the breaker, got %+v", status)
+	}
+
+	// A successful trial closes it
+	healthy.Store(true)
+	now = now.Add(2 * time.Minute)
+	if _, err := client.Query(context.Background(), "up"); err != nil {
+		t.Fatalf("expected the trial to succeed, got %v", err)
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerClosed || status.ConsecutiveFailures != 0 {
+		t.Errorf("expected closed breaker, got %+v", status)
+	}
+}
+
+func TestBreakerCountsFanOutOnce(t *testing.T) {
+	var requests atomic.Int32
+	server := flakyServer(t, http.StatusServiceUnavailable, 1000, &requests)
+	defer server.Close()
+
+	client := NewClient(server.URL, WithCircuitBreaker(DefaultBreakerPolicy()), WithMaxConcurrency(0))
+	queries := make(map[string]string)
+	for _, name := range gpuMetricTypes {
+		queries[name] = "up"
+	}
+
+	_, failures := client.queryAll(context.Background(), queries)
+	if len(failures) != len(queries) {
+		t.Fatalf("expected every query to fail, got %d failures", len(failures))
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerClosed || status.ConsecutiveFailures != 1 {
+		t.Fatalf("expected one failed fan-out to count once, got %+v", status)
+	}
+
+	for i := 1; i < DefaultBreakerPolicy().FailureThreshold; i++ {
+		client.queryAll(context.Background(), queries)
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerOpen {
+		t.Errorf("expected the threshold of failed fan-outs to open the breaker, got %+v", status)
+	}
+}
+
+func TestBreakerIgnoresQueryErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error"}`))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithCircuitBreaker(BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute}))
+	for i := 0; i < 3; i++ {
+		_, err := client.Query(context.Background(), "up{")
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.ErrorType != "bad_data" || apiErr.Message != "parse error" {
+			t.Fatalf("expected the query error payload, got %v", err)
+		}
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerClosed {
+		t.Errorf("expected rejected queries to leave the breaker closed, got %+v", status)
+	}
+}
+
+func TestBreakerOpensOnServerErrors(t *testing.T) {
+	var requests atomic.Int32
+	server := flakyServer(t, http.StatusInternalServerError, 1000, &requests)
+	defer server.Close()
+
+	client := NewClient(server.URL, WithCircuitBreaker(BreakerPolicy{FailureThreshold: 3, OpenTimeout: time.Minute}))
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		client.Query(context.Background(), "up")
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerOpen || status.ConsecutiveFailures != 3 {
+		t.Fatalf("expected a run of 500s to open the breaker, got %+v", status)
+	}
+
+	// A 500 during half-open reopens it rather than closing it
+	now = now.Add(2 * time.Minute)
+	if _, err := client.Query(context.Background(), "up"); err == nil || errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("expected the trial to reach Prometheus, got %v", err)
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerOpen {
+		t.Errorf("expected a failed trial to reopen the breaker, got %+v", status)
+	}
+}
+
+func TestPrometheusWarningsPassedThrough(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"success","warnings":["partial response: store unavailable"],"data":{"resultType":"vector","result":[
+  {"metric":{"hostname":"node1","gpu_id":"0"},"value":[1700000000,"50"]}]}}`))
+	}))
+	defer server.Close()
+
+	_, warnings, err := NewClient(server.URL).GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	found := false
+	for _, w := range warnings {
+		if w.Reason == "prometheus warning: partial response: store unavailable" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected Prometheus warning to be passed through, got %+v", warnings)
+	}
+}
+
+func TestBreakerHalfOpenFanOut(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		// Hold the trial so its siblings reach the half-open breaker meanwhile
+		time.Sleep(50 * time.Millisecond)
+		w.Write([]byte(emptyVector))
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, WithCircuitBreaker(BreakerPolicy{FailureThreshold: 1, OpenTimeout: time.Minute}), WithMaxConcurrency(0))
+	now := time.Now()
+	client.breaker.now = func() time.Time { return now }
+	client.breaker.state = BreakerOpen
+	client.breaker.openedAt = now.Add(-2 * time.Minute)
+
+	queries := map[string]string{"a": "a", "b": "b", "c": "c", "d": "d"}
+	results, failures := client.queryAll(context.Background(), queries)
+	if len(results) != 1 || requests.Load() != 1 {
+		t.Fatalf("expected only the trial to reach Prometheus, got %d results and %d requests", len(results), requests.Load())
+	}
+	for name, err := range failures {
+		if !errors.Is(err, ErrCircuitOpen) {
+			t.Errorf("%s: expected the half-open breaker to reject it, got %v", name, err)
+		}
+	}
+	if status, _ := client.BreakerStatus(); status.State != BreakerClosed {
+		t.Errorf("expected the successful trial to close the breaker, got %+v", status)
+	}
+}
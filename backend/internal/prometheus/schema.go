@@ -0,0 +1,357 @@
+package prometheus
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"k8s-gpu-monitoring/internal/promql"
+)
+
+// Built-in schema profile names.
+const (
+	SchemaNvidiaGPUExporter = "nvidia_gpu_exporter"
+	SchemaDCGMExporter      = "dcgm"
+)
+
+// MetricNames holds the exporter-specific metric names for each GPU metric.
+// An empty name means the exporter does not provide the metric.
+type MetricNames struct {
+	Utilization       string `json:"utilization"`
+	MemoryUsed        string `json:"memory_used"`
+	MemoryTotal       string `json:"memory_total"`
+	MemoryFree        string `json:"memory_free"`
+	MemoryUtilization string `json:"memory_utilization"`
+	Temperature       string `json:"temperature"`
+	PowerDraw         string `json:"power_draw"`
+	PowerLimit        string `json:"power_limit"`
+	// EnergyTotal is a monotonically increasing energy counter, if the exporter provides one.
+	EnergyTotal string `json:"energy_total"`
+	// SMClock and MemoryClock are reported in MHz; the ECC metrics are error counters.
+	SMClock        string `json:"sm_clock"`
+	MemoryClock    string `json:"memory_clock"`
+	ECCCorrected   string `json:"ecc_corrected"`
+	ECCUncorrected string `json:"ecc_uncorrected"`
+}
+
+// LabelNames holds the exporter-specific label names identifying a GPU.
+type LabelNames struct {
+	Node     string `json:"node"`
+	GPUIndex string `json:"gpu_index"`
+	GPUName  string `json:"gpu_name"`
+	UUID     string `json:"uuid"`
+	// Pod, Namespace and Container identify the workload owning the GPU, when the exporter provides them.
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Container string `json:"container"`
+	// DriverVersion and PCIBusID are informational labels shown on device detail.
+	DriverVersion string `json:"driver_version"`
+	PCIBusID      string `json:"pci_bus_id"`
+}
+
+// Schema describes how an exporter names its GPU metrics and labels.
+type Schema struct {
+	Name    string      `json:"name"`
+	Metrics MetricNames `json:"metrics"`
+	Labels  LabelNames  `json:"labels"`
+	// MemoryBytesPerUnit converts the exporter's memory unit to bytes (1 for bytes, 1048576 for MiB).
+	MemoryBytesPerUnit float64 `json:"memory_bytes_per_unit"`
+	// EnergyJoulesPerUnit converts the energy counter's unit to joules (0.001 for millijoules).
+	EnergyJoulesPerUnit float64 `json:"energy_joules_per_unit"`
+}
+
+// builtinSchemas contains the profiles selectable by name.
+var builtinSchemas = map[string]Schema{
+	SchemaNvidiaGPUExporter: {
+		Name: SchemaNvidiaGPUExporter,
+		Metrics: MetricNames{
+			Utilization:       "nvidia_gpu_utilization_percent",
+			MemoryUsed:        "nvidia_gpu_used_memory_bytes",
+			MemoryTotal:       "nvidia_gpu_total_memory_bytes",
+			MemoryFree:        "nvidia_gpu_free_memory_bytes",
+			MemoryUtilization: "nvidia_gpu_memory_utilization_percent",
+			Temperature:       "nvidia_gpu_temperature_celsius",
+			PowerDraw:         "nvidia_gpu_power_draw_watts",
+			PowerLimit:        "nvidia_gpu_power_limit_watts",
+			SMClock:           "nvidia_gpu_sm_clock_mhz",
+			MemoryClock:       "nvidia_gpu_memory_clock_mhz",
+			ECCCorrected:      "nvidia_gpu_ecc_corrected_errors_total",
+			ECCUncorrected:    "nvidia_gpu_ecc_uncorrected_errors_total",
+		},
+		Labels: LabelNames{
+			Node:          "hostname",
+			GPUIndex:      "gpu_id",
+			GPUName:       "gpu_name",
+			UUID:          "uuid",
+			DriverVersion: "driver_version",
+			PCIBusID:      "pci_bus_id",
+		},
+		MemoryBytesPerUnit:  1,
+		EnergyJoulesPerUnit: 1,
+	},
+	SchemaDCGMExporter: {
+		Name: SchemaDCGMExporter,
+		Metrics: MetricNames{
+			Utilization: "DCGM_FI_DEV_GPU_UTIL",
+			MemoryUsed:  "DCGM_FI_DEV_FB_USED",
+			// dcgm-exporter has no total framebuffer metric; it is derived from used + free.
+			MemoryTotal:       "",
+			MemoryFree:        "DCGM_FI_DEV_FB_FREE",
+			MemoryUtilization: "DCGM_FI_DEV_MEM_COPY_UTIL",
+			Temperature:       "DCGM_FI_DEV_GPU_TEMP",
+			PowerDraw:         "DCGM_FI_DEV_POWER_USAGE",
+			PowerLimit:        "DCGM_FI_DEV_ENFORCED_POWER_LIMIT",
+			EnergyTotal:       "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION",
+			SMClock:           "DCGM_FI_DEV_SM_CLOCK",
+			MemoryClock:       "DCGM_FI_DEV_MEM_CLOCK",
+			ECCCorrected:      "DCGM_FI_DEV_ECC_SBE_VOL_TOTAL",
+			ECCUncorrected:    "DCGM_FI_DEV_ECC_DBE_VOL_TOTAL",
+		},
+		Labels: LabelNames{
+			Node:          "Hostname",
+			GPUIndex:      "gpu",
+			GPUName:       "modelName",
+			UUID:          "UUID",
+			Pod:           "pod",
+			Namespace:     "namespace",
+			Container:     "container",
+			DriverVersion: "DCGM_FI_DRIVER_VERSION",
+			PCIBusID:      "pci_bus_id",
+		},
+		MemoryBytesPerUnit:  1024 * 1024,
+		EnergyJoulesPerUnit: 0.001,
+	},
+}
+
+// DefaultSchema returns the nvidia_gpu_exporter profile used when nothing is configured.
+func DefaultSchema() Schema {
+	return builtinSchemas[SchemaNvidiaGPUExporter]
+}
+
+// SchemaNames returns the names of the built-in profiles in sorted order.
+func SchemaNames() []string {
+	names := make([]string, 0, len(builtinSchemas))
+	for name := range builtinSchemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LookupSchema returns the built-in profile with the given name.
+func LookupSchema(name string) (Schema, error) {
+	schema, ok := builtinSchemas[name]
+	if !ok {
+		return Schema{}, fmt.Errorf("unknown metric schema %q (available: %v)", name, SchemaNames())
+	}
+	return schema, nil
+}
+
+// customSchemaFile is the on-disk format of a user-defined profile. Metric and
+// label names left out are inherited from the base profile; a name set to null
+// or "" marks the base's metric or label as unsupported.
+type customSchemaFile struct {
+	Base                string             `json:"base"`
+	Name                string             `json:"name"`
+	Metrics             map[string]*string `json:"metrics"`
+	Labels              map[string]*string `json:"labels"`
+	MemoryBytesPerUnit  float64            `json:"memory_bytes_per_unit"`
+	EnergyJoulesPerUnit float64            `json:"energy_joules_per_unit"`
+}
+
+// LoadSchemaFile reads a user-defined profile from a JSON file.
+func LoadSchemaFile(path string) (Schema, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Schema{}, fmt.Errorf("reading schema file: %w", err)
+	}
+
+	var file customSchemaFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return Schema{}, fmt.Errorf("parsing schema file: %w", err)
+	}
+
+	base := DefaultSchema()
+	if file.Base != "" {
+		base, err = LookupSchema(file.Base)
+		if err != nil {
+			return Schema{}, err
+		}
+	}
+
+	schema, err := mergeSchema(base, file)
+	if err != nil {
+		return Schema{}, err
+	}
+	if schema.Name == base.Name || schema.Name == "" {
+		schema.Name = "custom"
+	}
+	if err := schema.Validate(); err != nil {
+		return Schema{}, err
+	}
+	return schema, nil
+}
+
+// mergeSchema overlays the fields set in file onto base.
+func mergeSchema(base Schema, file customSchemaFile) (Schema, error) {
+	result := base
+	if file.Name != "" {
+		result.Name = file.Name
+	}
+	if err := overlayNames(&result.Metrics, file.Metrics); err != nil {
+		return Schema{}, fmt.Errorf("schema metrics: %w", err)
+	}
+	if err := overlayNames(&result.Labels, file.Labels); err != nil {
+		return Schema{}, fmt.Errorf("schema labels: %w", err)
+	}
+	if file.MemoryBytesPerUnit > 0 {
+		result.MemoryBytesPerUnit = file.MemoryBytesPerUnit
+	}
+	if file.EnergyJoulesPerUnit > 0 {
+		result.EnergyJoulesPerUnit = file.EnergyJoulesPerUnit
+	}
+	return result, nil
+}
+
+// overlayNames sets the fields of names, a *MetricNames or *LabelNames, keyed by
+// their JSON names in overrides. A nil override clears the field.
+func overlayNames(names any, overrides map[string]*string) error {
+	data, err := json.Marshal(names)
+	if err != nil {
+		return err
+	}
+	fields := make(map[string]string)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+
+	for key, value := range overrides {
+		if _, ok := fields[key]; !ok {
+			return fmt.Errorf("unknown field %q", key)
+		}
+		fields[key] = ""
+		if value != nil {
+			fields[key] = *value
+		}
+	}
+
+	data, err = json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, names)
+}
+
+// Validate checks that the schema can identify GPUs and query utilization.
+func (s Schema) Validate() error {
+	if s.Metrics.Utilization == "" {
+		return fmt.Errorf("metric schema %q: utilization metric is required", s.Name)
+	}
+	if s.Labels.Node == "" || s.Labels.GPUIndex == "" {
+		return fmt.Errorf("metric schema %q: node and gpu_index labels are required", s.Name)
+	}
+	if s.MemoryBytesPerUnit <= 0 {
+		return fmt.Errorf("metric schema %q: memory_bytes_per_unit must be positive", s.Name)
+	}
+	if s.Metrics.EnergyTotal != "" && s.EnergyJoulesPerUnit <= 0 {
+		return fmt.Errorf("metric schema %q: energy_joules_per_unit must be positive", s.Name)
+	}
+	return nil
+}
+
+// memoryToGB converts a memory sample in the exporter's unit to gigabytes.
+func (s Schema) memoryToGB(value float64) float64 {
+	return value * s.MemoryBytesPerUnit / (1024 * 1024 * 1024)
+}
+
+// energyQuery returns PromQL for the energy in watt-hours each GPU consumed over window.
+// It uses increase() on the energy counter when available, and otherwise integrates the
+// average power draw over the window. It returns "" if the exporter reports neither.
+func (s Schema) energyQuery(window time.Duration, matchers labelMatchers) string {
+	if s.Metrics.EnergyTotal != "" {
+		joules := promql.Mul(promql.Increase(matchers.selector(s.Metrics.EnergyTotal).Over(window)), promql.Number(s.EnergyJoulesPerUnit))
+		return promql.Div(joules, promql.Number(3600)).String()
+	}
+	if s.Metrics.PowerDraw != "" {
+		return promql.Mul(promql.AvgOverTime(matchers.selector(s.Metrics.PowerDraw).Over(window)), promql.Number(window.Hours())).String()
+	}
+	return ""
+}
+
+// metricName returns the exporter metric name for a metric type, or "" if unsupported.
+func (s Schema) metricName(metricType string) string {
+	switch metricType {
+	case "utilization":
+		return s.Metrics.Utilization
+	case "memory_used":
+		return s.Metrics.MemoryUsed
+	case "memory_total":
+		return s.Metrics.MemoryTotal
+	case "memory_free":
+		return s.Metrics.MemoryFree
+	case "memory_utilization":
+		return s.Metrics.MemoryUtilization
+	case "temperature":
+		return s.Metrics.Temperature
+	case "power_draw":
+		return s.Metrics.PowerDraw
+	case "power_limit":
+		return s.Metrics.PowerLimit
+	case "sm_clock":
+		return s.Metrics.SMClock
+	case "memory_clock":
+		return s.Metrics.MemoryClock
+	case "ecc_corrected":
+		return s.Metrics.ECCCorrected
+	case "ecc_uncorrected":
+		return s.Metrics.ECCUncorrected
+	}
+	return ""
+}
+
+// queries returns a selector for each requested metric type the exporter provides.
+func (s Schema) queries(matchers labelMatchers, metricTypes ...string) map[string]string {
+	queries := make(map[string]string, len(metricTypes))
+	for _, metricType := range metricTypes {
+		if metric := s.metricName(metricType); metric != "" {
+			queries[metricType] = matchers.selector(metric).String()
+		}
+	}
+	return queries
+}
+
+// labelMatchers select series by label. A label with one value renders an
+// equality matcher; several values render an anchored regex alternation.
+type labelMatchers map[string][]string
+
+// matchers returns the PromQL matchers ordered by label name.
+func (m labelMatchers) matchers() []promql.Matcher {
+	names := make([]string, 0, len(m))
+	for name, values := range m {
+		if len(values) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	matchers := make([]promql.Matcher, 0, len(names))
+	for _, name := range names {
+		matchers = append(matchers, promql.OneOf(name, m[name]...))
+	}
+	return matchers
+}
+
+// selector returns a selector for metric restricted by the matchers.
+func (m labelMatchers) selector(metric string) promql.Selector {
+	return promql.Select(metric, m.matchers()...)
+}
+
+// String renders the matchers as a PromQL selector suffix, or "" when empty.
+func (m labelMatchers) String() string {
+	if len(m.matchers()) == 0 {
+		return ""
+	}
+	return m.selector("").String()
+}
@@ -0,0 +1,151 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLookupSchema(t *testing.T) {
+	for _, name := range SchemaNames() {
+		schema, err := LookupSchema(name)
+		if err != nil {
+			t.Fatalf("lookup %s: %v", name, err)
+		}
+		if err := schema.Validate(); err != nil {
+			t.Errorf("built-in schema %s is invalid: %v", name, err)
+		}
+	}
+
+	if _, err := LookupSchema("unknown"); err == nil {
+		t.Error("expected error for unknown schema")
+	}
+}
+
+func TestLoadSchemaFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	content := `{
+  "base": "dcgm",
+  "name": "site-dcgm",
+  "labels": {"node": "kubernetes_node"}
+}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := LoadSchemaFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if schema.Name != "site-dcgm" {
+		t.Errorf("expected name site-dcgm, got %s", schema.Name)
+	}
+	if schema.Labels.Node != "kubernetes_node" {
+		t.Errorf("expected overridden node label, got %s", schema.Labels.Node)
+	}
+	if schema.Labels.GPUIndex != "gpu" || schema.Metrics.Utilization != "DCGM_FI_DEV_GPU_UTIL" {
+		t.Errorf("expected dcgm fields to be inherited, got %+v", schema)
+	}
+}
+
+func TestLoadSchemaFileClearsInherited(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schema.json")
+	content := `{
+  "base": "dcgm",
+  "metrics": {"energy_total": null, "ecc_corrected": ""},
+  "labels": {"pod": null}
+}`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	schema, err := LoadSchemaFile(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if schema.Metrics.EnergyTotal != "" || schema.Metrics.ECCCorrected != "" || schema.Labels.Pod != "" {
+		t.Errorf("expected cleared fields to be unsupported, got %+v", schema)
+	}
+	if schema.Metrics.PowerDraw != "DCGM_FI_DEV_POWER_USAGE" || schema.Labels.Namespace != "namespace" {
+		t.Errorf("expected other dcgm fields to be inherited, got %+v", schema)
+	}
+	if got, want := schema.energyQuery(time.Hour, nil), `avg_over_time(DCGM_FI_DEV_POWER_USAGE[3600s]) * 1`; got != want {
+		t.Errorf("expected energy integrated from power without the counter:\n got %s\nwant %s", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"metrics": {"energy": "x"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSchemaFile(path); err == nil {
+		t.Error("expected error for an unknown metric field")
+	}
+}
+
+func TestGetGPUMetricsDCGMSchema(t *testing.T) {
+	bodies := map[string]string{
+		"DCGM_FI_DEV_GPU_UTIL":             `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"Hostname":"node1","gpu":"1","modelName":"NVIDIA H100","UUID":"GPU-abc"},"value":[1700000000,"87"]}]}}`,
+		"DCGM_FI_DEV_FB_USED":              `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"Hostname":"node1","gpu":"1"},"value":[1700000000,"1024"]}]}}`,
+		"DCGM_FI_DEV_FB_FREE":              `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"Hostname":"node1","gpu":"1"},"value":[1700000000,"3072"]}]}}`,
+		"DCGM_FI_DEV_POWER_USAGE":          `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"Hostname":"node1","gpu":"1"},"value":[1700000000,"450.5"]}]}}`,
+		"DCGM_FI_DEV_ENFORCED_POWER_LIMIT": `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"Hostname":"node1","gpu":"1"},"value":[1700000000,"700"]}]}}`,
+		`increase(DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION[3600s]) * 0.001 / 3600`: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"Hostname":"node1","gpu":"1"},"value":[1700000000,"400"]}]}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := bodies[r.URL.Query().Get("query")]
+		if !ok {
+			body = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	schema, _ := LookupSchema(SchemaDCGMExporter)
+	client := NewClient(server.URL, WithSchema(schema))
+
+	metrics, _, err := client.GetGPUMetrics(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(metrics) != 1 {
+		t.Fatalf("expected 1 GPU, got %d", len(metrics))
+	}
+
+	m := metrics[0]
+	if m.NodeName != "node1" || m.GPUIndex != 1 || m.GPUName != "NVIDIA H100" || m.UUID != "GPU-abc" {
+		t.Errorf("unexpected identity: %+v", m)
+	}
+	if m.Utilization != 87 {
+		t.Errorf("expected utilization 87, got %v", m.Utilization)
+	}
+	if m.MemoryUsed != 1 || m.MemoryFree != 3 || m.MemoryTotal != 4 {
+		t.Errorf("expected MiB converted to GB with derived total, got used=%v free=%v total=%v", m.MemoryUsed, m.MemoryFree, m.MemoryTotal)
+	}
+	if m.PowerDraw != 450.5 || m.PowerLimit != 700 || m.PowerHeadroom != 249.5 {
+		t.Errorf("unexpected power: draw=%v limit=%v headroom=%v", m.PowerDraw, m.PowerLimit, m.PowerHeadroom)
+	}
+	if m.EnergyConsumed != 400 || m.EnergyWindow != "1h0m0s" {
+		t.Errorf("unexpected energy: %v over %s", m.EnergyConsumed, m.EnergyWindow)
+	}
+}
+
+func TestEnergyQuery(t *testing.T) {
+	dcgm, _ := LookupSchema(SchemaDCGMExporter)
+	if got, want := dcgm.energyQuery(time.Hour, nil), `increase(DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION[3600s]) * 0.001 / 3600`; got != want {
+		t.Errorf("counter energy query:\n got %s\nwant %s", got, want)
+	}
+
+	nvidia := DefaultSchema()
+	if got, want := nvidia.energyQuery(30*time.Minute, labelMatchers{"hostname": {"node1"}}), `avg_over_time(nvidia_gpu_power_draw_watts{hostname="node1"}[1800s]) * 0.5`; got != want {
+		t.Errorf("integrated energy query:\n got %s\nwant %s", got, want)
+	}
+
+	none := Schema{}
+	if got := none.energyQuery(time.Hour, nil); got != "" {
+		t.Errorf("expected empty query without power metrics, got %s", got)
+	}
+}
@@ -0,0 +1,162 @@
+package prometheus
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// contains reports whether values holds value.
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeMatchers returns label matchers selecting every series s may allow, and
+// whether they enforce s exactly. Label matchers cannot join clauses by OR, so
+// only the labels every clause restricts are pushed down, as the union of the
+// clauses' values. Namespaces can only be pushed down when the exporter labels
+// series with the owning pod's namespace.
+func (c *Client) scopeMatchers(s metrics.Scope) (labelMatchers, bool) {
+	var nodes, namespaces []string
+	allNodes := len(s.Clauses) > 0
+	allNamespaces := len(s.Clauses) > 0 && c.schema.Labels.Namespace != ""
+	for _, clause := range s.Clauses {
+		allNodes = allNodes && len(clause.Nodes) > 0
+		allNamespaces = allNamespaces && len(clause.Namespaces) > 0
+		nodes = appendUnique(nodes, clause.Nodes...)
+		namespaces = appendUnique(namespaces, clause.Namespaces...)
+	}
+
+	matchers := labelMatchers{}
+	if allNodes {
+		matchers[c.schema.Labels.Node] = nodes
+	}
+	if allNamespaces {
+		matchers[c.schema.Labels.Namespace] = namespaces
+	}
+	exact := len(s.Clauses) == 1 && (len(s.Clauses[0].Namespaces) == 0 || c.schema.Labels.Namespace != "")
+	return matchers, exact
+}
+
+// appendUnique appends the values not already present in list.
+func appendUnique(list []string, values ...string) []string {
+	for _, v := range values {
+		if !contains(list, v) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// mergeMatchers combines two matcher sets, intersecting the values of labels present
+// in both. It returns false when the intersection selects nothing.
+func mergeMatchers(a, b labelMatchers) (labelMatchers, bool) {
+	merged := make(labelMatchers, len(a)+len(b))
+	for label, values := range a {
+		if len(values) > 0 {
+			merged[label] = values
+		}
+	}
+
+	for label, values := range b {
+		if len(values) == 0 {
+			continue
+		}
+		existing, found := merged[label]
+		if !found {
+			merged[label] = values
+			continue
+		}
+
+		var common []string
+		for _, v := range existing {
+			if contains(values, v) {
+				common = append(common, v)
+			}
+		}
+		if len(common) == 0 {
+			return nil, false
+		}
+		merged[label] = common
+	}
+
+	return merged, true
+}
+
+// scopeQuery narrows matchers to the caller's scope. It returns the scope still
+// to enforce on the results, nil when the matchers enforce it alone, and false
+// when the scope leaves nothing visible.
+func (c *Client) scopeQuery(ctx context.Context, matchers labelMatchers) (labelMatchers, *metrics.Scope, bool) {
+	s, scoped := metrics.ScopeFrom(ctx)
+	if !scoped {
+		return matchers, nil, true
+	}
+	if len(s.Clauses) == 0 {
+		return nil, nil, false
+	}
+
+	scopeMatchers, exact := c.scopeMatchers(s)
+	merged, ok := mergeMatchers(matchers, scopeMatchers)
+	if !ok {
+		return nil, nil, false
+	}
+	if exact {
+		return merged, nil, true
+	}
+	return merged, &s, true
+}
+
+// allowedGPUs returns the keys of the GPUs identified by labelSets that s allows.
+// Owners come from the series labels, falling back to resource requests like
+// getGPUMetrics when a clause restricts namespaces.
+func (c *Client) allowedGPUs(ctx context.Context, s metrics.Scope, labelSets []map[string]string) map[string]bool {
+	labels := c.schema.Labels
+	var gpus []models.GPUMetrics
+	byKey := make(map[string]int)
+	for _, set := range labelSets {
+		nodeName := set[labels.Node]
+		gpuIndex, err := strconv.Atoi(set[labels.GPUIndex])
+		if nodeName == "" || err != nil {
+			continue
+		}
+
+		owner := models.OwnerFromLabels(set, labels.Pod, labels.Namespace, labels.Container)
+		key := gpuKey(nodeName, gpuIndex)
+		if i, found := byKey[key]; found {
+			if gpus[i].Owner == nil {
+				gpus[i].Owner = owner
+			}
+			continue
+		}
+		byKey[key] = len(gpus)
+		gpus = append(gpus, models.GPUMetrics{NodeName: nodeName, GPUIndex: gpuIndex, Owner: owner})
+	}
+
+	for _, clause := range s.Clauses {
+		if len(clause.Namespaces) > 0 {
+			c.attributeOwners(ctx, gpus)
+			break
+		}
+	}
+
+	allowed := make(map[string]bool, len(gpus))
+	for _, m := range gpus {
+		if s.Allows(m.NodeName, m.Owner) {
+			allowed[gpuKey(m.NodeName, m.GPUIndex)] = true
+		}
+	}
+	return allowed
+}
+
+// gpuKey identifies a GPU by node and index.
+func gpuKey(nodeName string, gpuIndex int) string {
+	return fmt.Sprintf("%s:%d", nodeName, gpuIndex)
+}
@@ -0,0 +1,205 @@
+package prometheus
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/fakeprom"
+	"k8s-gpu-monitoring/internal/metrics"
+)
+
+func TestMergeMatchers(t *testing.T) {
+	merged, ok := mergeMatchers(labelMatchers{"hostname": {"a", "b"}, "gpu_name": {"T4"}}, labelMatchers{"hostname": {"b", "c"}})
+	if !ok || !reflect.DeepEqual(merged, labelMatchers{"hostname": {"b"}, "gpu_name": {"T4"}}) {
+		t.Errorf("unexpected merge: %v, %v", merged, ok)
+	}
+
+	if _, ok := mergeMatchers(labelMatchers{"hostname": {"a"}}, labelMatchers{"hostname": {"b"}}); ok {
+		t.Error("expected disjoint matchers to select nothing")
+	}
+}
+
+// fleetServer serves two GPUs, node1/0 and node2/0, and a kube-state-metrics
+// request placing pod ml/train on node1. Exporter series carry no pod labels.
+func fleetServer(t *testing.T) (*fakeprom.Server, *httptest.Server) {
+	t.Helper()
+	return fakePrometheus(t, DefaultSchema(), fakeprom.Fleet{
+		NodeNames:        []string{"node1", "node2"},
+		GPUsPerNode:      1,
+		Workloads:        []fakeprom.Workload{{Namespace: "ml", Pod: "train", Container: "main", Node: "node1", GPUs: 1}},
+		KubeStateMetrics: true,
+	})
+}
+
+func TestScopedNodes(t *testing.T) {
+	fake, server := fleetServer(t)
+
+	client := NewClient(server.URL)
+	ctx := metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Nodes: []string{"node2"}}}})
+
+	gpus, _, err := client.GetGPUMetrics(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gpus) != 1 || gpus[0].NodeName != "node2" {
+		t.Fatalf("expected only node2, got %+v", gpus)
+	}
+
+	for _, q := range fake.Queries() {
+		if !strings.Contains(q, "kube_pod") && !strings.Contains(q, `hostname="node2"`) {
+			t.Errorf("expected node scope in query, got %s", q)
+		}
+	}
+
+	// A filter outside the scope selects nothing without querying Prometheus
+	before := len(fake.Queries())
+	gpus, _, err = client.SelectGPUMetrics(ctx, metrics.GPUSelector{Nodes: []string{"node1"}})
+	if err != nil || len(gpus) != 0 {
+		t.Errorf("expected no metrics outside scope, got %+v, %v", gpus, err)
+	}
+	if len(fake.Queries()) != before {
+		t.Error("expected disjoint scope to skip queries")
+	}
+
+	nodes, err := client.GetGPUNodes(ctx)
+	if err != nil || len(nodes) != 1 || nodes[0].NodeName != "node2" || nodes[0].GPUCount != 1 {
+		t.Errorf("unexpected nodes: %+v, %v", nodes, err)
+	}
+}
+
+func TestScopedNodesSorted(t *testing.T) {
+	names := []string{"node-f", "node-c", "node-a", "node-e", "node-b", "node-d"}
+	_, server := nodeFleetServer(t, names)
+
+	ctx := metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Nodes: names}}})
+	nodes, err := NewClient(server.URL).GetGPUNodes(ctx)
+	if err != nil || len(nodes) != len(names) {
+		t.Fatalf("unexpected nodes: %+v, %v", nodes, err)
+	}
+	if !sort.SliceIsSorted(nodes, func(i, j int) bool { return nodes[i].NodeName < nodes[j].NodeName }) {
+		t.Errorf("expected nodes ordered by name, got %+v", nodes)
+	}
+}
+
+func TestScopedNamespaces(t *testing.T) {
+	fake, server := fleetServer(t)
+
+	client := NewClient(server.URL)
+	ctx := metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{"ml"}}}})
+
+	// The exporter has no namespace label, so ownership from resource requests decides visibility
+	gpus, _, err := client.GetGPUMetrics(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(gpus) != 1 || gpus[0].NodeName != "node1" || gpus[0].Owner == nil {
+		t.Fatalf("expected only the GPU owned by ml, got %+v", gpus)
+	}
+
+	before := len(fake.Queries())
+	series, _, err := client.GetGPUMetricsRange(ctx, time.Unix(1700000000, 0), time.Unix(1700000060, 0), time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(series) != 1 || series[0].NodeName != "node1" {
+		t.Errorf("expected range series limited to node1, got %+v", series)
+	}
+
+	// Only the range queries and the ownership lookup run, not an instant fan-out
+	rangeQueries := client.schema.queries(nil, "utilization", "memory_used", "memory_total", "memory_free", "temperature", "power_draw")
+	var metricQueries int
+	for _, q := range fake.Queries()[before:] {
+		if !strings.Contains(q, "kube_pod") {
+			metricQueries++
+		}
+	}
+	if metricQueries != len(rangeQueries) {
+		t.Errorf("expected %d range queries, got %v", len(rangeQueries), fake.Queries()[before:])
+	}
+
+	utilization, err := client.GetGPUUtilization(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(utilization.Data.Result) != 1 || utilization.Data.Result[0].Metric["hostname"] != "node1" {
+		t.Errorf("expected utilization limited to node1, got %+v", utilization.Data.Result)
+	}
+
+	pods, _, err := client.GetGPUPods(metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{"other"}}}}))
+	if err != nil || len(pods) != 0 {
+		t.Errorf("expected no pods outside scope, got %+v, %v", pods, err)
+	}
+}
+
+func TestScopeNamespacePushdown(t *testing.T) {
+	var queries queryRecorder
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries.add(r.URL.Query().Get("query"))
+		w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[]}}`))
+	}))
+	defer server.Close()
+
+	schema, _ := LookupSchema(SchemaDCGMExporter)
+	client := NewClient(server.URL, WithSchema(schema))
+	if _, _, err := client.GetGPUMetrics(metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{"ml"}}}})); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, q := range queries.all() {
+		if !strings.Contains(q, "kube_pod") && !strings.Contains(q, `namespace="ml"`) {
+			t.Errorf("expected namespace matcher in query, got %s", q)
+		}
+	}
+}
+
+func TestScopeClausesUnion(t *testing.T) {
+	_, server := fakePrometheus(t, DefaultSchema(), fakeprom.Fleet{
+		NodeNames:        []string{"node1", "node2", "node3"},
+		GPUsPerNode:      1,
+		Workloads:        []fakeprom.Workload{{Namespace: "ml", Pod: "train", Container: "main", Node: "node1", GPUs: 1}},
+		KubeStateMetrics: true,
+	})
+
+	client := NewClient(server.URL)
+	ctx := metrics.WithScope(context.Background(), metrics.Scope{Clauses: []metrics.ScopeClause{
+		{Namespaces: []string{"ml"}},
+		{Nodes: []string{"node3"}},
+	}})
+
+	// Either clause grants a GPU: node1 through its owner, node3 through its node
+	gpus, _, err := client.GetGPUMetrics(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var nodes []string
+	for _, m := range gpus {
+		nodes = append(nodes, m.NodeName)
+	}
+	sort.Strings(nodes)
+	if !reflect.DeepEqual(nodes, []string{"node1", "node3"}) {
+		t.Fatalf("expected node1 and node3, got %v", nodes)
+	}
+
+	series, _, err := client.GetGPUMetricsRange(ctx, time.Unix(1700000000, 0), time.Unix(1700000060, 0), time.Minute)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	nodes = nil
+	for _, s := range series {
+		nodes = append(nodes, s.NodeName)
+	}
+	sort.Strings(nodes)
+	if !reflect.DeepEqual(nodes, []string{"node1", "node3"}) {
+		t.Errorf("expected range series of node1 and node3, got %v", nodes)
+	}
+
+	if gpus, _, err := client.GetGPUMetrics(metrics.WithScope(context.Background(), metrics.Scope{})); err != nil || len(gpus) != 0 {
+		t.Errorf("expected a scope without clauses to allow nothing, got %+v, %v", gpus, err)
+	}
+}
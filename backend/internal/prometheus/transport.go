@@ -0,0 +1,195 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// credentials authorizes a request to Prometheus.
+type credentials interface {
+	apply(req *http.Request) error
+}
+
+// bearerToken sends a fixed bearer token.
+type bearerToken string
+
+func (t bearerToken) apply(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return nil
+}
+
+// basicAuth sends HTTP basic credentials.
+type basicAuth struct {
+	username, password string
+}
+
+func (b basicAuth) apply(req *http.Request) error {
+	req.SetBasicAuth(b.username, b.password)
+	return nil
+}
+
+// tokenFile sends a bearer token read from a file, rereading it whenever the
+// file changes so rotated tokens, e.g. projected ServiceAccount tokens, are picked up.
+type tokenFile struct {
+	path string
+
+	mu      sync.Mutex
+	token   string
+	modTime time.Time
+	size    int64
+}
+
+func (f *tokenFile) apply(req *http.Request) error {
+	token, err := f.read()
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// read returns the current token, rereading the file only if it changed since the last read.
+func (f *tokenFile) read() (string, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return "", fmt.Errorf("reading bearer token file: %w", err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.token != "" && info.ModTime().Equal(f.modTime) && info.Size() == f.size {
+		return f.token, nil
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		return "", fmt.Errorf("reading bearer token file: %w", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("bearer token file %s is empty", f.path)
+	}
+
+	f.token, f.modTime, f.size = token, info.ModTime(), info.Size()
+	return token, nil
+}
+
+// WithBearerToken authenticates to Prometheus with a static bearer token.
+func WithBearerToken(token string) Option {
+	return func(c *Client) {
+		c.credentials = bearerToken(token)
+	}
+}
+
+// WithBearerTokenFile authenticates to Prometheus with a bearer token read from
+// path. The file is reread when it changes, so rotated tokens take effect without a restart.
+func WithBearerTokenFile(path string) Option {
+	return func(c *Client) {
+		c.credentials = &tokenFile{path: path}
+	}
+}
+
+// WithBasicAuth authenticates to Prometheus with HTTP basic credentials.
+func WithBasicAuth(username, password string) Option {
+	return func(c *Client) {
+		c.credentials = basicAuth{username: username, password: password}
+	}
+}
+
+// WithHeaders adds headers to every request, e.g. X-Scope-OrgID to select a Mimir or Cortex tenant.
+func WithHeaders(headers map[string]string) Option {
+	return func(c *Client) {
+		for name, value := range headers {
+			c.headers.Set(name, value)
+		}
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to connect to Prometheus.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.tlsConfig = config
+	}
+}
+
+// TLSOptions describes how to verify Prometheus and present a client certificate.
+// Empty fields keep the system defaults.
+type TLSOptions struct {
+	// CAFile is a PEM bundle trusted in addition to the system roots.
+	CAFile string
+	// CertFile and KeyFile hold a PEM client certificate and key for mutual TLS.
+	CertFile string
+	KeyFile  string
+	// ServerName overrides the name verified against the server certificate.
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// Enabled reports whether any option differs from the defaults.
+func (o TLSOptions) Enabled() bool {
+	return o != TLSOptions{}
+}
+
+// LoadTLSConfig builds a TLS configuration from the files named in opts.
+func LoadTLSConfig(opts TLSOptions) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         opts.ServerName,
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", opts.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if (opts.CertFile == "") != (opts.KeyFile == "") {
+		return nil, fmt.Errorf("client certificate and key files must be set together")
+	}
+	if opts.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opts.CertFile, opts.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// newRequest creates a GET request carrying the configured headers and credentials.
+func (c *Client) newRequest(ctx context.Context, requestURL string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	if c.credentials != nil {
+		if err := c.credentials.apply(req); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
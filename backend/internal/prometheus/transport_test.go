@@ -0,0 +1,213 @@
+package prometheus
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const emptyVector = `{"status":"success","data":{"resultType":"vector","result":[]}}`
+
+// headerRecorder serves empty results and records the headers of the last request.
+type headerRecorder struct {
+	mu     sync.Mutex
+	header http.Header
+}
+
+func (h *headerRecorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.header = r.Header.Clone()
+	h.mu.Unlock()
+	w.Write([]byte(emptyVector))
+}
+
+func (h *headerRecorder) get(name string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.header.Get(name)
+}
+
+func TestClientCredentials(t *testing.T) {
+	recorder := &headerRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	client := NewClient(server.URL,
+		WithBearerToken("secret"),
+		WithHeaders(map[string]string{"X-Scope-OrgID": "team-a"}),
+	)
+	if _, err := client.Query(context.Background(), "up"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := recorder.get("Authorization"); got != "Bearer secret" {
+		t.Errorf("unexpected Authorization header %q", got)
+	}
+	if got := recorder.get("X-Scope-OrgID"); got != "team-a" {
+		t.Errorf("unexpected X-Scope-OrgID header %q", got)
+	}
+
+	client = NewClient(server.URL, WithBasicAuth("admin", "hunter2"))
+	if _, err := client.Query(context.Background(), "up"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	req := &http.Request{Header: http.Header{"Authorization": {recorder.get("Authorization")}}}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "admin" || pass != "hunter2" {
+		t.Errorf("unexpected basic auth %q", recorder.get("Authorization"))
+	}
+}
+
+func TestBearerTokenFileRotation(t *testing.T) {
+	recorder := &headerRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	client := NewClient(server.URL, WithBearerTokenFile(path))
+	if _, err := client.Query(context.Background(), "up"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := recorder.get("Authorization"); got != "Bearer first" {
+		t.Errorf("unexpected Authorization header %q", got)
+	}
+
+	// Rotate the token; a distinct modification time marks the file as changed
+	if err := os.WriteFile(path, []byte("second-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Query(context.Background(), "up"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := recorder.get("Authorization"); got != "Bearer second-token" {
+		t.Errorf("expected rotated token, got %q", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.Query(context.Background(), "up"); err == nil {
+		t.Error("expected an error once the token file is gone")
+	}
+}
+
+// writePEM writes a PEM block to a file in dir and returns its path.
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newClientCert creates a self-signed client certificate and returns it with its key in DER form.
+func newClientCert(t *testing.T) (*x509.Certificate, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "gpu-monitoring"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, certDER, keyDER
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	clientCert, certDER, keyDER := newClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(emptyVector))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", certDER)
+	keyFile := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+
+	// Without the CA bundle the server certificate is untrusted
+	if _, err := NewClient(server.URL).Query(context.Background(), "up"); err == nil {
+		t.Fatal("expected an unknown authority error")
+	}
+
+	// Trusting the server but presenting no certificate is rejected by the server
+	config, err := LoadTLSConfig(TLSOptions{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewClient(server.URL, WithTLSConfig(config)).Query(context.Background(), "up"); err == nil {
+		t.Fatal("expected the server to require a client certificate")
+	}
+
+	config, err = LoadTLSConfig(TLSOptions{CAFile: caFile, CertFile: certFile, KeyFile: keyFile})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := NewClient(server.URL, WithTLSConfig(config)).Query(context.Background(), "up"); err != nil {
+		t.Errorf("expected mutual TLS to succeed, got %v", err)
+	}
+}
+
+func TestLoadTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	bogus := filepath.Join(dir, "bogus.pem")
+	if err := os.WriteFile(bogus, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []TLSOptions{
+		{CAFile: filepath.Join(dir, "missing.pem")},
+		{CAFile: bogus},
+		{CertFile: bogus},
+		{CertFile: bogus, KeyFile: bogus},
+	}
+	for _, opts := range tests {
+		if _, err := LoadTLSConfig(opts); err == nil {
+			t.Errorf("expected error for %+v", opts)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package prometheus
+
+import (
+	"errors"
+	"sort"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// buildWarnings describes every metric that failed or returned no series, and passes
+// through warnings Prometheus attached to successful responses. Empty results are
+// only reported when some other metric did return series, since an entirely empty
+// fleet is not a partial result.
+func buildWarnings(queries map[string]string, failures map[string]error, seriesCounts map[string]int, promWarnings map[string][]string) []models.Warning {
+	anySeries := false
+	for _, count := range seriesCounts {
+		if count > 0 {
+			anySeries = true
+			break
+		}
+	}
+
+	var warnings []models.Warning
+	for name, err := range failures {
+		warnings = append(warnings, models.Warning{
+			Metric: name,
+			Query:  queries[name],
+			Reason: models.FailureReason(err),
+		})
+	}
+	if anySeries {
+		for name, count := range seriesCounts {
+			if count == 0 {
+				warnings = append(warnings, models.Warning{
+					Metric: name,
+					Query:  queries[name],
+					Reason: "no series found",
+				})
+			}
+		}
+	}
+
+	for name, messages := range promWarnings {
+		for _, message := range messages {
+			warnings = append(warnings, models.Warning{
+				Metric: name,
+				Query:  queries[name],
+				Reason: "prometheus warning: " + message,
+			})
+		}
+	}
+
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Metric < warnings[j].Metric
+	})
+	return warnings
+}
+
+// joinFailures combines per-metric errors in a stable order.
+func joinFailures(failures map[string]error) error {
+	names := make([]string, 0, len(failures))
+	for name := range failures {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	errs := make([]error, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, failures[name])
+	}
+	return errors.Join(errs...)
+}
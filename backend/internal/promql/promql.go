@@ -0,0 +1,437 @@
+// Package promql composes PromQL expressions from typed parts. Label values
+// are always rendered as escaped string literals, so callers can pass
+// untrusted values without risking query injection.
+package promql
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Expr is a PromQL expression.
+type Expr interface {
+	String() string
+	// precedence is the binding strength of the outermost operator; operands
+	// binding looser than their parent operator are parenthesized.
+	precedence() int
+}
+
+// atomPrecedence binds tighter than any binary operator.
+const atomPrecedence = 100
+
+// MatchType is a label matching operator.
+type MatchType string
+
+// Label matching operators.
+const (
+	MatchEqual     MatchType = "="
+	MatchNotEqual  MatchType = "!="
+	MatchRegexp    MatchType = "=~"
+	MatchNotRegexp MatchType = "!~"
+)
+
+// Matcher matches a label against a value or regular expression.
+type Matcher struct {
+	Label string
+	Type  MatchType
+	Value string
+}
+
+// Eq matches series whose label equals value.
+func Eq(label, value string) Matcher {
+	return Matcher{Label: label, Type: MatchEqual, Value: value}
+}
+
+// Ne matches series whose label differs from value.
+func Ne(label, value string) Matcher {
+	return Matcher{Label: label, Type: MatchNotEqual, Value: value}
+}
+
+// Re matches series whose label matches the regular expression pattern, which Prometheus anchors.
+func Re(label, pattern string) Matcher {
+	return Matcher{Label: label, Type: MatchRegexp, Value: pattern}
+}
+
+// NotRe matches series whose label does not match the regular expression pattern.
+func NotRe(label, pattern string) Matcher {
+	return Matcher{Label: label, Type: MatchNotRegexp, Value: pattern}
+}
+
+// OneOf matches series whose label equals any of values. One value renders an
+// equality matcher; several render a regex alternation of the escaped values.
+func OneOf(label string, values ...string) Matcher {
+	if len(values) == 1 {
+		return Eq(label, values[0])
+	}
+
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = regexp.QuoteMeta(value)
+	}
+	sort.Strings(escaped)
+	return Re(label, strings.Join(escaped, "|"))
+}
+
+// String renders the matcher, quoting the value as a string literal.
+func (m Matcher) String() string {
+	return m.Label + string(m.Type) + Quote(m.Value)
+}
+
+// Quote renders s as a PromQL string literal, escaping quotes and backslashes.
+func Quote(s string) string {
+	return strconv.Quote(s)
+}
+
+// Selector is an instant vector selector.
+type Selector struct {
+	Metric   string
+	Matchers []Matcher
+}
+
+// Select returns a selector for metric restricted by matchers.
+func Select(metric string, matchers ...Matcher) Selector {
+	return Selector{Metric: metric, Matchers: matchers}
+}
+
+// Where returns a copy of the selector with matchers added.
+func (s Selector) Where(matchers ...Matcher) Selector {
+	s.Matchers = append(append([]Matcher(nil), s.Matchers...), matchers...)
+	return s
+}
+
+// Over returns a range selector covering window.
+func (s Selector) Over(window time.Duration) RangeSelector {
+	return RangeSelector{Selector: s, Window: window}
+}
+
+// String renders the selector, omitting the braces when there are no matchers.
+func (s Selector) String() string {
+	if len(s.Matchers) == 0 {
+		if s.Metric == "" {
+			return "{}"
+		}
+		return s.Metric
+	}
+
+	parts := make([]string, len(s.Matchers))
+	for i, m := range s.Matchers {
+		parts[i] = m.String()
+	}
+	return s.Metric + "{" + strings.Join(parts, ",") + "}"
+}
+
+func (Selector) precedence() int { return atomPrecedence }
+
+// RangeSelector is a range vector selector.
+type RangeSelector struct {
+	Selector Selector
+	Window   time.Duration
+}
+
+// String renders the selector followed by its window.
+func (r RangeSelector) String() string {
+	return r.Selector.String() + "[" + Duration(r.Window) + "]"
+}
+
+func (RangeSelector) precedence() int { return atomPrecedence }
+
+// Duration renders d in PromQL duration syntax, rounded up to whole seconds so
+// that a positive window never renders as the invalid 0s.
+func Duration(d time.Duration) string {
+	seconds := d / time.Second
+	if d%time.Second > 0 {
+		seconds++
+	}
+	return fmt.Sprintf("%ds", int64(seconds))
+}
+
+// Number is a scalar literal.
+type Number float64
+
+// String renders the shortest representation of the number.
+func (n Number) String() string {
+	return strconv.FormatFloat(float64(n), 'g', -1, 64)
+}
+
+func (Number) precedence() int { return atomPrecedence }
+
+// Call is a function call.
+type Call struct {
+	Func string
+	Args []Expr
+}
+
+// Func returns a call of the named function.
+func Func(name string, args ...Expr) Call {
+	return Call{Func: name, Args: args}
+}
+
+// Rate returns rate(r).
+func Rate(r RangeSelector) Call { return Func("rate", r) }
+
+// Increase returns increase(r).
+func Increase(r RangeSelector) Call { return Func("increase", r) }
+
+// AvgOverTime returns avg_over_time(r).
+func AvgOverTime(r RangeSelector) Call { return Func("avg_over_time", r) }
+
+// MaxOverTime returns max_over_time(r).
+func MaxOverTime(r RangeSelector) Call { return Func("max_over_time", r) }
+
+// QuantileOverTime returns quantile_over_time(q, r).
+func QuantileOverTime(q float64, r RangeSelector) Call {
+	return Func("quantile_over_time", Number(q), r)
+}
+
+// String renders the call with its arguments.
+func (c Call) String() string {
+	args := make([]string, len(c.Args))
+	for i, arg := range c.Args {
+		args[i] = arg.String()
+	}
+	return c.Func + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (Call) precedence() int { return atomPrecedence }
+
+// Aggregation is an aggregation operator such as sum or count, optionally
+// grouped by or without labels.
+type Aggregation struct {
+	Op   string
+	Expr Expr
+	// Param is the leading parameter of topk, bottomk, quantile and count_values.
+	Param Expr
+
+	grouping []string
+	without  bool
+}
+
+// Sum returns sum(e).
+func Sum(e Expr) Aggregation { return Aggregation{Op: "sum", Expr: e} }
+
+// Count returns count(e).
+func Count(e Expr) Aggregation { return Aggregation{Op: "count", Expr: e} }
+
+// Avg returns avg(e).
+func Avg(e Expr) Aggregation { return Aggregation{Op: "avg", Expr: e} }
+
+// Min returns min(e).
+func Min(e Expr) Aggregation { return Aggregation{Op: "min", Expr: e} }
+
+// Max returns max(e).
+func Max(e Expr) Aggregation { return Aggregation{Op: "max", Expr: e} }
+
+// Group returns group(e).
+func Group(e Expr) Aggregation { return Aggregation{Op: "group", Expr: e} }
+
+// TopK returns topk(k, e).
+func TopK(k int, e Expr) Aggregation {
+	return Aggregation{Op: "topk", Expr: e, Param: Number(k)}
+}
+
+// By groups the aggregation by labels, skipping empty label names.
+func (a Aggregation) By(labels ...string) Aggregation {
+	a.grouping, a.without = nonEmpty(labels), false
+	return a
+}
+
+// Without aggregates away labels, keeping all others.
+func (a Aggregation) Without(labels ...string) Aggregation {
+	a.grouping, a.without = nonEmpty(labels), true
+	return a
+}
+
+// String renders the aggregation with its grouping clause before the operand.
+func (a Aggregation) String() string {
+	var b strings.Builder
+	b.WriteString(a.Op)
+	if a.without {
+		b.WriteString(" without (" + strings.Join(a.grouping, ", ") + ") ")
+	} else if len(a.grouping) > 0 {
+		b.WriteString(" by (" + strings.Join(a.grouping, ", ") + ") ")
+	}
+	b.WriteString("(")
+	if a.Param != nil {
+		b.WriteString(a.Param.String() + ", ")
+	}
+	b.WriteString(a.Expr.String() + ")")
+	return b.String()
+}
+
+func (Aggregation) precedence() int { return atomPrecedence }
+
+// nonEmpty returns labels without empty names.
+func nonEmpty(labels []string) []string {
+	kept := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label != "" {
+			kept = append(kept, label)
+		}
+	}
+	return kept
+}
+
+// Binary operator precedences, from loosest to tightest.
+var binaryPrecedence = map[string]int{
+	"or":     1,
+	"and":    2,
+	"unless": 2,
+	"==":     3,
+	"!=":     3,
+	"<":      3,
+	"<=":     3,
+	">":      3,
+	">=":     3,
+	"+":      4,
+	"-":      4,
+	"*":      5,
+	"/":      5,
+	"%":      5,
+	"atan2":  5,
+	"^":      6,
+}
+
+// Binary is a binary operation, optionally with vector matching.
+type Binary struct {
+	LHS Expr
+	Op  string
+	RHS Expr
+	// ReturnBool turns a comparison into a 0/1 result instead of a filter.
+	ReturnBool bool
+
+	matching *vectorMatching
+}
+
+// vectorMatching restricts the labels used to match series and allows
+// many-to-one or one-to-many matching.
+type vectorMatching struct {
+	on      bool
+	labels  []string
+	group   string
+	include []string
+}
+
+func binary(lhs Expr, op string, rhs Expr) Binary {
+	return Binary{LHS: lhs, Op: op, RHS: rhs}
+}
+
+// Add returns lhs + rhs.
+func Add(lhs, rhs Expr) Binary { return binary(lhs, "+", rhs) }
+
+// Sub returns lhs - rhs.
+func Sub(lhs, rhs Expr) Binary { return binary(lhs, "-", rhs) }
+
+// Mul returns lhs * rhs.
+func Mul(lhs, rhs Expr) Binary { return binary(lhs, "*", rhs) }
+
+// Div returns lhs / rhs.
+func Div(lhs, rhs Expr) Binary { return binary(lhs, "/", rhs) }
+
+// Eql returns lhs == rhs.
+func Eql(lhs, rhs Expr) Binary { return binary(lhs, "==", rhs) }
+
+// Neq returns lhs != rhs.
+func Neq(lhs, rhs Expr) Binary { return binary(lhs, "!=", rhs) }
+
+// Gt returns lhs > rhs.
+func Gt(lhs, rhs Expr) Binary { return binary(lhs, ">", rhs) }
+
+// Gte returns lhs >= rhs.
+func Gte(lhs, rhs Expr) Binary { return binary(lhs, ">=", rhs) }
+
+// Lt returns lhs < rhs.
+func Lt(lhs, rhs Expr) Binary { return binary(lhs, "<", rhs) }
+
+// Lte returns lhs <= rhs.
+func Lte(lhs, rhs Expr) Binary { return binary(lhs, "<=", rhs) }
+
+// And returns lhs and rhs.
+func And(lhs, rhs Expr) Binary { return binary(lhs, "and", rhs) }
+
+// Or returns lhs or rhs.
+func Or(lhs, rhs Expr) Binary { return binary(lhs, "or", rhs) }
+
+// Unless returns lhs unless rhs.
+func Unless(lhs, rhs Expr) Binary { return binary(lhs, "unless", rhs) }
+
+// Bool makes a comparison return 0 or 1 instead of filtering.
+func (b Binary) Bool() Binary {
+	b.ReturnBool = true
+	return b
+}
+
+// On matches series on labels only.
+func (b Binary) On(labels ...string) Binary {
+	return b.match(func(m *vectorMatching) { m.on, m.labels = true, labels })
+}
+
+// Ignoring matches series on all labels except labels.
+func (b Binary) Ignoring(labels ...string) Binary {
+	return b.match(func(m *vectorMatching) { m.on, m.labels = false, labels })
+}
+
+// GroupLeft allows many left-hand series to match one right-hand series,
+// copying include labels from the right.
+func (b Binary) GroupLeft(include ...string) Binary {
+	return b.match(func(m *vectorMatching) { m.group, m.include = "group_left", include })
+}
+
+// GroupRight allows one left-hand series to match many right-hand series,
+// copying include labels from the left.
+func (b Binary) GroupRight(include ...string) Binary {
+	return b.match(func(m *vectorMatching) { m.group, m.include = "group_right", include })
+}
+
+// match returns a copy of b with its vector matching updated by set.
+func (b Binary) match(set func(*vectorMatching)) Binary {
+	m := vectorMatching{}
+	if b.matching != nil {
+		m = *b.matching
+	}
+	set(&m)
+	b.matching = &m
+	return b
+}
+
+// String renders the operation, parenthesizing operands only where precedence requires.
+func (b Binary) String() string {
+	prec := b.precedence()
+	// ^ is right-associative; every other operator is left-associative
+	leftTight, rightTight := prec, prec+1
+	if b.Op == "^" {
+		leftTight, rightTight = prec+1, prec
+	}
+
+	var s strings.Builder
+	s.WriteString(operand(b.LHS, leftTight))
+	s.WriteString(" " + b.Op)
+	if b.ReturnBool {
+		s.WriteString(" bool")
+	}
+	if m := b.matching; m != nil {
+		if m.on {
+			s.WriteString(" on (" + strings.Join(m.labels, ", ") + ")")
+		} else if len(m.labels) > 0 {
+			s.WriteString(" ignoring (" + strings.Join(m.labels, ", ") + ")")
+		}
+		if m.group != "" {
+			s.WriteString(" " + m.group + "(" + strings.Join(m.include, ", ") + ")")
+		}
+	}
+	s.WriteString(" " + operand(b.RHS, rightTight))
+	return s.String()
+}
+
+func (b Binary) precedence() int { return binaryPrecedence[b.Op] }
+
+// operand renders e, parenthesized when it binds looser than minimum.
+func operand(e Expr, minimum int) string {
+	if e.precedence() < minimum {
+		return "(" + e.String() + ")"
+	}
+	return e.String()
+}
@@ -0,0 +1,91 @@
+package promql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRender(t *testing.T) {
+	gpu := Select("nvidia_gpu_utilization_percent")
+
+	tests := []struct {
+		name string
+		expr Expr
+		want string
+	}{
+		{"bare metric", gpu, `nvidia_gpu_utilization_percent`},
+		{"equality", gpu.Where(Eq("hostname", "node1")), `nvidia_gpu_utilization_percent{hostname="node1"}`},
+		{"escaped value", gpu.Where(Eq("hostname", `node"1\`)), `nvidia_gpu_utilization_percent{hostname="node\"1\\"}`},
+		{"injection stays inside the literal", gpu.Where(Eq("hostname", `x"} or vector(1) or up{a="`)), `nvidia_gpu_utilization_percent{hostname="x\"} or vector(1) or up{a=\""}`},
+		{"one of", gpu.Where(OneOf("gpu_name", "Tesla T4 (PCIe)", "NVIDIA A100")), `nvidia_gpu_utilization_percent{gpu_name=~"NVIDIA A100|Tesla T4 \\(PCIe\\)"}`},
+		{"one of single value", gpu.Where(OneOf("gpu_id", "0")), `nvidia_gpu_utilization_percent{gpu_id="0"}`},
+		{"negative matchers", Select("up", Ne("job", "a"), NotRe("instance", "10\\..*")), `up{job!="a",instance!~"10\\..*"}`},
+		{"name only matcher", Select("", Re("__name__", "a|b")), `{__name__=~"a|b"}`},
+		{"range function", AvgOverTime(gpu.Over(90 * time.Second)), `avg_over_time(nvidia_gpu_utilization_percent[90s])`},
+		{"quantile", QuantileOverTime(0.95, gpu.Over(time.Hour)), `quantile_over_time(0.95, nvidia_gpu_utilization_percent[3600s])`},
+		{"rate", Rate(Select("errors_total").Over(5 * time.Minute)), `rate(errors_total[300s])`},
+		{"aggregation", Sum(gpu), `sum(nvidia_gpu_utilization_percent)`},
+		{"aggregation by", Count(gpu).By("hostname", "", "gpu_name"), `count by (hostname, gpu_name) (nvidia_gpu_utilization_percent)`},
+		{"aggregation without", Avg(gpu).Without("gpu_id"), `avg without (gpu_id) (nvidia_gpu_utilization_percent)`},
+		{"topk", TopK(5, gpu), `topk(5, nvidia_gpu_utilization_percent)`},
+		{
+			"left-associative chain",
+			Div(Mul(Increase(Select("energy").Over(time.Hour)), Number(0.001)), Number(3600)),
+			`increase(energy[3600s]) * 0.001 / 3600`,
+		},
+		{"right operand of equal precedence", Sub(Number(1), Sub(Number(2), Number(3))), `1 - (2 - 3)`},
+		{"power is right-associative", binary(binary(Number(2), "^", Number(3)), "^", Number(2)), `(2 ^ 3) ^ 2`},
+		{"looser operand", Mul(Add(Number(1), Number(2)), Number(3)), `(1 + 2) * 3`},
+		{"tighter operand", Add(Number(1), Mul(Number(2), Number(3))), `1 + 2 * 3`},
+		{"bool comparison", Gt(gpu, Number(80)).Bool(), `nvidia_gpu_utilization_percent > bool 80`},
+		{
+			"vector matching",
+			Mul(
+				Gt(Select("kube_pod_container_resource_requests", Eq("resource", "nvidia.com/gpu")), Number(0)),
+				Eql(Select("kube_pod_status_phase", Eq("phase", "Running")), Number(1)),
+			).On("namespace", "pod").GroupLeft(),
+			`(kube_pod_container_resource_requests{resource="nvidia.com/gpu"} > 0) * on (namespace, pod) group_left() (kube_pod_status_phase{phase="Running"} == 1)`,
+		},
+		{"ignoring", Div(gpu, gpu).Ignoring("gpu_id").GroupRight("hostname"), `nvidia_gpu_utilization_percent / ignoring (gpu_id) group_right(hostname) nvidia_gpu_utilization_percent`},
+		{"set operators", Or(And(gpu, gpu), Unless(gpu, gpu)), `nvidia_gpu_utilization_percent and nvidia_gpu_utilization_percent or nvidia_gpu_utilization_percent unless nvidia_gpu_utilization_percent`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.expr.String(); got != tt.want {
+				t.Errorf("got  %s\nwant %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWhereDoesNotAlias(t *testing.T) {
+	base := Select("up", Eq("job", "a"))
+	base.Matchers = append(make([]Matcher, 0, 4), base.Matchers...)
+
+	first := base.Where(Eq("instance", "1"))
+	second := base.Where(Eq("instance", "2"))
+	if first.String() != `up{job="a",instance="1"}` || second.String() != `up{job="a",instance="2"}` {
+		t.Errorf("derived selectors share matchers: %s, %s", first, second)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, "0s"},
+		{time.Millisecond, "1s"},
+		{500 * time.Millisecond, "1s"},
+		{time.Second, "1s"},
+		{1500 * time.Millisecond, "2s"},
+		{5 * time.Minute, "300s"},
+		{time.Hour + time.Nanosecond, "3601s"},
+	}
+	for _, tt := range tests {
+		if got := Duration(tt.d); got != tt.want {
+			t.Errorf("Duration(%s) = %s, want %s", tt.d, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package scrape
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Media types of the exposition formats an exporter may answer with.
+const (
+	textFormat        = "text/plain"
+	openMetricsFormat = "application/openmetrics-text"
+)
+
+// acceptHeader prefers OpenMetrics and falls back to the text format, as Prometheus does.
+const acceptHeader = "application/openmetrics-text;version=1.0.0;q=0.9,text/plain;version=0.0.4;q=0.5,*/*;q=0.1"
+
+// Sample is a single sample of an exposition.
+type Sample struct {
+	Name   string
+	Labels map[string]string
+	Value  float64
+	// Timestamp is zero when the exposition gives none.
+	Timestamp time.Time
+}
+
+// Parse reads the samples of an exposition in the Prometheus text format, or in
+// OpenMetrics when contentType says so. Comments, metadata and exemplars are
+// skipped.
+func Parse(r io.Reader, contentType string) ([]Sample, error) {
+	openMetrics := false
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		openMetrics = mediaType == openMetricsFormat
+	}
+
+	var samples []Sample
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	line, eof := 0, false
+	for scanner.Scan() {
+		line++
+		text := scanner.Text()
+		if eof {
+			return nil, fmt.Errorf("line %d: content after # EOF", line)
+		}
+		if openMetrics && text == "# EOF" {
+			eof = true
+			continue
+		}
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		s, err := parseSample(text, openMetrics)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, s)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading exposition: %w", err)
+	}
+	if openMetrics && !eof {
+		return nil, fmt.Errorf("missing # EOF, the exposition may be truncated")
+	}
+	return samples, nil
+}
+
+// parseSample parses a line of the form: name{label="value",...} value [timestamp].
+func parseSample(line string, openMetrics bool) (Sample, error) {
+	p := &lineParser{s: line}
+
+	name := p.name()
+	if name == "" {
+		return Sample{}, fmt.Errorf("expected a metric name in %q", line)
+	}
+	s := Sample{Name: name, Labels: map[string]string{}}
+
+	if p.peek() == '{' {
+		p.pos++
+		if err := p.labels(s.Labels); err != nil {
+			return Sample{}, err
+		}
+	}
+
+	fields := strings.Fields(p.s[p.pos:])
+	// OpenMetrics exemplars follow the sample after " # ".
+	for i, f := range fields {
+		if f == "#" {
+			fields = fields[:i]
+			break
+		}
+	}
+	if len(fields) == 0 || len(fields) > 2 {
+		return Sample{}, fmt.Errorf("expected a value and optional timestamp after %s", name)
+	}
+
+	value, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return Sample{}, fmt.Errorf("invalid value %q of %s", fields[0], name)
+	}
+	s.Value = value
+
+	if len(fields) == 2 {
+		if openMetrics {
+			seconds, err := strconv.ParseFloat(fields[1], 64)
+			if err != nil || math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+				return Sample{}, fmt.Errorf("invalid timestamp %q of %s", fields[1], name)
+			}
+			whole, frac := math.Modf(seconds)
+			s.Timestamp = time.Unix(int64(whole), int64(frac*1e9))
+		} else {
+			millis, err := strconv.ParseInt(fields[1], 10, 64)
+			if err != nil {
+				return Sample{}, fmt.Errorf("invalid timestamp %q of %s", fields[1], name)
+			}
+			s.Timestamp = time.UnixMilli(millis)
+		}
+	}
+	return s, nil
+}
+
+// lineParser scans the metric name and labels of a sample line.
+type lineParser struct {
+	s   string
+	pos int
+}
+
+func (p *lineParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *lineParser) skipSpace() {
+	for p.pos < len(p.s) && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+// name scans a metric or label name, returning "" if none starts at the cursor.
+func (p *lineParser) name() string {
+	start := p.pos
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		if c == '_' || c == ':' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || p.pos > start && c >= '0' && c <= '9' {
+			p.pos++
+			continue
+		}
+		break
+	}
+	return p.s[start:p.pos]
+}
+
+// labels scans label pairs up to and including the closing brace.
+func (p *lineParser) labels(labels map[string]string) error {
+	for {
+		p.skipSpace()
+		if p.peek() == '}' {
+			p.pos++
+			return nil
+		}
+
+		name := p.name()
+		if name == "" {
+			return fmt.Errorf("expected a label name at column %d", p.pos+1)
+		}
+		p.skipSpace()
+		if p.peek() != '=' {
+			return fmt.Errorf("expected = after label %s", name)
+		}
+		p.pos++
+		p.skipSpace()
+		value, err := p.quoted()
+		if err != nil {
+			return fmt.Errorf("label %s: %w", name, err)
+		}
+		labels[name] = value
+
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			return fmt.Errorf("expected , or } after label %s", name)
+		}
+	}
+}
+
+// quoted scans a double-quoted label value, resolving escapes.
+func (p *lineParser) quoted() (string, error) {
+	if p.peek() != '"' {
+		return "", fmt.Errorf("expected a quoted value")
+	}
+	p.pos++
+
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch c {
+		case '"':
+			return b.String(), nil
+		case '\\':
+			if p.pos == len(p.s) {
+				return "", fmt.Errorf("unterminated escape")
+			}
+			switch e := p.s[p.pos]; e {
+			case 'n':
+				b.WriteByte('\n')
+			case '\\', '"':
+				b.WriteByte(e)
+			default:
+				b.WriteByte('\\')
+				b.WriteByte(e)
+			}
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", fmt.Errorf("unterminated value")
+}
@@ -0,0 +1,85 @@
+package scrape
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseText(t *testing.T) {
+	text := `# HELP DCGM_FI_DEV_GPU_UTIL GPU utilization (in %).
+# TYPE DCGM_FI_DEV_GPU_UTIL gauge
+DCGM_FI_DEV_GPU_UTIL{gpu="0",UUID="GPU-1",modelName="NVIDIA A100",Hostname="node-a",pod="train \"a\"\\b\n",} 87
+DCGM_FI_DEV_GPU_UTIL{gpu="1", Hostname = "node-a"} NaN 1700000000123
+
+up 1
+nvidia_gpu_power_draw_watts{gpu_id="0"} +Inf
+`
+	samples, err := Parse(strings.NewReader(text), "text/plain; version=0.0.4; charset=utf-8")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(samples) != 4 {
+		t.Fatalf("expected 4 samples, got %+v", samples)
+	}
+
+	first := samples[0]
+	if first.Name != "DCGM_FI_DEV_GPU_UTIL" || first.Value != 87 || first.Labels["Hostname"] != "node-a" || first.Labels["pod"] != "train \"a\"\\b\n" || !first.Timestamp.IsZero() {
+		t.Errorf("unexpected first sample %+v", first)
+	}
+	if !math.IsNaN(samples[1].Value) || !samples[1].Timestamp.Equal(time.UnixMilli(1700000000123)) || samples[1].Labels["Hostname"] != "node-a" {
+		t.Errorf("unexpected second sample %+v", samples[1])
+	}
+	if samples[2].Name != "up" || len(samples[2].Labels) != 0 || samples[2].Value != 1 {
+		t.Errorf("unexpected unlabelled sample %+v", samples[2])
+	}
+	if !math.IsInf(samples[3].Value, 1) {
+		t.Errorf("expected +Inf, got %v", samples[3].Value)
+	}
+}
+
+func TestParseOpenMetrics(t *testing.T) {
+	text := `# TYPE gpu_energy_joules counter
+# UNIT gpu_energy_joules joules
+gpu_energy_joules_total{gpu="0"} 1234.5 1700000000.250 # {trace_id="abc"} 1.0 1700000000.0
+gpu_energy_joules_created{gpu="0"} 1699990000
+# EOF
+`
+	samples, err := Parse(strings.NewReader(text), "application/openmetrics-text; version=1.0.0; charset=utf-8")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 samples, got %+v", samples)
+	}
+	if s := samples[0]; s.Name != "gpu_energy_joules_total" || s.Value != 1234.5 || !s.Timestamp.Equal(time.UnixMilli(1700000000250)) {
+		t.Errorf("unexpected sample %+v", s)
+	}
+
+	truncated := strings.TrimSuffix(text, "# EOF\n")
+	if _, err := Parse(strings.NewReader(truncated), "application/openmetrics-text; version=1.0.0"); err == nil {
+		t.Error("expected error for an exposition without # EOF")
+	}
+	if _, err := Parse(strings.NewReader(text+"up 1\n"), "application/openmetrics-text"); err == nil {
+		t.Error("expected error for samples after # EOF")
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, line := range []string{
+		`{gpu="0"} 1`,
+		`metric{gpu="0" 1`,
+		`metric{gpu=0} 1`,
+		`metric{gpu="0} 1`,
+		`metric{="0"} 1`,
+		`metric`,
+		`metric one`,
+		`metric 1 soon`,
+		`metric 1 2 3`,
+	} {
+		if _, err := Parse(strings.NewReader(line+"\n"), "text/plain"); err == nil {
+			t.Errorf("expected error parsing %s", line)
+		}
+	}
+}
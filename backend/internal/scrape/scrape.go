@@ -0,0 +1,536 @@
+// Package scrape is a metrics source for clusters without Prometheus: it
+// scrapes GPU exporters' /metrics endpoints directly, from a configured list
+// or a DNS-discovered set, and keeps a short window of samples in memory to
+// answer range and idle queries.
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/prometheus"
+	"k8s-gpu-monitoring/internal/workgroup"
+)
+
+const (
+	// staleness is how long a GPU keeps reporting its last reading after its
+	// exporter stops answering, like Prometheus's lookback delta.
+	staleness = 5 * time.Minute
+	// maxConcurrency bounds the targets scraped at once.
+	maxConcurrency = 16
+	// maxBodyBytes bounds the size of an exposition.
+	maxBodyBytes = 64 << 20
+	// defaultPath is the metrics path of discovered targets and of targets given without one.
+	defaultPath = "/metrics"
+)
+
+// errNotScraped is reported until the first scrape has completed.
+var errNotScraped error = notScrapedError{}
+
+// notScrapedError is the type of errNotScraped.
+type notScrapedError struct{}
+
+// Error implements the error interface.
+func (notScrapedError) Error() string { return "no scrape has completed yet" }
+
+// Reason implements models.Reasoner.
+func (e notScrapedError) Reason() string { return e.Error() }
+
+// metric indexes the values of a reading.
+type metric int
+
+const (
+	utilization metric = iota
+	memoryUsed
+	memoryTotal
+	memoryFree
+	memoryUtilization
+	temperature
+	powerDraw
+	powerLimit
+	energyTotal
+	smClock
+	memoryClock
+	eccCorrected
+	eccUncorrected
+	numMetrics
+)
+
+// gpuMetricTypes names the metrics making up models.GPUMetrics, as warnings
+// of the Prometheus-backed source do.
+var gpuMetricTypes = map[metric]string{
+	utilization:       "utilization",
+	memoryUsed:        "memory_used",
+	memoryTotal:       "memory_total",
+	memoryFree:        "memory_free",
+	memoryUtilization: "memory_utilization",
+	temperature:       "temperature",
+	powerDraw:         "power_draw",
+	powerLimit:        "power_limit",
+}
+
+// reading is the values of one GPU from one scrape; missing values are NaN.
+type reading struct {
+	at     time.Time
+	values [numMetrics]float64
+}
+
+// device identifies a GPU by the labels of its latest scrape.
+type device struct {
+	nodeName      string
+	gpuIndex      int
+	gpuName       string
+	uuid          string
+	driverVersion string
+	pciBusID      string
+	owner         *models.GPUOwner
+}
+
+// series is a GPU's readings within the window, oldest first.
+type series struct {
+	device
+	readings []reading
+}
+
+// Scraper scrapes GPU exporters and serves their metrics as a single cluster.
+// It is safe for concurrent use.
+type Scraper struct {
+	cluster      string
+	schema       prometheus.Schema
+	static       []string
+	dnsName      string
+	lookupHost   func(ctx context.Context, host string) ([]string, error)
+	httpClient   *http.Client
+	interval     time.Duration
+	window       time.Duration
+	energyWindow time.Duration
+	now          func() time.Time
+	// names are the exporter metric names of each value; metrics maps them back.
+	names   [numMetrics]string
+	metrics map[string]metric
+
+	mu        sync.RWMutex
+	gpus      map[string]*series
+	targets   map[string]error
+	discovery error
+	scraped   bool
+}
+
+// Option configures optional Scraper behaviour.
+type Option func(*Scraper)
+
+// WithCluster names the cluster the scraped GPUs belong to.
+func WithCluster(name string) Option {
+	return func(s *Scraper) {
+		s.cluster = name
+	}
+}
+
+// WithSchema sets the metric schema of the scraped exporters.
+func WithSchema(schema prometheus.Schema) Option {
+	return func(s *Scraper) {
+		s.schema = schema
+	}
+}
+
+// WithDNS scrapes every address hostPort's host resolves to, e.g. the headless
+// service of an exporter DaemonSet, re-resolving it on every scrape.
+func WithDNS(hostPort string) Option {
+	return func(s *Scraper) {
+		s.dnsName = hostPort
+	}
+}
+
+// WithResolver replaces the DNS lookup of WithDNS.
+func WithResolver(lookupHost func(ctx context.Context, host string) ([]string, error)) Option {
+	return func(s *Scraper) {
+		s.lookupHost = lookupHost
+	}
+}
+
+// WithInterval sets how often Run scrapes.
+func WithInterval(interval time.Duration) Option {
+	return func(s *Scraper) {
+		if interval > 0 {
+			s.interval = interval
+		}
+	}
+}
+
+// WithWindow sets how long samples are kept for range and idle queries.
+func WithWindow(window time.Duration) Option {
+	return func(s *Scraper) {
+		if window > 0 {
+			s.window = window
+		}
+	}
+}
+
+// WithTimeout bounds each scrape of a target.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Scraper) {
+		if timeout > 0 {
+			s.httpClient.Timeout = timeout
+		}
+	}
+}
+
+// WithEnergyWindow sets the lookback of per-GPU energy consumption. It is
+// capped at the sample window.
+func WithEnergyWindow(window time.Duration) Option {
+	return func(s *Scraper) {
+		if window > 0 {
+			s.energyWindow = window
+		}
+	}
+}
+
+// WithClock replaces time.Now, for tests.
+func WithClock(now func() time.Time) Option {
+	return func(s *Scraper) {
+		s.now = now
+	}
+}
+
+// New creates a scraper of the given target URLs; a target without a scheme
+// or path is scraped over HTTP at /metrics.
+func New(targets []string, opts ...Option) (*Scraper, error) {
+	s := &Scraper{
+		schema:       prometheus.DefaultSchema(),
+		lookupHost:   net.DefaultResolver.LookupHost,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		interval:     15 * time.Second,
+		window:       15 * time.Minute,
+		energyWindow: time.Hour,
+		now:          time.Now,
+		gpus:         make(map[string]*series),
+		targets:      make(map[string]error),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if len(targets) == 0 && s.dnsName == "" {
+		return nil, fmt.Errorf("no scrape targets configured")
+	}
+	for _, target := range targets {
+		u, err := targetURL(target)
+		if err != nil {
+			return nil, err
+		}
+		s.static = append(s.static, u)
+	}
+	if s.dnsName != "" {
+		if _, _, err := net.SplitHostPort(s.dnsName); err != nil {
+			return nil, fmt.Errorf("DNS target %q is not host:port: %w", s.dnsName, err)
+		}
+	}
+	if err := s.schema.Validate(); err != nil {
+		return nil, err
+	}
+
+	m := s.schema.Metrics
+	s.names = [numMetrics]string{
+		utilization:       m.Utilization,
+		memoryUsed:        m.MemoryUsed,
+		memoryTotal:       m.MemoryTotal,
+		memoryFree:        m.MemoryFree,
+		memoryUtilization: m.MemoryUtilization,
+		temperature:       m.Temperature,
+		powerDraw:         m.PowerDraw,
+		powerLimit:        m.PowerLimit,
+		energyTotal:       m.EnergyTotal,
+		smClock:           m.SMClock,
+		memoryClock:       m.MemoryClock,
+		eccCorrected:      m.ECCCorrected,
+		eccUncorrected:    m.ECCUncorrected,
+	}
+	s.metrics = make(map[string]metric)
+	for index, name := range s.names {
+		if name != "" {
+			s.metrics[name] = metric(index)
+		}
+	}
+	return s, nil
+}
+
+// targetURL completes a target to a URL with a scheme and path.
+func targetURL(target string) (string, error) {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return "", fmt.Errorf("empty scrape target")
+	}
+	if !strings.Contains(target, "://") {
+		target = "http://" + target
+	}
+	scheme, rest, _ := strings.Cut(target, "://")
+	if scheme != "http" && scheme != "https" {
+		return "", fmt.Errorf("scrape target %q must use http or https", target)
+	}
+	if !strings.Contains(rest, "/") {
+		target += defaultPath
+	}
+	return target, nil
+}
+
+// Run scrapes every target immediately and then at every interval until ctx is canceled.
+func (s *Scraper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.Scrape(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("Error scraping GPU exporters: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover returns the static targets and the addresses the DNS name resolves to.
+func (s *Scraper) discover(ctx context.Context) ([]string, error) {
+	targets := append([]string(nil), s.static...)
+	if s.dnsName == "" {
+		return targets, nil
+	}
+
+	host, port, _ := net.SplitHostPort(s.dnsName)
+	addrs, err := s.lookupHost(ctx, host)
+	if err != nil {
+		return targets, fmt.Errorf("resolving %s: %w", host, err)
+	}
+	sort.Strings(addrs)
+	for _, addr := range addrs {
+		targets = append(targets, "http://"+net.JoinHostPort(addr, port)+defaultPath)
+	}
+	return targets, nil
+}
+
+// Scrape scrapes every target once and adds their readings to the window. It
+// returns an error when discovery failed or no target could be scraped.
+func (s *Scraper) Scrape(ctx context.Context) error {
+	targets, discoveryErr := s.discover(ctx)
+
+	samples := make([][]Sample, len(targets))
+	errs := make([]error, len(targets))
+	g, gctx := workgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrency)
+	for i, target := range targets {
+		g.Go(func() error {
+			samples[i], errs[i] = s.fetch(gctx, target)
+			return nil
+		})
+	}
+	g.Wait()
+
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scraped = true
+	s.discovery = discoveryErr
+	s.targets = make(map[string]error, len(targets))
+	for i, target := range targets {
+		s.targets[target] = errs[i]
+		if errs[i] == nil {
+			s.add(samples[i], now)
+		}
+	}
+	s.trim(now)
+
+	return s.health()
+}
+
+// fetch scrapes a single target.
+func (s *Scraper) fetch(ctx context.Context, target string) ([]Sample, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", acceptHeader)
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+		return nil, &StatusError{Target: target, StatusCode: resp.StatusCode}
+	}
+
+	samples, err := Parse(io.LimitReader(resp.Body, maxBodyBytes), resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, &ParseError{Target: target, Err: err}
+	}
+	return samples, nil
+}
+
+// add groups a target's samples into one reading per GPU. Callers must hold s.mu.
+func (s *Scraper) add(samples []Sample, scrapedAt time.Time) {
+	labels := s.schema.Labels
+	readings := make(map[string]*reading)
+	for _, smp := range samples {
+		index, ok := s.metrics[smp.Name]
+		if !ok {
+			continue
+		}
+		nodeName := smp.Labels[labels.Node]
+		gpuIndex, err := strconv.Atoi(smp.Labels[labels.GPUIndex])
+		if nodeName == "" || err != nil {
+			continue
+		}
+
+		key := gpuKey(nodeName, gpuIndex)
+		r := readings[key]
+		if r == nil {
+			r = &reading{at: scrapedAt}
+			for i := range r.values {
+				r.values[i] = math.NaN()
+			}
+			readings[key] = r
+
+			sr := s.gpus[key]
+			if sr == nil {
+				sr = &series{}
+				s.gpus[key] = sr
+			}
+			// Identity labels may change between scrapes, e.g. when a pod is replaced
+			sr.device = device{nodeName: nodeName, gpuIndex: gpuIndex}
+		}
+		if !smp.Timestamp.IsZero() && smp.Timestamp.Before(r.at) {
+			r.at = smp.Timestamp
+		}
+		r.values[index] = smp.Value
+
+		d := &s.gpus[key].device
+		set := func(dst *string, label string) {
+			if *dst == "" && label != "" {
+				*dst = smp.Labels[label]
+			}
+		}
+		set(&d.gpuName, labels.GPUName)
+		set(&d.uuid, labels.UUID)
+		set(&d.driverVersion, labels.DriverVersion)
+		set(&d.pciBusID, labels.PCIBusID)
+		if d.owner == nil {
+			d.owner = models.OwnerFromLabels(smp.Labels, labels.Pod, labels.Namespace, labels.Container)
+		}
+	}
+
+	for key, r := range readings {
+		sr := s.gpus[key]
+		// Keep readings in time order even if an exporter's clock goes backwards
+		if n := len(sr.readings); n > 0 && !r.at.After(sr.readings[n-1].at) {
+			sr.readings[n-1] = *r
+			continue
+		}
+		sr.readings = append(sr.readings, *r)
+	}
+}
+
+// trim drops readings older than the window, and GPUs left without any. Callers must hold s.mu.
+func (s *Scraper) trim(now time.Time) {
+	cutoff := now.Add(-s.window)
+	for key, sr := range s.gpus {
+		i := sort.Search(len(sr.readings), func(i int) bool {
+			return !sr.readings[i].at.Before(cutoff)
+		})
+		sr.readings = append(sr.readings[:0], sr.readings[i:]...)
+		if len(sr.readings) == 0 {
+			delete(s.gpus, key)
+		}
+	}
+}
+
+// health returns an error when the latest scrape reached no target. Callers must hold s.mu.
+func (s *Scraper) health() error {
+	if !s.scraped {
+		return errNotScraped
+	}
+
+	var errs []error
+	if s.discovery != nil {
+		errs = append(errs, s.discovery)
+	}
+	for _, target := range s.targetNames() {
+		if err := s.targets[target]; err != nil {
+			errs = append(errs, err)
+		} else {
+			return nil
+		}
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("no scrape targets discovered")
+	}
+	return errors.Join(errs...)
+}
+
+// targetNames returns the targets of the latest scrape in order. Callers must hold s.mu.
+func (s *Scraper) targetNames() []string {
+	names := make([]string, 0, len(s.targets))
+	for target := range s.targets {
+		names = append(names, target)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// gpuKey identifies a GPU by node and index.
+func gpuKey(nodeName string, gpuIndex int) string {
+	return fmt.Sprintf("%s:%d", nodeName, gpuIndex)
+}
+
+// StatusError is returned when an exporter answers a scrape with a non-200 status.
+type StatusError struct {
+	Target     string
+	StatusCode int
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("scraping %s: status %d", e.Target, e.StatusCode)
+}
+
+// Reason implements models.Reasoner.
+func (e *StatusError) Reason() string {
+	return fmt.Sprintf("exporter returned status %d", e.StatusCode)
+}
+
+// ParseError is returned when an exporter's exposition cannot be parsed.
+type ParseError struct {
+	Target string
+	Err    error
+}
+
+// Error implements the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("parsing %s: %v", e.Target, e.Err)
+}
+
+// Unwrap returns the underlying parse error.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// Reason implements models.Reasoner.
+func (e *ParseError) Reason() string {
+	return "invalid exposition: " + e.Err.Error()
+}
@@ -0,0 +1,357 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/gpusim"
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+	"k8s-gpu-monitoring/internal/prometheus"
+)
+
+// testStart is the time of the first scrape.
+var testStart = time.Unix(1700000000, 0)
+
+// exporter serves canned exposition text that tests can replace between scrapes.
+type exporter struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	body        string
+	status      int
+	contentType string
+	accept      string
+}
+
+func newExporter(t *testing.T, body string) *exporter {
+	t.Helper()
+	e := &exporter{body: body, status: http.StatusOK, contentType: "text/plain; version=0.0.4"}
+	e.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		e.accept = r.Header.Get("Accept")
+		if r.URL.Path != "/metrics" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", e.contentType)
+		w.WriteHeader(e.status)
+		fmt.Fprint(w, e.body)
+	}))
+	t.Cleanup(e.Close)
+	return e
+}
+
+func (e *exporter) set(status int, body string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.status, e.body = status, body
+}
+
+// dcgm renders dcgm-exporter output for the GPUs of a node; GPU 0 is held by a pod.
+func dcgm(node string, util, energy float64, gpus int) string {
+	var b strings.Builder
+	b.WriteString("# HELP DCGM_FI_DEV_GPU_UTIL GPU utilization (in %).\n# TYPE DCGM_FI_DEV_GPU_UTIL gauge\n")
+	for i := 0; i < gpus; i++ {
+		labels := fmt.Sprintf(`gpu="%d",UUID="GPU-%s-%d",modelName="NVIDIA A100-SXM4-80GB",Hostname="%s",DCGM_FI_DRIVER_VERSION="550.54.15",pci_bus_id="00000000:%02d:00.0"`, i, node, i, node, i+1)
+		if i == 0 {
+			labels += fmt.Sprintf(`,container="trainer",namespace="ml",pod="train-%s"`, node)
+		}
+		fmt.Fprintf(&b, "DCGM_FI_DEV_GPU_UTIL{%s} %g\n", labels, util)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_FB_USED{%s} 61440\n", labels)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_FB_FREE{%s} 20480\n", labels)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_GPU_TEMP{%s} 65\n", labels)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_POWER_USAGE{%s} 300.5\n", labels)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_ENFORCED_POWER_LIMIT{%s} 400\n", labels)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_TOTAL_ENERGY_CONSUMPTION{%s} %g\n", labels, energy)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_SM_CLOCK{%s} 1410\n", labels)
+		fmt.Fprintf(&b, "DCGM_FI_DEV_XID_ERRORS{%s} 0\n", labels)
+	}
+	return b.String()
+}
+
+// newScraper creates a dcgm scraper whose clock is advanced by the returned function.
+func newScraper(t *testing.T, targets []string, opts ...Option) (*Scraper, func(time.Duration)) {
+	t.Helper()
+	schema, _ := prometheus.LookupSchema(prometheus.SchemaDCGMExporter)
+	now := testStart
+	opts = append([]Option{WithSchema(schema), WithClock(func() time.Time { return now })}, opts...)
+	s, err := New(targets, opts...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestScrapeServesModels(t *testing.T) {
+	a := newExporter(t, dcgm("node-a", 80, 1e6, 2))
+	b := newExporter(t, dcgm("node-b", 20, 1e6, 1))
+	s, _ := newScraper(t, []string{a.URL, strings.TrimPrefix(b.URL, "http://")}, WithCluster("lab"))
+
+	if err := s.Scrape(context.Background()); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if !strings.Contains(a.accept, "application/openmetrics-text") {
+		t.Errorf("expected OpenMetrics to be accepted, got %q", a.accept)
+	}
+
+	ctx := context.Background()
+	gpus, warnings, err := s.GetGPUMetrics(ctx)
+	if err != nil || len(gpus) != 3 {
+		t.Fatalf("expected 3 GPUs, got %+v, %v", gpus, err)
+	}
+	m := gpus[0]
+	if m.Cluster != "lab" || m.NodeName != "node-a" || m.GPUIndex != 0 || m.GPUName != "NVIDIA A100-SXM4-80GB" || m.UUID != "GPU-node-a-0" || m.Utilization != 80 {
+		t.Errorf("unexpected identity %+v", m)
+	}
+	if m.MemoryUsed != 60 || m.MemoryFree != 20 || m.MemoryTotal != 80 || m.PowerHeadroom != 99.5 || !m.Timestamp.Equal(testStart) {
+		t.Errorf("unexpected readings %+v", m)
+	}
+	if m.Owner == nil || m.Owner.Namespace != "ml" || m.Owner.Pod != "train-node-a" || m.Owner.Source != models.OwnerSourceExporter || gpus[1].Owner != nil {
+		t.Errorf("unexpected owners %+v, %+v", m.Owner, gpus[1].Owner)
+	}
+	// dcgm-exporter reports no memory copy utilization in this exposition
+	if len(warnings) != 1 || warnings[0].Metric != "memory_utilization" || warnings[0].Reason != "no series found" {
+		t.Errorf("unexpected warnings %+v", warnings)
+	}
+
+	nodes, _, err := s.GetGPUNodes(ctx)
+	if err != nil || len(nodes) != 2 || nodes[0].GPUCount != 2 || nodes[0].PowerLimit != 800 || nodes[1].NodeName != "node-b" {
+		t.Errorf("unexpected nodes %+v, %v", nodes, err)
+	}
+
+	device, _, err := s.GetGPUDevice(ctx, "node-a", 1)
+	if err != nil || device.DriverVersion != "550.54.15" || device.PCIBusID != "00000000:02:00.0" || device.SMClock != 1410 {
+		t.Errorf("unexpected device %+v, %v", device, err)
+	}
+	if _, _, err := s.GetGPUDevice(ctx, "node-a", 7); !errors.Is(err, metrics.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+
+	detail, _, err := s.GetGPUNode(ctx, "node-a")
+	if err != nil || detail.GPUCount != 2 || detail.AverageUtilization != 80 || detail.MemoryTotal != 160 {
+		t.Errorf("unexpected node detail %+v, %v", detail, err)
+	}
+
+	pods, _, err := s.GetGPUPods(ctx)
+	if err != nil || len(pods) != 2 || pods[0].Pod != "train-node-a" || len(pods[0].GPUs) != 1 || pods[0].GPUs[0].Cluster != "lab" {
+		t.Errorf("unexpected pods %+v, %v", pods, err)
+	}
+
+	samples, _, err := s.GetGPUUtilization(ctx)
+	if err != nil || len(samples) != 3 {
+		t.Fatalf("unexpected utilization %+v, %v", samples, err)
+	}
+	if sample := samples[2]; sample.Cluster != "lab" || sample.NodeName != "node-b" || sample.GPUIndex != 0 || sample.Timestamp.IsZero() {
+		t.Errorf("unexpected utilization sample %+v", sample)
+	}
+
+	scoped := metrics.WithScope(ctx, metrics.Scope{Clauses: []metrics.ScopeClause{{Namespaces: []string{"ml"}}}})
+	if gpus, _, _ := s.GetGPUMetrics(scoped); len(gpus) != 2 {
+		t.Errorf("expected the 2 GPUs of namespace ml, got %+v", gpus)
+	}
+	if gpus, _, _ := s.GetGPUMetrics(metrics.WithClusters(ctx, []string{"other"})); len(gpus) != 0 {
+		t.Errorf("expected no GPUs for another cluster, got %+v", gpus)
+	}
+	if err := s.Validate([]string{"other"}); !errors.Is(err, metrics.ErrUnknownCluster) {
+		t.Errorf("expected ErrUnknownCluster, got %v", err)
+	}
+}
+
+func TestScrapeFailures(t *testing.T) {
+	a := newExporter(t, dcgm("node-a", 50, 0, 1))
+	b := newExporter(t, "")
+	b.set(http.StatusInternalServerError, "boom")
+	s, advance := newScraper(t, []string{a.URL, b.URL})
+	ctx := context.Background()
+
+	if gpus, _, err := s.GetGPUMetrics(ctx); err != nil || len(gpus) != 0 {
+		t.Errorf("expected no GPUs before the first scrape, got %+v, %v", gpus, err)
+	}
+
+	if err := s.Scrape(ctx); err != nil {
+		t.Fatalf("expected a partial scrape to succeed, got %v", err)
+	}
+	gpus, warnings, err := s.GetGPUMetrics(ctx)
+	if err != nil || len(gpus) != 1 {
+		t.Fatalf("expected node-a's GPU, got %+v, %v", gpus, err)
+	}
+	if len(warnings) < 1 || warnings[0].Metric != "target" || warnings[0].Query != b.URL+"/metrics" || warnings[0].Reason != "exporter returned status 500" {
+		t.Errorf("unexpected warnings %+v", warnings)
+	}
+	if strings.Contains(fmt.Sprint(warnings), "boom") {
+		t.Error("expected response bodies not to be echoed")
+	}
+
+	// A malformed exposition fails the target too; the last readings stay until they go stale
+	a.set(http.StatusOK, "DCGM_FI_DEV_GPU_UTIL{gpu=\"0\" 1\n")
+	advance(time.Minute)
+	if err := s.Scrape(ctx); err == nil {
+		t.Fatal("expected an error when no target could be scraped")
+	}
+	gpus, warnings, err = s.GetGPUMetrics(ctx)
+	if err != nil || len(gpus) != 1 || !strings.Contains(fmt.Sprint(warnings), "invalid exposition") {
+		t.Errorf("expected the stale reading with warnings, got %+v, %+v, %v", gpus, warnings, err)
+	}
+	if failures := s.Ping(ctx); failures[""] == nil {
+		t.Errorf("expected Ping to report the cluster down, got %v", failures)
+	}
+	summaries, _ := s.Summaries(ctx)
+	if len(summaries) != 1 || summaries[0].Healthy || summaries[0].Error == "" {
+		t.Errorf("expected an unhealthy summary, got %+v", summaries)
+	}
+
+	advance(staleness)
+	s.Scrape(ctx)
+	if _, _, err := s.GetGPUMetrics(ctx); err == nil {
+		t.Error("expected an error once every reading went stale")
+	}
+}
+
+func TestDNSDiscovery(t *testing.T) {
+	a := newExporter(t, dcgm("node-a", 50, 0, 2))
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(a.URL, "http://"))
+
+	addrs := []string{"127.0.0.1"}
+	resolve := func(ctx context.Context, host string) ([]string, error) {
+		if host != "dcgm-exporter.gpu.svc" {
+			return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+		}
+		return addrs, nil
+	}
+	s, _ := newScraper(t, nil, WithDNS("dcgm-exporter.gpu.svc:"+port), WithResolver(resolve))
+
+	if err := s.Scrape(context.Background()); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	if gpus, _, _ := s.GetGPUMetrics(context.Background()); len(gpus) != 2 {
+		t.Errorf("expected the discovered exporter's 2 GPUs, got %+v", gpus)
+	}
+
+	addrs = nil
+	if err := s.Scrape(context.Background()); err == nil {
+		t.Error("expected an error when no exporter is discovered")
+	}
+
+	if _, err := New(nil); err == nil {
+		t.Error("expected an error without targets")
+	}
+	if _, err := New(nil, WithDNS("dcgm-exporter")); err == nil {
+		t.Error("expected an error for a DNS name without port")
+	}
+}
+
+func TestWindow(t *testing.T) {
+	a := newExporter(t, "")
+	s, advance := newScraper(t, []string{a.URL}, WithWindow(10*time.Minute), WithEnergyWindow(time.Hour))
+	ctx := context.Background()
+
+	// One reading a minute for 15 minutes, utilization counting up and energy growing 60 kJ a minute
+	for i := 0; i <= 15; i++ {
+		if i > 0 {
+			advance(time.Minute)
+		}
+		a.set(http.StatusOK, dcgm("node-a", float64(i), float64(i)*60e6, 1))
+		if err := s.Scrape(ctx); err != nil {
+			t.Fatalf("Scrape: %v", err)
+		}
+	}
+	end := testStart.Add(15 * time.Minute)
+
+	series, warnings, err := s.GetGPUMetricsRange(ctx, end.Add(-20*time.Minute), end, 5*time.Minute)
+	if err != nil || len(series) != 1 {
+		t.Fatalf("expected 1 series, got %+v, %v", series, err)
+	}
+	points := series[0].Utilization
+	if len(points) != 3 || points[0].Value != 5 || points[2].Value != 15 || !points[2].Timestamp.Equal(end) {
+		t.Errorf("expected readings from the last 10 minutes only, got %+v", points)
+	}
+	if len(series[0].MemoryTotal) != 3 || series[0].MemoryTotal[0].Value != 80 {
+		t.Errorf("unexpected memory totals %+v", series[0].MemoryTotal)
+	}
+	if len(warnings) != 1 || warnings[0].Metric != "range" {
+		t.Errorf("expected a warning about the window, got %+v", warnings)
+	}
+	if _, warnings, _ := s.GetGPUMetricsRange(ctx, end.Add(-5*time.Minute), end, time.Minute); len(warnings) != 0 {
+		t.Errorf("expected no warning within the window, got %+v", warnings)
+	}
+
+	// The energy window is capped at the 10 minute sample window: 10 minutes of 60 kJ
+	gpus, _, _ := s.GetGPUMetrics(ctx)
+	if m := gpus[0]; m.EnergyWindow != "10m0s" || m.EnergyConsumed != 10*60e3/3600 {
+		t.Errorf("unexpected energy %g over %s", m.EnergyConsumed, m.EnergyWindow)
+	}
+
+	// Idle detection judges the readings within the lookback: 11 to 15
+	report, warnings, err := s.GetIdleGPUs(ctx, metrics.IdleCriteria{Lookback: 4 * time.Minute, MaxAverage: 13, MaxP95: 15})
+	if err != nil || len(report.GPUs) != 1 || report.GPUs[0].AverageUtilization != 13 || report.GPUs[0].P95Utilization != 15 || len(warnings) != 0 {
+		t.Errorf("unexpected idle report %+v, %+v, %v", report, warnings, err)
+	}
+	report, warnings, _ = s.GetIdleGPUs(ctx, metrics.IdleCriteria{Lookback: time.Hour, MaxAverage: 5, MaxP95: 20})
+	if len(report.GPUs) != 0 || len(warnings) != 1 || warnings[0].Metric != "utilization" {
+		t.Errorf("expected no idle GPU and a window warning, got %+v, %+v", report, warnings)
+	}
+}
+
+func TestOpenMetricsExporter(t *testing.T) {
+	a := newExporter(t, `# TYPE nvidia_gpu_utilization_percent gauge
+nvidia_gpu_utilization_percent{hostname="node-a",gpu_id="0",gpu_name="Tesla T4"} 42.5
+nvidia_gpu_total_memory_bytes{hostname="node-a",gpu_id="0"} 17179869184
+# EOF
+`)
+	a.contentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	s, err := New([]string{a.URL}, WithClock(func() time.Time { return testStart }))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if err := s.Scrape(context.Background()); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+	gpus, _, _ := s.GetGPUMetrics(context.Background())
+	if len(gpus) != 1 || gpus[0].Utilization != 42.5 || gpus[0].MemoryTotal != 16 || gpus[0].GPUName != "Tesla T4" {
+		t.Errorf("unexpected gpus %+v", gpus)
+	}
+}
+
+func TestScrapeSimulator(t *testing.T) {
+	schema, _ := prometheus.LookupSchema(prometheus.SchemaDCGMExporter)
+	sim, err := gpusim.New(gpusim.Config{Schema: schema, Nodes: 3, GPUsPerNode: 4}, testStart)
+	if err != nil {
+		t.Fatalf("gpusim.New: %v", err)
+	}
+	server := httptest.NewServer(sim.Handler(func() time.Time { return testStart.Add(time.Minute) }))
+	defer server.Close()
+
+	var targets []string
+	for _, node := range sim.Nodes() {
+		targets = append(targets, server.URL+"/nodes/"+node+"/metrics")
+	}
+	s, _ := newScraper(t, targets)
+	if err := s.Scrape(context.Background()); err != nil {
+		t.Fatalf("Scrape: %v", err)
+	}
+
+	nodes, _, err := s.GetGPUNodes(context.Background())
+	if err != nil || len(nodes) != 3 || nodes[2].GPUCount != 4 {
+		t.Fatalf("expected 3 nodes of 4 GPUs, got %+v, %v", nodes, err)
+	}
+	gpus, warnings, _ := s.GetGPUMetrics(context.Background())
+	if len(warnings) != 0 {
+		t.Errorf("expected the simulator to report every metric, got %+v", warnings)
+	}
+	for _, m := range gpus {
+		if m.MemoryTotal != 80 || m.PowerLimit != 400 || m.Temperature == 0 {
+			t.Errorf("unexpected simulated readings %+v", m)
+		}
+	}
+}
@@ -0,0 +1,573 @@
+package scrape
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"k8s-gpu-monitoring/internal/metrics"
+	"k8s-gpu-monitoring/internal/models"
+)
+
+var _ metrics.Source = (*Scraper)(nil)
+
+// Names returns the cluster name, or nil when it is unnamed.
+func (s *Scraper) Names() []string {
+	if s.cluster == "" {
+		return nil
+	}
+	return []string{s.cluster}
+}
+
+// Validate checks that every name refers to the scraped cluster.
+func (s *Scraper) Validate(names []string) error {
+	for _, name := range names {
+		if name != s.cluster {
+			return fmt.Errorf("%w %q", metrics.ErrUnknownCluster, name)
+		}
+	}
+	return nil
+}
+
+// selected reports whether ctx selects the scraped cluster.
+func (s *Scraper) selected(ctx context.Context) bool {
+	names := metrics.ClustersFrom(ctx)
+	if len(names) == 0 {
+		return true
+	}
+	for _, name := range names {
+		if name == s.cluster {
+			return true
+		}
+	}
+	return false
+}
+
+// visible returns the GPUs with a reading within the staleness period that the
+// caller's scope allows, in node and index order. Callers must hold s.mu.
+func (s *Scraper) visible(ctx context.Context, now time.Time) []*series {
+	scope, scoped := metrics.ScopeFrom(ctx)
+	var gpus []*series
+	for _, sr := range s.gpus {
+		latest := sr.readings[len(sr.readings)-1]
+		if now.Sub(latest.at) > staleness {
+			continue
+		}
+		if scoped && !scope.Allows(sr.nodeName, sr.owner) {
+			continue
+		}
+		gpus = append(gpus, sr)
+	}
+	sort.Slice(gpus, func(i, j int) bool {
+		if gpus[i].nodeName != gpus[j].nodeName {
+			return gpus[i].nodeName < gpus[j].nodeName
+		}
+		return gpus[i].gpuIndex < gpus[j].gpuIndex
+	})
+	return gpus
+}
+
+// begin returns the visible GPUs and a warning for each target that failed the
+// latest scrape. It fails when no target could be scraped and no GPU is left.
+// Callers must hold s.mu.
+func (s *Scraper) begin(ctx context.Context) ([]*series, []models.Warning, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+	if !s.selected(ctx) {
+		return nil, nil, nil
+	}
+
+	gpus := s.visible(ctx, s.now())
+	if err := s.health(); err != nil && len(gpus) == 0 {
+		if errors.Is(err, errNotScraped) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	var warnings []models.Warning
+	if s.discovery != nil {
+		warnings = append(warnings, models.Warning{Cluster: s.cluster, Metric: "discovery", Query: s.dnsName, Reason: models.FailureReason(s.discovery)})
+	}
+	for _, target := range s.targetNames() {
+		if err := s.targets[target]; err != nil {
+			warnings = append(warnings, models.Warning{Cluster: s.cluster, Metric: "target", Query: target, Reason: models.FailureReason(err)})
+		}
+	}
+	return gpus, warnings, nil
+}
+
+// missingWarnings reports the GPU metrics the schema names that no visible GPU reported.
+func (s *Scraper) missingWarnings(gpus []*series) []models.Warning {
+	if len(gpus) == 0 {
+		return nil
+	}
+
+	var warnings []models.Warning
+	for index := utilization; index < numMetrics; index++ {
+		metricType, ok := gpuMetricTypes[index]
+		name := s.names[index]
+		if !ok || name == "" {
+			continue
+		}
+
+		reported := false
+		for _, sr := range gpus {
+			if !math.IsNaN(sr.readings[len(sr.readings)-1].values[index]) {
+				reported = true
+				break
+			}
+		}
+		if !reported {
+			warnings = append(warnings, models.Warning{Cluster: s.cluster, Metric: metricType, Query: name, Reason: "no series found"})
+		}
+	}
+	return warnings
+}
+
+// gpuMetrics converts a GPU's latest reading to models.GPUMetrics.
+func (s *Scraper) gpuMetrics(sr *series) models.GPUMetrics {
+	latest := sr.readings[len(sr.readings)-1]
+	value := func(index metric) float64 {
+		if v := latest.values[index]; !math.IsNaN(v) {
+			return v
+		}
+		return 0
+	}
+
+	m := models.GPUMetrics{
+		Cluster:           s.cluster,
+		NodeName:          sr.nodeName,
+		GPUIndex:          sr.gpuIndex,
+		GPUName:           sr.gpuName,
+		UUID:              sr.uuid,
+		Utilization:       value(utilization),
+		MemoryUsed:        s.memoryToGB(value(memoryUsed)),
+		MemoryTotal:       s.memoryToGB(value(memoryTotal)),
+		MemoryFree:        s.memoryToGB(value(memoryFree)),
+		MemoryUtilization: value(memoryUtilization),
+		Temperature:       value(temperature),
+		PowerDraw:         value(powerDraw),
+		PowerLimit:        value(powerLimit),
+		Owner:             sr.owner,
+		Timestamp:         latest.at,
+	}
+	// Exporters without a total memory metric report used and free only
+	if s.schema.Metrics.MemoryTotal == "" {
+		m.MemoryTotal = m.MemoryUsed + m.MemoryFree
+	}
+	if m.PowerLimit > 0 {
+		m.PowerHeadroom = m.PowerLimit - m.PowerDraw
+	}
+	if energy, window, ok := s.energy(sr, latest.at); ok {
+		m.EnergyConsumed = energy
+		m.EnergyWindow = window.String()
+	}
+	return m
+}
+
+// energy returns the watt-hours a GPU consumed over the energy window, capped
+// at the sample window, from the energy counter or else the average power draw.
+func (s *Scraper) energy(sr *series, now time.Time) (float64, time.Duration, bool) {
+	window := min(s.energyWindow, s.window)
+	start := now.Add(-window)
+
+	var joules, powerSum float64
+	var powerCount int
+	counter := false
+	prev := math.NaN()
+	for _, r := range sr.readings {
+		if r.at.Before(start) {
+			continue
+		}
+		if v := r.values[energyTotal]; !math.IsNaN(v) {
+			counter = true
+			switch {
+			case math.IsNaN(prev):
+			case v >= prev:
+				joules += (v - prev) * s.schema.EnergyJoulesPerUnit
+			default:
+				// The counter reset, e.g. when the driver reloaded
+				joules += v * s.schema.EnergyJoulesPerUnit
+			}
+			prev = v
+		}
+		if v := r.values[powerDraw]; !math.IsNaN(v) {
+			powerSum += v
+			powerCount++
+		}
+	}
+
+	switch {
+	case counter:
+		return joules / 3600, window, true
+	case powerCount > 0:
+		return powerSum / float64(powerCount) * window.Hours(), window, true
+	}
+	return 0, 0, false
+}
+
+// memoryToGB converts a memory sample in the exporter's unit to gigabytes.
+func (s *Scraper) memoryToGB(value float64) float64 {
+	return value * s.schema.MemoryBytesPerUnit / (1024 * 1024 * 1024)
+}
+
+// GetGPUMetrics returns the latest reading of every visible GPU.
+func (s *Scraper) GetGPUMetrics(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+	return s.SelectGPUMetrics(ctx, metrics.GPUSelector{})
+}
+
+// SelectGPUMetrics returns the latest reading of the visible GPUs matching sel.
+func (s *Scraper) SelectGPUMetrics(ctx context.Context, sel metrics.GPUSelector) ([]models.GPUMetrics, []models.Warning, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.selectGPUs(ctx, sel)
+}
+
+// selectGPUs returns the latest reading of the visible GPUs matching sel.
+// Callers must hold s.mu.
+func (s *Scraper) selectGPUs(ctx context.Context, sel metrics.GPUSelector) ([]models.GPUMetrics, []models.Warning, error) {
+	gpus, warnings, err := s.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	selected := make([]models.GPUMetrics, 0, len(gpus))
+	var matched []*series
+	for _, sr := range gpus {
+		if sel.Matches(sr.nodeName, sr.gpuName, sr.owner) {
+			selected = append(selected, s.gpuMetrics(sr))
+			matched = append(matched, sr)
+		}
+	}
+	return selected, append(warnings, s.missingWarnings(matched)...), nil
+}
+
+// GetGPUMetricsRange returns the readings of visible GPUs between start and end,
+// taking at every step the latest reading no older than the staleness period.
+// Samples older than the window are gone, which is reported as a warning.
+func (s *Scraper) GetGPUMetricsRange(ctx context.Context, start, end time.Time, step time.Duration) ([]models.GPUTimeSeries, []models.Warning, error) {
+	if step <= 0 {
+		return nil, nil, fmt.Errorf("step must be positive")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gpus, warnings, err := s.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if oldest := s.now().Add(-s.window); start.Before(oldest) {
+		warnings = append(warnings, s.windowWarning("range"))
+	}
+
+	series := make([]models.GPUTimeSeries, 0, len(gpus))
+	for _, sr := range gpus {
+		ts := models.GPUTimeSeries{
+			Cluster:     s.cluster,
+			NodeName:    sr.nodeName,
+			GPUIndex:    sr.gpuIndex,
+			GPUName:     sr.gpuName,
+			Utilization: []models.DataPoint{},
+			MemoryUsed:  []models.DataPoint{},
+			MemoryTotal: []models.DataPoint{},
+			MemoryFree:  []models.DataPoint{},
+			Temperature: []models.DataPoint{},
+			PowerDraw:   []models.DataPoint{},
+		}
+		add := func(points *[]models.DataPoint, t time.Time, v, scale float64) {
+			if !math.IsNaN(v) {
+				*points = append(*points, models.DataPoint{Timestamp: t.UTC(), Value: v * scale})
+			}
+		}
+
+		next := 0
+		for t := start; !t.After(end); t = t.Add(step) {
+			for next < len(sr.readings) && !sr.readings[next].at.After(t) {
+				next++
+			}
+			if next == 0 || t.Sub(sr.readings[next-1].at) > staleness {
+				continue
+			}
+
+			v := sr.readings[next-1].values
+			gb := s.memoryToGB(1)
+			add(&ts.Utilization, t, v[utilization], 1)
+			add(&ts.MemoryUsed, t, v[memoryUsed], gb)
+			add(&ts.MemoryFree, t, v[memoryFree], gb)
+			if s.schema.Metrics.MemoryTotal == "" {
+				add(&ts.MemoryTotal, t, v[memoryUsed]+v[memoryFree], gb)
+			} else {
+				add(&ts.MemoryTotal, t, v[memoryTotal], gb)
+			}
+			add(&ts.Temperature, t, v[temperature], 1)
+			add(&ts.PowerDraw, t, v[powerDraw], 1)
+		}
+		series = append(series, ts)
+	}
+	return series, warnings, nil
+}
+
+// windowWarning tells that a query reached further back than the samples kept.
+func (s *Scraper) windowWarning(metric string) models.Warning {
+	return models.Warning{Cluster: s.cluster, Metric: metric, Reason: fmt.Sprintf("scrape mode keeps only the last %s of samples", s.window)}
+}
+
+// GetGPUUtilization returns the latest utilization of visible GPUs.
+func (s *Scraper) GetGPUUtilization(ctx context.Context) ([]metrics.UtilizationSample, []models.Warning, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gpus, warnings, err := s.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var samples []metrics.UtilizationSample
+	for _, sr := range gpus {
+		latest := sr.readings[len(sr.readings)-1]
+		if math.IsNaN(latest.values[utilization]) {
+			continue
+		}
+		samples = append(samples, metrics.UtilizationSample{
+			Cluster:     s.cluster,
+			NodeName:    sr.nodeName,
+			GPUIndex:    sr.gpuIndex,
+			Utilization: latest.values[utilization],
+			Timestamp:   latest.at,
+		})
+	}
+	return samples, warnings, nil
+}
+
+// GetGPUNodes groups the visible GPUs into nodes.
+func (s *Scraper) GetGPUNodes(ctx context.Context) ([]models.GPUNode, []models.Warning, error) {
+	metrics, warnings, err := s.GetGPUMetrics(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return models.GroupNodes(metrics), warnings, nil
+}
+
+// GetGPUNode returns the latest readings of every visible GPU on a node.
+func (s *Scraper) GetGPUNode(ctx context.Context, nodeName string) (*models.GPUNodeDetail, []models.Warning, error) {
+	gpus, warnings, err := s.SelectGPUMetrics(ctx, metrics.GPUSelector{Nodes: []string{nodeName}})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(gpus) == 0 {
+		return nil, nil, metrics.ErrNotFound
+	}
+
+	detail := &models.GPUNodeDetail{GPUNode: models.GroupNodes(gpus)[0], GPUs: gpus}
+	for _, m := range gpus {
+		detail.AverageUtilization += m.Utilization
+		detail.MemoryUsed += m.MemoryUsed
+		detail.MemoryTotal += m.MemoryTotal
+	}
+	detail.AverageUtilization /= float64(len(gpus))
+	return detail, warnings, nil
+}
+
+// GetGPUDevice returns the latest reading and device information of a visible GPU.
+func (s *Scraper) GetGPUDevice(ctx context.Context, nodeName string, gpuIndex int) (*models.GPUDetail, []models.Warning, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gpus, warnings, err := s.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, sr := range gpus {
+		if sr.nodeName != nodeName || sr.gpuIndex != gpuIndex {
+			continue
+		}
+
+		latest := sr.readings[len(sr.readings)-1]
+		value := func(index metric) float64 {
+			if v := latest.values[index]; !math.IsNaN(v) {
+				return v
+			}
+			return 0
+		}
+		return &models.GPUDetail{
+			GPUMetrics:           s.gpuMetrics(sr),
+			DriverVersion:        sr.driverVersion,
+			PCIBusID:             sr.pciBusID,
+			SMClock:              value(smClock),
+			MemoryClock:          value(memoryClock),
+			ECCCorrectedErrors:   value(eccCorrected),
+			ECCUncorrectedErrors: value(eccUncorrected),
+		}, append(warnings, s.missingWarnings([]*series{sr})...), nil
+	}
+	return nil, nil, metrics.ErrNotFound
+}
+
+// GetGPUPods groups the visible GPUs by the pod container the exporter labels
+// them with. Without kube-state-metrics the requested GPU counts are unknown.
+func (s *Scraper) GetGPUPods(ctx context.Context) ([]models.GPUPodAllocation, []models.Warning, error) {
+	metrics, warnings, err := s.GetGPUMetrics(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	allocations := make(map[string]*models.GPUPodAllocation) // key: "namespace/pod/container"
+	var keys []string
+	for _, m := range metrics {
+		if m.Owner == nil {
+			continue
+		}
+
+		key := fmt.Sprintf("%s/%s/%s", m.Owner.Namespace, m.Owner.Pod, m.Owner.Container)
+		alloc := allocations[key]
+		if alloc == nil {
+			alloc = &models.GPUPodAllocation{
+				Cluster:   s.cluster,
+				Namespace: m.Owner.Namespace,
+				Pod:       m.Owner.Pod,
+				Container: m.Owner.Container,
+				NodeName:  m.NodeName,
+				GPUs:      make([]models.GPURef, 0),
+			}
+			allocations[key] = alloc
+			keys = append(keys, key)
+		}
+		alloc.GPUs = append(alloc.GPUs, models.GPURef{Cluster: s.cluster, NodeName: m.NodeName, GPUIndex: m.GPUIndex, UUID: m.UUID})
+		alloc.AverageUtilization += m.Utilization
+		alloc.MemoryUsed += m.MemoryUsed
+	}
+
+	sort.Strings(keys)
+	pods := make([]models.GPUPodAllocation, 0, len(keys))
+	for _, key := range keys {
+		alloc := allocations[key]
+		alloc.AverageUtilization /= float64(len(alloc.GPUs))
+		pods = append(pods, *alloc)
+	}
+	return pods, warnings, nil
+}
+
+// GetIdleGPUs finds visible GPUs whose average and p95 utilization stayed at
+// or below the thresholds over the lookback, ranked by wasted GPU-hours. Only
+// the samples within the window can be judged, which is reported as a warning
+// when the lookback is longer.
+func (s *Scraper) GetIdleGPUs(ctx context.Context, criteria metrics.IdleCriteria) (*models.IdleGPUReport, []models.Warning, error) {
+	if criteria.Lookback <= 0 {
+		return nil, nil, fmt.Errorf("lookback must be positive")
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	gpus, warnings, err := s.begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if criteria.Lookback > s.window {
+		warnings = append(warnings, s.windowWarning("utilization"))
+	}
+
+	report := &models.IdleGPUReport{
+		Lookback:   criteria.Lookback.String(),
+		MaxAverage: criteria.MaxAverage,
+		MaxP95:     criteria.MaxP95,
+		GPUs:       []models.IdleGPU{},
+	}
+	start := s.now().Add(-criteria.Lookback)
+	for _, sr := range gpus {
+		var values []float64
+		for _, r := range sr.readings {
+			if !r.at.Before(start) && !math.IsNaN(r.values[utilization]) {
+				values = append(values, r.values[utilization])
+			}
+		}
+		if len(values) == 0 {
+			continue
+		}
+
+		average, p95 := models.UtilizationSummary(values)
+		if average > criteria.MaxAverage || p95 > criteria.MaxP95 || (criteria.AllocatedOnly && sr.owner == nil) {
+			continue
+		}
+
+		observed := math.Min(float64(len(values))*s.interval.Hours(), criteria.Lookback.Hours())
+		report.GPUs = append(report.GPUs, models.IdleGPU{
+			GPUMetrics:         s.gpuMetrics(sr),
+			AverageUtilization: average,
+			P95Utilization:     p95,
+			ObservedHours:      observed,
+			WastedGPUHours:     observed * (100 - average) / 100,
+		})
+		report.TotalWastedGPUHours += observed * (100 - average) / 100
+	}
+
+	sort.SliceStable(report.GPUs, func(i, j int) bool {
+		return report.GPUs[i].WastedGPUHours > report.GPUs[j].WastedGPUHours
+	})
+	return report, warnings, nil
+}
+
+// Summaries reports the GPU totals of the scraped cluster, marking it unhealthy
+// when the latest scrape reached no exporter.
+func (s *Scraper) Summaries(ctx context.Context) ([]models.ClusterSummary, []models.Warning) {
+	if !s.selected(ctx) {
+		return []models.ClusterSummary{}, nil
+	}
+
+	// Read the GPUs and the scrape health under one lock so both come from the same scrape
+	summary := models.ClusterSummary{Name: s.cluster, CheckedAt: s.now()}
+	s.mu.RLock()
+	gpus, warnings, err := s.selectGPUs(ctx, metrics.GPUSelector{})
+	if err == nil {
+		err = s.health()
+	}
+	s.mu.RUnlock()
+	if err != nil {
+		summary.Error = models.FailureReason(err)
+		return []models.ClusterSummary{summary}, nil
+	}
+
+	summary.Healthy = true
+	nodes := make(map[string]bool)
+	for _, m := range gpus {
+		nodes[m.NodeName] = true
+		summary.AverageUtilization += m.Utilization
+		summary.MemoryUsed += m.MemoryUsed
+		summary.MemoryTotal += m.MemoryTotal
+		summary.PowerDraw += m.PowerDraw
+	}
+	summary.NodeCount = len(nodes)
+	summary.GPUCount = len(gpus)
+	if summary.GPUCount > 0 {
+		summary.AverageUtilization /= float64(summary.GPUCount)
+	}
+	return []models.ClusterSummary{summary}, warnings
+}
+
+// Ping returns the cluster's error when the latest scrape reached no exporter.
+func (s *Scraper) Ping(ctx context.Context) map[string]error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	failures := make(map[string]error)
+	if err := s.health(); err != nil && s.selected(ctx) {
+		failures[s.cluster] = err
+	}
+	return failures
+}
+
+// CacheStats reports caching as disabled; readings are served from memory.
+func (s *Scraper) CacheStats() (metrics.CacheStats, bool) {
+	return metrics.CacheStats{}, false
+}
+
+// BackendStatuses returns nil; exporters are scraped without a circuit breaker.
+func (s *Scraper) BackendStatuses() []metrics.BackendStatus {
+	return nil
+}
@@ -0,0 +1,169 @@
+// Package spa serves a built single-page application from a file system,
+// falling back to index.html for client-side routes.
+package spa
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// indexFile is served for the root and for client-side routes.
+const indexFile = "index.html"
+
+// Cache-Control values for fingerprinted assets and for everything else.
+const (
+	immutableCache = "public, max-age=31536000, immutable"
+	revalidate     = "no-cache"
+)
+
+// assetsDir is where the build tool writes fingerprinted bundles.
+const assetsDir = "assets/"
+
+// hashedAsset matches file names carrying a content hash, such as the
+// index-BkZ3a9xQ.js bundles Vite emits, which never change once published.
+var hashedAsset = regexp.MustCompile(`[.-][A-Za-z0-9_-]{8,}\.[A-Za-z0-9]+$`)
+
+// encodings lists the precompressed variants looked up next to each file, in order of preference.
+var encodings = []struct {
+	name      string
+	extension string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// Handler serves the application in fsys. Existing files are served as is,
+// preferring a precompressed .br or .gz sibling the client accepts. Paths
+// without a file extension that match no file are client-side routes and get
+// index.html; missing assets and anything under apiPrefix get 404.
+func Handler(fsys fs.FS, apiPrefix string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		urlPath := path.Clean("/" + r.URL.Path)
+		if apiPrefix != "" && (urlPath+"/" == apiPrefix || strings.HasPrefix(urlPath, apiPrefix)) {
+			http.NotFound(w, r)
+			return
+		}
+
+		name := strings.TrimPrefix(urlPath, "/")
+		if name == "" {
+			name = indexFile
+		}
+		if info, err := fs.Stat(fsys, name); err == nil && info.IsDir() {
+			name = path.Join(name, indexFile)
+		}
+
+		if _, err := fs.Stat(fsys, name); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) || path.Ext(name) != "" {
+				http.NotFound(w, r)
+				return
+			}
+			name = indexFile
+		}
+
+		serveFile(w, r, fsys, name)
+	})
+}
+
+// serveFile writes name with caching headers, using a precompressed variant when possible.
+func serveFile(w http.ResponseWriter, r *http.Request, fsys fs.FS, name string) {
+	header := w.Header()
+	if strings.HasPrefix(name, assetsDir) && hashedAsset.MatchString(path.Base(name)) {
+		header.Set("Cache-Control", immutableCache)
+	} else {
+		header.Set("Cache-Control", revalidate)
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	header.Add("Vary", "Accept-Encoding")
+
+	accepted := acceptedEncodings(r.Header.Get("Accept-Encoding"))
+	for _, encoding := range encodings {
+		if !accepted[encoding.name] {
+			continue
+		}
+		if f, info, ok := open(fsys, name+encoding.extension); ok {
+			defer f.Close()
+			header.Set("Content-Encoding", encoding.name)
+			serveContent(w, r, name, info.ModTime(), f)
+			return
+		}
+	}
+
+	f, info, ok := open(fsys, name)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+	serveContent(w, r, name, info.ModTime(), f)
+}
+
+// serveContent serves f, falling back to copying it when the file cannot seek.
+func serveContent(w http.ResponseWriter, r *http.Request, name string, modTime time.Time, f fs.File) {
+	if seeker, ok := f.(io.ReadSeeker); ok {
+		http.ServeContent(w, r, name, modTime, seeker)
+		return
+	}
+
+	if info, err := f.Stat(); err == nil {
+		w.Header().Set("Content-Length", strconv.FormatInt(info.Size(), 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		io.Copy(w, f)
+	}
+}
+
+// open opens a regular file in fsys.
+func open(fsys fs.FS, name string) (fs.File, fs.FileInfo, bool) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, nil, false
+	}
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		f.Close()
+		return nil, nil, false
+	}
+	return f, info, true
+}
+
+// acceptedEncodings parses an Accept-Encoding header, ignoring codings with q=0.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.EqualFold(key, "q") {
+				if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		accepted[coding] = q > 0
+	}
+	return accepted
+}
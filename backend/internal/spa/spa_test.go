@@ -0,0 +1,120 @@
+package spa
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+)
+
+var app = fstest.MapFS{
+	"index.html":                  {Data: []byte("<html>app</html>")},
+	"favicon.svg":                 {Data: []byte("<svg/>")},
+	"assets/index-BkZ3a9xQ.js":    {Data: []byte("console.log(1)")},
+	"assets/index-BkZ3a9xQ.js.br": {Data: []byte("brotli")},
+	"assets/index-BkZ3a9xQ.js.gz": {Data: []byte("gzip")},
+	"assets/style-C0ffee12.css":   {Data: []byte("body{}")},
+	"docs/index.html":             {Data: []byte("<html>docs</html>")},
+}
+
+func serve(t *testing.T, method, target, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	rec := httptest.NewRecorder()
+	Handler(app, "/api/").ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRouting(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		wantCode int
+		wantBody string
+	}{
+		{"root", "/", http.StatusOK, "<html>app</html>"},
+		{"index", "/index.html", http.StatusOK, "<html>app</html>"},
+		{"client-side route", "/nodes/gpu-node-1", http.StatusOK, "<html>app</html>"},
+		{"static file", "/favicon.svg", http.StatusOK, "<svg/>"},
+		{"directory index", "/docs/", http.StatusOK, "<html>docs</html>"},
+		{"missing asset", "/assets/missing-AbCdEf12.js", http.StatusNotFound, ""},
+		{"unknown API path", "/api/v1/unknown", http.StatusNotFound, ""},
+		{"API prefix itself", "/api", http.StatusNotFound, ""},
+		{"traversal stays inside", "/../../etc/passwd", http.StatusOK, "<html>app</html>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(t, http.MethodGet, tt.target, "")
+			if rec.Code != tt.wantCode {
+				t.Fatalf("got status %d, want %d", rec.Code, tt.wantCode)
+			}
+			if tt.wantBody != "" && rec.Body.String() != tt.wantBody {
+				t.Errorf("got body %q, want %q", rec.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestCacheHeaders(t *testing.T) {
+	tests := map[string]string{
+		"/":                          revalidate,
+		"/nodes/gpu-node-1":          revalidate,
+		"/favicon.svg":               revalidate,
+		"/assets/index-BkZ3a9xQ.js":  immutableCache,
+		"/assets/style-C0ffee12.css": immutableCache,
+	}
+	for target, want := range tests {
+		if got := serve(t, http.MethodGet, target, "").Header().Get("Cache-Control"); got != want {
+			t.Errorf("%s: got Cache-Control %q, want %q", target, got, want)
+		}
+	}
+}
+
+func TestPrecompressed(t *testing.T) {
+	tests := []struct {
+		acceptEncoding string
+		wantEncoding   string
+		wantBody       string
+	}{
+		{"gzip, deflate, br", "br", "brotli"},
+		{"gzip", "gzip", "gzip"},
+		{"br;q=0, gzip;q=0.5", "gzip", "gzip"},
+		{"", "", "console.log(1)"},
+		{"identity", "", "console.log(1)"},
+	}
+
+	for _, tt := range tests {
+		rec := serve(t, http.MethodGet, "/assets/index-BkZ3a9xQ.js", tt.acceptEncoding)
+		if got := rec.Header().Get("Content-Encoding"); got != tt.wantEncoding {
+			t.Errorf("%q: got Content-Encoding %q, want %q", tt.acceptEncoding, got, tt.wantEncoding)
+		}
+		if rec.Body.String() != tt.wantBody {
+			t.Errorf("%q: got body %q, want %q", tt.acceptEncoding, rec.Body.String(), tt.wantBody)
+		}
+		if got := rec.Header().Get("Content-Type"); got != "text/javascript; charset=utf-8" {
+			t.Errorf("%q: expected the type of the original file, got %q", tt.acceptEncoding, got)
+		}
+		if rec.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("%q: expected Vary: Accept-Encoding", tt.acceptEncoding)
+		}
+	}
+
+	// Files without a compressed variant are served as is
+	rec := serve(t, http.MethodGet, "/assets/style-C0ffee12.css", "br, gzip")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "body{}" {
+		t.Errorf("unexpected response %q with encoding %q", rec.Body.String(), rec.Header().Get("Content-Encoding"))
+	}
+}
+
+func TestMethods(t *testing.T) {
+	if rec := serve(t, http.MethodHead, "/", ""); rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Errorf("expected empty 200 for HEAD, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if rec := serve(t, http.MethodPost, "/", ""); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected 405 for POST, got %d", rec.Code)
+	}
+}
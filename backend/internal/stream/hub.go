@@ -0,0 +1,177 @@
+package stream
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+// FetchFunc retrieves the current GPU metrics.
+type FetchFunc func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error)
+
+// Snapshot is the result of a single poll shared with every subscriber.
+type Snapshot struct {
+	Metrics  []models.GPUMetrics
+	Warnings []models.Warning
+	Err      error
+	Time     time.Time
+}
+
+// Hub runs one shared poller and fans its snapshots out to subscribers.
+// The poller only runs while at least one subscriber is connected.
+type Hub struct {
+	fetch        FetchFunc
+	interval     time.Duration
+	fetchTimeout time.Duration
+
+	mu     sync.Mutex
+	subs   map[chan Snapshot]struct{}
+	last   *Snapshot
+	cancel context.CancelFunc
+	closed bool
+}
+
+// DefaultInterval is the polling interval used when NewHub is given a non-positive one.
+const DefaultInterval = 10 * time.Second
+
+// NewHub creates a hub polling fetch every interval.
+func NewHub(fetch FetchFunc, interval time.Duration) *Hub {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	return &Hub{
+		fetch:        fetch,
+		interval:     interval,
+		fetchTimeout: 30 * time.Second,
+		subs:         make(map[chan Snapshot]struct{}),
+	}
+}
+
+// Subscribe registers a subscriber and returns its snapshot channel and an
+// unsubscribe function. The latest snapshot, if any, is delivered immediately.
+// The channel is closed when the hub is closed.
+func (h *Hub) Subscribe() (<-chan Snapshot, func()) {
+	ch := make(chan Snapshot, 1)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+
+	h.subs[ch] = struct{}{}
+	if h.last != nil {
+		ch <- *h.last
+	}
+	if h.cancel == nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		h.cancel = cancel
+		go h.poll(ctx)
+	}
+
+	return ch, func() { h.unsubscribe(ch) }
+}
+
+// Subscribers returns the number of connected subscribers.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs)
+}
+
+// Close stops the poller and closes every subscriber channel.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.closed {
+		return
+	}
+	h.closed = true
+
+	if h.cancel != nil {
+		h.cancel()
+		h.cancel = nil
+	}
+	for ch := range h.subs {
+		delete(h.subs, ch)
+		close(ch)
+	}
+}
+
+// unsubscribe removes a subscriber, stopping the poller when none remain.
+func (h *Hub) unsubscribe(ch chan Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subs[ch]; !ok {
+		return
+	}
+	delete(h.subs, ch)
+	close(ch)
+
+	if len(h.subs) == 0 && h.cancel != nil {
+		h.cancel()
+		h.cancel = nil
+		// Drop the cached snapshot so the next subscriber does not see stale data
+		h.last = nil
+	}
+}
+
+// poll fetches metrics immediately and then every interval until ctx is canceled.
+func (h *Hub) poll(ctx context.Context) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		h.pollOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollOnce fetches one snapshot and broadcasts it.
+func (h *Hub) pollOnce(ctx context.Context) {
+	fetchCtx, cancel := context.WithTimeout(ctx, h.fetchTimeout)
+	metrics, warnings, err := h.fetch(fetchCtx)
+	cancel()
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	snapshot := Snapshot{
+		Metrics:  metrics,
+		Warnings: warnings,
+		Err:      err,
+		Time:     time.Now(),
+	}
+	h.broadcast(snapshot)
+}
+
+// broadcast delivers a snapshot to every subscriber. Slow subscribers skip
+// intermediate snapshots rather than blocking the poller.
+func (h *Hub) broadcast(snapshot Snapshot) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if snapshot.Err == nil {
+		h.last = &snapshot
+	}
+
+	for ch := range h.subs {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- snapshot
+	}
+}
@@ -0,0 +1,83 @@
+package stream
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"k8s-gpu-monitoring/internal/models"
+)
+
+func TestHubSharesOnePoller(t *testing.T) {
+	var calls atomic.Int32
+	hub := NewHub(func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+		calls.Add(1)
+		return []models.GPUMetrics{{NodeName: "node1"}}, nil, nil
+	}, time.Hour)
+	defer hub.Close()
+
+	first, unsubscribeFirst := hub.Subscribe()
+	second, unsubscribeSecond := hub.Subscribe()
+	defer unsubscribeSecond()
+
+	for _, ch := range []<-chan Snapshot{first, second} {
+		select {
+		case snapshot := <-ch:
+			if len(snapshot.Metrics) != 1 {
+				t.Errorf("unexpected snapshot: %+v", snapshot)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for snapshot")
+		}
+	}
+
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected a single poll for both subscribers, got %d", got)
+	}
+
+	unsubscribeFirst()
+	if got := hub.Subscribers(); got != 1 {
+		t.Errorf("expected 1 subscriber, got %d", got)
+	}
+}
+
+func TestHubStopsPollingWithoutSubscribers(t *testing.T) {
+	var calls atomic.Int32
+	hub := NewHub(func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+		calls.Add(1)
+		return nil, nil, nil
+	}, 5*time.Millisecond)
+	defer hub.Close()
+
+	ch, unsubscribe := hub.Subscribe()
+	<-ch
+	<-ch
+	unsubscribe()
+
+	// Allow an in-flight poll to finish before sampling
+	time.Sleep(20 * time.Millisecond)
+	stopped := calls.Load()
+	time.Sleep(50 * time.Millisecond)
+	if got := calls.Load(); got != stopped {
+		t.Errorf("expected polling to stop, calls went from %d to %d", stopped, got)
+	}
+}
+
+func TestHubCloseClosesSubscribers(t *testing.T) {
+	hub := NewHub(func(ctx context.Context) ([]models.GPUMetrics, []models.Warning, error) {
+		return nil, nil, nil
+	}, time.Hour)
+
+	ch, _ := hub.Subscribe()
+	hub.Close()
+
+	// Drain the initial snapshot, if it arrived before Close
+	for range ch {
+	}
+
+	late, _ := hub.Subscribe()
+	if _, ok := <-late; ok {
+		t.Error("expected subscription after Close to be closed")
+	}
+}
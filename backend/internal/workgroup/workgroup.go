@@ -0,0 +1,66 @@
+// Package workgroup runs related tasks concurrently with bounded parallelism,
+// canceling the remaining tasks once one fails, in the manner of errgroup.
+package workgroup
+
+import (
+	"context"
+	"sync"
+)
+
+// Group is a collection of tasks working on subtasks of the same overall job.
+// A Group must be created with WithContext and must not be copied.
+type Group struct {
+	cancel context.CancelCauseFunc
+	wg     sync.WaitGroup
+	sem    chan struct{}
+
+	errOnce sync.Once
+	err     error
+}
+
+// WithContext returns a Group and a context derived from ctx that is canceled
+// when a task returns an error or Wait returns, whichever comes first.
+func WithContext(ctx context.Context) (*Group, context.Context) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return &Group{cancel: cancel}, ctx
+}
+
+// SetLimit limits the number of tasks running at once to n; n <= 0 removes the limit.
+// It must not be called while tasks are running.
+func (g *Group) SetLimit(n int) {
+	if n <= 0 {
+		g.sem = nil
+		return
+	}
+	g.sem = make(chan struct{}, n)
+}
+
+// Go runs f in a new goroutine, blocking until the limit allows another task to start.
+// The first error returned by a task cancels the group's context.
+func (g *Group) Go(f func() error) {
+	if g.sem != nil {
+		g.sem <- struct{}{}
+	}
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		if g.sem != nil {
+			defer func() { <-g.sem }()
+		}
+
+		if err := f(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel(err)
+			})
+		}
+	}()
+}
+
+// Wait blocks until every task has returned, then returns the first error, if any.
+func (g *Group) Wait() error {
+	g.wg.Wait()
+	g.cancel(g.err)
+	return g.err
+}
@@ -0,0 +1,86 @@
+package workgroup
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLimit(t *testing.T) {
+	g, _ := WithContext(context.Background())
+	g.SetLimit(3)
+
+	var running, peak, done atomic.Int32
+	for i := 0; i < 20; i++ {
+		g.Go(func() error {
+			n := running.Add(1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			running.Add(-1)
+			done.Add(1)
+			return nil
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if done.Load() != 20 {
+		t.Errorf("expected every task to run, got %d", done.Load())
+	}
+	if peak.Load() > 3 {
+		t.Errorf("expected at most 3 concurrent tasks, got %d", peak.Load())
+	}
+}
+
+func TestFirstErrorCancelsSiblings(t *testing.T) {
+	g, ctx := WithContext(context.Background())
+	fatal := errors.New("fatal")
+
+	var canceled atomic.Int32
+	for i := 0; i < 5; i++ {
+		g.Go(func() error {
+			select {
+			case <-ctx.Done():
+				canceled.Add(1)
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		})
+	}
+	g.Go(func() error { return fatal })
+
+	start := time.Now()
+	if err := g.Wait(); !errors.Is(err, fatal) {
+		t.Fatalf("expected the first error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Error("expected siblings to stop once the group was canceled")
+	}
+	if canceled.Load() != 5 {
+		t.Errorf("expected 5 canceled siblings, got %d", canceled.Load())
+	}
+	if cause := context.Cause(ctx); !errors.Is(cause, fatal) {
+		t.Errorf("expected cancellation cause to be the error, got %v", cause)
+	}
+}
+
+func TestWaitCancelsContext(t *testing.T) {
+	g, ctx := WithContext(context.Background())
+	g.Go(func() error { return nil })
+
+	if err := g.Wait(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected Wait to release the group's context")
+	}
+}
@@ -0,0 +1,22 @@
+//go:build embedfrontend
+
+// Package web holds the frontend build compiled into the binary. Build with
+// -tags embedfrontend after copying frontend/dist to web/dist to embed it.
+package web
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:embed all:dist
+var dist embed.FS
+
+// Frontend returns the embedded frontend build, or false when the binary was built without it.
+func Frontend() (fs.FS, bool) {
+	build, err := fs.Sub(dist, "dist")
+	if err != nil {
+		return nil, false
+	}
+	return build, true
+}
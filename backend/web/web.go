@@ -0,0 +1,12 @@
+//go:build !embedfrontend
+
+// Package web holds the frontend build compiled into the binary. Build with
+// -tags embedfrontend after copying frontend/dist to web/dist to embed it.
+package web
+
+import "io/fs"
+
+// Frontend returns the embedded frontend build, or false when the binary was built without it.
+func Frontend() (fs.FS, bool) {
+	return nil, false
+}
@@ -2,7 +2,8 @@ import { useState } from 'react';
 import { useQuery, useQueryClient } from '@tanstack/react-query';
 import { RefreshCw, AlertCircle, Activity } from 'lucide-react';
 
-import { gpuApi, queryKeys } from '@/api/client';
+import { AuthRequiredError, gpuApi, queryKeys } from '@/api/client';
+import { CredentialForm } from '@/components/CredentialForm';
 import { GPUTable } from '@/components/GPUTable';
 import { Button } from '@/components/ui/button';
 import { Card, CardContent, CardHeader, CardTitle } from '@/components/ui/card';
@@ -22,10 +23,13 @@ function App() {
     queryKey: queryKeys.gpuMetrics,
     queryFn: gpuApi.getGPUMetrics,
     refetchInterval: autoRefresh ? 30000 : false, // Auto-refresh every 30 seconds
-    retry: 3,
+    retry: (failureCount, error) => !(error instanceof AuthRequiredError) && failureCount < 3,
     retryDelay: 1000,
   });
 
+  // Authentication is enabled on the backend and no valid credential is stored
+  const authRequired = error instanceof AuthRequiredError;
+
   // Health check
   const { data: healthData } = useQuery({
     queryKey: queryKeys.health,
@@ -164,12 +168,19 @@ function App() {
           </div>
         )}
 
-        {/* GPU data table */}
-        <GPUTable
-          data={gpuData?.data || []}
-          isLoading={isLoading}
-          error={error}
-        />
+        {/* Credential prompt or GPU data table */}
+        {authRequired ? (
+          <CredentialForm
+            message={error?.message}
+            onSaved={() => queryClient.invalidateQueries()}
+          />
+        ) : (
+          <GPUTable
+            data={gpuData?.data || []}
+            isLoading={isLoading}
+            error={error}
+          />
+        )}
       </div>
     </div>
   );
@@ -13,6 +13,58 @@ const apiClient = axios.create({
   },
 });
 
+// 認証情報（APIキーまたはOIDCのベアラートークン）の保存先
+const CREDENTIAL_STORAGE_KEY = 'gpu-monitoring-credential';
+
+export type CredentialType = 'api_key' | 'bearer';
+
+export interface Credential {
+  type: CredentialType;
+  value: string;
+}
+
+// バックエンドの認証が有効で、認証情報がないか拒否された場合のエラー
+export class AuthRequiredError extends Error {
+  constructor(message = 'Authentication required') {
+    super(message);
+    this.name = 'AuthRequiredError';
+  }
+}
+
+export const credentials = {
+  get(): Credential | null {
+    const stored = localStorage.getItem(CREDENTIAL_STORAGE_KEY);
+    if (!stored) {
+      return null;
+    }
+    try {
+      const credential = JSON.parse(stored) as Credential;
+      return credential.value ? credential : null;
+    } catch {
+      return null;
+    }
+  },
+
+  set(credential: Credential) {
+    localStorage.setItem(CREDENTIAL_STORAGE_KEY, JSON.stringify(credential));
+  },
+
+  clear() {
+    localStorage.removeItem(CREDENTIAL_STORAGE_KEY);
+  },
+};
+
+// リクエストインターセプター（保存済みの認証情報を付与）
+apiClient.interceptors.request.use((config) => {
+  const credential = credentials.get();
+  if (credential?.type === 'api_key') {
+    config.headers.set('X-API-Key', credential.value);
+  } else if (credential?.type === 'bearer') {
+    config.headers.set('Authorization', `Bearer ${credential.value}`);
+  }
+  return config;
+});
+
 // レスポンスインターセプター（エラーハンドリング）
 apiClient.interceptors.response.use(
   (response) => response,
@@ -29,6 +81,15 @@ apiClient.interceptors.response.use(
     
     const status = error.response.status;
     switch (status) {
+      case 401:
+        // 拒否された認証情報は破棄して再入力を促す
+        if (credentials.get()) {
+          credentials.clear();
+          throw new AuthRequiredError('Invalid credentials');
+        }
+        throw new AuthRequiredError();
+      case 403:
+        throw new Error('Access denied');
       case 404:
         throw new Error('API endpoint not found');
       case 500:
@@ -0,0 +1,78 @@
+import { FormEvent, useState } from 'react';
+import { KeyRound } from 'lucide-react';
+
+import { credentials, type CredentialType } from '@/api/client';
+import { Button } from '@/components/ui/button';
+import { Card, CardContent, CardHeader, CardTitle } from '@/components/ui/card';
+
+interface CredentialFormProps {
+  message?: string;
+  onSaved: () => void;
+}
+
+// バックエンドの認証が有効な場合に、APIキーまたはベアラートークンを入力するフォーム
+export function CredentialForm({ message, onSaved }: CredentialFormProps) {
+  const [type, setType] = useState<CredentialType>('api_key');
+  const [value, setValue] = useState('');
+
+  const handleSubmit = (event: FormEvent<HTMLFormElement>) => {
+    event.preventDefault();
+    const trimmed = value.trim();
+    if (!trimmed) {
+      return;
+    }
+    credentials.set({ type, value: trimmed });
+    setValue('');
+    onSaved();
+  };
+
+  return (
+    <Card className="max-w-lg">
+      <CardHeader className="flex flex-row items-center space-x-2 space-y-0">
+        <KeyRound className="h-4 w-4 text-muted-foreground" />
+        <CardTitle className="text-base">認証が必要です</CardTitle>
+      </CardHeader>
+      <CardContent>
+        <form onSubmit={handleSubmit} className="space-y-4">
+          {message === 'Invalid credentials' && (
+            <p className="text-sm text-red-500">認証情報が拒否されました。再入力してください</p>
+          )}
+          <div className="flex space-x-4 text-sm">
+            <label className="flex items-center space-x-2">
+              <input
+                type="radio"
+                name="credential-type"
+                checked={type === 'api_key'}
+                onChange={() => setType('api_key')}
+              />
+              <span>APIキー</span>
+            </label>
+            <label className="flex items-center space-x-2">
+              <input
+                type="radio"
+                name="credential-type"
+                checked={type === 'bearer'}
+                onChange={() => setType('bearer')}
+              />
+              <span>OIDCトークン</span>
+            </label>
+          </div>
+          <input
+            type="password"
+            autoComplete="off"
+            aria-label={type === 'api_key' ? 'APIキー' : 'OIDCトークン'}
+            className="w-full rounded-md border bg-background px-3 py-2 text-sm"
+            value={value}
+            onChange={(event) => setValue(event.target.value)}
+          />
+          <p className="text-xs text-muted-foreground">
+            入力した認証情報はこのブラウザーのlocalStorageに保存され、APIリクエストに付与されます
+          </p>
+          <Button type="submit" size="sm" disabled={!value.trim()}>
+            保存
+          </Button>
+        </form>
+      </CardContent>
+    </Card>
+  );
+}